@@ -0,0 +1,101 @@
+package machineid
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var (
+	// appIDMu guards appIDRegistry.
+	appIDMu sync.Mutex
+	// appIDRegistry maps an appID skeleton to the distinct appIDs seen for it,
+	// each with the call site that first used it. It is allocated lazily.
+	appIDRegistry map[string]map[string]string
+)
+
+// confusables maps characters that are easily mistaken for one another to a common form.
+var confusables = strings.NewReplacer(
+	"0", "o",
+	"1", "l",
+	"i", "l",
+	"|", "l",
+	"5", "s",
+	"rn", "m",
+	"vv", "w",
+)
+
+// appIDSkeleton reduces an appID to a form where visually similar IDs compare equal:
+// case, separators and punctuation are dropped, and confusable characters are unified.
+// E.g., "My-App", "my_app" and "myapp" share a skeleton, and so do "app1" and "appl".
+func appIDSkeleton(appID string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(appID) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '|' {
+			b.WriteRune(r)
+		}
+	}
+	return confusables.Replace(b.String())
+}
+
+// trackAppID records appID and warns (via SetLogger) when a different but visually
+// similar appID was used elsewhere in the process. site identifies the caller.
+func trackAppID(appID, site string) {
+	skeleton := appIDSkeleton(appID)
+
+	appIDMu.Lock()
+	if appIDRegistry == nil {
+		appIDRegistry = make(map[string]map[string]string)
+	}
+	seen := appIDRegistry[skeleton]
+	if seen == nil {
+		seen = make(map[string]string)
+		appIDRegistry[skeleton] = seen
+	}
+	if _, ok := seen[appID]; ok {
+		appIDMu.Unlock()
+		return
+	}
+	seen[appID] = site
+
+	var similar []string
+	for other, otherSite := range seen {
+		if other != appID {
+			similar = append(similar, fmt.Sprintf("%q (%s)", other, otherSite))
+		}
+	}
+	appIDMu.Unlock()
+
+	if len(similar) > 0 {
+		sort.Strings(similar)
+		logWarn("machineid: appID is visually similar to another appID used in this process; this may be an accidental namespace collision",
+			"appID", appID, "site", site, "similar", strings.Join(similar, ", "))
+	}
+}
+
+// RegisteredAppIDs returns every distinct appID passed to ProtectedID so far, sorted.
+func RegisteredAppIDs() []string {
+	appIDMu.Lock()
+	defer appIDMu.Unlock()
+
+	var ids []string
+	for _, seen := range appIDRegistry {
+		for appID := range seen {
+			ids = append(ids, appID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// callerSite returns "file:line" of the caller skip frames above callerSite's caller.
+func callerSite(skip int) string {
+	_, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
@@ -0,0 +1,85 @@
+// Package beacon announces a truncated hash of the machine ID as a DNS query, so fleet
+// owners can detect duplicate IDs network-wide (e.g., cloned VM images) from the query logs
+// of a DNS zone they control, without standing up an HTTP service.
+//
+// Nothing is sent unless Send is called with a domain. The announced label is derived with
+// machineid.ProtectedID under a beacon-specific app ID, so it can't be correlated with the
+// IDs applications report, and it is truncated to DefaultLength characters (40 bits): enough
+// to spot duplicates in a fleet, too short to identify a machine on its own.
+package beacon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/banditmoscow1337/machineid"
+)
+
+// DefaultAppID is the ProtectedID app ID used unless Config.AppID is set.
+const DefaultAppID = "machineid-beacon"
+
+// DefaultLength is the number of Crockford base32 characters (5 bits each) kept in the label.
+const DefaultLength = 8
+
+// Config configures the beacon. Domain is required.
+type Config struct {
+	// Domain is the zone the query is sent to (e.g., "beacon.example.com"). Its authoritative
+	// name servers see the queries, through the machine's resolvers.
+	Domain string
+	// AppID scopes the announced hash. Fleets sharing a zone can use distinct app IDs.
+	AppID string
+	// Length is the number of characters kept in the label (values <= 0 select DefaultLength).
+	// Longer labels lower the rate of false duplicates, but identify machines more precisely.
+	Length int
+	// Resolver sends the query. If nil, net.DefaultResolver is used.
+	Resolver *net.Resolver
+}
+
+// protectedID derives the announced hash. It is a variable so tests can use a fixed ID.
+var protectedID = machineid.ProtectedID
+
+// Name returns the DNS name Send queries: "<label>.<domain>".
+func Name(cfg Config) (string, error) {
+	domain := strings.Trim(strings.TrimSpace(cfg.Domain), ".")
+	if domain == "" {
+		return "", errors.New("beacon: no domain configured")
+	}
+	appID := cfg.AppID
+	if appID == "" {
+		appID = DefaultAppID
+	}
+	length := cfg.Length
+	if length <= 0 {
+		length = DefaultLength
+	}
+
+	label, err := protectedID(appID, machineid.WithoutPrefix(), machineid.WithEncoding(machineid.Crockford), machineid.WithLength(length))
+	if err != nil {
+		return "", fmt.Errorf("beacon: %w", err)
+	}
+	return strings.ToLower(label) + "." + domain, nil
+}
+
+// Send queries the beacon name once. Run it at startup, in a goroutine if the resolvers may
+// be slow; the context bounds the query. The zone is not expected to answer: a "no such
+// host" response means the query reached the resolvers and is reported as success.
+func Send(ctx context.Context, cfg Config) error {
+	name, err := Name(cfg)
+	if err != nil {
+		return err
+	}
+	r := cfg.Resolver
+	if r == nil {
+		r = net.DefaultResolver
+	}
+
+	_, err = r.LookupTXT(ctx, name)
+	var dnsErr *net.DNSError
+	if err == nil || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		return nil
+	}
+	return fmt.Errorf("beacon: %w", err)
+}
@@ -0,0 +1,79 @@
+package beacon
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/banditmoscow1337/machineid"
+)
+
+func fixedID(t *testing.T) {
+	t.Helper()
+	protectedID = func(appID string, opts ...machineid.Option) (string, error) {
+		return "ABABABAB", nil
+	}
+	t.Cleanup(func() { protectedID = machineid.ProtectedID })
+}
+
+func TestName(t *testing.T) {
+	fixedID(t)
+
+	if _, err := Name(Config{}); err == nil {
+		t.Error("expected an error without a domain")
+	}
+	name, err := Name(Config{Domain: "beacon.example.com."})
+	if err != nil || name != "abababab.beacon.example.com" {
+		t.Errorf("unexpected name %q, %v", name, err)
+	}
+}
+
+// TestSend runs a DNS server answering NXDOMAIN and checks the queried name.
+func TestSend(t *testing.T) {
+	fixedID(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	queried := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil || n < 13 {
+			return
+		}
+		// Decode the QNAME labels following the 12-byte header.
+		var labels []string
+		for i := 12; i < n && buf[i] != 0; i += int(buf[i]) + 1 {
+			labels = append(labels, string(buf[i+1:i+1+int(buf[i])]))
+		}
+		queried <- strings.Join(labels, ".")
+
+		// Echo the query as a response with RCODE 3 (NXDOMAIN).
+		buf[2] |= 0x80
+		buf[3] = buf[3]&0xf0 | 3
+		conn.WriteTo(buf[:n], addr)
+	}()
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", conn.LocalAddr().String())
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := Send(ctx, Config{Domain: "beacon.example.com", Resolver: resolver}); err != nil {
+		t.Fatalf("expected NXDOMAIN to count as sent, got %v", err)
+	}
+	if got := <-queried; got != "abababab.beacon.example.com" {
+		t.Errorf("unexpected query %q", got)
+	}
+}
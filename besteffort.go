@@ -0,0 +1,177 @@
+package machineid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Degradation ranks how far the ID resolution fell back, from the platform source
+// (DegradationNone) to a random per-process identifier (DegradationRandom).
+type Degradation int
+
+const (
+	// DegradationNone means the ID comes from the platform source or a source named in
+	// SetSourcePriority.
+	DegradationNone Degradation = iota
+	// DegradationFallback means the ID comes from the MAC addresses or gethostid(2).
+	DegradationFallback
+	// DegradationPersisted means no source was usable and the ID is a random value kept in
+	// the store given to WithBestEffortStore. It is stable until the store is lost.
+	DegradationPersisted
+	// DegradationRandom means no source and no store were usable: the ID is random and only
+	// stable for the lifetime of the process.
+	DegradationRandom
+)
+
+// String returns the name of the level (e.g., "fallback").
+func (d Degradation) String() string {
+	switch d {
+	case DegradationNone:
+		return "none"
+	case DegradationFallback:
+		return "fallback"
+	case DegradationPersisted:
+		return "persisted"
+	case DegradationRandom:
+		return "random"
+	default:
+		return "unknown"
+	}
+}
+
+// Sources reported in MachineInfo.Source by BestEffortInfo when no real source was usable.
+const (
+	SourcePersisted = "persisted"
+	SourceRandom    = "random"
+)
+
+var (
+	// processIDOnce guards processID, the random raw ID of DegradationRandom.
+	processIDOnce sync.Once
+	processID     string
+
+	// noNetworkWarning limits the expected ErrNoNetwork degradation to one warning.
+	noNetworkWarning sync.Once
+)
+
+// WithBestEffortStore persists the random ID BestEffortID falls back to when no source is
+// usable, so the degraded ID survives restarts. Without a store the fallback is per process.
+func WithBestEffortStore(store Store) Option {
+	return func(o *options) {
+		o.bestEffortStore = store
+	}
+}
+
+// BestEffortID returns ID(opts...) if it succeeds and never fails: if the ID can't be resolved,
+// it falls back to a random ID, persisted with WithBestEffortStore if given. Every degradation is
+// logged (see SetLogger), and BestEffortInfo reports the level, so telemetry callers don't have
+// to swallow errors and hide real problems.
+//
+// Degraded IDs are never cached: ID() keeps failing, and the next call retries the real sources.
+func BestEffortID(opts ...Option) string {
+	snap := bestEffortSnapshot(newOptions(opts))
+	id, err := formatID(snap.prefix, domainID, []string{snap.rawID}, opts)
+	if err != nil {
+		// Unreachable: the raw ID is never empty.
+		return ""
+	}
+	return id
+}
+
+// BestEffortInfo is the Info counterpart of BestEffortID. MachineInfo.Degradation reports the
+// level of the ID. A failing asset tag provider leaves MachineInfo.AssetTag empty.
+func BestEffortInfo(ctx context.Context, opts ...Option) *MachineInfo {
+	o := newOptions(opts)
+	snap := bestEffortSnapshot(o)
+
+	info, err := newInfo(ctx, snap, o, opts)
+	if err != nil {
+		logWarn("machineid: asset tag provider failed", "error", err)
+		o.assetTagProvider = nil
+		info, _ = newInfo(ctx, snap, o, opts)
+	}
+	return info
+}
+
+// bestEffortSnapshot returns the resolution ID() derives the ID from, or a degraded snapshot
+// if it can't be resolved or is rejected by the options.
+func bestEffortSnapshot(o options) snapshot {
+	snap, err := o.resolveSnapshot(options.load)
+	if err == nil {
+		return snap
+	}
+
+	prefix, _ := cachedSourceValue(SourceEnvironment, func() (string, error) {
+		return getEnvTypeFunc(), nil
+	})
+
+	if o.bestEffortStore != nil {
+		id, storeErr := persistedRandomID(o.bestEffortStore)
+		if storeErr == nil {
+			warnDegraded("machineid: ID unavailable, using the persisted best-effort ID", err)
+			return snapshot{rawID: id, prefix: prefix, source: SourcePersisted}
+		}
+		err = errors.Join(err, storeErr)
+	}
+
+	warnDegraded("machineid: ID unavailable, using a random per-process ID", err)
+	processIDOnce.Do(func() {
+		processID = randomRawID()
+	})
+	return snapshot{rawID: processID, prefix: prefix, source: SourceRandom}
+}
+
+// warnDegraded logs a degradation. Scratch containers (ErrNoNetwork) degrade on every call by
+// design, so that case is only logged once.
+func warnDegraded(msg string, err error) {
+	if errors.Is(err, ErrNoNetwork) {
+		noNetworkWarning.Do(func() { logWarn(msg, "error", err) })
+		return
+	}
+	logWarn(msg, "error", err)
+}
+
+// persistedRandomID loads the random raw ID from store, creating and saving it on first use.
+func persistedRandomID(store Store) (string, error) {
+	data, err := store.Load()
+	if err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	id := randomRawID()
+	if err := store.Save([]byte(id)); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// randomRawID returns 128 random bits (hex). The "random:" namespace keeps it from ever
+// colliding with a real raw ID.
+func randomRawID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "random:" + hex.EncodeToString(b)
+}
+
+// degradationOf returns the level of an ID resolved from source.
+func degradationOf(source string) Degradation {
+	switch source {
+	case ComponentMAC, SourceHostID:
+		return DegradationFallback
+	case SourcePersisted:
+		return DegradationPersisted
+	case SourceRandom:
+		return DegradationRandom
+	default:
+		return DegradationNone
+	}
+}
@@ -0,0 +1,19 @@
+package machineid
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// getBootID returns the UUID the kernel generates at every boot (kern.bootsessionuuid).
+func getBootID() (string, error) {
+	id, err := unix.Sysctl("kern.bootsessionuuid")
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		return "", os.ErrNotExist
+	}
+	return id, nil
+}
@@ -0,0 +1,19 @@
+package machineid
+
+import (
+	"os"
+	"strings"
+)
+
+// getBootID returns the random UUID the kernel generates at every boot.
+func getBootID() (string, error) {
+	b, err := osReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(string(b))
+	if id == "" {
+		return "", os.ErrNotExist
+	}
+	return id, nil
+}
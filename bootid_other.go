@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package machineid
+
+import "os"
+
+// getBootID reports that there is no boot ID on this platform.
+func getBootID() (string, error) {
+	return "", os.ErrNotExist
+}
@@ -0,0 +1,34 @@
+package machineid
+
+import (
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// getBootID identifies the boot session: Windows has no per-boot UUID, but increments the BootId
+// value of the prefetcher parameters at every boot. The counter is only unique per machine, so
+// it is qualified with the MachineGuid.
+func getBootID() (string, error) {
+	const key = `SYSTEM\CurrentControlSet\Control\Session Manager\Memory Management\PrefetchParameters`
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, key, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	logDebug("machineid: registry probe", "key", `HKLM\`+key, "error", err)
+	if err != nil {
+		return "", err
+	}
+	defer k.Close()
+
+	id, _, err := k.GetIntegerValue("BootId")
+	if err != nil {
+		return "", err
+	}
+	if id == 0 {
+		return "", os.ErrNotExist
+	}
+	guid, err := getRegistryID()
+	if err != nil {
+		return "", err
+	}
+	return guid + ":" + strconv.FormatUint(id, 10), nil
+}
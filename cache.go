@@ -0,0 +1,177 @@
+package machineid
+
+import (
+	"sync"
+	"time"
+)
+
+// SourceEnvironment names the environment detection in Refresh.
+const SourceEnvironment = "environment"
+
+// sourceValidity bounds how long a cached source value is served. Sources not listed
+// stay valid until Refresh. The MAC set changes when NICs are added or swapped.
+var sourceValidity = map[string]time.Duration{
+	ComponentMAC: time.Minute,
+}
+
+// sourceEntry is a cached source value.
+type sourceEntry struct {
+	value   string
+	expires time.Time // zero means "until Refresh"
+}
+
+// sourceCache caches each source independently, so refreshing one of them (e.g., the NIC set)
+// doesn't re-run expensive ones (exec of wmic or ioreg). Errors are never cached, so a
+// failing source is retried on the next call.
+var sourceCache = struct {
+	sync.Mutex
+	entries map[string]sourceEntry
+}{entries: map[string]sourceEntry{}}
+
+// cachedSourceValue returns the cached value of the named source, or calls get and caches
+// a successful result. Sources turned off with DisableSource fail with ErrSourceDisabled.
+func cachedSourceValue(name string, get func() (string, error)) (string, error) {
+	if !sourceEnabled(name) {
+		return "", disabledError(name)
+	}
+	now := time.Now()
+
+	sourceCache.Lock()
+	e, ok := sourceCache.entries[name]
+	sourceCache.Unlock()
+	if ok && (e.expires.IsZero() || now.Before(e.expires)) {
+		return e.value, nil
+	}
+
+	value, err := get()
+	if err != nil {
+		return value, err
+	}
+
+	e = sourceEntry{value: value}
+	if ttl := sourceValidity[name]; ttl > 0 {
+		e.expires = now.Add(ttl)
+	}
+	sourceCache.Lock()
+	sourceCache.entries[name] = e
+	sourceCache.Unlock()
+	return value, nil
+}
+
+// uncachedSourceValue is the cachedSourceValue counterpart that always calls get, and neither
+// reads nor updates the cache.
+func uncachedSourceValue(name string, get func() (string, error)) (string, error) {
+	if !sourceEnabled(name) {
+		return "", disabledError(name)
+	}
+	return get()
+}
+
+// sourceCacheState reports whether a value of the named source is cached and, for sources
+// with a bounded validity, when it expires.
+func sourceCacheState(name string) (cached bool, expires time.Time) {
+	sourceCache.Lock()
+	defer sourceCache.Unlock()
+	e, ok := sourceCache.entries[name]
+	if !ok || (!e.expires.IsZero() && time.Now().After(e.expires)) {
+		return false, time.Time{}
+	}
+	return true, e.expires
+}
+
+// invalidateSources drops the cached values of the named sources (all of them if none are given).
+func invalidateSources(names ...string) {
+	sourceCache.Lock()
+	defer sourceCache.Unlock()
+
+	if len(names) == 0 {
+		clear(sourceCache.entries)
+		return
+	}
+	for _, name := range names {
+		delete(sourceCache.entries, name)
+	}
+}
+
+// Refresh discards the cached values of the named sources (SourceEnvironment, ComponentMachineID,
+// ComponentMAC, or any fingerprint component), or of every source if none are given.
+// The next call re-runs only those sources; the others are served from the cache.
+// If a source contributing to the ID is refreshed, the ID is re-resolved on the next call.
+func Refresh(sources ...string) {
+	invalidateSources(sources...)
+
+	primary := len(sources) == 0
+	for _, name := range sources {
+		switch name {
+		case SourceEnvironment, ComponentMachineID, ComponentMAC:
+			primary = true
+		}
+	}
+	if primary {
+		mu.Lock()
+		initialized = false
+		mu.Unlock()
+	}
+}
+
+// WithCacheTTL re-resolves the ID when the cached resolution is older than d, so long-running
+// daemons notice a regenerated machine-id or a cloned VM instead of trusting the value resolved
+// at startup forever. The sources contributing to the ID are re-run and the cache is updated
+// for every caller. Values <= 0 keep the cache until Refresh (the default).
+func WithCacheTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.cacheTTL = d
+	}
+}
+
+// WithNoCache re-resolves the ID on every call, re-running the sources contributing to it.
+// The cache is still updated, so calls without the option get the latest resolution.
+func WithNoCache() Option {
+	return func(o *options) {
+		o.noCache = true
+	}
+}
+
+// load is the package-level load honoring WithCacheTTL and WithNoCache, with the fallback
+// options applied (see applyFallbackPolicy).
+func (o options) load() (snapshot, error) {
+	if o.noCache || (o.cacheTTL > 0 && cacheAge() >= o.cacheTTL) {
+		expireResolution()
+	}
+	return o.applyPolicy(load())
+}
+
+// applyPolicy applies the fallback policy and the container scope to a package-level
+// resolution.
+func (o options) applyPolicy(snap snapshot, err error) (snapshot, error) {
+	snap, err = o.applyFallbackPolicy(platformFallbackSources(), snap, err)
+	if err != nil {
+		return snapshot{}, err
+	}
+	// The scope applies last: the fallback policy may replace the raw ID.
+	return scopeToContainer(snap), nil
+}
+
+// cacheAge returns how long ago the cache was resolved, or 0 if it isn't resolved.
+func cacheAge() time.Duration {
+	mu.Lock()
+	defer mu.Unlock()
+	if !initialized {
+		return 0
+	}
+	return time.Since(cachedAt)
+}
+
+// expireResolution drops the cached resolution and the cached values of the sources it may
+// use, so the next load re-runs them.
+func expireResolution() {
+	names := []string{SourceEnvironment, ComponentMachineID, ComponentMAC}
+	if chain := sourcePriority.Load(); chain != nil {
+		names = append(names, *chain...)
+	}
+	invalidateSources(names...)
+
+	mu.Lock()
+	initialized = false
+	mu.Unlock()
+}
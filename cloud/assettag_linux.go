@@ -0,0 +1,20 @@
+//go:build linux
+
+package cloud
+
+import (
+	"os"
+	"strings"
+)
+
+// chassisAssetTagPath is where the kernel exposes the chassis asset tag.
+var chassisAssetTagPath = "/sys/class/dmi/id/chassis_asset_tag"
+
+// chassisAssetTag returns the chassis asset tag, or "" if it can't be read.
+func chassisAssetTag() string {
+	b, err := os.ReadFile(chassisAssetTagPath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package cloud
+
+// chassisAssetTag returns "": the chassis asset tag is only read on Linux. Azure and Oracle
+// Cloud instances are recognized with Config.Metadata on other platforms.
+func chassisAssetTag() string {
+	return ""
+}
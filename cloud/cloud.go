@@ -0,0 +1,112 @@
+// Package cloud identifies the cloud provider the machine runs on (AWS, GCP, Azure,
+// DigitalOcean, Oracle Cloud, Hetzner), e.g. to apply different licensing policies to
+// cloud and on-premises installations.
+//
+// Detection reads the DMI strings, which works offline and without privileges on most
+// platforms. Querying the providers' metadata endpoints is opt-in (Config.Metadata): it
+// confirms providers whose DMI strings are ambiguous (e.g., Azure VMs look like Hyper-V
+// guests when the chassis asset tag can't be read).
+package cloud
+
+import (
+	"context"
+	"strings"
+
+	"github.com/banditmoscow1337/machineid"
+	"github.com/banditmoscow1337/machineid/internal/bridge"
+	"github.com/banditmoscow1337/machineid/internal/imds"
+)
+
+// Provider names a cloud provider. The values are used in tags and are stable.
+type Provider string
+
+const (
+	None         Provider = ""
+	AWS          Provider = "aws"
+	GCP          Provider = "gcp"
+	Azure        Provider = "azure"
+	DigitalOcean Provider = "digitalocean"
+	Oracle       Provider = "oracle"
+	Hetzner      Provider = "hetzner"
+)
+
+// Config controls detection.
+type Config struct {
+	// Metadata queries the providers' metadata endpoints when the DMI strings don't name a
+	// provider. The context passed to Detect bounds the queries.
+	Metadata bool
+}
+
+// Chassis asset tags set by providers whose system vendor is generic.
+const (
+	azureAssetTag  = "7783-7084-3265-9085-8269-3286-77"
+	oracleAssetTag = "OracleCloud.com"
+)
+
+var (
+	// dmiFunc and assetTagFunc read the raw DMI strings. They are variables so tests can
+	// simulate other hosts.
+	dmiFunc = func() (vendor, product string, err error) {
+		_ = machineid.ComponentMachineID // Guarantees the bridge is installed.
+		return bridge.DMI()
+	}
+	assetTagFunc = chassisAssetTag
+	detectIMDS   = imds.Detect
+)
+
+func init() {
+	// Name the provider in the tags of machineid.DescribeEnvironment. Only the DMI strings are
+	// read: the metadata endpoints would block the caller.
+	bridge.RegisterCloudProvider(func() string {
+		return string(Detect(context.Background(), Config{}))
+	})
+}
+
+// Detect returns the cloud provider, or None on-premises.
+func Detect(ctx context.Context, cfg Config) Provider {
+	vendor, product, _ := dmiFunc()
+	if p := fromDMI(vendor, product, assetTagFunc()); p != None {
+		return p
+	}
+	if cfg.Metadata {
+		return Provider(detectIMDS(ctx))
+	}
+	return None
+}
+
+// Tag returns the provider as an environment tag "<environment>:<provider>" (e.g.,
+// "vm:aws", or "docker:gcp" for a container on a GCE instance), or "" on-premises.
+func Tag(ctx context.Context, cfg Config) string {
+	p := Detect(ctx, cfg)
+	if p == None {
+		return ""
+	}
+	env, err := machineid.Environment()
+	if err != nil {
+		env = machineid.EnvVM.String()
+	}
+	return env + ":" + string(p)
+}
+
+// fromDMI recognizes a provider from the system vendor, product name and chassis asset tag.
+func fromDMI(vendor, product, assetTag string) Provider {
+	vendor = strings.ToLower(strings.TrimSpace(vendor))
+	product = strings.ToLower(strings.TrimSpace(product))
+	assetTag = strings.TrimSpace(assetTag)
+
+	switch {
+	case strings.Contains(vendor, "amazon ec2"):
+		return AWS
+	case vendor == "google" || strings.Contains(product, "google compute engine"):
+		return GCP
+	case assetTag == azureAssetTag:
+		return Azure
+	case strings.Contains(vendor, "digitalocean"):
+		return DigitalOcean
+	case assetTag == oracleAssetTag:
+		return Oracle
+	case strings.Contains(vendor, "hetzner"):
+		return Hetzner
+	}
+	return None
+}
@@ -0,0 +1,72 @@
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/banditmoscow1337/machineid"
+	"github.com/banditmoscow1337/machineid/internal/imds"
+)
+
+func TestFromDMI(t *testing.T) {
+	tests := []struct {
+		vendor, product, assetTag string
+		want                      Provider
+	}{
+		{"Amazon EC2", "m5.large", "", AWS},
+		{"Amazon EC2", "m5.metal", "", AWS},
+		{"Google", "Google Compute Engine", "", GCP},
+		{"Microsoft Corporation", "Virtual Machine", "7783-7084-3265-9085-8269-3286-77", Azure},
+		{"Microsoft Corporation", "Virtual Machine", "", None}, // Plain Hyper-V guest.
+		{"DigitalOcean", "Droplet", "", DigitalOcean},
+		{"QEMU", "Standard PC (i440FX + PIIX, 1996)", "OracleCloud.com", Oracle},
+		{"Hetzner", "vServer", "", Hetzner},
+		{"Dell Inc.", "PowerEdge R740", "", None},
+	}
+	for _, tt := range tests {
+		if got := fromDMI(tt.vendor, tt.product, tt.assetTag); got != tt.want {
+			t.Errorf("fromDMI(%q, %q, %q) = %q, want %q", tt.vendor, tt.product, tt.assetTag, got, tt.want)
+		}
+	}
+}
+
+func TestDetectMetadata(t *testing.T) {
+	// A Hyper-V guest whose asset tag is unreadable: only the metadata service tells Azure.
+	defer func(d func() (string, string, error), a func() string) { dmiFunc, assetTagFunc = d, a }(dmiFunc, assetTagFunc)
+	dmiFunc = func() (string, string, error) { return "Microsoft Corporation", "Virtual Machine", nil }
+	assetTagFunc = func() string { return "" }
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metadata/instance/compute/vmId" && r.Header.Get("Metadata") == "true" {
+			w.Write([]byte("02aab8a4-74ef-476e-8182-f6d2ba4166a6"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	defer func(e string) { imds.Endpoint = e }(imds.Endpoint)
+	imds.Endpoint = srv.URL
+
+	if got := Detect(context.Background(), Config{}); got != None {
+		t.Errorf("metadata must not be queried unless enabled, got %q", got)
+	}
+	if got := Detect(context.Background(), Config{Metadata: true}); got != Azure {
+		t.Errorf("expected azure from the metadata service, got %q", got)
+	}
+}
+
+func TestEnvironmentTags(t *testing.T) {
+	defer func(d func() (string, string, error), a func() string) { dmiFunc, assetTagFunc = d, a }(dmiFunc, assetTagFunc)
+	dmiFunc = func() (string, string, error) { return "Amazon EC2", "m5.large", nil }
+	assetTagFunc = func() string { return "" }
+
+	info, err := machineid.DescribeEnvironment()
+	if err != nil {
+		t.Skip(err)
+	}
+	if len(info.Tags) < 2 || info.Tags[0] != string(AWS) || info.Tags[len(info.Tags)-1] != info.Type {
+		t.Errorf("expected the provider as the outermost tag, got %q", info.Tags)
+	}
+}
@@ -0,0 +1,67 @@
+package machineid
+
+import (
+	"context"
+	"time"
+
+	"github.com/banditmoscow1337/machineid/internal/imds"
+)
+
+// SourceCloudInstanceID is reported in MachineInfo.Source when WithCloudMetadata(true)
+// replaced the raw ID with the cloud instance ID.
+const SourceCloudInstanceID = "cloud-instance-id"
+
+// DefaultCloudMetadataTimeout bounds the metadata queries when the context has no deadline
+// (ID() and ProtectedID() take no context).
+const DefaultCloudMetadataTimeout = 2 * time.Second
+
+var getCloudInstanceIDFunc = imds.InstanceID
+
+// WithCloudMetadata makes ID(), ProtectedID() and Info() derive the ID from the cloud
+// instance ID (EC2 IMDSv2, GCE metadata, Azure IMDS) when the machine is a cloud instance.
+// On autoscaled fleets the machine-id is baked into the image and shared by every instance,
+// while the instance ID is unique per instance (and changes when the instance is replaced).
+//
+// It is off by default: it queries the link-local metadata address over HTTP, bounded by the
+// context of Info() or DefaultCloudMetadataTimeout. The outcome, including "not a cloud
+// instance", is cached until Refresh(SourceCloudInstanceID); elsewhere the usual ID is returned.
+func WithCloudMetadata(enabled bool) Option {
+	return func(o *options) {
+		o.cloudMetadata = enabled
+	}
+}
+
+// applyCloudMetadata replaces the raw ID of snap with the cloud instance ID if the options
+// ask for it and the metadata service answers.
+func (o options) applyCloudMetadata(ctx context.Context, snap snapshot) snapshot {
+	if !o.cloudMetadata {
+		return snap
+	}
+	if id, err := cloudInstanceID(ctx); err == nil && id != "" {
+		snap.rawID, snap.source = id, SourceCloudInstanceID
+	}
+	return snap
+}
+
+// cloudInstanceID returns the provider-qualified instance ID (e.g., "aws:i-0abc123"), or ""
+// outside the cloud. Only a cancellation by the caller is reported as an error (and not
+// cached): unreachable metadata services are the normal case on-premises.
+func cloudInstanceID(ctx context.Context) (string, error) {
+	return cachedSourceValue(SourceCloudInstanceID, func() (string, error) {
+		query := ctx
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			query, cancel = context.WithTimeout(ctx, DefaultCloudMetadataTimeout)
+			defer cancel()
+		}
+
+		id, err := getCloudInstanceIDFunc(query)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			return "", nil
+		}
+		return id, nil
+	})
+}
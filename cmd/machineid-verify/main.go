@@ -0,0 +1,179 @@
+// Command machineid-verify checks that the machine ID is stable on the current OS image, so
+// new images can be validated before rollout. It resolves the ID:
+//
+//   - twice, discarding the cache in between,
+//   - in a child process (across a fork/exec boundary),
+//   - with the MAC fallback computed after a simulated interface change (interfaces reordered
+//     and renamed, virtual interfaces added), which must not affect it,
+//
+// and reports each check. The exit status is 0 if the ID is stable, 1 otherwise.
+//
+// With -doctor, it prints the diagnosis of every source instead (see machineid.Doctor); the exit
+// status is 0 if the ID could be resolved.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/banditmoscow1337/machineid"
+	"github.com/banditmoscow1337/machineid/internal/bridge"
+)
+
+// childEnv marks the child process started by the fork/exec check.
+const childEnv = "MACHINEID_VERIFY_CHILD"
+
+func main() {
+	if os.Getenv(childEnv) == "1" {
+		id, err := machineid.ID()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(id)
+		return
+	}
+
+	quiet := flag.Bool("q", false, "only report failures")
+	doctor := flag.Bool("doctor", false, "diagnose every source instead of checking stability")
+	flag.Parse()
+
+	if *doctor {
+		if !printDoctor(machineid.Doctor()) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	info, err := machineid.Info(context.Background(), machineid.WithDMISanitization(machineid.SanitizeRedacted))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "machineid-verify: cannot resolve the ID:", err)
+		os.Exit(1)
+	}
+	if !*quiet {
+		fmt.Printf("id:          %s\nenvironment: %s %s\nsource:      %s\n\n", info.ID, info.Environment, info.EnvironmentDetail, info.Source)
+	}
+
+	ok := true
+	for _, c := range []struct {
+		name string
+		run  func(id string) error
+	}{
+		{"cache reset", checkRefresh},
+		{"fork/exec", checkChild},
+		{"interface change", checkInterfaces},
+	} {
+		if err := c.run(info.ID); err != nil {
+			ok = false
+			fmt.Printf("FAIL  %-16s %v\n", c.name, err)
+		} else if !*quiet {
+			fmt.Printf("ok    %s\n", c.name)
+		}
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// checkRefresh re-resolves every source.
+func checkRefresh(id string) error {
+	machineid.Refresh()
+	again, err := machineid.ID()
+	if err != nil {
+		return err
+	}
+	if again != id {
+		return fmt.Errorf("ID changed to %s", again)
+	}
+	return nil
+}
+
+// checkChild resolves the ID in a fresh process.
+func checkChild(id string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe)
+	cmd.Env = append(os.Environ(), childEnv+"=1")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("child: %w", err)
+	}
+	if child := strings.TrimSpace(string(out)); child != id {
+		return fmt.Errorf("child reported %s", child)
+	}
+	return nil
+}
+
+// checkInterfaces compares the MAC fallback before and after a simulated interface change.
+// It applies whatever the source of the ID, since any machine can end up on the fallback.
+func checkInterfaces(string) error {
+	before, errBefore := bridge.MACFallback(func(ifaces []net.Interface) []net.Interface { return ifaces })
+	after, errAfter := bridge.MACFallback(simulateChange)
+	switch {
+	case errBefore != nil && errAfter != nil:
+		// No usable interface (e.g., no network): the fallback is unavailable either way.
+		return nil
+	case errBefore != nil || errAfter != nil:
+		return fmt.Errorf("MAC fallback availability changed: %v", errors.Join(errBefore, errAfter))
+	case after != before:
+		return fmt.Errorf("MAC fallback changed from %s to %s", before, after)
+	}
+	return nil
+}
+
+// simulateChange reverses the interface order, renames the interfaces (as a switch to
+// predictable interface names would), and adds a Docker bridge and a VPN tunnel.
+func simulateChange(ifaces []net.Interface) []net.Interface {
+	out := slices.Clone(ifaces)
+	slices.Reverse(out)
+	for i := range out {
+		if out[i].Flags&net.FlagLoopback == 0 {
+			out[i].Name = fmt.Sprintf("enp%ds0", i)
+		}
+	}
+	return append(out,
+		net.Interface{Name: "docker0", Flags: net.FlagUp, HardwareAddr: net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x01}},
+		net.Interface{Name: "tun0", Flags: net.FlagUp, HardwareAddr: net.HardwareAddr{0x02, 0x00, 0x5e, 0x10, 0x00, 0x01}},
+	)
+}
+
+// printDoctor prints the report and returns whether the ID was resolved.
+func printDoctor(r *machineid.DoctorReport) bool {
+	if r.Err != nil {
+		fmt.Printf("id:          cannot be resolved: %v\n", r.Err)
+	} else {
+		fmt.Printf("environment: %s\nsource:      %s\nstrength:    %.2f\n", r.Environment, r.Source, r.Strength.Score)
+		for _, w := range r.Strength.Warnings {
+			fmt.Printf("warning:     %s\n", w)
+		}
+	}
+	fmt.Println()
+
+	for _, d := range r.Sources {
+		if d.Problem == machineid.ProblemNone {
+			fmt.Printf("ok    %-18s %s\n", d.Name, d.Hash[:12])
+			continue
+		}
+		status := "FAIL"
+		if d.Problem == machineid.ProblemMissing && d.Hint == "" {
+			// Optional sources absent on this hardware are expected.
+			status = "n/a"
+		}
+		fmt.Printf("%-5s %-18s %s: %v\n", status, d.Name, d.Problem, d.Err)
+		if d.Hint != "" {
+			fmt.Printf("      %-18s hint: %s\n", "", d.Hint)
+		}
+	}
+	return r.Err == nil
+}
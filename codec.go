@@ -0,0 +1,204 @@
+package machineid
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// Compact binary encodings of MachineInfo for IoT and low-bandwidth telemetry.
+// Both encodings are deterministic: empty fields are omitted and map keys are sorted
+// by length, then bytewise (RFC 8949 core deterministic encoding), so the same report
+// always encodes to the same bytes.
+
+// Field names used by the binary encodings. They are part of the wire format and must never change.
+const (
+	keyID                = "id"
+	keyEnvironment       = "env"
+	keyEnvironmentDetail = "env_detail"
+	keySource            = "source"
+	keySourceTool        = "source_tool"
+	keyAssetTag          = "asset_tag"
+	keyDMI               = "dmi"
+	keyDMIVendor         = "vendor"
+	keyDMIProduct        = "product"
+	keyDMIFamily         = "family"
+	keyEnv               = "env_vars"
+	keyTags              = "tags"
+	keyDegradation       = "degradation"
+	keyKubernetes        = "k8s"
+	keyK8sNamespace      = "namespace"
+	keyK8sPod            = "pod"
+	keyK8sNode           = "node"
+)
+
+// field is a map entry holding a string value, a nested map, or a list of strings.
+type field struct {
+	key    string
+	value  string
+	nested []field
+	list   []string
+}
+
+// fields returns the non-empty fields of the report in deterministic order.
+func (m *MachineInfo) fields() []field {
+	dmi := sortedFields(
+		field{key: keyDMIVendor, value: m.DMI.Vendor},
+		field{key: keyDMIProduct, value: m.DMI.Product},
+		field{key: keyDMIFamily, value: m.DMI.Family},
+	)
+	var k8s []field
+	if m.Kubernetes != nil {
+		k8s = sortedFields(
+			field{key: keyK8sNamespace, value: m.Kubernetes.Namespace},
+			field{key: keyK8sPod, value: m.Kubernetes.Pod},
+			field{key: keyK8sNode, value: m.Kubernetes.Node},
+		)
+	}
+	var degradation string
+	if m.Degradation != DegradationNone {
+		degradation = m.Degradation.String()
+	}
+	env := make([]field, 0, len(m.Env))
+	for name, value := range m.Env {
+		env = append(env, field{key: name, value: value})
+	}
+	return sortedFields(
+		field{key: keyID, value: m.ID},
+		field{key: keyEnvironment, value: m.Environment},
+		field{key: keyEnvironmentDetail, value: m.EnvironmentDetail},
+		field{key: keySource, value: m.Source},
+		field{key: keySourceTool, value: m.SourceTool},
+		field{key: keyAssetTag, value: m.AssetTag},
+		field{key: keyDMI, nested: dmi},
+		field{key: keyEnv, nested: sortedFields(env...)},
+		field{key: keyTags, list: m.Tags},
+		field{key: keyDegradation, value: degradation},
+		field{key: keyKubernetes, nested: k8s},
+	)
+}
+
+// sortedFields drops empty fields and sorts the rest by key length, then bytewise.
+func sortedFields(all ...field) []field {
+	out := all[:0]
+	for _, f := range all {
+		if f.value != "" || len(f.nested) > 0 || len(f.list) > 0 {
+			out = append(out, f)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i].key) != len(out[j].key) {
+			return len(out[i].key) < len(out[j].key)
+		}
+		return out[i].key < out[j].key
+	})
+	return out
+}
+
+// MarshalCBOR encodes the report as a CBOR map (RFC 8949) with deterministic encoding.
+func (m *MachineInfo) MarshalCBOR() ([]byte, error) {
+	return appendCBORMap(nil, m.fields()), nil
+}
+
+// MarshalMsgpack encodes the report as a MessagePack map with the same field order as MarshalCBOR.
+func (m *MachineInfo) MarshalMsgpack() ([]byte, error) {
+	return appendMsgpackMap(nil, m.fields()), nil
+}
+
+// CBOR major types.
+const (
+	cborText  = 3 << 5
+	cborArray = 4 << 5
+	cborMap   = 5 << 5
+)
+
+// appendCBORHead appends the shortest head encoding of the major type and argument.
+func appendCBORHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= 0xff:
+		return append(b, major|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, major|27), n)
+	}
+}
+
+func appendCBORText(b []byte, s string) []byte {
+	return append(appendCBORHead(b, cborText, uint64(len(s))), s...)
+}
+
+func appendCBORMap(b []byte, fields []field) []byte {
+	b = appendCBORHead(b, cborMap, uint64(len(fields)))
+	for _, f := range fields {
+		b = appendCBORText(b, f.key)
+		switch {
+		case f.nested != nil:
+			b = appendCBORMap(b, f.nested)
+		case f.list != nil:
+			b = appendCBORHead(b, cborArray, uint64(len(f.list)))
+			for _, s := range f.list {
+				b = appendCBORText(b, s)
+			}
+		default:
+			b = appendCBORText(b, f.value)
+		}
+	}
+	return b
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= 0xff:
+		b = append(b, 0xd9, byte(n))
+	case n <= 0xffff:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackArrayHead(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+func appendMsgpackMap(b []byte, fields []field) []byte {
+	n := len(fields)
+	switch {
+	case n < 16:
+		b = append(b, 0x80|byte(n))
+	case n <= 0xffff:
+		b = binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+	}
+	for _, f := range fields {
+		b = appendMsgpackString(b, f.key)
+		switch {
+		case f.nested != nil:
+			b = appendMsgpackMap(b, f.nested)
+		case f.list != nil:
+			b = appendMsgpackArrayHead(b, len(f.list))
+			for _, s := range f.list {
+				b = appendMsgpackString(b, s)
+			}
+		default:
+			b = appendMsgpackString(b, f.value)
+		}
+	}
+	return b
+}
@@ -0,0 +1,8 @@
+//go:build (linux || windows) && !386 && !amd64
+
+package machineid
+
+// hypervisorCPUID reports no hypervisor: CPUID only exists on x86.
+var hypervisorCPUID = func() (bool, string) {
+	return false, ""
+}
@@ -0,0 +1,38 @@
+//go:build (linux || windows) && (386 || amd64)
+
+package machineid
+
+import "strings"
+
+// hypervisorCPUID probes the CPUID hypervisor leaves. It is a variable so tests can
+// simulate other hosts.
+var hypervisorCPUID = cpuidHypervisor
+
+// cpuid executes the CPUID instruction (see cpuid_amd64.s and cpuid_386.s).
+func cpuid(leaf, subleaf uint32) (eax, ebx, ecx, edx uint32)
+
+// cpuidHypervisor reports whether the hypervisor present bit (leaf 1, ECX bit 31) is set,
+// and the vendor signature of leaf 0x40000000 (e.g., "KVMKVMKVM"). Unlike the DMI tables,
+// CPUID needs no privileges.
+func cpuidHypervisor() (bool, string) {
+	if _, _, ecx, _ := cpuid(1, 0); ecx&(1<<31) == 0 {
+		return false, ""
+	}
+
+	maxLeaf, ebx, ecx, edx := cpuid(0x40000000, 0)
+	var sig []byte
+	for _, r := range []uint32{ebx, ecx, edx} {
+		sig = append(sig, byte(r), byte(r>>8), byte(r>>16), byte(r>>24))
+	}
+	vendor := strings.TrimRight(string(sig), "\x00")
+
+	// With Hyper-V enabled (e.g., for virtualization-based security), Windows itself runs
+	// in the root partition and sees the hypervisor too. Only the root partition holds the
+	// CreatePartitions privilege (leaf 0x40000003, EBX bit 0).
+	if vendor == "Microsoft Hv" && maxLeaf >= 0x40000003 {
+		if _, privileges, _, _ := cpuid(0x40000003, 0); privileges&1 != 0 {
+			return false, ""
+		}
+	}
+	return true, vendor
+}
@@ -0,0 +1,120 @@
+package machineid
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// DebugPath is the conventional mount point of Handler, next to /debug/pprof and /debug/vars.
+const DebugPath = "/debug/machineid"
+
+// debugReport is the JSON document served by Handler.
+type debugReport struct {
+	ID                string        `json:"id,omitempty"`
+	ProtectedID       string        `json:"protected_id,omitempty"`
+	Environment       string        `json:"environment,omitempty"`
+	EnvironmentDetail string        `json:"environment_detail,omitempty"`
+	Tags              []string      `json:"tags,omitempty"`
+	Source            string        `json:"source,omitempty"`
+	SourceTool        string        `json:"source_tool,omitempty"`
+	Degradation       string        `json:"degradation,omitempty"`
+	DMI               *debugDMI     `json:"dmi,omitempty"`
+	Sources           []debugSource `json:"sources"`
+	Error             string        `json:"error,omitempty"`
+}
+
+// debugDMI holds the redacted DMI strings.
+type debugDMI struct {
+	Vendor  string `json:"vendor,omitempty"`
+	Product string `json:"product,omitempty"`
+	Family  string `json:"family,omitempty"`
+}
+
+// debugSource reports the state of one source.
+type debugSource struct {
+	Name    string     `json:"name"`
+	Role    SourceRole `json:"role"`
+	Enabled bool       `json:"enabled"`
+	Cached  bool       `json:"cached"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// Handler returns a read-only http.Handler serving a redacted MachineInfo and the state of
+// every source as JSON, for live troubleshooting of agents and for sidecars serving the
+// identity to their pod. Mount it next to pprof:
+//
+//	mux.Handle(machineid.DebugPath, machineid.Handler())
+//
+// With ?app=<appID>, the report also carries ProtectedID(appID) if appID was allowed with
+// WithDebugApps; other appIDs are refused with 403. IDs are shortened unless opts say otherwise
+// (e.g., Handler(WithLength(0)) serves full Crockford IDs), the DMI strings are redacted, and no
+// environment variables or asset tags are included. Serving the report never re-runs sources
+// that are already cached.
+func Handler(opts ...Option) http.Handler {
+	// Clipped, so concurrent requests appending to it never share a backing array.
+	opts = slices.Clip(append([]Option{Short()}, opts...))
+	apps := newOptions(opts).debugApps
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveDebug(w, r, apps, opts)
+	})
+}
+
+// WithDebugApps allows Handler to serve the ProtectedID of the given appIDs (?app=<appID>).
+// Without it, the handler never serves a ProtectedID, so anyone reaching the debug listener
+// can't obtain the identity of an application it wasn't meant for.
+func WithDebugApps(appIDs ...string) Option {
+	return func(o *options) {
+		o.debugApps = append(o.debugApps, appIDs...)
+	}
+}
+
+func serveDebug(w http.ResponseWriter, r *http.Request, apps []string, opts []Option) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	app := r.URL.Query().Get("app")
+	if app != "" && !slices.Contains(apps, app) {
+		http.Error(w, "app not allowed", http.StatusForbidden)
+		return
+	}
+
+	status := http.StatusOK
+	var report debugReport
+	info, err := Info(r.Context(), append(opts, WithDMISanitization(SanitizeRedacted))...)
+	if err == nil && app != "" {
+		report.ProtectedID, err = protectedID(app, opts)
+	}
+	if err != nil {
+		status = http.StatusServiceUnavailable
+		report.Error = err.Error()
+	} else {
+		report.ID = info.ID
+		report.Environment = info.Environment
+		report.EnvironmentDetail = info.EnvironmentDetail
+		report.Tags = info.Tags
+		report.Source = info.Source
+		report.SourceTool = info.SourceTool
+		report.Degradation = info.Degradation.String()
+		report.DMI = &debugDMI{Vendor: info.DMI.Vendor, Product: info.DMI.Product, Family: info.DMI.Family}
+	}
+
+	for _, d := range Sources() {
+		s := debugSource{Name: d.Name, Role: d.Role, Enabled: d.Enabled}
+		var expires time.Time
+		if s.Cached, expires = sourceCacheState(d.Name); !expires.IsZero() {
+			s.Expires = &expires
+		}
+		report.Sources = append(report.Sources, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+}
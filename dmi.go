@@ -0,0 +1,82 @@
+package machineid
+
+import (
+	"strings"
+
+	"github.com/banditmoscow1337/machineid/internal/bridge"
+)
+
+// Sanitization controls how raw hardware strings are reported in MachineInfo.
+type Sanitization int
+
+const (
+	// SanitizeHashed reports the SHA256 (hex) of each value. This is the default.
+	SanitizeHashed Sanitization = iota
+	// SanitizeRedacted replaces each available value with RedactedValue.
+	SanitizeRedacted
+	// SanitizeNone reports the raw, readable values (e.g., "Dell Inc.").
+	SanitizeNone
+)
+
+// RedactedValue replaces available values under SanitizeRedacted.
+// Values the platform doesn't provide stay empty, so it is still visible which fields exist.
+const RedactedValue = "[REDACTED]"
+
+// DMIInfo holds selected SMBIOS/DMI system strings.
+type DMIInfo struct {
+	Vendor  string // System manufacturer (e.g., "LENOVO").
+	Product string // Product name (e.g., "20XW0026GE").
+	Family  string // Product family (e.g., "ThinkPad X1 Carbon Gen 9").
+}
+
+var getDMIStringsFunc = getDMIStrings
+
+func init() {
+	bridge.DMI = func() (string, string, error) {
+		raw, err := getDMIStringsFunc()
+		return raw.Vendor, raw.Product, err
+	}
+}
+
+// WithDMISanitization selects how Info() reports the DMI strings in MachineInfo.DMI.
+func WithDMISanitization(level Sanitization) Option {
+	return func(o *options) {
+		o.dmiSanitization = level
+	}
+}
+
+// readDMI resolves the DMI strings and applies the sanitization level.
+// Unavailable strings are left empty; DMI is informational and never fails Info().
+func readDMI(level Sanitization) DMIInfo {
+	raw, err := getDMIStringsFunc()
+	if err != nil {
+		return DMIInfo{}
+	}
+
+	return DMIInfo{
+		Vendor:  sanitize(raw.Vendor, level),
+		Product: sanitize(raw.Product, level),
+		Family:  sanitize(raw.Family, level),
+	}
+}
+
+// sanitize applies level to a single value.
+func sanitize(value string, level Sanitization) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+
+	switch level {
+	case SanitizeNone:
+		return value
+	case SanitizeRedacted:
+		return RedactedValue
+	default:
+		hash, err := protect(value)
+		if err != nil {
+			return ""
+		}
+		return hash
+	}
+}
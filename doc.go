@@ -0,0 +1,15 @@
+// Package machineid generates a unique, stable, and anonymized identifier for the machine
+// it is running on, prefixed with the detected environment type (e.g., "physical", "vm").
+//
+// # API Stability
+//
+// This package is the stable layer. For a given machine and identical options, including
+// WithRulesVersion, the output of ID() and ProtectedID() is frozen: rules that change the
+// value a source prefers only apply with a higher WithRulesVersion (see RulesVersion), and
+// hashing and encodings only change in a new major version, so IDs can safely be persisted
+// (e.g., in license files or databases). A release may still return an ID where earlier ones
+// failed, e.g. from the gethostid(2) value on machines without a usable network interface.
+//
+// Experimental features, whose output may still change between releases, live in the
+// machineid/x package and are only active when imported explicitly.
+package machineid
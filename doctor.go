@@ -0,0 +1,120 @@
+package machineid
+
+import (
+	"errors"
+	"os"
+)
+
+// Problem classifies why a source failed.
+type Problem string
+
+const (
+	// ProblemNone means the source produced a value.
+	ProblemNone Problem = ""
+	// ProblemMissing means the source doesn't exist on this machine (os.ErrNotExist).
+	ProblemMissing Problem = "missing"
+	// ProblemPermission means the source exists but the process may not read it (os.ErrPermission).
+	ProblemPermission Problem = "permission"
+	// ProblemParse means the source produced output that couldn't be parsed (ErrParse).
+	ProblemParse Problem = "parse"
+	// ProblemDisabled means the source was turned off with DisableSource.
+	ProblemDisabled Problem = "disabled"
+	// ProblemOther covers every other failure.
+	ProblemOther Problem = "error"
+)
+
+// SourceDiagnosis is the outcome of a source with the classified problem and, when there is
+// something to do about it, a hint.
+type SourceDiagnosis struct {
+	SourceStatus
+	Problem Problem
+	Hint    string
+}
+
+// DoctorReport is the structured report returned by Doctor.
+type DoctorReport struct {
+	// Environment and Source describe the resolved ID; they are empty if it couldn't be resolved.
+	Environment string
+	Source      string
+	// Err is the reason the ID couldn't be resolved, nil otherwise.
+	Err error
+	// Strength rates the resolved ID (see Strength); it is nil if the ID couldn't be resolved.
+	Strength *IdentityStrength
+	// Sources diagnoses every source listed by Sources, in the same order.
+	Sources []SourceDiagnosis
+}
+
+// Doctor resolves the ID, runs every source (see ProbeSources) and returns a report of what
+// failed, why (missing, permission, parse...) and what to do about it. Attached to a support
+// ticket, it usually tells why an ID is missing, weak, or shared by several machines. Like
+// ProbeSources, it reports hashes only, never raw values. The ID is resolved like ID(opts...),
+// so pass the options the ID is used with.
+func Doctor(opts ...Option) *DoctorReport {
+	report := &DoctorReport{}
+	if snap, err := newOptions(opts).resolveSnapshot(options.load); err != nil {
+		report.Err = err
+	} else {
+		report.Environment = snap.prefix
+		report.Source = snap.source
+		report.Strength = identityStrength(snap)
+	}
+
+	for _, s := range ProbeSources() {
+		d := SourceDiagnosis{SourceStatus: s, Problem: classifyProblem(s.Err)}
+		d.Hint = problemHint(d.Name, d.Role, d.Problem, d.Err)
+		report.Sources = append(report.Sources, d)
+	}
+	return report
+}
+
+// classifyProblem maps a source error to its Problem. ErrSourceDisabled wraps os.ErrNotExist,
+// so it is tested first.
+func classifyProblem(err error) Problem {
+	switch {
+	case err == nil:
+		return ProblemNone
+	case errors.Is(err, ErrSourceDisabled):
+		return ProblemDisabled
+	case errors.Is(err, os.ErrPermission):
+		return ProblemPermission
+	case errors.Is(err, ErrParse):
+		return ProblemParse
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, ErrNoNetwork):
+		return ProblemMissing
+	}
+	return ProblemOther
+}
+
+// problemHint suggests a fix for the problem of a source, or returns "" if there is nothing
+// to do (e.g., an optional source missing on this hardware).
+func problemHint(name string, role SourceRole, p Problem, err error) string {
+	switch p {
+	case ProblemDisabled:
+		return "turned off with DisableSource; EnableSource turns it back on"
+	case ProblemPermission:
+		if name == ComponentDMIUUID {
+			return "the SMBIOS UUID is only readable by root; run privileged or keep the default source"
+		}
+		return "run with elevated privileges (root, or Administrator on Windows) or grant read access to the source"
+	case ProblemParse:
+		return "the source produced unexpected output; please report it with the output of the underlying tool"
+	case ProblemMissing:
+		switch {
+		case errors.Is(err, ErrNoNetwork):
+			return "no network interfaces besides loopback; use WithGeneratedFallback or BestEffortID with a store"
+		case name == ComponentMachineID:
+			return "no machine ID; provision one in the image (e.g., systemd-machine-id-setup) or mount the host's /etc/machine-id into the container"
+		case name == ComponentMAC:
+			return "no usable network interface; use WithGeneratedFallback for a persisted ID"
+		}
+	case ProblemOther:
+		if name == ComponentMAC {
+			// Interfaces exist, but none passed the filters (e.g., only virtual ones).
+			return "no usable network interface; use WithGeneratedFallback for a persisted ID"
+		}
+		if role == SourceRolePrimary || role == SourceRoleFallback {
+			return "unexpected failure; enable debug logging with SetLogger to trace the resolution"
+		}
+	}
+	return ""
+}
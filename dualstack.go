@@ -0,0 +1,128 @@
+package machineid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// DualStack holds the current identity and, after an OS image update regenerated the
+// machine-id, the identity the machine had before.
+type DualStack struct {
+	// Current is the identity derived from the live sources.
+	Current string
+	// Previous is the identity remembered by the Store, if it differs from Current.
+	// It is kept until CommitDualStack, so a re-registration interrupted by a restart can be retried.
+	Previous string
+	// PrefixDrift is true if only the environment prefix changed (e.g., physical -> vm after a
+	// P2V migration) while the raw ID stayed the same.
+	PrefixDrift bool
+}
+
+// dualStackState is the persisted form. Raw values are stored so that any option or
+// appID can be applied to the previous identity as well.
+type dualStackState struct {
+	Raw            string `json:"raw"`
+	Prefix         string `json:"prefix"`
+	PreviousRaw    string `json:"previous_raw,omitempty"`
+	PreviousPrefix string `json:"previous_prefix,omitempty"`
+}
+
+// DualStackID returns the current ID() and, if store remembers a different raw ID, the ID the
+// machine had before. This enables seamless re-registration during blue/green reimaging: the
+// backend looks the machine up by Previous and moves it to Current. Call CommitDualStack once
+// the re-registration succeeded.
+func DualStackID(store Store, opts ...Option) (DualStack, error) {
+	return dualStack(store, domainID, nil, opts)
+}
+
+// DualStackProtectedID is DualStackID for ProtectedID(appID).
+func DualStackProtectedID(store Store, appID string, opts ...Option) (DualStack, error) {
+	trackAppID(appID, callerSite(1))
+	o := newOptions(opts)
+	return dualStack(store, o.protectedDomain(), []string{o.normalizeAppID(appID)}, opts)
+}
+
+func dualStack(store Store, domain string, extra []string, opts []Option) (DualStack, error) {
+	snap, err := newOptions(opts).resolveSnapshot(options.load)
+	if err != nil {
+		return DualStack{}, err
+	}
+
+	state, err := loadDualStackState(store)
+	if err != nil {
+		return DualStack{}, err
+	}
+
+	switch {
+	case state.Raw == "":
+		// First run: remember the current identity.
+		state = dualStackState{Raw: snap.rawID, Prefix: snap.prefix}
+		err = saveDualStackState(store, state)
+	case state.Raw != snap.rawID || state.Prefix != snap.prefix:
+		if state.Raw == snap.rawID {
+			warnPrefixDrift(state.Prefix, snap.prefix)
+		}
+		// The identity changed since the last run: keep the old one as previous.
+		state = dualStackState{
+			Raw:            snap.rawID,
+			Prefix:         snap.prefix,
+			PreviousRaw:    state.Raw,
+			PreviousPrefix: state.Prefix,
+		}
+		err = saveDualStackState(store, state)
+	}
+	if err != nil {
+		return DualStack{}, err
+	}
+
+	var ds DualStack
+	if ds.Current, err = formatID(snap.prefix, domain, append([]string{snap.rawID}, extra...), opts); err != nil {
+		return DualStack{}, err
+	}
+	if state.PreviousRaw != "" {
+		ds.Previous, err = formatID(state.PreviousPrefix, domain, append([]string{state.PreviousRaw}, extra...), opts)
+		if err != nil {
+			return DualStack{}, err
+		}
+		ds.PrefixDrift = state.PreviousRaw == snap.rawID
+	}
+	return ds, nil
+}
+
+// CommitDualStack forgets the previous identity once the backend has re-registered the machine.
+func CommitDualStack(store Store) error {
+	state, err := loadDualStackState(store)
+	if err != nil || state.PreviousRaw == "" {
+		return err
+	}
+	state.PreviousRaw, state.PreviousPrefix = "", ""
+	return saveDualStackState(store, state)
+}
+
+func loadDualStackState(store Store) (dualStackState, error) {
+	var state dualStackState
+	data, err := store.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("machineid: load state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("machineid: decode state: %w", err)
+	}
+	return state, nil
+}
+
+func saveDualStackState(store Store, state dualStackState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := store.Save(data); err != nil {
+		return fmt.Errorf("machineid: save state: %w", err)
+	}
+	return nil
+}
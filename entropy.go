@@ -0,0 +1,173 @@
+package machineid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+)
+
+// DefaultMinEntropy is the minimum estimated entropy, in bits, PresetLicensing requires of
+// the raw ID. It rejects placeholders such as an all-zero MAC address or a 4-character ID,
+// while accepting short hardware serials (e.g., 7-character service tags).
+const DefaultMinEntropy = 16
+
+// ErrWeakIdentity is wrapped by WeakIdentityError.
+var ErrWeakIdentity = errors.New("machineid: raw identity too weak")
+
+// WeakIdentityError reports a raw ID whose estimated entropy is below the minimum set with
+// WithMinEntropy. Licensing flows can use it to require manual activation instead of binding
+// to a trivially guessable identifier.
+type WeakIdentityError struct {
+	Source  string  // The source of the raw ID (see MachineInfo.Source).
+	Length  int     // Length of the raw ID, in characters.
+	Entropy float64 // Estimated entropy of the raw ID, in bits.
+	Min     float64 // The required minimum, in bits.
+}
+
+func (e *WeakIdentityError) Error() string {
+	return fmt.Sprintf("machineid: raw identity from source %s too weak: %d characters, %.1f bits of entropy (minimum %.0f)",
+		e.Source, e.Length, e.Entropy, e.Min)
+}
+
+func (e *WeakIdentityError) Unwrap() error {
+	return ErrWeakIdentity
+}
+
+// WithMinEntropy makes ID(), ProtectedID() and Info() fail with a WeakIdentityError if the
+// estimated entropy of the raw ID is below bits. Values <= 0 disable the check (the default).
+func WithMinEntropy(bits float64) Option {
+	return func(o *options) {
+		o.minEntropy = bits
+	}
+}
+
+// entropyBits estimates the entropy of s: its length times the Shannon entropy of its
+// character distribution. It is an upper bound for structured IDs, but separates random IDs
+// (~120 bits for a machine-id) from placeholders ("00:00:00:00:00:00" scores ~15 bits).
+func entropyBits(s string) float64 {
+	s = strings.TrimSpace(s)
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h * float64(n)
+}
+
+// checkEntropy verifies the raw ID of snap against the minimum.
+func checkEntropy(snap snapshot, min float64) error {
+	if min <= 0 {
+		return nil
+	}
+	if bits := entropyBits(snap.rawID); bits < min {
+		return &WeakIdentityError{
+			Source:  snap.source,
+			Length:  len([]rune(strings.TrimSpace(snap.rawID))),
+			Entropy: bits,
+			Min:     min,
+		}
+	}
+	return nil
+}
+
+// fullStrengthEntropy is the estimated entropy, in bits, from which a raw ID no longer lowers
+// IdentityStrength.Score.
+const fullStrengthEntropy = 64
+
+// sourceQuality rates how unique and stable each source is, from 0 to 1. Sources not listed
+// (e.g., registered with RegisterSource) rate defaultSourceQuality.
+var sourceQuality = map[string]float64{
+	ComponentMachineID:    1,
+	SourceMachineGuid:     1,
+	SourceCloudInstanceID: 1,
+	ComponentDMIUUID:      0.9, // Duplicated by cloned VMs and some cheap boards.
+	SourceBIOSSerial:      0.8,
+	SourcePersisted:       0.6, // Unique, but lost with the file.
+	ComponentMAC:          0.4, // Changes with network cards, trivially spoofable.
+	SourceHostID:          0.2, // Often derived from the IP address.
+}
+
+const defaultSourceQuality = 0.8
+
+// IdentityStrength estimates how unique and stable the raw ID is.
+type IdentityStrength struct {
+	// Source is the source of the raw ID (see MachineInfo.Source).
+	Source string
+	// Entropy is the estimated entropy of the raw ID, in bits.
+	Entropy float64
+	// Score rates the ID from 0 (useless) to 1 (unique and stable): the quality of the source,
+	// lowered for raw IDs below 64 bits of estimated entropy.
+	Score float64
+	// Warnings explain what lowered the score, in plain language.
+	Warnings []string
+}
+
+// Strength resolves the ID like ID(opts...) and estimates how unique and stable it is, so
+// callers can refuse weak IDs for security-sensitive uses (e.g., Score < 0.5). It fails only if
+// the ID itself can't be resolved.
+func Strength(opts ...Option) (*IdentityStrength, error) {
+	o := newOptions(opts)
+	snap, err := o.applyGeneratedFallback(o.load())
+	if err != nil {
+		return nil, err
+	}
+	snap = o.applyCloudMetadata(context.Background(), snap)
+	return identityStrength(snap), nil
+}
+
+// identityStrength rates the raw ID of snap.
+func identityStrength(snap snapshot) *IdentityStrength {
+	s := &IdentityStrength{Source: snap.source, Entropy: entropyBits(snap.rawID)}
+
+	quality, ok := sourceQuality[snap.source]
+	if !ok {
+		quality = defaultSourceQuality
+	}
+	switch snap.source {
+	case ComponentDMIUUID:
+		s.Warnings = append(s.Warnings, "the SMBIOS UUID is duplicated by cloned VMs and some boards")
+	case SourcePersisted:
+		s.Warnings = append(s.Warnings, "the ID was generated and is lost with the file it is kept in")
+	case SourceHostID:
+		s.Warnings = append(s.Warnings, "the gethostid(2) value is often derived from the IP address")
+	case ComponentMAC:
+		s.Warnings = append(s.Warnings, "the ID is derived from MAC addresses and changes with network cards")
+		if addrs, err := GetHardwareAddresses(); err == nil {
+			if len(addrs) == 1 {
+				s.Warnings = append(s.Warnings, "the ID is derived from a single network interface")
+			}
+			if len(addrs) > 0 && allLocallyAdministered(addrs) {
+				quality = 0.1
+				s.Warnings = append(s.Warnings, "every MAC address is locally administered and may be randomized")
+			}
+		}
+	}
+
+	if s.Entropy < DefaultMinEntropy {
+		s.Warnings = append(s.Warnings, fmt.Sprintf("the raw ID has only %.1f bits of estimated entropy", s.Entropy))
+	}
+	s.Score = quality * min(s.Entropy/fullStrengthEntropy, 1)
+	return s
+}
+
+// allLocallyAdministered reports whether every address has the locally administered bit set,
+// as randomized (private) Wi-Fi addresses do.
+func allLocallyAdministered(addrs []HardwareAddress) bool {
+	for _, a := range addrs {
+		mac, err := net.ParseMAC(a.MAC)
+		if err != nil || mac[0]&0x02 == 0 {
+			return false
+		}
+	}
+	return true
+}
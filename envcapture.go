@@ -0,0 +1,53 @@
+package machineid
+
+import "os"
+
+// EnvRule allow-lists one environment variable for capture into MachineInfo.Env.
+type EnvRule struct {
+	// Name is the exact variable name (e.g., "NODE_NAME").
+	Name string
+	// Sanitization selects how the value is reported (hashed by default).
+	Sanitization Sanitization
+}
+
+// OrchestrationEnv returns rules for the identity variables commonly injected by orchestrators
+// (via the Kubernetes downward API, ECS, Nomad). Node and namespace names are reported as-is;
+// instance-specific values such as pod UIDs and task ARNs are hashed.
+func OrchestrationEnv() []EnvRule {
+	return []EnvRule{
+		{Name: "NODE_NAME", Sanitization: SanitizeNone},
+		{Name: "POD_NAMESPACE", Sanitization: SanitizeNone},
+		{Name: "POD_NAME", Sanitization: SanitizeHashed},
+		{Name: "POD_UID", Sanitization: SanitizeHashed},
+		{Name: "ECS_TASK_ARN", Sanitization: SanitizeHashed},
+		{Name: "ECS_CONTAINER_METADATA_URI_V4", Sanitization: SanitizeRedacted},
+		{Name: "NOMAD_ALLOC_ID", Sanitization: SanitizeHashed},
+	}
+}
+
+// WithEnvCapture makes Info() capture the allow-listed environment variables into
+// MachineInfo.Env, sanitized according to each rule. Variables that aren't listed are never
+// read; listed variables that are unset or empty are omitted.
+func WithEnvCapture(rules ...EnvRule) Option {
+	return func(o *options) {
+		o.envRules = append(o.envRules, rules...)
+	}
+}
+
+// captureEnv applies the rules to the process environment.
+func captureEnv(rules []EnvRule) map[string]string {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	env := make(map[string]string, len(rules))
+	for _, rule := range rules {
+		if v := sanitize(os.Getenv(rule.Name), rule.Sanitization); v != "" {
+			env[rule.Name] = v
+		}
+	}
+	if len(env) == 0 {
+		return nil
+	}
+	return env
+}
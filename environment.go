@@ -0,0 +1,205 @@
+package machineid
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/banditmoscow1337/machineid/internal/bridge"
+)
+
+// EnvCode is a compact numeric code for the environment class reported as the ID prefix.
+// It is meant for metrics systems with label-cardinality concerns and for binary wire formats.
+//
+// IMPORTANT: The values are part of the public contract. Never renumber or reuse a code;
+// new environment classes must be appended with a fresh value.
+type EnvCode uint8
+
+const (
+	EnvUnknown    EnvCode = 0  // Unrecognized or unsupported platform ("unknown").
+	EnvPhysical   EnvCode = 1  // Bare-metal hardware ("physical").
+	EnvVM         EnvCode = 2  // Virtual machine / hypervisor guest ("vm").
+	EnvContainer  EnvCode = 3  // Generic container detected via cgroups ("container").
+	EnvDocker     EnvCode = 4  // Docker container detected via /.dockerenv ("docker").
+	EnvIOS        EnvCode = 5  // iOS app sandbox ("ios").
+	EnvBrowser    EnvCode = 6  // js/wasm in a web browser ("browser").
+	EnvWASI       EnvCode = 7  // WebAssembly System Interface runtime ("wasi").
+	EnvWSL        EnvCode = 8  // Windows Subsystem for Linux ("wsl").
+	EnvPodman     EnvCode = 9  // Podman container detected via /run/.containerenv ("podman").
+	EnvLXC        EnvCode = 10 // LXC/LXD system container ("lxc").
+	EnvNspawn     EnvCode = 11 // systemd-nspawn container ("nspawn").
+	EnvCRIO       EnvCode = 12 // CRI-O container outside Kubernetes pods ("crio").
+	EnvContainerd EnvCode = 13 // containerd container outside Kubernetes pods ("containerd").
+	EnvGarden     EnvCode = 14 // Cloud Foundry Garden container ("garden").
+	EnvKubernetes EnvCode = 15 // Kubernetes pod ("kubernetes").
+	EnvServerless EnvCode = 16 // Serverless function or container sandbox ("serverless").
+)
+
+// envNames maps each code to the prefix string used in ID().
+var envNames = map[EnvCode]string{
+	EnvUnknown:    "unknown",
+	EnvPhysical:   "physical",
+	EnvVM:         "vm",
+	EnvContainer:  "container",
+	EnvDocker:     "docker",
+	EnvIOS:        "ios",
+	EnvBrowser:    "browser",
+	EnvWASI:       "wasi",
+	EnvWSL:        "wsl",
+	EnvPodman:     "podman",
+	EnvLXC:        "lxc",
+	EnvNspawn:     "nspawn",
+	EnvCRIO:       "crio",
+	EnvContainerd: "containerd",
+	EnvGarden:     "garden",
+	EnvKubernetes: "kubernetes",
+	EnvServerless: "serverless",
+}
+
+// isContainer reports whether the environment type is a container (or pod) class.
+func isContainer(env string) bool {
+	switch ParseEnvCode(env) {
+	case EnvContainer, EnvDocker, EnvPodman, EnvLXC, EnvNspawn, EnvCRIO, EnvContainerd, EnvGarden, EnvKubernetes:
+		return true
+	}
+	return false
+}
+
+// String returns the environment prefix for the code (e.g., "vm").
+func (c EnvCode) String() string {
+	if name, ok := envNames[c]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseEnvCode converts an environment prefix (as returned in ID()) into its numeric code.
+// Unrecognized strings map to EnvUnknown.
+func ParseEnvCode(s string) EnvCode {
+	for code, name := range envNames {
+		if name == s {
+			return code
+		}
+	}
+	return EnvUnknown
+}
+
+// Environment returns the detected environment type (e.g., "physical", "vm", "docker").
+// This is the same value used as the prefix of ID().
+func Environment() (string, error) {
+	snap, err := load()
+	if err != nil {
+		return "", err
+	}
+	return snap.prefix, nil
+}
+
+// EnvironmentInfo explains the detected environment type.
+type EnvironmentInfo struct {
+	// Type is the environment type, as returned by Environment() (e.g., "vm", "docker").
+	Type string
+	// Tags lists the nested environments, outermost first, e.g. ["aws", "vm", "docker"] for a
+	// Docker container in a KVM guest on EC2. The last tag is always Type. The cloud provider
+	// is only included if machineid/cloud is imported.
+	Tags []string
+	// SubType refines Type when the platform can tell more (e.g., "kvm" for "vm", "2" for
+	// "wsl", "aws-lambda" for "serverless"). It is the suffix of MachineInfo.EnvironmentDetail.
+	SubType string
+	// Evidence lists the checks that matched, in the form "<kind>:<what>" (e.g.,
+	// "file:/.dockerenv", "cgroup:kubepods", "cpuid:KVMKVMKVM"). It is empty when no check
+	// matched and Type is the default of the platform.
+	Evidence []string
+	// Confidence estimates how reliable the classification is, from 0 (a guess) to 1 (fixed by
+	// the platform, e.g., "ios").
+	Confidence float64
+}
+
+// Confidence levels of the environment checks.
+const (
+	confidencePlatform  = 1.0  // The build target only runs there (iOS, browsers, WASI).
+	confidenceMarker    = 0.95 // A file or variable the runtime creates on purpose.
+	confidenceSignature = 0.9  // Firmware, CPU or kernel signatures of a hypervisor or sandbox.
+	confidenceHeuristic = 0.7  // Naming conventions (cgroup paths, mounts, "virtual" products).
+	confidenceDefault   = 0.5  // Nothing matched; the default of the platform.
+)
+
+// envMatch is the outcome of the environment detection: the environment type, the check that
+// classified it and how reliable that check is.
+type envMatch struct {
+	env        string
+	evidence   string
+	confidence float64
+}
+
+// getEnvironmentType returns the detected environment type (see detectEnvironment).
+func getEnvironmentType() string {
+	return detectEnvironment().env
+}
+
+// getEnvironmentDetail returns the refined environment (see detectEnvironmentDetail).
+func getEnvironmentDetail() string {
+	detail, _ := detectEnvironmentDetail()
+	return detail
+}
+
+// DescribeEnvironment returns the detected environment type with the checks that classified it,
+// to troubleshoot a wrong detection. The Type is always the prefix of ID().
+func DescribeEnvironment() (*EnvironmentInfo, error) {
+	snap, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &EnvironmentInfo{Type: snap.prefix, Tags: environmentTags(snap.prefix)}
+	// The prefix is cached, while the checks run again: they only explain it if they agree.
+	if m := detectEnvironment(); m.env == snap.prefix {
+		if m.evidence != "" {
+			info.Evidence = append(info.Evidence, m.evidence)
+		}
+		info.Confidence = m.confidence
+	}
+
+	detail, evidence := environmentDetail(snap.prefix)
+	if sub, ok := strings.CutPrefix(detail, snap.prefix+"/"); ok {
+		info.SubType = sub
+		if evidence != "" && !slices.Contains(info.Evidence, evidence) {
+			info.Evidence = append(info.Evidence, evidence)
+		}
+	}
+	return info, nil
+}
+
+// cloudProvider names the cloud provider for the environment tags. It is nil unless
+// machineid/cloud is imported (see bridge.RegisterCloudProvider).
+var cloudProvider func() string
+
+func init() {
+	bridge.RegisterCloudProvider = func(detect func() string) {
+		cloudProvider = detect
+	}
+}
+
+// environmentTags returns the nested environments of env, outermost first: the cloud provider,
+// the VM or WSL instance hosting a container (known from the environment detail), and env itself,
+// the most specific one.
+func environmentTags(env string) []string {
+	var tags []string
+	if cloudProvider != nil {
+		if provider := cloudProvider(); provider != "" {
+			tags = append(tags, provider)
+		}
+	}
+	detail, _ := detectEnvironmentDetail()
+	if host, _, _ := strings.Cut(detail, "/"); (host == "vm" || host == "wsl") && host != env {
+		tags = append(tags, host)
+	}
+	return append(tags, env)
+}
+
+// EnvironmentCode returns the numeric code of the detected environment type.
+func EnvironmentCode() (EnvCode, error) {
+	env, err := Environment()
+	if err != nil {
+		return EnvUnknown, err
+	}
+	return ParseEnvCode(env), nil
+}
@@ -0,0 +1,54 @@
+package machineid
+
+// BootPrefix is the prefix of BootID. It marks an identifier that rotates at every reboot.
+const BootPrefix = "boot"
+
+// EphemeralPrefix is the prefix of EphemeralID. It marks an identifier scoped to one boot of
+// one sandbox, which must not be used where a machine ID is expected.
+const EphemeralPrefix = "ephemeral"
+
+// SourceBootID names the boot ID in the errors of EphemeralID.
+const SourceBootID = "boot-id"
+
+var getBootIDFunc = getBootID
+
+// EphemeralID returns an identifier for the current boot of the current sandbox, combining the
+// kernel boot ID with the container ID (or the hostname outside of containers).
+//
+// Format: "ephemeral:<hash>"
+//
+// Serverless and per-boot sandboxes (Lambda, Cloud Run) often share the machine ID of their
+// image, or get a new one on every cold start. EphemeralID gives them an identifier whose
+// lifetime is explicit: it is stable until the next reboot or container restart, and never
+// equal to an ID(). Options apply as for ID(). It fails with a *SourceError wrapping
+// os.ErrNotExist on platforms without a boot ID (see BootID).
+func EphemeralID(opts ...Option) (string, error) {
+	boot, err := getBootIDFunc()
+	if err != nil {
+		return "", &SourceError{Source: SourceBootID, Err: err}
+	}
+	return formatID(EphemeralPrefix, domainEphemeralID, []string{boot, containerKeyFunc()}, opts)
+}
+
+// BootID returns an identifier for the current boot of the machine, for callers that want an
+// identifier that intentionally rotates at every reboot (e.g., to detect restarts or scope
+// per-boot state). It is derived from the boot ID of the kernel (/proc/sys/kernel/random/boot_id
+// on Linux, kern.bootsessionuuid on macOS, the boot session number qualified by the MachineGuid
+// on Windows) and the raw machine ID, and is never equal to an ID() or EphemeralID().
+//
+// Format: "boot:<hash>"
+//
+// Options apply as for ID(). It fails with a *SourceError wrapping os.ErrNotExist on platforms
+// without a boot ID.
+func BootID(opts ...Option) (string, error) {
+	boot, err := getBootIDFunc()
+	if err != nil {
+		return "", &SourceError{Source: SourceBootID, Err: err}
+	}
+	o := newOptions(opts)
+	snap, err := o.resolveSnapshot(options.load)
+	if err != nil {
+		return "", err
+	}
+	return formatID(BootPrefix, domainBootID, []string{snap.rawID, boot}, opts)
+}
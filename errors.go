@@ -0,0 +1,26 @@
+package machineid
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrParse is wrapped by errors reporting that a source produced output that couldn't be
+// parsed (e.g., an unexpected ioreg format), as opposed to a missing source (os.ErrNotExist).
+var ErrParse = errors.New("machineid: cannot parse source output")
+
+// SourceError reports the failure of a single source, identified by name
+// (e.g., "ioreg" or "system_profiler"). Use errors.As to inspect it and errors.Is
+// to test the cause (ErrParse, os.ErrNotExist, os.ErrPermission...).
+type SourceError struct {
+	Source string
+	Err    error
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("machineid: source %s: %v", e.Source, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,165 @@
+package machineid
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCommandTimeout bounds every external tool the package runs (ioreg, sysctl,
+// system_profiler, wmic) unless SetCommandTimeout says otherwise.
+const DefaultCommandTimeout = 10 * time.Second
+
+// commandTimeout holds the deadline set with SetCommandTimeout (0 disables it).
+var commandTimeout atomic.Int64
+
+func init() {
+	commandTimeout.Store(int64(DefaultCommandTimeout))
+}
+
+// SetCommandTimeout sets the deadline of the external tools the package runs, so a wedged
+// daemon (e.g., IOKit on a heavily loaded Mac) can't hang ID() forever. A tool that misses it
+// is killed and its source fails with an error wrapping context.DeadlineExceeded; the chain then
+// moves on (e.g., from ioreg to system_profiler). Zero or negative values disable the deadline.
+func SetCommandTimeout(d time.Duration) {
+	commandTimeout.Store(int64(max(d, 0)))
+}
+
+// runCommand executes an external tool and returns its stdout.
+// The locale is pinned to "C" so tools that still print human-readable text
+// don't translate headers or labels on non-English systems.
+func runCommand(name string, args ...string) ([]byte, error) {
+	ctx := context.Background()
+	if d := time.Duration(commandTimeout.Load()); d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C", "LANG=C")
+	// Don't wait for grandchildren still holding stdout once the tool was killed.
+	cmd.WaitDelay = time.Second
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("%s: %w", name, ctxErr)
+		}
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// parsePlistString finds the <string> value for key in an XML property list
+// (as produced by "ioreg -a"), or its <data> value decoded as a NUL-terminated string.
+// The plist format is locale independent, unlike the default ioreg text output.
+// It returns "" if the key is not present.
+func parsePlistString(data []byte, key string) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	// Plist files declare a DOCTYPE; the decoder must not try to resolve it.
+	dec.Strict = false
+
+	matched := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", nil
+			}
+			return "", err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		var text string
+		switch start.Name.Local {
+		case "key":
+			if err := dec.DecodeElement(&text, &start); err != nil {
+				return "", err
+			}
+			matched = text == key
+		case "string":
+			if err := dec.DecodeElement(&text, &start); err != nil {
+				return "", err
+			}
+			if matched {
+				return strings.TrimSpace(text), nil
+			}
+		case "data":
+			// ioreg stores some string properties (e.g., board-id) as NUL-terminated <data>.
+			if err := dec.DecodeElement(&text, &start); err != nil {
+				return "", err
+			}
+			if matched {
+				b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+				if err != nil {
+					return "", err
+				}
+				return strings.TrimSpace(strings.TrimRight(string(b), "\x00")), nil
+			}
+			matched = false
+		default:
+			// Any other value type (dict, data, integer...) breaks the key/value pairing.
+			matched = false
+		}
+	}
+}
+
+// parseWmicValue extracts the value of key from "wmic ... get <key> /value" output.
+// The /value format prints "Key=Value" lines, where Key is the WMI property name
+// and never localized, unlike the column headers of the default table format.
+// It returns the first non-empty value, or "" if none is present.
+func parseWmicValue(out []byte, key string) string {
+	// WMIC often outputs messy encodings (UTF-16 artifacts, null bytes).
+	cleaned := strings.ReplaceAll(string(out), "\x00", "")
+
+	for _, line := range strings.Split(cleaned, "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(k), key) {
+			continue
+		}
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseSystemProfilerHardware extracts the platform UUID and serial number from
+// "system_profiler SPHardwareDataType -json" output. The JSON keys are stable identifiers,
+// unlike the localized labels of the default text output.
+func parseSystemProfilerHardware(data []byte) (uuid, serial string, err error) {
+	var report struct {
+		Hardware []struct {
+			PlatformUUID string `json:"platform_UUID"`
+			SerialNumber string `json:"serial_number"`
+		} `json:"SPHardwareDataType"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return "", "", err
+	}
+
+	for _, hw := range report.Hardware {
+		if uuid == "" {
+			uuid = strings.TrimSpace(hw.PlatformUUID)
+		}
+		if serial == "" {
+			serial = strings.TrimSpace(hw.SerialNumber)
+		}
+	}
+	return uuid, serial, nil
+}
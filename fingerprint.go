@@ -0,0 +1,72 @@
+package machineid
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/banditmoscow1337/machineid/internal/bridge"
+)
+
+// Component names identify the sources of the ID (see MachineInfo.Source) and the
+// components of the experimental fingerprint (machineid/x).
+// These strings are part of the canonical encoding, so they must never change.
+const (
+	ComponentMachineID   = "machine-id"
+	ComponentDMIUUID     = "dmi-uuid"
+	ComponentMAC         = "mac"
+	ComponentDiskSerial  = "disk-serial"
+	ComponentCPU         = "cpu"
+	ComponentBoardSerial = "board-serial"
+)
+
+// Additional source names reported in MachineInfo.Source by platforms with a multi-tier chain.
+const (
+	SourceMachineGuid = "machine-guid" // Windows HKLM\SOFTWARE\Microsoft\Cryptography\MachineGuid.
+	SourceBIOSSerial  = "bios-serial"  // SMBIOS system serial number.
+)
+
+// componentSource pairs a component name with the function resolving its raw value.
+type componentSource struct {
+	name string
+	get  func() (string, error)
+}
+
+// fingerprintSources lists the sources in canonical order.
+// It is a variable so tests can substitute deterministic sources.
+var fingerprintSources = func() []componentSource {
+	return []componentSource{
+		{ComponentMachineID, getMachineIDFunc},
+		{ComponentDMIUUID, getDMIUUID},
+		{ComponentMAC, getHardwareId},
+		{ComponentDiskSerial, getDiskSerial},
+		{ComponentCPU, getCPUInfo},
+		{ComponentBoardSerial, getBoardSerial},
+	}
+}
+
+func init() {
+	bridge.Components = collectComponents
+}
+
+// collectComponents resolves every fingerprint component and hashes its raw value.
+// Individual source failures are recorded per component. The raw values never leave
+// this package; machineid/x builds the experimental Fingerprint API on top of it.
+func collectComponents() []bridge.Component {
+	sources := fingerprintSources()
+	components := make([]bridge.Component, 0, len(sources))
+	for _, src := range sources {
+		c := bridge.Component{Name: src.name}
+
+		raw, err := cachedSourceValue(src.name, src.get)
+		if err == nil && strings.TrimSpace(raw) == "" {
+			err = errors.New("empty value")
+		}
+		if err == nil {
+			c.Hash, err = protect(raw)
+		}
+		c.Err = err
+
+		components = append(components, c)
+	}
+	return components
+}
@@ -0,0 +1,68 @@
+//go:build darwin && !ios
+
+package machineid
+
+import (
+	"errors"
+	"strings"
+)
+
+// getDMIUUID returns the IOPlatformUUID, which is the SMBIOS system UUID on Macs.
+func getDMIUUID() (string, error) {
+	return getMachineID()
+}
+
+// diskControllerClasses lists the IORegistry classes of internal storage controllers, in order.
+// Macs boot from their internal SSD, so the first controller reporting a serial backs the root
+// filesystem: NVMe (Intel and Apple Silicon, AppleANS*) before SATA (older models).
+var diskControllerClasses = []string{"IONVMeController", "AppleANS3NVMeController", "IOAHCIBlockStorageDevice"}
+
+// getDiskSerial returns the serial of the internal (boot) disk from the IORegistry.
+func getDiskSerial() (string, error) {
+	for _, class := range diskControllerClasses {
+		out, err := runCommand("ioreg", "-a", "-r", "-d1", "-c", class)
+		if err != nil || len(out) == 0 {
+			continue
+		}
+		if s, err := parsePlistString(out, "Serial Number"); err == nil && s != "" {
+			return s, nil
+		}
+	}
+	return "", errors.New("no disk serial found in the IORegistry")
+}
+
+// getBoardSerial returns the board-id of the logic board (e.g., "Mac-827FB448E656EC26").
+// Apple doesn't expose the logic board serial; board-id identifies the board model and is only
+// present on Intel Macs.
+func getBoardSerial() (string, error) {
+	out, err := runCommand("ioreg", "-a", "-rd1", "-c", "IOPlatformExpertDevice")
+	if err != nil {
+		return "", err
+	}
+	id, err := parsePlistString(out, "board-id")
+	if err == nil && id == "" {
+		err = errors.New("board-id not found")
+	}
+	return id, err
+}
+
+// getCPUInfo returns the CPU brand string reported by sysctl.
+func getCPUInfo() (string, error) {
+	out, err := runCommand("sysctl", "-n", "machdep.cpu.brand_string")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// getDMIStrings reports Apple as vendor and the model identifier (e.g., "MacBookPro18,3") as product.
+// The family is the model name without its version (e.g., "MacBookPro").
+func getDMIStrings() (DMIInfo, error) {
+	out, err := runCommand("sysctl", "-n", "hw.model")
+	if err != nil {
+		return DMIInfo{}, err
+	}
+	model := strings.TrimSpace(string(out))
+	family := strings.TrimRight(model, "0123456789,")
+	return DMIInfo{Vendor: "Apple Inc.", Product: model, Family: family}, nil
+}
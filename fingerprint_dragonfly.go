@@ -0,0 +1,30 @@
+//go:build dragonfly
+
+package machineid
+
+import "errors"
+
+// getDMIUUID returns the host UUID, which is the SMBIOS system UUID when the firmware provides one.
+func getDMIUUID() (string, error) {
+	return sysctlString("kern.hostuuid")
+}
+
+// getBoardSerial is not implemented on DragonFly BSD yet.
+func getBoardSerial() (string, error) {
+	return "", errors.New("board serial not supported on dragonfly")
+}
+
+// getDiskSerial is not implemented on DragonFly yet.
+func getDiskSerial() (string, error) {
+	return "", errors.New("disk serial not supported on dragonfly")
+}
+
+// getCPUInfo returns the CPU model reported by the hw.model sysctl.
+func getCPUInfo() (string, error) {
+	return sysctlString("hw.model")
+}
+
+// getDMIStrings is not implemented on DragonFly yet.
+func getDMIStrings() (DMIInfo, error) {
+	return DMIInfo{}, errors.New("dmi strings not supported on dragonfly")
+}
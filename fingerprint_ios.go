@@ -0,0 +1,39 @@
+//go:build ios
+
+package machineid
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// getDMIUUID is not available: iOS does not expose the hardware UUID to apps.
+func getDMIUUID() (string, error) {
+	return "", errors.New("dmi uuid not available on ios")
+}
+
+// getBoardSerial: iOS exposes no hardware serials to apps.
+func getBoardSerial() (string, error) {
+	return "", errors.New("board serial not available on ios")
+}
+
+// getDiskSerial is not available: iOS does not expose storage serials to apps.
+func getDiskSerial() (string, error) {
+	return "", errors.New("disk serial not available on ios")
+}
+
+// getCPUInfo returns the hardware model identifier (e.g., "iPhone15,2").
+func getCPUInfo() (string, error) {
+	return unix.Sysctl("hw.machine")
+}
+
+// getDMIStrings reports Apple as vendor and the device model (e.g., "iPhone15,2") as product.
+func getDMIStrings() (DMIInfo, error) {
+	model, err := unix.Sysctl("hw.machine")
+	if err != nil {
+		return DMIInfo{}, err
+	}
+	return DMIInfo{Vendor: "Apple Inc.", Product: model, Family: strings.TrimRight(model, "0123456789,")}, nil
+}
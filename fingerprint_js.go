@@ -0,0 +1,37 @@
+//go:build js
+
+package machineid
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+// getDMIUUID is not available inside the browser sandbox.
+func getDMIUUID() (string, error) {
+	return "", errors.New("dmi uuid not available in the browser")
+}
+
+// getBoardSerial: browsers expose no hardware serials.
+func getBoardSerial() (string, error) {
+	return "", errors.New("board serial not available in the browser")
+}
+
+// getDiskSerial is not available inside the browser sandbox.
+func getDiskSerial() (string, error) {
+	return "", errors.New("disk serial not available in the browser")
+}
+
+// getCPUInfo returns navigator.hardwareConcurrency and navigator.platform.
+func getCPUInfo() (string, error) {
+	nav := js.Global().Get("navigator")
+	if nav.IsUndefined() || nav.IsNull() {
+		return "", errors.New("navigator unavailable")
+	}
+	return nav.Get("hardwareConcurrency").String() + "|" + nav.Get("platform").String(), nil
+}
+
+// getDMIStrings is not available inside the browser sandbox.
+func getDMIStrings() (DMIInfo, error) {
+	return DMIInfo{}, errors.New("dmi strings not available in the browser")
+}
@@ -0,0 +1,160 @@
+//go:build linux
+
+package machineid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// getDMIUUID reads the SMBIOS system UUID exposed by the kernel.
+// Note: product_uuid is readable by root only on most distributions.
+func getDMIUUID() (string, error) {
+	return readFile("/sys/class/dmi/id/product_uuid")
+}
+
+// sysfsRoot is the sysfs mount point. It is a variable so tests can use a fake tree.
+var sysfsRoot = "/sys"
+
+// getDiskSerial returns the serial of the disk backing the root filesystem.
+// If the root disk can't be resolved (e.g., overlayfs in a container), it returns the serial
+// of the first block device (by name) that reports one.
+func getDiskSerial() (string, error) {
+	var st unix.Stat_t
+	if err := unix.Stat("/", &st); err == nil {
+		if s, err := rootDiskSerial(unix.Major(st.Dev), unix.Minor(st.Dev)); err == nil {
+			return s, nil
+		}
+	}
+	return firstDiskSerial()
+}
+
+// rootDiskSerial resolves the block device major:minor to its whole disk, following
+// partitions and device-mapper stacks (LVM, LUKS), and reads the disk serial.
+func rootDiskSerial(major, minor uint32) (string, error) {
+	dev, err := filepath.EvalSymlinks(filepath.Join(sysfsRoot, "dev/block", fmt.Sprintf("%d:%d", major, minor)))
+	if err != nil {
+		return "", err
+	}
+
+	// Device-mapper devices have no serial: descend into the first underlying device.
+	// The depth limit guards against unexpected loops.
+	for depth := 0; depth < 8; depth++ {
+		slaves, err := os.ReadDir(filepath.Join(dev, "slaves"))
+		if err != nil || len(slaves) == 0 {
+			break
+		}
+		if dev, err = filepath.EvalSymlinks(filepath.Join(dev, "slaves", slaves[0].Name())); err != nil {
+			return "", err
+		}
+	}
+
+	// Partitions live in the directory of their disk.
+	if _, err := os.Stat(filepath.Join(dev, "partition")); err == nil {
+		dev = filepath.Dir(dev)
+	}
+
+	if s := blockDeviceSerial(dev); s != "" {
+		return s, nil
+	}
+	return "", fmt.Errorf("no serial for root disk %s", filepath.Base(dev))
+}
+
+// firstDiskSerial returns the serial of the first block device (by name) that reports one.
+func firstDiskSerial() (string, error) {
+	entries, err := os.ReadDir(filepath.Join(sysfsRoot, "block"))
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		// Skip virtual block devices: they have no hardware serial.
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") ||
+			strings.HasPrefix(name, "dm-") || strings.HasPrefix(name, "zram") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if s := blockDeviceSerial(filepath.Join(sysfsRoot, "block", name)); s != "" {
+			return s, nil
+		}
+	}
+	return "", errors.New("no disk serial found")
+}
+
+// blockDeviceSerial reads the serial of a whole-disk sysfs directory.
+// SATA/SCSI disks expose it under device/serial, NVMe namespaces under device/serial of the
+// controller, and virtio disks under serial.
+func blockDeviceSerial(dir string) string {
+	for _, p := range []string{"device/serial", "serial"} {
+		if s, err := readFile(filepath.Join(dir, p)); err == nil && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// getBoardSerial reads the baseboard serial exposed by the kernel (readable by root only).
+func getBoardSerial() (string, error) {
+	s, err := readFile(filepath.Join(sysfsRoot, "class/dmi/id/board_serial"))
+	if err != nil {
+		return "", err
+	}
+	if isPlaceholderSerial(s) {
+		return "", errors.New("placeholder board serial")
+	}
+	return s, nil
+}
+
+// getCPUInfo returns the CPU vendor and model name from /proc/cpuinfo.
+func getCPUInfo() (string, error) {
+	data, err := readFile("/proc/cpuinfo")
+	if err != nil {
+		return "", err
+	}
+
+	var vendor, model string
+	for _, line := range strings.Split(data, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "vendor_id":
+			if vendor == "" {
+				vendor = strings.TrimSpace(value)
+			}
+		case "model name":
+			if model == "" {
+				model = strings.TrimSpace(value)
+			}
+		}
+	}
+
+	if vendor == "" && model == "" {
+		return "", errors.New("cpu info not found")
+	}
+	return vendor + "|" + model, nil
+}
+
+// getDMIStrings reads the system strings exposed by the kernel in sysfs (readable by any user).
+func getDMIStrings() (DMIInfo, error) {
+	vendor, err := readFile("/sys/class/dmi/id/sys_vendor")
+	if err != nil {
+		return DMIInfo{}, err
+	}
+	product, _ := readFile("/sys/class/dmi/id/product_name")
+	family, _ := readFile("/sys/class/dmi/id/product_family")
+	return DMIInfo{Vendor: vendor, Product: product, Family: family}, nil
+}
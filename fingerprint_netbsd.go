@@ -0,0 +1,42 @@
+//go:build netbsd
+
+package machineid
+
+import "errors"
+
+// getDMIUUID returns the SMBIOS system UUID from the machdep.dmi sysctl tree.
+func getDMIUUID() (string, error) {
+	return sysctlString("machdep.dmi.system-uuid")
+}
+
+// getDiskSerial is not implemented on NetBSD yet.
+// getBoardSerial reads the baseboard serial from the machdep.dmi sysctl tree.
+func getBoardSerial() (string, error) {
+	s, err := sysctlString("machdep.dmi.board-serial")
+	if err != nil {
+		return "", err
+	}
+	if isPlaceholderSerial(s) {
+		return "", errors.New("placeholder board serial")
+	}
+	return s, nil
+}
+
+func getDiskSerial() (string, error) {
+	return "", errors.New("disk serial not supported on netbsd")
+}
+
+// getCPUInfo returns the CPU model reported by the machdep.cpu_brand sysctl.
+func getCPUInfo() (string, error) {
+	return sysctlString("machdep.cpu_brand")
+}
+
+// getDMIStrings reads the SMBIOS system strings from the machdep.dmi sysctl tree.
+func getDMIStrings() (DMIInfo, error) {
+	vendor, err := sysctlString("machdep.dmi.system-vendor")
+	if err != nil {
+		return DMIInfo{}, err
+	}
+	product, _ := sysctlString("machdep.dmi.system-product")
+	return DMIInfo{Vendor: vendor, Product: product}, nil
+}
@@ -0,0 +1,35 @@
+//go:build openbsd
+
+package machineid
+
+import "errors"
+
+// getDMIUUID returns the SMBIOS system UUID reported by the hw.uuid sysctl.
+func getDMIUUID() (string, error) {
+	return sysctlString("hw.uuid")
+}
+
+// getBoardSerial is not implemented on OpenBSD yet.
+func getBoardSerial() (string, error) {
+	return "", errors.New("board serial not supported on openbsd")
+}
+
+// getDiskSerial is not implemented on OpenBSD yet.
+func getDiskSerial() (string, error) {
+	return "", errors.New("disk serial not supported on openbsd")
+}
+
+// getCPUInfo returns the CPU model reported by the hw.model sysctl.
+func getCPUInfo() (string, error) {
+	return sysctlString("hw.model")
+}
+
+// getDMIStrings reads the SMBIOS vendor and product from sysctl. OpenBSD has no family node.
+func getDMIStrings() (DMIInfo, error) {
+	vendor, err := sysctlString("hw.vendor")
+	if err != nil {
+		return DMIInfo{}, err
+	}
+	product, _ := sysctlString("hw.product")
+	return DMIInfo{Vendor: vendor, Product: product}, nil
+}
@@ -0,0 +1,25 @@
+//go:build !linux && !darwin && !windows && !openbsd && !netbsd && !dragonfly && !js && !wasip1
+
+package machineid
+
+import "errors"
+
+func getDMIUUID() (string, error) {
+	return "", errors.New("os not supported")
+}
+
+func getBoardSerial() (string, error) {
+	return "", errors.New("os not supported")
+}
+
+func getDiskSerial() (string, error) {
+	return "", errors.New("os not supported")
+}
+
+func getCPUInfo() (string, error) {
+	return "", errors.New("os not supported")
+}
+
+func getDMIStrings() (DMIInfo, error) {
+	return DMIInfo{}, errors.New("os not supported")
+}
@@ -0,0 +1,29 @@
+//go:build wasip1
+
+package machineid
+
+import (
+	"errors"
+	"fmt"
+)
+
+func getDMIUUID() (string, error) {
+	return "", fmt.Errorf("dmi uuid not available in wasi: %w", errors.ErrUnsupported)
+}
+
+// getBoardSerial: WASI modules can't see the hardware.
+func getBoardSerial() (string, error) {
+	return "", fmt.Errorf("board serial not available in wasi: %w", errors.ErrUnsupported)
+}
+
+func getDiskSerial() (string, error) {
+	return "", fmt.Errorf("disk serial not available in wasi: %w", errors.ErrUnsupported)
+}
+
+func getCPUInfo() (string, error) {
+	return "", fmt.Errorf("cpu info not available in wasi: %w", errors.ErrUnsupported)
+}
+
+func getDMIStrings() (DMIInfo, error) {
+	return DMIInfo{}, fmt.Errorf("dmi strings not available in wasi: %w", errors.ErrUnsupported)
+}
@@ -0,0 +1,136 @@
+//go:build windows
+
+package machineid
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// getDMIUUID returns the SMBIOS system UUID read via GetSystemFirmwareTable.
+func getDMIUUID() (string, error) {
+	return getBiosUUID()
+}
+
+// getDiskSerial returns the serial of the physical disk backing the system drive, read via
+// IOCTL_STORAGE_QUERY_PROPERTY. If the disk can't be queried, it returns the serial of the
+// first disk drive reported by WMI.
+func getDiskSerial() (string, error) {
+	if s, err := systemDiskSerial(); err == nil {
+		return s, nil
+	}
+	return getWmic("diskdrive", "serialnumber")
+}
+
+// systemDiskSerial maps the system drive (e.g., C:) to its physical disk and queries its serial.
+func systemDiskSerial() (string, error) {
+	drive := os.Getenv("SystemDrive")
+	if drive == "" {
+		drive = "C:"
+	}
+
+	// 1. Volume -> disk number (the first extent; spanned volumes are rare for system drives).
+	volume, err := openDevice(`\\.\` + drive)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(volume)
+
+	// VOLUME_DISK_EXTENTS: NumberOfDiskExtents (4), padding (4), then DISK_EXTENT entries
+	// starting with DiskNumber.
+	extents := make([]byte, 64)
+	var n uint32
+	if err := windows.DeviceIoControl(volume, ioctlVolumeGetVolumeDiskExtents, nil, 0,
+		&extents[0], uint32(len(extents)), &n, nil); err != nil {
+		return "", err
+	}
+	if n < 12 || binary.LittleEndian.Uint32(extents) == 0 {
+		return "", errors.New("system volume has no disk extents")
+	}
+	disk := binary.LittleEndian.Uint32(extents[8:])
+
+	// 2. Disk -> STORAGE_DEVICE_DESCRIPTOR.
+	h, err := openDevice(fmt.Sprintf(`\\.\PhysicalDrive%d`, disk))
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(h)
+
+	// STORAGE_PROPERTY_QUERY{PropertyId: StorageDeviceProperty, QueryType: PropertyStandardQuery}.
+	query := make([]byte, 12)
+	buf := make([]byte, 1024)
+	if err := windows.DeviceIoControl(h, ioctlStorageQueryProperty, &query[0], uint32(len(query)),
+		&buf[0], uint32(len(buf)), &n, nil); err != nil {
+		return "", err
+	}
+	return parseStorageDeviceSerial(buf[:n])
+}
+
+const (
+	ioctlStorageQueryProperty       = 0x2D1400
+	ioctlVolumeGetVolumeDiskExtents = 0x560000
+)
+
+// openDevice opens a volume or disk for metadata queries only. A zero access mask
+// doesn't require administrator privileges.
+func openDevice(path string) (windows.Handle, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	return windows.CreateFile(p, 0, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
+		nil, windows.OPEN_EXISTING, 0, 0)
+}
+
+// getBoardSerial returns the serial of the Baseboard structure (Type 2) of the SMBIOS table.
+func getBoardSerial() (string, error) {
+	buf, err := getSMBIOSTable()
+	if err != nil {
+		return "", err
+	}
+	raw, err := parseRawSMBIOS(buf)
+	if err != nil {
+		return "", err
+	}
+
+	serial := findSerialInSMBIOS(raw, smbiosBaseboard)
+	if isPlaceholderSerial(serial) {
+		return "", errors.New("no baseboard serial in SMBIOS")
+	}
+	return serial, nil
+}
+
+// getCPUInfo returns the processor vendor and name from the registry.
+func getCPUInfo() (string, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `HARDWARE\DESCRIPTION\System\CentralProcessor\0`, registry.QUERY_VALUE)
+	if err != nil {
+		return "", err
+	}
+	defer k.Close()
+
+	vendor, _, _ := k.GetStringValue("VendorIdentifier")
+	name, _, err := k.GetStringValue("ProcessorNameString")
+	if err != nil {
+		return "", err
+	}
+	return vendor + "|" + name, nil
+}
+
+// getDMIStrings reads the SMBIOS system strings cached by Windows in the registry.
+func getDMIStrings() (DMIInfo, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `HARDWARE\DESCRIPTION\System\BIOS`, registry.QUERY_VALUE)
+	if err != nil {
+		return DMIInfo{}, err
+	}
+	defer k.Close()
+
+	vendor, _, _ := k.GetStringValue("SystemManufacturer")
+	product, _, _ := k.GetStringValue("SystemProductName")
+	family, _, _ := k.GetStringValue("SystemFamily")
+	return DMIInfo{Vendor: vendor, Product: product, Family: family}, nil
+}
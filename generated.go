@@ -0,0 +1,110 @@
+package machineid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// generatedIDs caches the generated IDs by path, so the file is only read once per process.
+var generatedIDs sync.Map
+
+// WithGeneratedFallback makes ID(), ProtectedID() and Info() fall back to a random UUID persisted
+// at path (see GeneratedIDPath) when every source fails, instead of failing on every run on
+// stripped-down systems without a machine-id or a physical NIC. The UUID is generated on the
+// first such run, written atomically under a file lock (so concurrent processes agree on it) and
+// reused afterwards. MachineInfo.Source reports SourcePersisted, and PresetLicensing rejects it.
+func WithGeneratedFallback(path string) Option {
+	return func(o *options) {
+		o.generatedFallback = path
+	}
+}
+
+// GeneratedIDPath returns the conventional location of the generated ID of app:
+// %ProgramData%\<app>\machine-id on Windows, ~/Library/Application Support/<app>/machine-id on
+// macOS and /var/lib/<app>/machine-id elsewhere.
+func GeneratedIDPath(app string) (string, error) {
+	if app == "" || filepath.Base(app) != app {
+		return "", fmt.Errorf("machineid: invalid app name %q", app)
+	}
+
+	var dir string
+	switch runtime.GOOS {
+	case "windows":
+		if dir = os.Getenv("ProgramData"); dir == "" {
+			dir = `C:\ProgramData`
+		}
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, "Library", "Application Support")
+	default:
+		dir = "/var/lib"
+	}
+	return filepath.Join(dir, app, "machine-id"), nil
+}
+
+// applyGeneratedFallback replaces a resolution error with the generated ID, if configured.
+func (o options) applyGeneratedFallback(snap snapshot, err error) (snapshot, error) {
+	if err == nil || o.generatedFallback == "" {
+		return snap, err
+	}
+
+	id, genErr := generatedID(o.generatedFallback)
+	if genErr != nil {
+		return snapshot{}, errors.Join(err, genErr)
+	}
+	warnDegraded("machineid: ID unavailable, using the generated ID", err)
+
+	prefix, _ := cachedSourceValue(SourceEnvironment, func() (string, error) {
+		return getEnvTypeFunc(), nil
+	})
+	// The "generated:" namespace keeps it from ever colliding with a real raw ID.
+	return snapshot{rawID: "generated:" + id, prefix: prefix, source: SourcePersisted}, nil
+}
+
+// generatedID returns the UUID stored at path, generating and saving it on first use.
+func generatedID(path string) (string, error) {
+	if id, ok := generatedIDs.Load(path); ok {
+		return id.(string), nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer lock.Close()
+	unlock, err := lockFile(lock)
+	if err != nil {
+		return "", fmt.Errorf("machineid: lock %s: %w", path, err)
+	}
+	defer unlock()
+
+	store := FileStore(path)
+	data, err := store.Load()
+	id := strings.TrimSpace(string(data))
+	switch {
+	case err == nil && id != "":
+	case err == nil || errors.Is(err, os.ErrNotExist):
+		if id, err = newUUID(); err != nil {
+			return "", err
+		}
+		if err := store.Save([]byte(id + "\n")); err != nil {
+			return "", err
+		}
+	default:
+		return "", err
+	}
+
+	generatedIDs.Store(path, id)
+	return id, nil
+}
@@ -0,0 +1,10 @@
+package machineid
+
+// SourceHostID is reported in MachineInfo.Source when the ID was derived from the
+// gethostid(2) value stored in /etc/hostid (see resolve).
+const SourceHostID = "hostid"
+
+// getLegacyHostIDFunc returns the gethostid(2) value. It is only set on the Unix systems
+// where /etc/hostid is root-owned (see gethostid_unix.go); elsewhere, any user could plant
+// the file, so there is no such fallback.
+var getLegacyHostIDFunc func() (string, error)
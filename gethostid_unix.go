@@ -0,0 +1,38 @@
+//go:build linux || freebsd || openbsd || netbsd || dragonfly
+
+package machineid
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// hostidPath is the file read by glibc's and musl's gethostid(2). ZFS-based systems
+// (zgenhostid) and many embedded images populate it.
+var hostidPath = "/etc/hostid"
+
+func init() {
+	getLegacyHostIDFunc = getLegacyHostID
+}
+
+// getLegacyHostID returns the 32-bit gethostid(2) value from /etc/hostid as 8 hex digits,
+// as printed by hostid(1). It is a last resort: 32 bits are weak, but stable across reboots.
+// We deliberately don't call gethostid(2) itself, because without the file glibc derives the
+// value from the hostname's IP address, which is neither stable nor unique.
+func getLegacyHostID() (string, error) {
+	b, err := os.ReadFile(hostidPath)
+	if err != nil {
+		return "", err
+	}
+	if len(b) < 4 {
+		return "", fmt.Errorf("%s: short hostid (%d bytes)", hostidPath, len(b))
+	}
+
+	// The file holds the value as a native-endian 32-bit integer.
+	id := binary.NativeEndian.Uint32(b[:4])
+	if id == 0 || id == 0xffffffff {
+		return "", fmt.Errorf("%s: unset hostid: %w", hostidPath, os.ErrNotExist)
+	}
+	return fmt.Sprintf("%08x", id), nil
+}
@@ -0,0 +1,24 @@
+package machineid
+
+import "github.com/banditmoscow1337/machineid/internal/hooks"
+
+func init() {
+	hooks.SetMachineID = func(get func() (string, error)) func() {
+		return overrideHook(&getMachineIDFunc, get)
+	}
+	hooks.SetEnvironment = func(get func() string) func() {
+		return overrideHook(&getEnvTypeFunc, get)
+	}
+}
+
+// overrideHook replaces a detection hook for machineid/machineidtest and returns a function
+// restoring it.
+func overrideHook[T any](hook *T, v T) (restore func()) {
+	old := *hook
+	*hook = v
+	Refresh()
+	return func() {
+		*hook = old
+		Refresh()
+	}
+}
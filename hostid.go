@@ -0,0 +1,33 @@
+package machineid
+
+import (
+	"errors"
+	"strings"
+)
+
+var getHostIDFunc = getHostID
+
+// HostID returns the value OpenTelemetry semantic conventions define for the "host.id"
+// resource attribute on the current platform:
+//
+//   - Linux: contents of /etc/machine-id (or /var/lib/dbus/machine-id)
+//   - Windows: HKLM\SOFTWARE\Microsoft\Cryptography\MachineGuid
+//   - macOS: IOPlatformUUID
+//   - BSD: /etc/hostid or the kern.hostuuid / hw.uuid sysctls
+//
+// Unlike ID(), the value is NOT hashed, prefixed, or derived from MAC addresses, because
+// the conventions require the raw platform identifier so it matches what collectors and
+// other agents (e.g., the resourcedetection processor) report for the same host.
+// Prefer ID() or ProtectedID() unless you need that interoperability.
+func HostID() (string, error) {
+	id, err := getHostIDFunc()
+	if err != nil {
+		return "", err
+	}
+
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return "", errors.New("empty host id")
+	}
+	return id, nil
+}
@@ -0,0 +1,78 @@
+package machineid
+
+import "strings"
+
+// dmiHypervisors lists the DMI signatures of common hypervisors. Each entry matches the
+// lowercased system vendor, product name and BIOS vendor; the first match wins.
+var dmiHypervisors = []struct {
+	name  string
+	match func(vendor, product, bios string) bool
+}{
+	// Hyper-V (and Azure) guests report the Microsoft Corporation "Virtual Machine".
+	{"hyperv", func(v, p, _ string) bool {
+		return strings.Contains(v, "microsoft corporation") && strings.Contains(p, "virtual machine")
+	}},
+	// Xen HVM guests report "Xen" as vendor and "HVM domU" as product.
+	{"xen", func(v, p, b string) bool {
+		return strings.Contains(v, "xen") || strings.Contains(b, "xen") || strings.Contains(p, "hvm domu")
+	}},
+	{"firecracker", func(v, p, b string) bool {
+		return strings.Contains(v, "firecracker") || strings.Contains(p, "firecracker") || strings.Contains(b, "firecracker")
+	}},
+	// EC2 Nitro instances report "Amazon EC2". Bare-metal instances (e.g., "m5.metal")
+	// report it as well, but run on the hardware.
+	{"amazon", func(v, p, b string) bool {
+		return (strings.Contains(v, "amazon ec2") || strings.Contains(b, "amazon ec2")) && !strings.HasSuffix(p, ".metal")
+	}},
+	{"bhyve", func(v, p, b string) bool {
+		return strings.Contains(v, "bhyve") || strings.Contains(p, "bhyve") || strings.Contains(b, "bhyve")
+	}},
+	{"vmware", func(v, p, _ string) bool {
+		return strings.Contains(v, "vmware") || strings.Contains(p, "vmware")
+	}},
+	{"virtualbox", func(v, p, _ string) bool {
+		return strings.Contains(v, "innotek") || strings.Contains(p, "virtualbox")
+	}},
+	{"parallels", func(v, p, _ string) bool {
+		return strings.Contains(v, "parallels") || strings.Contains(p, "parallels")
+	}},
+	{"qemu", func(v, p, _ string) bool {
+		return strings.Contains(v, "qemu") || strings.Contains(p, "qemu")
+	}},
+	{"kvm", func(v, p, _ string) bool {
+		return strings.Contains(v, "kvm") || strings.Contains(p, "kvm")
+	}},
+}
+
+// hypervisorFromDMI returns the hypervisor named by the DMI strings (e.g., "xen", "hyperv"),
+// or "" if they don't name one.
+func hypervisorFromDMI(vendor, product, bios string) string {
+	vendor = strings.ToLower(strings.TrimSpace(vendor))
+	product = strings.ToLower(strings.TrimSpace(product))
+	bios = strings.ToLower(strings.TrimSpace(bios))
+	for _, h := range dmiHypervisors {
+		if h.match(vendor, product, bios) {
+			return h.name
+		}
+	}
+	return ""
+}
+
+// cpuidHypervisors maps the vendor signatures of CPUID leaf 0x40000000 to hypervisor names.
+var cpuidHypervisors = map[string]string{
+	"KVMKVMKVM":    "kvm",
+	"Microsoft Hv": "hyperv",
+	"VMwareVMware": "vmware",
+	"XenVMMXenVMM": "xen",
+	"bhyve bhyve ": "bhyve",
+	"VBoxVBoxVBox": "virtualbox",
+	" lrpepyh  vr": "parallels",
+	"TCGTCGTCGTCG": "qemu",
+	"ACRNACRNACRN": "acrn",
+}
+
+// hypervisorFromCPUID returns the hypervisor named by a CPUID vendor signature, or "" if
+// it is unknown.
+func hypervisorFromCPUID(vendor string) string {
+	return cpuidHypervisors[vendor]
+}
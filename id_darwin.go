@@ -1,36 +1,124 @@
-//go:build darwin
+//go:build darwin && !ios
 
 package machineid
 
 import (
-	"bytes"
-	"os/exec"
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// darwinTool records which binary produced the last ID (see machineIDTool).
+	// It is empty when gethostuuid(2) answered.
+	darwinToolMu sync.Mutex
+	darwinTool   string
 )
 
+func init() {
+	machineIDTool = func() string {
+		darwinToolMu.Lock()
+		defer darwinToolMu.Unlock()
+		return darwinTool
+	}
+}
+
 func getMachineID() (string, error) {
-	// Execute: ioreg -rd1 -c IOPlatformExpertDevice | grep IOPlatformUUID
-	cmd := exec.Command("ioreg", "-rd1", "-c", "IOPlatformExpertDevice")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
+	// 1. Priority: gethostuuid(2), which returns the IOPlatformUUID of the
+	// IOPlatformExpertDevice without spawning a process: this works with a sanitized PATH.
+	// (kern.uuid is unrelated: it identifies the kernel build, not the machine.)
+	if id, err := getHostUUID(); err == nil {
+		setDarwinTool("")
+		return id, nil
+	}
+
+	// 2. Fallback: ioreg, for sandboxes denying gethostuuid(2).
+	id, ioregErr := getIORegUUID()
+	if ioregErr == nil {
+		setDarwinTool("ioreg")
+		return id, nil
+	}
+
+	// 3. Last resort: system_profiler, for sandboxes and SIP setups where ioreg is absent or fails.
+	// It is much slower (it queries every hardware data provider), so it is only a fallback.
+	logWarn("machineid: ioreg failed, falling back to system_profiler", "error", ioregErr)
+	id, spErr := getSystemProfilerUUID()
+	if spErr == nil {
+		setDarwinTool("system_profiler")
+		return id, nil
+	}
+
+	// Both tools failed: report it instead of silently using the MAC fallback, which would
+	// change the ID of this machine.
+	return "", errors.Join(ioregErr, spErr)
+}
+
+// getHostUUID returns the platform UUID from gethostuuid(2), formatted like IOPlatformUUID.
+func getHostUUID() (string, error) {
+	var uuid [16]byte
+	// The timeout bounds the wait for the platform expert, which only matters early in boot.
+	ts := unix.Timespec{Sec: 1}
+	_, _, errno := unix.Syscall(unix.SYS_GETHOSTUUID, uintptr(unsafe.Pointer(&uuid[0])), uintptr(unsafe.Pointer(&ts)), 0)
+	if errno != 0 {
+		return "", &SourceError{Source: "gethostuuid", Err: errno}
+	}
+	if uuid == [16]byte{} {
+		return "", &SourceError{Source: "gethostuuid", Err: errors.New("empty host UUID")}
+	}
+	return strings.ToUpper(fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])), nil
+}
+
+// getIORegUUID reads IOPlatformUUID from the IOPlatformExpertDevice registry entry.
+func getIORegUUID() (string, error) {
+	// Execute: ioreg -a -rd1 -c IOPlatformExpertDevice
+	// The -a flag requests XML plist output, which is machine-readable and locale independent.
+	out, err := runCommand("ioreg", "-a", "-rd1", "-c", "IOPlatformExpertDevice")
 	if err != nil {
-		return "", err
+		return "", &SourceError{Source: "ioreg", Err: err}
 	}
 
 	// Parse output to find IOPlatformUUID
-	output := out.String()
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "IOPlatformUUID") {
-			parts := strings.Split(line, "=")
-			if len(parts) == 2 {
-				// Remove quotes and whitespace
-				id := strings.Trim(parts[1], " \"")
-				return id, nil
-			}
-		}
-	}
-
-	return "", nil
-}
\ No newline at end of file
+	id, err := parsePlistString(out, "IOPlatformUUID")
+	if err == nil && id == "" {
+		err = errors.New("IOPlatformUUID not found")
+	}
+	if err != nil {
+		return "", &SourceError{Source: "ioreg", Err: fmt.Errorf("%w: %w", ErrParse, err)}
+	}
+	return id, nil
+}
+
+// getSystemProfilerUUID reads the platform UUID (or, if absent, the serial number)
+// from system_profiler.
+func getSystemProfilerUUID() (string, error) {
+	out, err := runCommand("system_profiler", "SPHardwareDataType", "-json")
+	if err != nil {
+		return "", &SourceError{Source: "system_profiler", Err: err}
+	}
+	uuid, serial, err := parseSystemProfilerHardware(out)
+	if err == nil && uuid == "" {
+		uuid = serial
+	}
+	if err == nil && uuid == "" {
+		err = errors.New("platform_UUID not found")
+	}
+	if err != nil {
+		return "", &SourceError{Source: "system_profiler", Err: fmt.Errorf("%w: %w", ErrParse, err)}
+	}
+	return uuid, nil
+}
+
+func setDarwinTool(tool string) {
+	darwinToolMu.Lock()
+	darwinTool = tool
+	darwinToolMu.Unlock()
+}
+
+// getHostID follows the OpenTelemetry host.id convention for macOS (IOPlatformUUID).
+func getHostID() (string, error) {
+	return getMachineID()
+}
@@ -0,0 +1,30 @@
+//go:build dragonfly
+
+package machineid
+
+import (
+	"os"
+	"strings"
+)
+
+func getMachineID() (string, error) {
+	// kern.hostuuid is taken from the SMBIOS system UUID at boot,
+	// or generated and persisted in /etc/hostid by the rc scripts.
+	uuid, err := sysctlString("kern.hostuuid")
+	uuid = strings.TrimSpace(uuid)
+	if err != nil || isGenericUUID(uuid) {
+		// An empty result makes loadInfo fall back to the MAC hash.
+		return "", nil
+	}
+	return uuid, nil
+}
+
+// getHostID follows the OpenTelemetry host.id convention for BSD (/etc/hostid, then kern.hostuuid).
+func getHostID() (string, error) {
+	if b, err := os.ReadFile("/etc/hostid"); err == nil {
+		if id := strings.TrimSpace(string(b)); id != "" {
+			return id, nil
+		}
+	}
+	return sysctlString("kern.hostuuid")
+}
@@ -0,0 +1,148 @@
+//go:build ios
+
+package machineid
+
+/*
+#cgo LDFLAGS: -framework Security -framework CoreFoundation
+#include <Security/Security.h>
+#include <CoreFoundation/CoreFoundation.h>
+#include <stdlib.h>
+#include <string.h>
+
+// machineid_query builds the generic-password query identifying our keychain item.
+static CFMutableDictionaryRef machineid_query(const char *service, const char *account) {
+	CFMutableDictionaryRef q = CFDictionaryCreateMutable(NULL, 0, &kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	CFStringRef s = CFStringCreateWithCString(NULL, service, kCFStringEncodingUTF8);
+	CFStringRef a = CFStringCreateWithCString(NULL, account, kCFStringEncodingUTF8);
+	CFDictionarySetValue(q, kSecClass, kSecClassGenericPassword);
+	CFDictionarySetValue(q, kSecAttrService, s);
+	CFDictionarySetValue(q, kSecAttrAccount, a);
+	CFRelease(s);
+	CFRelease(a);
+	return q;
+}
+
+// machineid_keychain_get copies the stored value (NUL-terminated) into buf.
+static OSStatus machineid_keychain_get(const char *service, const char *account, char *buf, int size) {
+	CFMutableDictionaryRef q = machineid_query(service, account);
+	CFDictionarySetValue(q, kSecReturnData, kCFBooleanTrue);
+	CFDictionarySetValue(q, kSecMatchLimit, kSecMatchLimitOne);
+
+	CFTypeRef result = NULL;
+	OSStatus st = SecItemCopyMatching(q, &result);
+	CFRelease(q);
+	if (st != errSecSuccess) {
+		return st;
+	}
+
+	CFIndex n = CFDataGetLength((CFDataRef)result);
+	if (n >= size) {
+		n = size - 1;
+	}
+	memcpy(buf, CFDataGetBytePtr((CFDataRef)result), n);
+	buf[n] = 0;
+	CFRelease(result);
+	return errSecSuccess;
+}
+
+// machineid_keychain_add stores value as a device-only keychain item.
+static OSStatus machineid_keychain_add(const char *service, const char *account, const char *value) {
+	CFMutableDictionaryRef q = machineid_query(service, account);
+	CFDataRef data = CFDataCreate(NULL, (const UInt8 *)value, strlen(value));
+	CFDictionarySetValue(q, kSecValueData, data);
+	// ThisDeviceOnly: the item is never synced via iCloud Keychain nor restored onto another device.
+	// AfterFirstUnlock: background launches can read it once the device was unlocked after boot.
+	CFDictionarySetValue(q, kSecAttrAccessible, kSecAttrAccessibleAfterFirstUnlockThisDeviceOnly);
+
+	OSStatus st = SecItemAdd(q, NULL);
+	CFRelease(data);
+	CFRelease(q);
+	return st;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// Keychain item coordinates. The item lives in the app's keychain access group,
+// so it survives app updates.
+const (
+	keychainService = "github.com/banditmoscow1337/machineid"
+	keychainAccount = "machine-id"
+)
+
+// OSStatus values from <Security/SecBase.h>.
+const (
+	errSecItemNotFound  = -25300
+	errSecDuplicateItem = -25299
+)
+
+// keychainMu serializes the read-or-create sequence within the process.
+var keychainMu sync.Mutex
+
+// getMachineID returns a UUID persisted in the keychain, generating it on first run.
+// iOS does not expose any readable hardware identifier to apps.
+func getMachineID() (string, error) {
+	keychainMu.Lock()
+	defer keychainMu.Unlock()
+
+	id, status := keychainGet()
+	if status == 0 {
+		return id, nil
+	}
+	if status != errSecItemNotFound {
+		return "", fmt.Errorf("keychain read failed: OSStatus %d", status)
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return "", err
+	}
+
+	switch status := keychainAdd(id); status {
+	case 0:
+		return id, nil
+	case errSecDuplicateItem:
+		// Another process (e.g., an app extension sharing the access group) won the race.
+		if stored, status := keychainGet(); status == 0 {
+			return stored, nil
+		}
+		return "", fmt.Errorf("keychain read failed: OSStatus %d", status)
+	default:
+		return "", fmt.Errorf("keychain write failed: OSStatus %d", status)
+	}
+}
+
+// getHostID returns the keychain-persisted identifier; there is no OpenTelemetry convention for iOS.
+func getHostID() (string, error) {
+	return getMachineID()
+}
+
+func keychainGet() (string, int) {
+	service := C.CString(keychainService)
+	account := C.CString(keychainAccount)
+	defer C.free(unsafe.Pointer(service))
+	defer C.free(unsafe.Pointer(account))
+
+	var buf [64]C.char
+	status := C.machineid_keychain_get(service, account, &buf[0], C.int(len(buf)))
+	if status != 0 {
+		return "", int(status)
+	}
+	return C.GoString(&buf[0]), 0
+}
+
+func keychainAdd(value string) int {
+	service := C.CString(keychainService)
+	account := C.CString(keychainAccount)
+	v := C.CString(value)
+	defer C.free(unsafe.Pointer(service))
+	defer C.free(unsafe.Pointer(account))
+	defer C.free(unsafe.Pointer(v))
+
+	return int(C.machineid_keychain_add(service, account, v))
+}
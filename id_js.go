@@ -0,0 +1,88 @@
+//go:build js
+
+package machineid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall/js"
+)
+
+// localStorageKey is the key holding the generated identifier.
+const localStorageKey = "github.com/banditmoscow1337/machineid"
+
+// getMachineID returns an identifier persisted in the browser's localStorage, generating it on first use.
+// Browsers expose no hardware identifier, so the value is scoped to the origin and storage profile:
+// clearing site data or using a private window yields a new ID.
+func getMachineID() (string, error) {
+	storage, err := localStorage()
+	if err != nil {
+		// Without persistent storage (e.g., Web Workers, sandboxed iframes, storage disabled) there
+		// is nothing unique to report, so we fail rather than return a value shared by identical setups.
+		return "", fmt.Errorf("%w: %w", err, os.ErrNotExist)
+	}
+
+	v, err := jsGetItem(storage, localStorageKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", err, os.ErrNotExist)
+	}
+	if v != "" {
+		return v, nil
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return "", err
+	}
+	if err := jsCall(storage, "setItem", localStorageKey, id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// getHostID returns the persisted identifier; there is no OpenTelemetry convention for browsers.
+func getHostID() (string, error) {
+	return getMachineID()
+}
+
+// localStorage returns window.localStorage, or an error if it is missing or access throws
+// (Safari and Firefox throw a SecurityError when storage is blocked).
+func localStorage() (storage js.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("localStorage unavailable: %v", r)
+		}
+	}()
+
+	storage = js.Global().Get("localStorage")
+	if storage.IsUndefined() || storage.IsNull() {
+		return js.Value{}, errors.New("localStorage unavailable")
+	}
+	return storage, nil
+}
+
+// jsGetItem reads a localStorage key, converting a thrown JS exception (e.g., a SecurityError in a
+// sandboxed iframe) into an error. A missing key yields an empty string.
+func jsGetItem(storage js.Value, key string) (value string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("getItem failed: %v", r)
+		}
+	}()
+	if v := storage.Call("getItem", key); v.Type() == js.TypeString {
+		return v.String(), nil
+	}
+	return "", nil
+}
+
+// jsCall invokes a method and converts a thrown JS exception (e.g., QuotaExceededError) into an error.
+func jsCall(v js.Value, method string, args ...any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s failed: %v", method, r)
+		}
+	}()
+	v.Call(method, args...)
+	return nil
+}
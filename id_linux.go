@@ -4,33 +4,91 @@ package machineid
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"strings"
 )
 
+// machineIDPaths lists the machine-id locations in priority order: the systemd file, then the
+// D-Bus copy, which is all minimal and older (non-systemd) distributions populate. Only the
+// first one is read unless WithRulesVersion(2) is given.
+var machineIDPaths = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// dmiProductUUIDPath is the opt-in source registered as ComponentDMIUUID (see SetSourcePriority).
+var dmiProductUUIDPath = "/sys/class/dmi/id/product_uuid"
+
 func getMachineID() (string, error) {
 	// We rely on the systemd machine-id file.
 	// This ID is generated at installation (or first boot) and is generally considered
 	// the standard unique ID for Linux systems.
-	id, err := readFile("/etc/machine-id")
+	return readMachineID(machineIDPaths[:1])
+}
+
+// getDBusMachineID reads the D-Bus copy of the machine-id, the secondary machine ID of the
+// version 2 rules.
+func getDBusMachineID() (string, error) {
+	return readMachineID(machineIDPaths[1:])
+}
+
+// readMachineID returns the first machine-id found in paths.
+func readMachineID(paths []string) (string, error) {
+	var err error
+	for _, path := range paths {
+		var id string
+		id, err = readFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			// Try the next location before falling back to the (far less stable) MAC addresses.
+			continue
+		}
+		if err != nil {
+			// IMPORTANT: We return the raw error here.
+			// If it exists but is unreadable (os.ErrPermission), we want the user to know.
+			return "", err
+		}
+
+		if id == "" {
+			return "", errors.New("empty machine-id file")
+		}
+
+		return id, nil
+	}
+
+	// If no file exists (os.ErrNotExist), the caller (loadInfo) handles the fallback logic.
+	return "", err
+}
+
+func init() {
+	optionalSources[ComponentDMIUUID] = getProductUUID
+	getSecondaryMachineIDFunc = getDBusMachineID
+}
+
+// getProductUUID reads the SMBIOS system UUID exposed by the kernel. It is hardware-rooted
+// and survives OS reinstalls, but the file is only readable by root.
+func getProductUUID() (string, error) {
+	id, err := readFile(dmiProductUUIDPath)
 	if err != nil {
-		// IMPORTANT: We return the raw error here.
-		// If the file is missing (os.ErrNotExist), the caller (loadInfo) handles the fallback logic.
-		// If it exists but is unreadable (os.ErrPermission), we want the user to know.
 		return "", err
 	}
 
-	if id == "" {
-		return "", errors.New("empty machine-id file")
+	id = strings.ToLower(id)
+	// Unset UUIDs (all zeros or all ones) are shared by every board of the vendor.
+	if strings.Trim(id, "0-") == "" || strings.Trim(id, "f-") == "" {
+		return "", fmt.Errorf("placeholder product_uuid %q: %w", id, os.ErrNotExist)
 	}
-
 	return id, nil
 }
 
 func readFile(path string) (string, error) {
 	b, err := os.ReadFile(path)
+	logDebug("machineid: read file", "path", path, "error", err)
 	if err != nil {
 		return "", err
 	}
 	return strings.TrimSpace(string(b)), nil
-}
\ No newline at end of file
+}
+
+// getHostID follows the OpenTelemetry host.id convention for Linux, which reads the D-Bus copy
+// if /etc/machine-id is missing.
+func getHostID() (string, error) {
+	return readMachineID(machineIDPaths)
+}
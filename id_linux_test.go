@@ -0,0 +1,700 @@
+//go:build linux
+
+package machineid
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestGetMachineIDDBusFallback(t *testing.T) {
+	dir := t.TempDir()
+	etc := filepath.Join(dir, "etc-machine-id")
+	dbus := filepath.Join(dir, "dbus-machine-id")
+
+	defer func(paths []string) { machineIDPaths = paths }(machineIDPaths)
+	machineIDPaths = []string{etc, dbus}
+
+	// 1. Neither file exists: ErrNotExist, so the caller can use the MAC fallback.
+	if _, err := getMachineID(); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+
+	// 2. Only the D-Bus copy exists: it is the secondary machine ID and the host.id, but the
+	// version 1 rules keep the MAC fallback.
+	if err := os.WriteFile(dbus, []byte("dbus-id\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getMachineID(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if id, err := getDBusMachineID(); err != nil || id != "dbus-id" {
+		t.Errorf("expected dbus-id, got %q, %v", id, err)
+	}
+	if id, err := getHostID(); err != nil || id != "dbus-id" {
+		t.Errorf("expected dbus-id, got %q, %v", id, err)
+	}
+
+	// 3. /etc/machine-id takes precedence.
+	if err := os.WriteFile(etc, []byte("etc-id\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := getMachineID(); err != nil || id != "etc-id" {
+		t.Errorf("expected etc-id, got %q, %v", id, err)
+	}
+	if id, err := getHostID(); err != nil || id != "etc-id" {
+		t.Errorf("expected etc-id, got %q, %v", id, err)
+	}
+
+	// 4. An empty /etc/machine-id is an error, not a reason to skip to the D-Bus copy.
+	if err := os.WriteFile(etc, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getHostID(); err == nil {
+		t.Error("expected an error for an empty machine-id file")
+	}
+}
+
+func TestDBusMachineIDRules(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	dbus := filepath.Join(t.TempDir(), "dbus-machine-id")
+	if err := os.WriteFile(dbus, []byte("dbus-id\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(paths []string) { machineIDPaths = paths }(machineIDPaths)
+	machineIDPaths = []string{filepath.Join(t.TempDir(), "missing"), dbus}
+
+	mac := net.HardwareAddr{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0xcc}
+	netInterfaces = mockInterfaces([]net.Interface{{Name: "Ethernet", HardwareAddr: mac}}, nil)
+	defer func() { netInterfaces = net.Interfaces }()
+
+	// The version 1 rules use the MAC fallback, version 2 the D-Bus copy.
+	if snap, err := load(); err != nil || snap.source != ComponentMAC {
+		t.Fatalf("expected the MAC fallback, got %q, %v", snap.source, err)
+	}
+	snap, err := options{rulesVersion: 2}.load()
+	if err != nil || snap.source != ComponentMachineID || snap.rawID != "dbus-id" {
+		t.Errorf("expected the D-Bus machine-id, got %+v, %v", snap, err)
+	}
+	v1, _ := ID()
+	if v2, err := ID(WithRulesVersion(2)); err != nil || v2 == v1 {
+		t.Errorf("the version 2 rules must derive the ID from the D-Bus machine-id, got %q, %v", v2, err)
+	}
+}
+
+func TestProductUUIDSource(t *testing.T) {
+	resetCache()
+	defer resetCache()
+	defer SetSourcePriority()
+
+	path := filepath.Join(t.TempDir(), "product_uuid")
+	defer func(p string) { dmiProductUUIDPath = p }(dmiProductUUIDPath)
+	dmiProductUUIDPath = path
+
+	getMachineIDFunc = func() (string, error) { return "os-id", nil }
+	defer func() { getMachineIDFunc = getMachineID }()
+
+	if err := SetSourcePriority(ComponentDMIUUID, ComponentMachineID); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1. Unreadable/missing product_uuid: next source in the chain.
+	if snap, err := load(); err != nil || snap.rawID != "os-id" || snap.source != ComponentMachineID {
+		t.Errorf("expected fallback to machine-id, got %+v, %v", snap, err)
+	}
+
+	// 2. Placeholder UUIDs are skipped.
+	os.WriteFile(path, []byte("00000000-0000-0000-0000-000000000000\n"), 0o644)
+	resetCache()
+	if snap, _ := load(); snap.source != ComponentMachineID {
+		t.Errorf("placeholder UUID must be skipped, got source %q", snap.source)
+	}
+
+	// 3. A valid UUID wins and is normalized to lowercase.
+	os.WriteFile(path, []byte("4C4C4544-0042-3510-8052-B4C04F4D4E32\n"), 0o644)
+	resetCache()
+	snap, err := load()
+	if err != nil || snap.rawID != "4c4c4544-0042-3510-8052-b4c04f4d4e32" || snap.source != ComponentDMIUUID {
+		t.Errorf("expected DMI UUID source, got %+v, %v", snap, err)
+	}
+}
+
+func TestRootDiskSerial(t *testing.T) {
+	root := t.TempDir()
+	defer func(r string) { sysfsRoot = r }(sysfsRoot)
+	sysfsRoot = root
+
+	mkdir := func(p string) string {
+		p = filepath.Join(root, p)
+		if err := os.MkdirAll(p, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	write := func(p, v string) {
+		if err := os.WriteFile(filepath.Join(root, p), []byte(v), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := func(target, p string) {
+		if err := os.Symlink(filepath.Join(root, target), filepath.Join(root, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// nvme0n1 (serial via the controller) with partition nvme0n1p2 under an LVM volume dm-0.
+	// sda is another disk with a serial that must not be picked.
+	nvme := "devices/pci0000:00/nvme/nvme0/nvme0n1"
+	mkdir(nvme + "/device")
+	write(nvme+"/device/serial", "S4EWNX0R123456  \n")
+	mkdir(nvme + "/nvme0n1p2")
+	write(nvme+"/nvme0n1p2/partition", "2")
+	mkdir("devices/pci0000:00/ata1/sda/device")
+	write("devices/pci0000:00/ata1/sda/device/serial", "WD-OTHER")
+	mkdir("devices/virtual/block/dm-0/slaves")
+	link(nvme+"/nvme0n1p2", "devices/virtual/block/dm-0/slaves/nvme0n1p2")
+
+	mkdir("dev/block")
+	link(nvme+"/nvme0n1p2", "dev/block/259:2")
+	link("devices/virtual/block/dm-0", "dev/block/253:0")
+
+	for _, dev := range [][2]uint32{{259, 2}, {253, 0}} {
+		got, err := rootDiskSerial(dev[0], dev[1])
+		if err != nil || got != "S4EWNX0R123456" {
+			t.Errorf("rootDiskSerial(%d:%d) = %q, %v", dev[0], dev[1], got, err)
+		}
+	}
+
+	// Unknown devices (e.g., overlayfs) fail, so the caller can scan /sys/block instead.
+	if _, err := rootDiskSerial(0, 42); err == nil {
+		t.Error("expected an error for an unknown device")
+	}
+}
+
+// fakeProcFS serves osReadFile and osStat from files; missing paths report os.ErrNotExist.
+func fakeProcFS(t *testing.T, files map[string]string) {
+	t.Helper()
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	saDir, probe := kubernetesServiceAccountDir, hypervisorCPUID
+	hypervisorCPUID = func() (bool, string) { return false, "" }
+	kubernetesServiceAccountDir = filepath.Join(t.TempDir(), "serviceaccount")
+	osReadFile = func(name string) ([]byte, error) {
+		if data, ok := files[name]; ok {
+			return []byte(data), nil
+		}
+		return nil, os.ErrNotExist
+	}
+	osStat = func(name string) (os.FileInfo, error) {
+		if _, ok := files[name]; ok {
+			return nil, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	t.Cleanup(func() {
+		osReadFile = os.ReadFile
+		osStat = os.Stat
+		kubernetesServiceAccountDir = saDir
+		hypervisorCPUID = probe
+	})
+}
+
+func TestDetectWSL(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]string
+		wantType   string
+		wantDetail string
+	}{
+		{
+			name:       "WSL1",
+			files:      map[string]string{"/proc/version": "Linux version 4.4.0-19041-Microsoft (Microsoft@Microsoft.com)"},
+			wantType:   "wsl",
+			wantDetail: "wsl/1",
+		},
+		{
+			name:       "WSL2",
+			files:      map[string]string{"/proc/version": "Linux version 5.15.153.1-microsoft-standard-WSL2 (root@941d701f84f1)"},
+			wantType:   "wsl",
+			wantDetail: "wsl/2",
+		},
+		{
+			name:       "interop only",
+			files:      map[string]string{"/proc/sys/fs/binfmt_misc/WSLInterop": ""},
+			wantType:   "wsl",
+			wantDetail: "wsl/2",
+		},
+		{
+			name: "container on WSL2",
+			files: map[string]string{
+				"/.dockerenv":   "",
+				"/proc/version": "Linux version 5.15.153.1-microsoft-standard-WSL2",
+			},
+			wantType:   "docker",
+			wantDetail: "wsl/2",
+		},
+		{
+			name:     "native Linux",
+			files:    map[string]string{"/proc/version": "Linux version 6.8.0-45-generic (buildd@lcy02-amd64-115)"},
+			wantType: "physical",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeProcFS(t, tt.files)
+			if got := getEnvironmentType(); got != tt.wantType {
+				t.Errorf("expected type %q, got %q", tt.wantType, got)
+			}
+			if got := getEnvironmentDetail(); got != tt.wantDetail {
+				t.Errorf("expected detail %q, got %q", tt.wantDetail, got)
+			}
+		})
+	}
+}
+
+func TestContainerRuntimes(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"podman", map[string]string{"/run/.containerenv": ""}, "podman"},
+		{"lxc environ", map[string]string{"/proc/1/environ": "PATH=/usr/bin\x00container=lxc\x00"}, "lxc"},
+		{"nspawn marker", map[string]string{"/run/systemd/container": "systemd-nspawn\n"}, "nspawn"},
+		{"unknown manager", map[string]string{"/run/systemd/container": "rkt\n"}, "container"},
+		{"kubernetes cri-o", map[string]string{"/proc/1/cgroup": "0::/kubepods.slice/kubepods-besteffort.slice/crio-4f1e.scope"}, "kubernetes"},
+		{"cri-o", map[string]string{"/proc/1/cgroup": "0::/machine.slice/crio-4f1e.scope"}, "crio"},
+		{"containerd", map[string]string{"/proc/1/cgroup": "0::/system.slice/containerd.service/default/app"}, "containerd"},
+		{"garden", map[string]string{"/proc/1/cgroup": "4:memory:/garden/8d8a7c1e"}, "garden"},
+		{"lxc cgroup", map[string]string{"/proc/1/cgroup": "0::/lxc.payload.web01"}, "lxc"},
+		{
+			name: "systemd on WSL",
+			files: map[string]string{
+				"/run/systemd/container": "wsl\n",
+				"/proc/version":          "Linux version 5.15.153.1-microsoft-standard-WSL2",
+			},
+			want: "wsl",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeProcFS(t, tt.files)
+			got := getEnvironmentType()
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+			if ParseEnvCode(got) == EnvUnknown {
+				t.Errorf("environment %q has no EnvCode", got)
+			}
+		})
+	}
+}
+
+func TestKubernetes(t *testing.T) {
+	fakeProcFS(t, map[string]string{"/.dockerenv": ""})
+	if got := getEnvironmentType(); got != "docker" {
+		t.Fatalf("expected docker outside Kubernetes, got %q", got)
+	}
+
+	// The service account mount alone identifies a pod.
+	if err := os.MkdirAll(kubernetesServiceAccountDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(kubernetesServiceAccountDir, "namespace"), []byte("payments\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := getEnvironmentType(); got != "kubernetes" {
+		t.Errorf("expected kubernetes, got %q", got)
+	}
+
+	t.Setenv("POD_NAME", "api-7d9f8-x2x4q")
+	t.Setenv("NODE_NAME", "")
+	k := readKubernetes(SanitizeNone)
+	if k == nil || k.Namespace != "payments" || k.Pod != "api-7d9f8-x2x4q" || k.Node != "" {
+		t.Errorf("unexpected metadata: %+v", k)
+	}
+
+	// The downward API takes precedence over the mount.
+	t.Setenv("POD_NAMESPACE", "billing")
+	if k := readKubernetes(SanitizeRedacted); k.Namespace != RedactedValue || k.Node != "" {
+		t.Errorf("unexpected metadata: %+v", k)
+	}
+}
+
+func TestCgroupV2Containers(t *testing.T) {
+	const controllers = "/sys/fs/cgroup/cgroup.controllers"
+	overlayRoot := "1029 978 0:112 / / rw,relatime master:527 - overlay overlay rw,lowerdir=/var/lib/x/l/AB:/var/lib/x/l/CD\n" +
+		"1031 1029 0:115 / /proc rw,nosuid,nodev,noexec,relatime - proc proc rw\n"
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "docker",
+			files: map[string]string{
+				controllers:            "cpu memory",
+				"/proc/1/cgroup":       "0::/",
+				"/proc/self/mountinfo": "1106 1029 8:1 /var/lib/docker/containers/4f1e/hostname /etc/hostname rw - ext4 /dev/sda1 rw\n",
+			},
+			want: "container",
+		},
+		{
+			name: "kubelet",
+			files: map[string]string{
+				controllers:            "cpu memory",
+				"/proc/self/mountinfo": "2240 2201 8:1 /var/lib/kubelet/pods/9c1e/etc-hosts /etc/hosts rw - ext4 /dev/sda1 rw\n",
+			},
+			want: "kubernetes",
+		},
+		{
+			name: "overlay root in a cgroup namespace",
+			files: map[string]string{
+				controllers:            "cpu memory",
+				"/proc/1/cgroup":       "0::/\n",
+				"/proc/self/mountinfo": overlayRoot,
+			},
+			want: "container",
+		},
+		{
+			name: "overlay root on a host",
+			files: map[string]string{
+				controllers:            "cpu memory",
+				"/proc/1/cgroup":       "0::/init.scope\n",
+				"/proc/self/mountinfo": overlayRoot,
+			},
+			want: "physical",
+		},
+		{
+			name: "docker host",
+			files: map[string]string{
+				controllers:            "cpu memory",
+				"/proc/1/cgroup":       "0::/init.scope\n",
+				"/proc/self/mountinfo": "412 29 0:58 / /var/lib/docker/containers/4f1e/mounts/shm rw - tmpfs shm rw\n",
+			},
+			want: "physical",
+		},
+		{
+			name: "cgroup v1 host",
+			files: map[string]string{
+				"/proc/self/mountinfo": "1106 1029 8:1 /var/lib/docker/containers/4f1e/hostname /etc/hostname rw - ext4 /dev/sda1 rw\n",
+			},
+			want: "physical",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeProcFS(t, tt.files)
+			if got := getEnvironmentType(); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHypervisors(t *testing.T) {
+	const dmi = "/sys/class/dmi/id/"
+	tests := []struct {
+		name       string
+		files      map[string]string
+		wantType   string
+		wantDetail string
+	}{
+		{"xen pv", map[string]string{"/sys/hypervisor/type": "xen\n"}, "vm", "vm/xen"},
+		{"xen dom0", map[string]string{"/sys/hypervisor/type": "xen\n", "/proc/xen/capabilities": "control_d\n"}, "physical", ""},
+		{"xen hvm", map[string]string{dmi + "sys_vendor": "Xen\n", dmi + "product_name": "HVM domU\n"}, "vm", "vm/xen"},
+		{"hyper-v", map[string]string{dmi + "sys_vendor": "Microsoft Corporation\n", dmi + "product_name": "Virtual Machine\n"}, "vm", "vm/hyperv"},
+		{"ec2 nitro", map[string]string{dmi + "sys_vendor": "Amazon EC2\n", dmi + "product_name": "m5.large\n"}, "vm", "vm/amazon"},
+		{"ec2 metal", map[string]string{dmi + "sys_vendor": "Amazon EC2\n", dmi + "product_name": "m5.metal\n"}, "physical", ""},
+		{"bhyve", map[string]string{dmi + "sys_vendor": "FreeBSD\n", dmi + "product_name": "BHYVE\n"}, "vm", "vm/bhyve"},
+		{"kvm", map[string]string{dmi + "sys_vendor": "QEMU\n", dmi + "product_name": "Standard PC (Q35 + ICH9, 2009)\n"}, "vm", "vm/qemu"},
+		{"firecracker", map[string]string{"/proc/cmdline": "console=ttyS0 reboot=k virtio_mmio.device=4K@0xd0000000:5\n"}, "vm", "vm/firecracker"},
+		{"gvisor", map[string]string{"/proc/version": "Linux version 4.4.0 #1 SMP Sun Jan 10 15:06:54 PST 2016\n"}, "container", "container/gvisor"},
+		{"dell", map[string]string{dmi + "sys_vendor": "Dell Inc.\n", dmi + "product_name": "PowerEdge R740\n"}, "physical", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeProcFS(t, tt.files)
+			if got := getEnvironmentType(); got != tt.wantType {
+				t.Errorf("expected type %q, got %q", tt.wantType, got)
+			}
+			if got := getEnvironmentDetail(); got != tt.wantDetail {
+				t.Errorf("expected detail %q, got %q", tt.wantDetail, got)
+			}
+		})
+	}
+}
+
+func TestHypervisorCPUID(t *testing.T) {
+	probe := hypervisorCPUID
+	fakeProcFS(t, nil)
+
+	// Without readable DMI strings, the CPUID leaves still identify the VM.
+	hypervisorCPUID = func() (bool, string) { return true, "KVMKVMKVM" }
+	if got, detail := getEnvironmentType(), getEnvironmentDetail(); got != "vm" || detail != "vm/kvm" {
+		t.Errorf("expected vm and vm/kvm, got %q and %q", got, detail)
+	}
+
+	// An unknown hypervisor is still a VM, without detail.
+	hypervisorCPUID = func() (bool, string) { return true, "NewHVNewHVNe" }
+	if got, detail := getEnvironmentType(), getEnvironmentDetail(); got != "vm" || detail != "" {
+		t.Errorf("expected vm without detail, got %q and %q", got, detail)
+	}
+
+	// The real probe must not crash.
+	if present, vendor := probe(); present {
+		t.Logf("hypervisor %q (%s)", vendor, hypervisorFromCPUID(vendor))
+	}
+}
+
+func TestDetectEnvironmentEvidence(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        map[string]string
+		wantEnv      string
+		wantEvidence string
+		wantConf     float64
+	}{
+		{"Docker", map[string]string{"/.dockerenv": ""}, "docker", "file:/.dockerenv", confidenceMarker},
+		{"Nspawn", map[string]string{"/run/systemd/container": "systemd-nspawn\n"}, "nspawn", "file:/run/systemd/container=systemd-nspawn", confidenceMarker},
+		{"Cgroup_CRIO", map[string]string{"/proc/1/cgroup": "0::/system.slice/crio-4f1c.scope\n"}, "crio", "cgroup:crio", confidenceHeuristic},
+		{"Xen", map[string]string{"/sys/hypervisor/type": "xen\n"}, "vm", "sysfs:/sys/hypervisor/type=xen", confidenceSignature},
+		{"Physical", nil, "physical", "", confidenceDefault},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeProcFS(t, tt.files)
+			if got := detectEnvironment(); got != (envMatch{tt.wantEnv, tt.wantEvidence, tt.wantConf}) {
+				t.Errorf("detectEnvironment() = %+v, want {%s %s %v}", got, tt.wantEnv, tt.wantEvidence, tt.wantConf)
+			}
+		})
+	}
+}
+
+func TestDescribeEnvironment_Linux(t *testing.T) {
+	resetCache()
+	defer resetCache()
+	fakeProcFS(t, map[string]string{"/sys/hypervisor/type": "xen\n"})
+	getMachineIDFunc = func() (string, error) { return "test-id", nil }
+	defer func() { getMachineIDFunc = getMachineID }()
+
+	info, err := DescribeEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := EnvironmentInfo{Type: "vm", Tags: []string{"vm"}, SubType: "xen", Evidence: []string{"sysfs:/sys/hypervisor/type=xen"}, Confidence: confidenceSignature}
+	if !reflect.DeepEqual(*info, want) {
+		t.Errorf("DescribeEnvironment() = %+v, want %+v", *info, want)
+	}
+}
+
+func TestEnvironmentTags(t *testing.T) {
+	resetCache()
+	defer resetCache()
+	fakeProcFS(t, map[string]string{"/.dockerenv": "", "/sys/hypervisor/type": "xen\n"})
+	getMachineIDFunc = func() (string, error) { return "test-id", nil }
+	defer func() {
+		getMachineIDFunc = getMachineID
+		cloudProvider = nil
+	}()
+
+	// A Docker container in a Xen guest on EC2.
+	cloudProvider = func() string { return "aws" }
+	info, err := DescribeEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"aws", "vm", "docker"}; !reflect.DeepEqual(info.Tags, want) {
+		t.Errorf("Tags = %q, want %q", info.Tags, want)
+	}
+	if env, _ := Environment(); info.Tags[len(info.Tags)-1] != env {
+		t.Errorf("the last tag must be the prefix %q, got %q", env, info.Tags)
+	}
+
+	// On-premises, without a VM layer.
+	cloudProvider = func() string { return "" }
+	fakeProcFS(t, map[string]string{"/.dockerenv": ""})
+	resetCache()
+	if info, _ = DescribeEnvironment(); !reflect.DeepEqual(info.Tags, []string{"docker"}) {
+		t.Errorf("Tags = %q, want [docker]", info.Tags)
+	}
+}
+
+func TestServerless(t *testing.T) {
+	// Lambda functions run on Firecracker: the platform wins over the hypervisor.
+	fakeProcFS(t, map[string]string{"/proc/cmdline": "console=ttyS0 virtio_mmio.device=4K@0xd0000000:5\n"})
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "resize")
+	if got, detail := getEnvironmentType(), getEnvironmentDetail(); got != "serverless" || detail != "serverless/aws-lambda" {
+		t.Errorf("expected serverless and serverless/aws-lambda, got %q and %q", got, detail)
+	}
+	if isContainer("serverless") {
+		t.Error("serverless must not be a container class")
+	}
+}
+
+func TestPermanentAddresses(t *testing.T) {
+	current, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	interfaces := withPermanentAddresses(current)
+	if len(interfaces) != len(current) {
+		t.Fatalf("expected %d interfaces, got %d", len(current), len(interfaces))
+	}
+
+	for i, iface := range interfaces {
+		if iface.Name != current[i].Name {
+			t.Errorf("interface %d renamed: %s -> %s", i, current[i].Name, iface.Name)
+		}
+		// A permanent address replaces the current one only if it has the same length.
+		if len(iface.HardwareAddr) != len(current[i].HardwareAddr) {
+			t.Errorf("%s: unexpected address %s", iface.Name, iface.HardwareAddr)
+		}
+		if iface.Flags&net.FlagLoopback != 0 && !slices.Equal(iface.HardwareAddr, current[i].HardwareAddr) {
+			t.Errorf("%s: the loopback address must be kept", iface.Name)
+		}
+	}
+
+	if addr := permanentAddress(net.Interface{Name: "does-not-exist0"}); addr != nil {
+		t.Errorf("expected no address for a missing interface, got %s", addr)
+	}
+}
+
+func TestVirtualDevices(t *testing.T) {
+	dir := t.TempDir()
+	defer func(p string) { sysClassNetPath = p }(sysClassNetPath)
+	sysClassNetPath = dir
+
+	// enp3s0 is backed by a PCI device; br0 is a bridge.
+	for name, index := range map[string]string{"enp3s0": "2", "br0": "3"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "ifindex"), []byte(index+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "enp3s0", "device"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	mac := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	addrs := filterHardwareAddresses([]net.Interface{
+		{Index: 2, Name: "enp3s0", HardwareAddr: mac},
+		{Index: 3, Name: "br0", HardwareAddr: mac},
+		{Index: 4, Name: "bond0", HardwareAddr: mac},
+		{Index: 5, Name: "wg0", HardwareAddr: mac},
+		// Listed in sysfs under another index (another network namespace): not trusted.
+		{Index: 9, Name: "br0", HardwareAddr: mac},
+	}, options{rulesVersion: 2})
+	want := []HardwareAddress{{Interface: "br0", MAC: mac.String()}, {Interface: "enp3s0", MAC: mac.String()}}
+	if !reflect.DeepEqual(addrs, want) {
+		t.Errorf("expected %v, got %v", want, addrs)
+	}
+
+	if v1 := filterHardwareAddresses([]net.Interface{{Index: 2, Name: "enp3s0", HardwareAddr: mac}, {Index: 3, Name: "br0", HardwareAddr: mac}}, options{}); len(v1) != 2 {
+		t.Errorf("the version 1 rules must keep devices without a backing device, got %v", v1)
+	}
+	if all := filterHardwareAddresses([]net.Interface{{Index: 3, Name: "br0", HardwareAddr: mac}, {Index: 2, Name: "enp3s0", HardwareAddr: mac}}, options{rulesVersion: 2, virtualInterfaces: true}); len(all) != 2 {
+		t.Errorf("WithVirtualInterfaces must keep devices without a backing device, got %v", all)
+	}
+}
+
+func TestVirtualDevicesInContainer(t *testing.T) {
+	dir := t.TempDir()
+	defer func(p string) { sysClassNetPath = p }(sysClassNetPath)
+	sysClassNetPath = dir
+
+	// In a container, eth0 is the end of a veth pair: it has no backing device, like lo.
+	for name, index := range map[string]string{"lo": "1", "eth0": "12"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "ifindex"), []byte(index+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mac := net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}
+	addrs := filterHardwareAddresses([]net.Interface{
+		{Index: 1, Name: "lo", Flags: net.FlagLoopback},
+		{Index: 12, Name: "eth0", HardwareAddr: mac},
+	}, options{rulesVersion: 2})
+	want := []HardwareAddress{{Interface: "eth0", MAC: mac.String()}}
+	if !reflect.DeepEqual(addrs, want) {
+		t.Errorf("the interfaces of a container must be kept, expected %v, got %v", want, addrs)
+	}
+}
+
+func TestDefaultRouteInterfaces(t *testing.T) {
+	dir := t.TempDir()
+	defer func(p [2]string) { procNetRoutePaths = p }(procNetRoutePaths)
+	procNetRoutePaths = [2]string{filepath.Join(dir, "route"), filepath.Join(dir, "ipv6_route")}
+
+	route := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		"eth0\t00000000\t0100A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n" +
+		"eth1\t0000A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n"
+	ipv6Route := "00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003     wlan0\n" +
+		"00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo\n" +
+		"fe800000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth1\n"
+	if err := os.WriteFile(procNetRoutePaths[0], []byte(route), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(procNetRoutePaths[1], []byte(ipv6Route), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{"eth0": true, "wlan0": true}
+	if got := defaultRouteInterfaces(); !reflect.DeepEqual(got, want) {
+		t.Errorf("defaultRouteInterfaces() = %v, want %v", got, want)
+	}
+}
+
+func TestLegacyHostIDFallback(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	path := filepath.Join(t.TempDir(), "hostid")
+	defer func(p string) { hostidPath = p }(hostidPath)
+	hostidPath = path
+
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	netInterfaces = mockInterfaces(nil, nil)
+	defer func() {
+		getMachineIDFunc = getMachineID
+		netInterfaces = net.Interfaces
+	}()
+
+	// 1. No machine-id, no MACs, no hostid: error.
+	if _, err := ID(); err == nil {
+		t.Fatal("expected an error without any source")
+	}
+
+	// 2. An unset hostid is ignored.
+	os.WriteFile(path, []byte{0, 0, 0, 0}, 0o644)
+	if _, err := ID(); err == nil {
+		t.Error("expected an error for an unset hostid")
+	}
+
+	// 3. A valid hostid rescues the resolution.
+	want := make([]byte, 4)
+	binary.NativeEndian.PutUint32(want, 0x00a8c0de)
+	os.WriteFile(path, want, 0o644)
+	snap, err := load()
+	if err != nil || snap.rawID != "00a8c0de" || snap.source != SourceHostID {
+		t.Errorf("expected hostid source, got %+v, %v", snap, err)
+	}
+}
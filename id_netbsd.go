@@ -0,0 +1,22 @@
+//go:build netbsd
+
+package machineid
+
+import "strings"
+
+func getMachineID() (string, error) {
+	// NetBSD exposes the SMBIOS tables through the machdep.dmi sysctl tree.
+	// The node only exists on platforms with SMBIOS (e.g., amd64, i386).
+	uuid, err := sysctlString("machdep.dmi.system-uuid")
+	uuid = strings.TrimSpace(uuid)
+	if err != nil || isGenericUUID(uuid) {
+		// An empty result makes loadInfo fall back to the MAC hash.
+		return "", nil
+	}
+	return uuid, nil
+}
+
+// getHostID returns the SMBIOS system UUID, the closest equivalent of the BSD host.id sources.
+func getHostID() (string, error) {
+	return sysctlString("machdep.dmi.system-uuid")
+}
@@ -0,0 +1,37 @@
+//go:build openbsd
+
+package machineid
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func getMachineID() (string, error) {
+	// 1. Priority: hw.uuid
+	// This is the SMBIOS system UUID reported by the firmware. It persists across re-installs.
+	uuid, err := sysctlString("hw.uuid")
+	uuid = strings.TrimSpace(uuid)
+	if err == nil && uuid != "" && !isGenericUUID(uuid) {
+		return uuid, nil
+	}
+
+	// 2. Fallback: hw.serialno
+	// The system serial number from SMBIOS. Not every board fills it in, and many report an OEM
+	// placeholder (e.g., "To Be Filled By O.E.M.") shared by every board of the model.
+	serial, err := sysctlString("hw.serialno")
+	if err != nil {
+		// An empty result makes loadInfo fall back to the MAC hash.
+		return "", nil
+	}
+	if isPlaceholderSerial(serial) {
+		return "", fmt.Errorf("hw.serialno: placeholder %q: %w", strings.TrimSpace(serial), os.ErrNotExist)
+	}
+	return strings.TrimSpace(serial), nil
+}
+
+// getHostID returns the hw.uuid sysctl, the closest equivalent of the BSD host.id sources.
+func getHostID() (string, error) {
+	return sysctlString("hw.uuid")
+}
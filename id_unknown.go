@@ -1,4 +1,4 @@
-//go:build !linux && !darwin && !windows
+//go:build !linux && !darwin && !windows && !openbsd && !netbsd && !dragonfly && !js && !wasip1
 
 package machineid
 
@@ -6,4 +6,8 @@ import "errors"
 
 func getMachineID() (string, error) {
 	return "", errors.New("os not supported")
-}
\ No newline at end of file
+}
+
+func getHostID() (string, error) {
+	return "", errors.New("os not supported")
+}
@@ -0,0 +1,56 @@
+//go:build wasip1
+
+package machineid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// WASI gives modules no access to hardware, so the host runtime has to inject the identity.
+// It can do so through the environment or through a file in a preopened directory.
+const (
+	// wasiIDEnv holds the identifier itself.
+	wasiIDEnv = "MACHINEID"
+	// wasiFileEnv holds the path of a file containing the identifier.
+	wasiFileEnv = "MACHINEID_FILE"
+	// wasiDefaultFile is read when the host preopens /etc and no override is set.
+	wasiDefaultFile = "/etc/machine-id"
+)
+
+func getMachineID() (string, error) {
+	// 1. Priority: Explicit value injected by the host.
+	if id := strings.TrimSpace(os.Getenv(wasiIDEnv)); id != "" {
+		return id, nil
+	}
+
+	// 2. File named by the host.
+	if path := os.Getenv(wasiFileEnv); path != "" {
+		// The host asked for this file explicitly, so any error is reported as-is.
+		return readFile(path)
+	}
+
+	// 3. Conventional machine-id file, if the host preopened it.
+	id, err := readFile(wasiDefaultFile)
+	if err == nil && id != "" {
+		return id, nil
+	}
+
+	// We deliberately do not return os.ErrNotExist: the MAC fallback can't work in WASI either.
+	return "", fmt.Errorf("no identifier injected by the host (set %s or %s): %w", wasiIDEnv, wasiFileEnv, errors.ErrUnsupported)
+}
+
+// getHostID returns the host-injected identifier; there is no OpenTelemetry convention for WASI.
+func getHostID() (string, error) {
+	return getMachineID()
+}
+
+func readFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
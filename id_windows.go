@@ -3,181 +3,194 @@
 package machineid
 
 import (
-	"bytes"
 	"fmt"
-	"os/exec"
 	"strings"
+	"sync"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
 	"golang.org/x/sys/windows/registry"
 )
 
-// getMachineID attempts to find a stable, unique identifier for the Windows machine.
-// It follows a strict priority order to balance stability (persistence across re-installs) vs. availability.
-func getMachineID() (string, error) {
-	// 1. Priority: Motherboard UUID (SMBIOS)
-	// This is burned into the hardware. It is the most stable ID as it persists
-	// even if Windows is completely re-installed.
-	// We use the native Windows API (GetSystemFirmwareTable) to read this, avoiding external CLI calls like 'wmic'.
-	uuid, err := getBiosUUID()
-	if err == nil && uuid != "" && uuid != "FFFFFFFF-FFFF-FFFF-FFFF-FFFFFFFFFFFF" {
-		return uuid, nil
-	}
+var (
+	// windowsSource records which tier of the chain produced the last ID (see machineIDSource).
+	windowsSourceMu sync.Mutex
+	windowsSource   = ComponentDMIUUID
+)
 
-	// 2. Fallback: Disk Serial Number
-	// If BIOS UUID is missing or generic (all F's), we try the primary disk's serial number.
-	// This also typically persists across OS re-installs.
-	disk, err := getWmic("diskdrive", "serialnumber")
-	if err == nil && disk != "" {
-		return disk, nil
+func init() {
+	getMachineIDV2Func = getMachineIDV2
+	machineIDSource = func() string {
+		windowsSourceMu.Lock()
+		defer windowsSourceMu.Unlock()
+		return windowsSource
 	}
+}
 
-	// 3. Last Resort: Registry MachineGuid
-	// Located at HKLM\SOFTWARE\Microsoft\Cryptography\MachineGuid.
-	// This ID is generated by Windows during installation. It is unique, but it WILL change
-	// if the user re-installs Windows.
-	return getRegistryID()
+// windowsTier is one tier of a machine ID chain.
+type windowsTier struct {
+	source string
+	get    func() (string, error)
 }
 
-// getBiosUUID fetches the machine UUID from the SMBIOS firmware table using the Windows API.
-// Reference: https://docs.microsoft.com/en-us/windows/win32/api/sysinfoapi/nf-sysinfoapi-getsystemfirmwaretable
-func getBiosUUID() (string, error) {
-	// 'RSMB' is the Little-Endian signature for the Raw SMBIOS provider (0x52534D42).
-	const rsmb = 0x52534D42
+// getMachineID attempts to find a stable, unique identifier for the Windows machine.
+// It walks an ordered chain; a failing tier (e.g., a registry read blocked by a locked-down
+// policy) moves on to the next tier instead of failing or immediately dropping to MAC addresses.
+// If every tier fails, "" is returned and loadInfo falls back to the MAC hash.
+//
+// This is the chain of the version 1 rules. It favors stability across re-installs; see
+// getMachineIDV2 for the chain of the version 2 rules.
+func getMachineID() (string, error) {
+	id, source := runWindowsChain([]windowsTier{
+		// 1. Priority: Motherboard UUID (SMBIOS)
+		// This is burned into the hardware and persists even if Windows is completely re-installed.
+		// We use the native Windows API (GetSystemFirmwareTable) to read this, avoiding external CLI calls like 'wmic'.
+		// Unconfigured placeholders (all zeros or all F's) are reported as "".
+		{ComponentDMIUUID, getBiosUUID},
+
+		// 2. Fallback: Disk Serial Number
+		// If the BIOS UUID is missing or generic, we try the primary disk's serial number.
+		// This also typically persists across OS re-installs.
+		{ComponentDiskSerial, func() (string, error) { return getWmic("diskdrive", "serialnumber") }},
+
+		// 3. Fallback: Registry MachineGuid
+		// Located at HKLM\SOFTWARE\Microsoft\Cryptography\MachineGuid.
+		// This ID is generated by Windows during installation. It is unique, but it WILL change
+		// if the user re-installs Windows.
+		{SourceMachineGuid, getRegistryID},
+	})
+	if id != "" {
+		windowsSourceMu.Lock()
+		windowsSource = source
+		windowsSourceMu.Unlock()
+	}
 
-	k32 := windows.NewLazySystemDLL("kernel32.dll")
-	proc := k32.NewProc("GetSystemFirmwareTable")
+	// 4. Last Resort: MAC hash (handled by loadInfo).
+	return id, nil
+}
 
-	// 1. Determine the buffer size required to hold the SMBIOS table.
-	// Passing 0 for buffer and size returns the required size.
-	r1, _, _ := proc.Call(uintptr(rsmb), 0, 0, 0)
-	if r1 == 0 {
-		return "", fmt.Errorf("failed to get firmware table size")
-	}
+// getMachineIDV2 is the chain of the version 2 rules (see WithRulesVersion).
+func getMachineIDV2() (string, string, error) {
+	id, source := runWindowsChain([]windowsTier{
+		// 1. Priority: Registry MachineGuid
+		// It is what most fingerprinting tools (and the OpenTelemetry host.id convention) use,
+		// it never depends on NICs, and unlike SMBIOS UUIDs it is not duplicated across cloned
+		// or cheaply-manufactured hardware.
+		{SourceMachineGuid, getRegistryID},
+
+		// 2. Fallback: Motherboard UUID (SMBIOS)
+		{ComponentDMIUUID, getBiosUUID},
+
+		// 3. Fallback: BIOS Serial Number
+		// Read from HARDWARE\DESCRIPTION\System\BIOS, or from the SMBIOS table if the registry lacks it.
+		{SourceBIOSSerial, getBIOSSerial},
+	})
+	return id, source, nil
+}
 
-	size := r1
-	buf := make([]byte, size)
+// runWindowsChain returns the ID of the first tier that answers, with its source.
+// It returns "" if every tier fails.
+func runWindowsChain(chain []windowsTier) (string, string) {
+	for _, tier := range chain {
+		id, err := tier.get()
+		logDebug("machineid: source tier tried", "source", tier.source, "empty", err == nil && id == "", "error", err)
+		if err != nil || id == "" {
+			continue
+		}
+		return id, tier.source
+	}
+	return "", ""
+}
 
-	// 2. Retrieve the actual SMBIOS table data.
-	r1, _, _ = proc.Call(uintptr(rsmb), 0, uintptr(unsafe.Pointer(&buf[0])), size)
-	if r1 != size {
-		return "", fmt.Errorf("failed to retrieve firmware table")
+// getBIOSSerial returns the system serial number.
+func getBIOSSerial() (string, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `HARDWARE\DESCRIPTION\System\BIOS`, registry.QUERY_VALUE)
+	logDebug("machineid: registry probe", "key", `HKLM\HARDWARE\DESCRIPTION\System\BIOS`, "error", err)
+	if err == nil {
+		serial, _, err := k.GetStringValue("SystemSerialNumber")
+		k.Close()
+		if err == nil && !isPlaceholderSerial(serial) {
+			return strings.TrimSpace(serial), nil
+		}
 	}
 
-	// Parse RawSMBIOSData structure:
-	// struct RawSMBIOSData {
-	//   BYTE  Used20CallingMethod;
-	//   BYTE  SMBIOSMajorVersion;
-	//   BYTE  SMBIOSMinorVersion;
-	//   BYTE  DmiRevision;
-	//   DWORD Length;          <-- 4 bytes indicating the size of the data following
-	//   BYTE  SMBIOSTableData[];
-	// }
-	// We skip the 8-byte header to access the Table Data directly.
-	if len(buf) < 8 {
-		return "", fmt.Errorf("buffer too small")
+	// Most firmware only exposes the serial through SMBIOS (Type 1, offset 0x07).
+	buf, err := getSMBIOSTable()
+	if err != nil {
+		return "", err
+	}
+	raw, err := parseRawSMBIOS(buf)
+	if err != nil {
+		return "", err
 	}
 
-	data := buf[8:]
-	return findUUIDInSMBIOS(data)
+	serial := findSerialInSMBIOS(raw, smbiosSystemInformation)
+	if isPlaceholderSerial(serial) {
+		return "", nil
+	}
+	return serial, nil
 }
 
-// findUUIDInSMBIOS iterates through the SMBIOS structures to find Type 1 (System Information).
-func findUUIDInSMBIOS(data []byte) (string, error) {
-	// SMBIOS data is a sequence of structures.
-	// Header format: [Type (1b)] [Length (1b)] [Handle (2b)] ... [Data] ... [Double Null \0\0]
-	i := 0
-	for i < len(data) {
-		if i+4 > len(data) {
-			break
-		}
-		typ := data[i]
-		length := int(data[i+1])
+// procGetSystemFirmwareTable is resolved lazily from kernel32 on first use.
+var procGetSystemFirmwareTable = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetSystemFirmwareTable")
 
-		if i+length > len(data) {
-			break
-		}
-
-		// Type 1 = System Information (contains UUID).
-		if typ == 1 {
-			// Offset 0x08 (8) is where the UUID starts within the Type 1 structure.
-			// The structure length must be at least 0x19 (25 bytes) to hold the 16-byte UUID.
-			if length >= 25 {
-				uuidBytes := data[i+8 : i+24]
-				return formatSMBIOSUUID(uuidBytes), nil
-			}
-		}
+// getBiosUUID fetches the machine UUID from the SMBIOS firmware table using the Windows API.
+// It does not touch WMI, so it works on systems where the WMI service is disabled or wmic is removed.
+// Reference: https://docs.microsoft.com/en-us/windows/win32/api/sysinfoapi/nf-sysinfoapi-getsystemfirmwaretable
+func getBiosUUID() (string, error) {
+	buf, err := getSMBIOSTable()
+	if err != nil {
+		return "", err
+	}
 
-		// Move to the next structure.
-		// The unformatted data section ends with a double-null terminator (0x00 0x00).
-		i += length
-		for i+1 < len(data) {
-			if data[i] == 0 && data[i+1] == 0 {
-				i += 2 // Skip the double null
-				break
-			}
-			i++
-		}
+	raw, err := parseRawSMBIOS(buf)
+	if err != nil {
+		return "", err
 	}
-	return "", fmt.Errorf("uuid not found in smbios")
+	return findUUIDInSMBIOS(raw)
 }
 
-// formatSMBIOSUUID formats the 16 bytes into a standard UUID string.
-// CRITICAL: SMBIOS 2.6+ specification dictates mixed-endian encoding for the UUID.
-// - The first 3 fields (DWORD, WORD, WORD) are Little-Endian.
-// - The last 2 fields (Char array) are Big-Endian (Network Byte Order).
-// We must swap bytes in the first 3 fields to match the standard string representation.
-func formatSMBIOSUUID(b []byte) string {
-	// Check for invalid UUIDs (all zeros or all ones) often found in unconfigured hardware.
-	if isAll(b, 0x00) || isAll(b, 0xFF) {
-		return ""
+// getSMBIOSTable returns the RawSMBIOSData blob from GetSystemFirmwareTable('RSMB').
+func getSMBIOSTable() ([]byte, error) {
+	// 'RSMB' is the Little-Endian signature for the Raw SMBIOS provider (0x52534D42).
+	const rsmb = 0x52534D42
+
+	// 1. Determine the buffer size required to hold the SMBIOS table.
+	// Passing 0 for buffer and size returns the required size.
+	r1, _, err := procGetSystemFirmwareTable.Call(uintptr(rsmb), 0, 0, 0)
+	if r1 == 0 {
+		return nil, fmt.Errorf("failed to get firmware table size: %w", err)
 	}
 
-	return fmt.Sprintf("%02x%02x%02x%02x-%02x%02x-%02x%02x-%02x%02x-%02x%02x%02x%02x%02x%02x",
-		b[3], b[2], b[1], b[0], // Field 1: Swap DWORD (4 bytes)
-		b[5], b[4],             // Field 2: Swap WORD (2 bytes)
-		b[7], b[6],             // Field 3: Swap WORD (2 bytes)
-		b[8], b[9], b[10], b[11], b[12], b[13], b[14], b[15]) // Remaining bytes are Big-Endian
-}
+	size := r1
+	buf := make([]byte, size)
 
-func isAll(b []byte, v byte) bool {
-	for _, x := range b {
-		if x != v {
-			return false
-		}
+	// 2. Retrieve the actual SMBIOS table data.
+	r1, _, err = procGetSystemFirmwareTable.Call(uintptr(rsmb), 0, uintptr(unsafe.Pointer(&buf[0])), size)
+	if r1 == 0 || r1 > size {
+		return nil, fmt.Errorf("failed to retrieve firmware table: %w", err)
 	}
-	return true
+	return buf[:r1], nil
 }
 
 // getWmic executes the "wmic" command as a fallback mechanism.
+// We request the "/value" format ("Key=Value" lines) because the default table output
+// uses localized column headers on non-English Windows installations.
 func getWmic(target string, query string) (string, error) {
 	// We invoke via 'cmd /c' to leverage the shell's handling of I/O, though direct invocation is possible.
-	cmd := exec.Command("cmd", "/c", "wmic", target, "get", query)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
+	out, err := runCommand("cmd", "/c", "wmic", target, "get", query, "/value")
 	if err != nil {
 		return "", err
 	}
-
-	// Sanitize Output: WMIC often outputs messy encodings (UTF-16 artifacts, null bytes).
-	cleaned := strings.ReplaceAll(out.String(), "\x00", "")
-
-	lines := strings.Split(cleaned, "\n")
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		// Skip empty lines and the header (which repeats the query command).
-		if trimmed == "" || strings.EqualFold(trimmed, query) {
-			continue
-		}
-		return trimmed, nil
-	}
-	return "", nil
+	return parseWmicValue(out, query), nil
 }
 
+// getRegistryID reads HKLM\SOFTWARE\Microsoft\Cryptography\MachineGuid.
+// The value only exists in the 64-bit registry view. A 32-bit process on 64-bit Windows is
+// redirected to WOW6432Node (where MachineGuid is missing), so we request the 64-bit view explicitly.
 func getRegistryID() (string, error) {
-	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Cryptography`, registry.QUERY_VALUE)
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Cryptography`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	logDebug("machineid: registry probe", "key", `HKLM\SOFTWARE\Microsoft\Cryptography`, "error", err)
 	if err != nil {
 		return "", err
 	}
@@ -188,4 +201,9 @@ func getRegistryID() (string, error) {
 		return "", err
 	}
 	return id, nil
-}
\ No newline at end of file
+}
+
+// getHostID follows the OpenTelemetry host.id convention for Windows (MachineGuid).
+func getHostID() (string, error) {
+	return getRegistryID()
+}
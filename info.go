@@ -0,0 +1,116 @@
+package machineid
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DetailNoNetwork is the MachineInfo.EnvironmentDetail of containers running in a network
+// namespace with only the loopback interface.
+const DetailNoNetwork = "container/no-net"
+
+// MachineInfo is a report describing the machine identity.
+type MachineInfo struct {
+	// ID is the value returned by ID() with the same options.
+	ID string
+	// Environment is the detected environment type (e.g., "physical", "vm", "docker").
+	Environment string
+	// EnvironmentDetail refines Environment when the platform can tell more (e.g., "vm/apple").
+	EnvironmentDetail string
+	// Tags flags execution conditions of the current process (e.g., TagRosetta).
+	Tags []string
+	// Source is the source the ID was derived from (e.g., ComponentMachineID, SourceMachineGuid,
+	// or ComponentMAC for the network fallback).
+	Source string
+	// SourceTool names the external binary that produced the ID (e.g., "ioreg", or
+	// "system_profiler" when ioreg failed on macOS). It is empty for native sources.
+	SourceTool string
+	// AssetTag is the value returned by the WithAssetTagProvider callback, if any.
+	// It is the raw tag unless WithHashedAssetTag was given.
+	AssetTag string
+	// DMI holds the system vendor, product and family, sanitized according to
+	// WithDMISanitization (hashed by default).
+	DMI DMIInfo
+	// Env holds the environment variables allow-listed with WithEnvCapture, keyed by name.
+	Env map[string]string
+	// Kubernetes identifies the pod when WithKubernetesMetadata is given and the process
+	// runs in Kubernetes.
+	Kubernetes *KubernetesInfo
+	// Degradation reports how far the ID resolution fell back (see BestEffortInfo).
+	Degradation Degradation
+}
+
+// Info returns a report about the machine identity.
+// The context is passed to callbacks such as the asset tag provider.
+func Info(ctx context.Context, opts ...Option) (*MachineInfo, error) {
+	o := newOptions(opts)
+	snap, err := o.applyGeneratedFallback(o.load())
+	if err != nil {
+		return nil, err
+	}
+	snap = o.applyCloudMetadata(ctx, snap)
+	if err := o.check(snap); err != nil {
+		return nil, err
+	}
+	return newInfo(ctx, snap, o, opts)
+}
+
+// newInfo builds the report for snap.
+func newInfo(ctx context.Context, snap snapshot, o options, opts []Option) (*MachineInfo, error) {
+	id, err := formatID(snap.prefix, domainID, []string{snap.rawID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	detail, _ := environmentDetail(snap.prefix)
+	info := &MachineInfo{
+		ID:                id,
+		Environment:       snap.prefix,
+		EnvironmentDetail: detail,
+		Tags:              getEnvironmentTagsFunc(),
+		Source:            snap.source,
+		DMI:               readDMI(o.dmiSanitization),
+		Env:               captureEnv(o.envRules),
+		Degradation:       degradationOf(snap.source),
+	}
+
+	if o.kubernetesMetadata {
+		info.Kubernetes = readKubernetes(o.kubernetesSanitization)
+	}
+
+	// Fallbacks and opt-in sources (see SetSourcePriority) don't run the platform tools.
+	if snap.source == machineIDSource() {
+		info.SourceTool = machineIDTool()
+	}
+
+	if o.assetTagProvider != nil {
+		tag, err := o.assetTagProvider(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("asset tag provider: %w", err)
+		}
+		if o.hashAssetTag && tag != "" {
+			if tag, err = protect(tag); err != nil {
+				return nil, err
+			}
+		}
+		info.AssetTag = tag
+	}
+
+	return info, nil
+}
+
+// environmentDetail returns the MachineInfo.EnvironmentDetail for the environment type env,
+// and the check that found it.
+func environmentDetail(env string) (detail, evidence string) {
+	detail, evidence = detectEnvironmentDetail()
+
+	// Scratch containers without network get a documented detail, since they can't use the
+	// MAC fallback (see ErrNoNetwork). It takes precedence over the hypervisor of the host.
+	if (detail == "" || strings.HasPrefix(detail, "vm/")) && isContainer(env) {
+		if interfaces, err := netInterfaces(); err == nil && !hasNetwork(interfaces) {
+			return DetailNoNetwork, "net:loopback-only"
+		}
+	}
+	return detail, evidence
+}
@@ -0,0 +1,30 @@
+// Package bridge connects the experimental machineid/x package to unexported internals
+// of machineid, so experimental features can be built on the platform sources without
+// adding them to the stable API. The machineid package fills in the hooks at init time.
+package bridge
+
+import "net"
+
+// Component is a fingerprint component as resolved by machineid.
+type Component struct {
+	Name string
+	Hash string // SHA256 (hex) of the raw value, empty if unavailable.
+	Err  error
+}
+
+// Components resolves every fingerprint component in canonical order.
+var Components func() []Component
+
+// RegisterCloudProvider installs the function naming the cloud provider (e.g., "aws") in the
+// environment tags of machineid.DescribeEnvironment. machineid/cloud registers it at init, so
+// the core package doesn't carry the provider signatures.
+var RegisterCloudProvider func(detect func() string)
+
+// DMI returns the raw (unsanitized) system vendor and product name, for optional packages
+// classifying the hardware (e.g., machineid/cloud).
+var DMI func() (vendor, product string, err error)
+
+// MACFallback returns the SHA256 (hex) of the MAC fallback value, computed from the current
+// interfaces as rewritten by transform. It doesn't affect the ID; cmd/machineid-verify uses
+// it to check that the fallback is stable when interfaces are reordered, renamed or added.
+var MACFallback func(transform func([]net.Interface) []net.Interface) (string, error)
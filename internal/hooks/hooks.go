@@ -0,0 +1,13 @@
+// Package hooks is the test seam of machineid. The detection hooks are unexported variables of
+// machineid that only its own tests assign; machineid/machineidtest substitutes them through
+// these functions instead. Being internal, they can't be reached from outside the module.
+// The machineid package fills them in at init time.
+package hooks
+
+// SetMachineID replaces the platform machine ID source and returns a function restoring it;
+// SetEnvironment does the same for the environment detection. Both drop every cached value, so
+// the next call resolves with the new source.
+var (
+	SetMachineID   func(get func() (string, error)) (restore func())
+	SetEnvironment func(get func() string) (restore func())
+)
@@ -0,0 +1,165 @@
+// Package imds queries the instance metadata services (IMDS) of cloud providers. The
+// services are only reachable from inside an instance, on the link-local address.
+package imds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Endpoint is the base URL every provider serves its metadata on. It is a variable so
+// tests can use a local server.
+var Endpoint = "http://169.254.169.254"
+
+// client bounds every request: the link-local address answers immediately on instances
+// and not at all elsewhere.
+var client = &http.Client{Timeout: 2 * time.Second}
+
+// probe is a request only the metadata service of provider answers with 200 OK.
+type probe struct {
+	provider string
+	method   string
+	path     string
+	header   map[string]string
+	// check further validates the response, if set.
+	check func(*http.Response) bool
+}
+
+// probes lists one request per supported provider.
+var probes = []probe{
+	// AWS IMDSv2: a session token is issued on PUT.
+	{provider: "aws", method: http.MethodPut, path: "/latest/api/token",
+		header: map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"}},
+	{provider: "gcp", method: http.MethodGet, path: "/computeMetadata/v1/instance/id",
+		header: map[string]string{"Metadata-Flavor": "Google"},
+		check:  func(r *http.Response) bool { return r.Header.Get("Metadata-Flavor") == "Google" }},
+	{provider: "azure", method: http.MethodGet, path: "/metadata/instance/compute/vmId?api-version=2021-02-01&format=text",
+		header: map[string]string{"Metadata": "true"}},
+	{provider: "digitalocean", method: http.MethodGet, path: "/metadata/v1/id"},
+	{provider: "oracle", method: http.MethodGet, path: "/opc/v2/instance/id",
+		header: map[string]string{"Authorization": "Bearer Oracle"}},
+	{provider: "hetzner", method: http.MethodGet, path: "/hetzner/v1/metadata/instance-id"},
+}
+
+// Detect queries every provider concurrently and returns the name of the first one that
+// answers (e.g., "aws"), or "" if none does before ctx is done.
+func Detect(ctx context.Context) string {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	found := make(chan string, len(probes))
+	for _, p := range probes {
+		go func() {
+			if p.do(ctx) {
+				found <- p.provider
+			} else {
+				found <- ""
+			}
+		}()
+	}
+
+	for range probes {
+		if provider := <-found; provider != "" {
+			return provider
+		}
+	}
+	return ""
+}
+
+// do sends the probe and reports whether the provider answered.
+func (p probe) do(ctx context.Context) bool {
+	req, err := http.NewRequestWithContext(ctx, p.method, Endpoint+p.path, nil)
+	if err != nil {
+		return false
+	}
+	for k, v := range p.header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK && (p.check == nil || p.check(resp))
+}
+
+// ErrNotFound is returned by InstanceID when no metadata service answered.
+var ErrNotFound = errors.New("imds: no instance metadata service")
+
+// instanceIDs lists the instance ID queries, keyed by provider.
+var instanceIDs = map[string]func(ctx context.Context) (string, error){
+	// AWS IMDSv2: the instance ID is only served with a session token.
+	"aws": func(ctx context.Context) (string, error) {
+		token, err := get(ctx, http.MethodPut, "/latest/api/token", map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+		if err != nil {
+			return "", err
+		}
+		return get(ctx, http.MethodGet, "/latest/meta-data/instance-id", map[string]string{"X-aws-ec2-metadata-token": token})
+	},
+	"gcp": func(ctx context.Context) (string, error) {
+		return get(ctx, http.MethodGet, "/computeMetadata/v1/instance/id", map[string]string{"Metadata-Flavor": "Google"})
+	},
+	"azure": func(ctx context.Context) (string, error) {
+		return get(ctx, http.MethodGet, "/metadata/instance/compute/vmId?api-version=2021-02-01&format=text", map[string]string{"Metadata": "true"})
+	},
+}
+
+// InstanceID queries the instance ID from the AWS, GCP and Azure metadata services
+// concurrently, and returns it qualified with the provider (e.g., "aws:i-0abc123").
+// It returns ErrNotFound if no service answered, or ctx.Err() if ctx is done first.
+func InstanceID(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	found := make(chan string, len(instanceIDs))
+	for provider, query := range instanceIDs {
+		go func() {
+			if id, err := query(ctx); err == nil && id != "" {
+				found <- provider + ":" + id
+			} else {
+				found <- ""
+			}
+		}()
+	}
+
+	for range instanceIDs {
+		if id := <-found; id != "" {
+			return id, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrNotFound
+}
+
+// get sends a request and returns the trimmed body of a 200 OK response.
+func get(ctx context.Context, method, path string, header map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, Endpoint+path, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("imds: %s %s: %s", method, path, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
@@ -0,0 +1,114 @@
+package machineid
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// InfoSchemaVersion is the version of the JSON encoding of MachineInfo, written to its "schema"
+// field. It is bumped on incompatible changes only: fields may be added within a version, so
+// decoders must ignore unknown fields.
+const InfoSchemaVersion = 1
+
+// machineInfoJSON is the JSON encoding of MachineInfo. It shares the field names of the binary
+// encodings; empty fields are omitted.
+type machineInfoJSON struct {
+	Schema            int               `json:"schema"`
+	ID                string            `json:"id,omitempty"`
+	Environment       string            `json:"env,omitempty"`
+	EnvironmentDetail string            `json:"env_detail,omitempty"`
+	Tags              []string          `json:"tags,omitempty"`
+	Source            string            `json:"source,omitempty"`
+	SourceTool        string            `json:"source_tool,omitempty"`
+	AssetTag          string            `json:"asset_tag,omitempty"`
+	DMI               *dmiJSON          `json:"dmi,omitempty"`
+	Env               map[string]string `json:"env_vars,omitempty"`
+	Kubernetes        *kubernetesJSON   `json:"k8s,omitempty"`
+	Degradation       string            `json:"degradation,omitempty"`
+}
+
+type dmiJSON struct {
+	Vendor  string `json:"vendor,omitempty"`
+	Product string `json:"product,omitempty"`
+	Family  string `json:"family,omitempty"`
+}
+
+type kubernetesJSON struct {
+	Namespace string `json:"namespace,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+	Node      string `json:"node,omitempty"`
+}
+
+// MarshalJSON encodes the report as a JSON object tagged with InfoSchemaVersion, using the field
+// names of MarshalCBOR (e.g., "env" for Environment), so reports from different library versions
+// can be shipped to a backend and compared.
+func (m MachineInfo) MarshalJSON() ([]byte, error) {
+	v := machineInfoJSON{
+		Schema:            InfoSchemaVersion,
+		ID:                m.ID,
+		Environment:       m.Environment,
+		EnvironmentDetail: m.EnvironmentDetail,
+		Tags:              m.Tags,
+		Source:            m.Source,
+		SourceTool:        m.SourceTool,
+		AssetTag:          m.AssetTag,
+		Env:               m.Env,
+	}
+	if m.DMI != (DMIInfo{}) {
+		v.DMI = &dmiJSON{Vendor: m.DMI.Vendor, Product: m.DMI.Product, Family: m.DMI.Family}
+	}
+	if m.Kubernetes != nil {
+		v.Kubernetes = &kubernetesJSON{Namespace: m.Kubernetes.Namespace, Pod: m.Kubernetes.Pod, Node: m.Kubernetes.Node}
+	}
+	if m.Degradation != DegradationNone {
+		v.Degradation = m.Degradation.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a report encoded by MarshalJSON. It rejects other schema versions.
+func (m *MachineInfo) UnmarshalJSON(data []byte) error {
+	var v machineInfoJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Schema != InfoSchemaVersion {
+		return fmt.Errorf("machineid: unsupported MachineInfo schema %d", v.Schema)
+	}
+	degradation, err := parseDegradation(v.Degradation)
+	if err != nil {
+		return err
+	}
+
+	*m = MachineInfo{
+		ID:                v.ID,
+		Environment:       v.Environment,
+		EnvironmentDetail: v.EnvironmentDetail,
+		Tags:              v.Tags,
+		Source:            v.Source,
+		SourceTool:        v.SourceTool,
+		AssetTag:          v.AssetTag,
+		Env:               v.Env,
+		Degradation:       degradation,
+	}
+	if v.DMI != nil {
+		m.DMI = DMIInfo{Vendor: v.DMI.Vendor, Product: v.DMI.Product, Family: v.DMI.Family}
+	}
+	if v.Kubernetes != nil {
+		m.Kubernetes = &KubernetesInfo{Namespace: v.Kubernetes.Namespace, Pod: v.Kubernetes.Pod, Node: v.Kubernetes.Node}
+	}
+	return nil
+}
+
+// parseDegradation is the inverse of Degradation.String; "" is DegradationNone.
+func parseDegradation(s string) (Degradation, error) {
+	if s == "" {
+		return DegradationNone, nil
+	}
+	for d := DegradationNone; d <= DegradationRandom; d++ {
+		if d.String() == s {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("machineid: unknown degradation %q", s)
+}
@@ -0,0 +1,70 @@
+package machineid
+
+import (
+	"os"
+	"strings"
+)
+
+// kubernetesServiceAccountDir is where the kubelet mounts the pod's service account token.
+var kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubernetesInfo identifies the pod the process runs in.
+type KubernetesInfo struct {
+	Namespace string
+	Pod       string
+	Node      string // Only known if NODE_NAME is injected via the downward API.
+}
+
+// WithKubernetesMetadata makes Info() report the pod's namespace, name and node in
+// MachineInfo.Kubernetes when running in a pod, sanitized according to level.
+func WithKubernetesMetadata(level Sanitization) Option {
+	return func(o *options) {
+		o.kubernetesMetadata = true
+		o.kubernetesSanitization = level
+	}
+}
+
+// inKubernetes reports whether the process runs in a Kubernetes pod. The kubelet injects
+// KUBERNETES_SERVICE_HOST into every container, and mounts the service account token
+// unless automountServiceAccountToken is disabled.
+func inKubernetes() bool {
+	return kubernetesEvidence() != ""
+}
+
+// kubernetesEvidence returns the variable or mount that places the process in a pod, or "".
+func kubernetesEvidence() string {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return "env:KUBERNETES_SERVICE_HOST"
+	}
+	if _, err := os.Stat(kubernetesServiceAccountDir); err == nil {
+		return "file:" + kubernetesServiceAccountDir
+	}
+	return ""
+}
+
+// readKubernetes returns the pod metadata, or nil outside of Kubernetes.
+// The downward API variables (POD_NAMESPACE, POD_NAME, NODE_NAME) take precedence; the
+// namespace otherwise comes from the service account mount and the pod name from the
+// hostname, which Kubernetes sets to the pod name.
+func readKubernetes(level Sanitization) *KubernetesInfo {
+	if !inKubernetes() {
+		return nil
+	}
+
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		if b, err := os.ReadFile(kubernetesServiceAccountDir + "/namespace"); err == nil {
+			namespace = strings.TrimSpace(string(b))
+		}
+	}
+	pod := os.Getenv("POD_NAME")
+	if pod == "" {
+		pod, _ = os.Hostname()
+	}
+
+	return &KubernetesInfo{
+		Namespace: sanitize(namespace, level),
+		Pod:       sanitize(pod, level),
+		Node:      sanitize(os.Getenv("NODE_NAME"), level),
+	}
+}
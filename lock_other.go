@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !openbsd && !netbsd && !dragonfly && !windows
+
+package machineid
+
+import "os"
+
+// lockFile is a no-op on platforms without file locking; the atomic write still prevents torn files.
+func lockFile(*os.File) (unlock func(), err error) {
+	return func() {}, nil
+}
@@ -0,0 +1,42 @@
+package machineid
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+// logger receives diagnostic messages from the package. It is nil (silent) by default.
+var logger atomic.Pointer[slog.Logger]
+
+// SetLogger installs a logger for diagnostic messages (e.g., appID collision warnings).
+// Passing nil disables logging again. The package never logs unless a logger is set.
+//
+// At slog.LevelDebug the logger also traces every step of the resolution: the files read, the
+// registry keys probed, the sources tried and why they failed, the interfaces the MAC fallback
+// skipped, and the source the ID was derived from (with a hash of the raw ID, never the raw ID
+// itself). Resolutions are cached, so call Refresh to trace a new one.
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+}
+
+// debugEnabled reports whether resolution traces are logged, so callers can skip costly
+// attributes.
+func debugEnabled() bool {
+	l := logger.Load()
+	return l != nil && l.Enabled(context.Background(), slog.LevelDebug)
+}
+
+// logDebug emits a resolution trace through the configured logger, if enabled.
+func logDebug(msg string, args ...any) {
+	if l := logger.Load(); l != nil {
+		l.Log(context.Background(), slog.LevelDebug, msg, args...)
+	}
+}
+
+// logWarn emits a warning through the configured logger, if any.
+func logWarn(msg string, args ...any) {
+	if l := logger.Load(); l != nil {
+		l.Log(context.Background(), slog.LevelWarn, msg, args...)
+	}
+}
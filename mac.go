@@ -0,0 +1,334 @@
+package machineid
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/banditmoscow1337/machineid/internal/bridge"
+)
+
+func init() {
+	bridge.MACFallback = macFallbackWith
+}
+
+// defaultRouteInterfacesFunc names the interfaces owning a default route (see WithActiveInterfaces).
+var defaultRouteInterfacesFunc = defaultRouteInterfaces
+
+// macFallbackWith hashes the MAC fallback value of the current interfaces, as rewritten by
+// transform (see bridge.MACFallback).
+func macFallbackWith(transform func([]net.Interface) []net.Interface) (string, error) {
+	interfaces, err := netInterfaces()
+	if err != nil {
+		return "", err
+	}
+	id, err := hardwareIDFrom(transform(interfaces))
+	if err != nil {
+		return "", err
+	}
+	return protect(id)
+}
+
+// HardwareAddress is a network interface kept by the MAC address filters.
+type HardwareAddress struct {
+	// Interface is the interface name (e.g., "eth0").
+	Interface string
+	// MAC is the address in NormalizeMAC form.
+	MAC string
+}
+
+// WithVirtualInterfaces makes GetHardwareAddresses keep the interfaces of virtualization tools
+// and VPNs (docker, veth, tun, tap and, with WithRulesVersion(2), bridges, bonds, WireGuard...),
+// and the addresses of virtual adapter vendors (VMware, VirtualBox, Hyper-V...), which the MAC
+// fallback ignores.
+func WithVirtualInterfaces() Option {
+	return func(o *options) {
+		o.virtualInterfaces = true
+	}
+}
+
+// WithoutLocallyAdministered makes GetHardwareAddresses drop locally administered addresses
+// (second-least significant bit of the first octet set), such as randomized Wi-Fi addresses and
+// most VM NICs. The MAC fallback keeps them, so IDs derived from it are not affected.
+func WithoutLocallyAdministered() Option {
+	return func(o *options) {
+		o.noLocallyAdministered = true
+	}
+}
+
+// WithActiveInterfaces restricts the MAC fallback (and GetHardwareAddresses) to the interfaces
+// that are up with a carrier, or that own a default route (Linux), so dormant adapters and the
+// transient interfaces of container hosts don't perturb the ID. The ID then depends on the link
+// state: resolution fails if no interface is active (e.g., before the network is up).
+func WithActiveInterfaces() Option {
+	return func(o *options) {
+		o.activeInterfaces = true
+	}
+}
+
+// macRulesV2 reports whether the MAC fallback follows the version 2 rules (see RulesVersion):
+// permanent addresses, and the bridge, bond, VPN, device-less and virtual vendor filters.
+func (o options) macRulesV2() bool {
+	return o.rulesVersion >= 2
+}
+
+// macInterfaces lists the interfaces with list, and applies the address rules of o to them.
+func (o options) macInterfaces(list func() ([]net.Interface, error)) ([]net.Interface, error) {
+	interfaces, err := list()
+	if err != nil || !o.macRulesV2() {
+		return interfaces, err
+	}
+	return withPermanentAddresses(interfaces), nil
+}
+
+// applyMACRules recomputes a MAC-derived resolution from the interfaces listed by list, if
+// WithActiveInterfaces or WithRulesVersion(2) select other interfaces than the cached
+// resolution, which follows the version 1 rules.
+func (o options) applyMACRules(list func() ([]net.Interface, error), snap snapshot, err error) (snapshot, error) {
+	if err != nil || snap.source != ComponentMAC || !o.activeInterfaces && !o.macRulesV2() {
+		return snap, err
+	}
+	interfaces, err := o.macInterfaces(list)
+	if err != nil {
+		return snapshot{}, err
+	}
+	addrs := filterHardwareAddresses(interfaces, options{activeInterfaces: o.activeInterfaces, rulesVersion: o.rulesVersion})
+	switch {
+	case len(addrs) == 0 && o.activeInterfaces:
+		return snapshot{}, errors.New("machineid: no interface with a carrier or default route for the MAC fallback")
+	case len(addrs) == 0:
+		return snapshot{}, errors.New("no valid network interfaces found for hardware ID fallback")
+	}
+	macs := make([]string, len(addrs))
+	for i, a := range addrs {
+		macs[i] = a.MAC
+	}
+	snap.rawID = strings.Join(macs, ",")
+	return snap, nil
+}
+
+// GetHardwareAddresses returns the network interfaces the MAC fallback would hash, with their
+// addresses normalized and in the same order: sorted by address, then by interface name, so
+// the OS enumeration order and interface renames don't matter. The loopback interface,
+// interfaces without a MAC address and virtual interfaces are filtered out; see
+// WithVirtualInterfaces and WithoutLocallyAdministered to change the filters, and
+// WithActiveInterfaces and WithRulesVersion to select the rules of the fallback. Other options
+// are ignored. The result is empty if no interface passes the filters.
+func GetHardwareAddresses(opts ...Option) ([]HardwareAddress, error) {
+	o := newOptions(opts)
+	interfaces, err := o.macInterfaces(netInterfaces)
+	if err != nil {
+		return nil, err
+	}
+	return filterHardwareAddresses(interfaces, o), nil
+}
+
+// filterHardwareAddresses applies the MAC address filters of o and sorts the result.
+func filterHardwareAddresses(interfaces []net.Interface, o options) []HardwareAddress {
+	// addrCandidate is a kept interface, with its sort key.
+	type addrCandidate struct {
+		HardwareAddress
+		addr net.HardwareAddr
+	}
+
+	var routed map[string]bool
+	if o.activeInterfaces {
+		routed = defaultRouteInterfacesFunc()
+	}
+
+	var candidates, deviceless []addrCandidate
+	for _, iface := range interfaces {
+		// Filter out Loopback (127.0.0.1) and interfaces without MAC addresses.
+		if iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) == 0 {
+			logDebug("machineid: interface skipped", "interface", iface.Name, "reason", "loopback or no hardware address")
+			continue
+		}
+
+		const carrier = net.FlagUp | net.FlagRunning
+		if o.activeInterfaces && iface.Flags&carrier != carrier && !routed[iface.Name] {
+			logDebug("machineid: interface skipped", "interface", iface.Name, "reason", "no carrier or default route")
+			continue
+		}
+
+		// Heuristic Filter: Ignore interfaces created by virtualization tools (Docker, KVM, VPNs).
+		// We only want "real" hardware interfaces to ensure the ID remains stable
+		// if the user spins up a new Docker container or VPN.
+		if !o.virtualInterfaces && (isVirtualInterface(iface.Name) || o.macRulesV2() && isBridgeOrVPN(iface.Name)) {
+			logDebug("machineid: interface skipped", "interface", iface.Name, "reason", "virtual")
+			continue
+		}
+		mac, err := NormalizeMAC(iface.HardwareAddr.String())
+		if err != nil {
+			// Unusual address lengths (e.g., FireWire) are not part of the fallback.
+			logDebug("machineid: interface skipped", "interface", iface.Name, "reason", "unsupported address length")
+			continue
+		}
+		addr, _ := net.ParseMAC(mac)
+		if o.noLocallyAdministered && addr[0]&0x02 != 0 {
+			logDebug("machineid: interface skipped", "interface", iface.Name, "reason", "locally administered")
+			continue
+		}
+		c := addrCandidate{HardwareAddress{Interface: iface.Name, MAC: mac}, addr}
+		if !o.virtualInterfaces && o.macRulesV2() && isVirtualDevice(iface) {
+			deviceless = append(deviceless, c)
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+
+	// Interfaces without a backing device are bridges, bonds and tunnels on a host, but the only
+	// interfaces of a container (its eth0 is one end of a veth pair), so they are kept if
+	// nothing else is left.
+	if len(candidates) == 0 {
+		candidates = deviceless
+	} else {
+		for _, c := range deviceless {
+			logDebug("machineid: interface skipped", "interface", c.Interface, "reason", "no backing device")
+		}
+	}
+
+	// Adapters of virtualization software on the host (e.g., VMware or VirtualBox host-only
+	// adapters on Windows) have arbitrary names but a well-known vendor prefix. Inside a guest
+	// these are the only addresses, so they are kept if nothing else is left.
+	if !o.virtualInterfaces && o.macRulesV2() {
+		physical := slices.DeleteFunc(slices.Clone(candidates), func(c addrCandidate) bool {
+			return virtualVendor(c.addr) != ""
+		})
+		if len(physical) > 0 && len(physical) < len(candidates) {
+			for _, c := range candidates {
+				if vendor := virtualVendor(c.addr); vendor != "" {
+					logDebug("machineid: interface skipped", "interface", c.Interface, "reason", "virtual adapter vendor", "vendor", vendor)
+				}
+			}
+			candidates = physical
+		}
+	}
+
+	// Sort by an explicit composite key, so neither the order reported by the OS nor the
+	// interface naming scheme (eth0 vs. enp3s0 after a kernel or udev upgrade) affects the ID:
+	//  1. the address bytes (a shorter address sorts first if it is a prefix of a longer one),
+	//  2. the interface name, which only orders interfaces sharing an address (e.g., bonds).
+	// This is the order the formatted addresses were sorted in historically, so IDs are unchanged.
+	slices.SortFunc(candidates, func(a, b addrCandidate) int {
+		if c := bytes.Compare(a.addr, b.addr); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Interface, b.Interface)
+	})
+
+	addrs := make([]HardwareAddress, len(candidates))
+	for i, c := range candidates {
+		addrs[i] = c.HardwareAddress
+	}
+	return addrs
+}
+
+// virtualInterfacePrefixes lists the name prefixes of bridges, bonds and overlay VPNs (libvirt,
+// Docker networks, WireGuard, ZeroTier, Tailscale), which come and go with their software.
+var virtualInterfacePrefixes = []string{"br-", "bond", "virbr", "wg", "zt", "tailscale"}
+
+// isVirtualInterface reports whether the interface name is one of a virtualization tool or VPN.
+func isVirtualInterface(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "docker") ||
+		strings.Contains(name, "veth") ||
+		strings.Contains(name, "tun") ||
+		strings.Contains(name, "tap")
+}
+
+// isBridgeOrVPN reports whether the interface name has one of virtualInterfacePrefixes. It is
+// part of the version 2 MAC rules, as version 1 hashes these interfaces.
+func isBridgeOrVPN(name string) bool {
+	name = strings.ToLower(name)
+	return slices.ContainsFunc(virtualInterfacePrefixes, func(prefix string) bool {
+		return strings.HasPrefix(name, prefix)
+	})
+}
+
+// virtualOUIs maps the organizationally unique identifiers (first three octets) assigned to
+// virtual network adapters to their vendor.
+var virtualOUIs = map[[3]byte]string{
+	{0x00, 0x50, 0x56}: "VMware",
+	{0x00, 0x0c, 0x29}: "VMware",
+	{0x00, 0x05, 0x69}: "VMware",
+	{0x00, 0x1c, 0x14}: "VMware",
+	{0x08, 0x00, 0x27}: "VirtualBox",
+	{0x0a, 0x00, 0x27}: "VirtualBox", // host-only adapters
+	{0x00, 0x15, 0x5d}: "Hyper-V",
+	{0x00, 0x03, 0xff}: "Virtual PC",
+	{0x00, 0x1c, 0x42}: "Parallels",
+	{0x00, 0x16, 0x3e}: "Xen",
+	{0x52, 0x54, 0x00}: "QEMU",
+}
+
+// virtualVendor returns the vendor of a virtual network adapter address, or "".
+func virtualVendor(addr net.HardwareAddr) string {
+	if len(addr) != 6 {
+		return ""
+	}
+	return virtualOUIs[[3]byte(addr[:3])]
+}
+
+// NormalizeMAC converts a hardware address into the canonical form used for hashing:
+// lowercase hex octets separated by colons (e.g., "aa:bb:cc:0d:0e:0f").
+//
+// Operating systems format MACs differently (Windows uses "AA-BB-CC-0D-0E-0F", some tools
+// strip leading zeros as in "aa:bb:cc:d:e:f", Cisco uses "aabb.cc0d.0e0f"). Without a single
+// canonical form the same hardware could produce different IDs depending on where the string
+// came from. Accepted lengths are 6 (EUI-48), 8 (EUI-64) and 20 (IP over InfiniBand) octets.
+func NormalizeMAC(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	var octets []byte
+	switch {
+	case strings.ContainsAny(s, ":-"):
+		sep := ":"
+		if strings.Contains(s, "-") {
+			sep = "-"
+		}
+		for _, group := range strings.Split(s, sep) {
+			// Restore zero-padding stripped by some tools ("d" -> "0d").
+			if len(group) == 1 {
+				group = "0" + group
+			}
+			b, err := decodeHexGroup(group, 2)
+			if err != nil {
+				return "", fmt.Errorf("invalid MAC address %q: %w", s, err)
+			}
+			octets = append(octets, b...)
+		}
+	case strings.Contains(s, "."):
+		for _, group := range strings.Split(s, ".") {
+			b, err := decodeHexGroup(group, 4)
+			if err != nil {
+				return "", fmt.Errorf("invalid MAC address %q: %w", s, err)
+			}
+			octets = append(octets, b...)
+		}
+	default:
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid MAC address %q: %w", s, err)
+		}
+		octets = b
+	}
+
+	switch len(octets) {
+	case 6, 8, 20:
+	default:
+		return "", fmt.Errorf("invalid MAC address %q: unexpected length %d", s, len(octets))
+	}
+	return net.HardwareAddr(octets).String(), nil
+}
+
+// decodeHexGroup decodes a separator-delimited group that must be exactly size hex digits.
+func decodeHexGroup(group string, size int) ([]byte, error) {
+	if len(group) != size {
+		return nil, fmt.Errorf("group %q must have %d hex digits", group, size)
+	}
+	return hex.DecodeString(group)
+}
@@ -0,0 +1,54 @@
+package machineid
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sysClassNetPath is where the kernel lists the network interfaces.
+var sysClassNetPath = "/sys/class/net"
+
+// isVirtualDevice reports whether the kernel lists the interface without a backing device
+// (bridges, bonds, VLANs, WireGuard...), whatever its name. sysfs may describe another network
+// namespace (e.g., the host's /sys mounted in a container), so it is only trusted if it reports
+// the same interface index.
+func isVirtualDevice(iface net.Interface) bool {
+	dir := filepath.Join(sysClassNetPath, iface.Name)
+	index, err := os.ReadFile(filepath.Join(dir, "ifindex"))
+	if err != nil || strings.TrimSpace(string(index)) != strconv.Itoa(iface.Index) {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(dir, "device"))
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// procNetRoutePaths are the IPv4 and IPv6 routing tables.
+var procNetRoutePaths = [2]string{"/proc/net/route", "/proc/net/ipv6_route"}
+
+// defaultRouteInterfaces names the interfaces owning an IPv4 or IPv6 default route.
+func defaultRouteInterfaces() map[string]bool {
+	routed := map[string]bool{}
+	if data, err := os.ReadFile(procNetRoutePaths[0]); err == nil {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		for line := range strings.Lines(string(data)) {
+			f := strings.Fields(line)
+			if len(f) >= 8 && f[1] == "00000000" && f[7] == "00000000" {
+				routed[f[0]] = true
+			}
+		}
+	}
+	if data, err := os.ReadFile(procNetRoutePaths[1]); err == nil {
+		// Destination PrefixLen Source SourcePrefixLen NextHop Metric RefCnt Use Flags Iface
+		for line := range strings.Lines(string(data)) {
+			f := strings.Fields(line)
+			if len(f) == 10 && f[1] == "00" && strings.Trim(f[0], "0") == "" && f[9] != "lo" {
+				routed[f[9]] = true
+			}
+		}
+	}
+	return routed
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package machineid
+
+import "net"
+
+// isVirtualDevice relies on the interface name filters on this platform.
+func isVirtualDevice(net.Interface) bool {
+	return false
+}
+
+// defaultRouteInterfaces reports no routes on this platform: WithActiveInterfaces relies on
+// the carrier.
+func defaultRouteInterfaces() map[string]bool {
+	return nil
+}
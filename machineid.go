@@ -1,14 +1,15 @@
 package machineid
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"net"
 	"os"
-	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
@@ -16,6 +17,10 @@ var (
 	cachedRawID string
 	// cachedPrefix stores the environment type (e.g., "vm", "docker", "physical").
 	cachedPrefix string
+	// cachedSource stores which source produced cachedRawID (e.g., ComponentMachineID or ComponentMAC).
+	cachedSource string
+	// cachedAt is when the cache was last resolved (see WithCacheTTL).
+	cachedAt time.Time
 
 	// mu guards the initialization of the cache.
 	// We deliberately use a Mutex + bool flag instead of sync.Once.
@@ -28,6 +33,22 @@ var (
 	netInterfaces    = net.Interfaces
 	getEnvTypeFunc   = getEnvironmentType
 	getMachineIDFunc = getMachineID
+
+	// getSecondaryMachineIDFunc returns the machine ID that the version 2 rules prefer over the
+	// hardware fallback (see WithRulesVersion). Platforms without one leave it nil.
+	getSecondaryMachineIDFunc func() (string, error)
+
+	// getMachineIDV2Func returns the platform machine ID and its source under the version 2
+	// rules, on platforms where they read it differently (Windows). Others leave it nil.
+	getMachineIDV2Func func() (string, string, error)
+
+	// machineIDSource names the source that produced the last successful getMachineID() result.
+	// Platforms with a multi-tier chain (Windows) replace it; the default is ComponentMachineID.
+	machineIDSource = func() string { return ComponentMachineID }
+
+	// machineIDTool names the external binary that produced the last getMachineID() result
+	// (e.g., "ioreg" or "system_profiler" on macOS). Native sources leave it empty.
+	machineIDTool = func() string { return "" }
 )
 
 // loadInfo attempts to resolve and cache the machine ID and environment type.
@@ -41,38 +62,149 @@ func loadInfo() error {
 		return nil
 	}
 
+	snap, err := resolve()
+	if err != nil {
+		// We do NOT set initialized=true, ensuring the next call attempts the resolution again.
+		return err
+	}
+
+	// The same machine now reports a different environment (e.g., physical -> vm after a
+	// P2V migration): every prefixed ID changes although the raw ID did not.
+	if cachedRawID == snap.rawID && cachedPrefix != "" && cachedPrefix != snap.prefix {
+		warnPrefixDrift(cachedPrefix, snap.prefix)
+	}
+
+	// Success: Update cache and freeze state.
+	cachedRawID = snap.rawID
+	cachedPrefix = snap.prefix
+	cachedSource = snap.source
+	cachedAt = time.Now()
+	initialized = true
+	return nil
+}
+
+// resolve runs environment detection and ID resolution without touching the cache.
+func resolve() (snapshot, error) {
+	return resolveWith(cachedSourceValue)
+}
+
+// resolveWith is resolve, reading the sources through value: cachedSourceValue, or
+// uncachedSourceValue to re-run them without affecting the source cache.
+func resolveWith(value func(name string, get func() (string, error)) (string, error)) (snapshot, error) {
+	r := resolution{
+		environment: func() string {
+			prefix, _ := value(SourceEnvironment, func() (string, error) {
+				return getEnvTypeFunc(), nil
+			})
+			return prefix
+		},
+		// Walks the chain configured by SetSourcePriority, if any.
+		sources: func() (string, string, error) {
+			return resolveSourcesWith(value)
+		},
+		mac: func() (string, error) {
+			return value(ComponentMAC, getHardwareId)
+		},
+	}
+	if sourceEnabled(SourceHostID) {
+		r.hostID = getLegacyHostIDFunc
+	}
+	return r.run()
+}
+
+// resolution holds the steps of an ID resolution, so the package-level state and Resolver
+// share the fallback logic.
+type resolution struct {
+	environment func() string
+	sources     func() (id, source string, err error)
+	mac         func() (string, error)
+	hostID      func() (string, error) // nil skips the gethostid(2) fallback
+}
+
+func (r resolution) run() (snapshot, error) {
 	// 1. Determine Environment Type
 	// We detect if we are running in a VM, Container, or Physical hardware.
 	// This helps scope the ID (e.g., a container might want to know it's a container).
-	prefix := getEnvTypeFunc()
+	prefix := r.environment()
+	logDebug("machineid: environment detected", "environment", prefix)
 
 	// 2. Resolve Unique ID
 	// Attempt to fetch the OS-specific unique ID (e.g., /etc/machine-id on Linux, Registry/BIOS on Windows).
-	id, err := getMachineIDFunc()
+	id, source, err := r.sources()
 
 	// 3. Fallback: Network Hardware ID
 	// If the OS-specific ID is missing (os.ErrNotExist) or returned an empty string,
 	// we fall back to hashing the MAC addresses of the network interfaces.
 	// This ensures we always return *some* ID, even on stripped-down systems.
 	if errors.Is(err, os.ErrNotExist) || (err == nil && id == "") {
-		id, err = getHardwareId()
+		logDebug("machineid: no source produced an ID, falling back to MAC addresses", "error", err)
+		id, err = r.mac()
+		source = ComponentMAC
+
+		// Last resort: the gethostid(2) value, for ZFS-based and embedded systems with
+		// neither a machine-id nor usable MAC addresses.
+		if err != nil && r.hostID != nil {
+			logDebug("machineid: MAC fallback failed, trying gethostid", "error", err)
+			if hostID, hostErr := r.hostID(); hostErr == nil {
+				id, err = hostID, nil
+				source = SourceHostID
+			}
+		}
 	} else if err != nil {
 		// If a specific error occurred (e.g., Permission Denied), we fail hard so the user knows
 		// something is wrong with their environment configuration.
-		return err
+		return snapshot{}, err
 	}
 
 	// Double-check: If we still failed to get an ID after fallback, return the error.
-	// We do NOT set initialized=true, ensuring the next call attempts the resolution again.
 	if err != nil {
-		return err
+		return snapshot{}, err
 	}
 
-	// Success: Update cache and freeze state.
-	cachedRawID = id
-	cachedPrefix = prefix
-	initialized = true
-	return nil
+	if debugEnabled() {
+		// The hash lets operators compare the raw IDs of colliding hosts without logging them.
+		hash, _ := protect(id)
+		logDebug("machineid: ID resolved", "environment", prefix, "source", source, "raw_hash", hash)
+	}
+	return snapshot{rawID: id, prefix: prefix, source: source}, nil
+}
+
+// snapshot holds a consistent copy of the cached state.
+type snapshot struct {
+	rawID  string
+	prefix string
+	source string
+}
+
+// load resolves the cache (see loadInfo) and returns a consistent copy of it.
+// The cache can be replaced at runtime (e.g., by the network watcher), so readers
+// must never access the cached* variables without holding mu.
+func load() (snapshot, error) {
+	if err := loadInfo(); err != nil {
+		return snapshot{}, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return snapshot{rawID: cachedRawID, prefix: cachedPrefix, source: cachedSource}, nil
+}
+
+// formatID hashes the tuple (the raw ID, followed by API-specific inputs) for the API named by
+// domain and renders it as "<prefix>:<hash>" according to opts.
+func formatID(prefix, domain string, tuple []string, opts []Option) (string, error) {
+	o := newOptions(opts)
+	sum, err := o.derive(domain, tuple)
+	if err != nil {
+		return "", err
+	}
+	if o.noPrefix {
+		return o.encode(sum), nil
+	}
+	sep := o.separator
+	if sep == "" {
+		sep = ":"
+	}
+	return prefix + sep + o.encode(sum), nil
 }
 
 // ID returns the unique machine ID, prefixed with the environment type.
@@ -80,49 +212,88 @@ func loadInfo() error {
 //
 // Format: "<environment>:<hash>"
 // Example: "physical:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
-func ID() (string, error) {
-	if err := loadInfo(); err != nil {
-		return "", err
-	}
+//
+// Options can change the hash encoding or shorten it, e.g. ID(Short()) returns "<environment>:" followed
+// by 12 Crockford base32 characters.
+func ID(opts ...Option) (string, error) {
+	return idFrom(options.load, opts)
+}
 
-	// Note: We access cachedRawID without a lock here because 'initialized' is true,
-	// meaning the cache is immutable for the lifetime of the process.
-	hash, err := protect(cachedRawID)
+// idFrom derives the ID from the resolution returned by load (the package cache, or the cache
+// of a Resolver).
+func idFrom(load func(options) (snapshot, error), opts []Option) (string, error) {
+	o := newOptions(opts)
+	snap, err := o.resolveSnapshot(load)
 	if err != nil {
 		return "", err
 	}
+	return formatID(snap.prefix, domainID, []string{snap.rawID}, opts)
+}
 
-	return cachedPrefix + ":" + hash, nil
+// resolveSnapshot returns the resolution an ID is derived from: the one returned by load, with
+// the generated fallback and cloud metadata applied, and checked against the options.
+func (o options) resolveSnapshot(load func(options) (snapshot, error)) (snapshot, error) {
+	snap, err := o.applyGeneratedFallback(load(o))
+	if err != nil {
+		return snapshot{}, err
+	}
+	snap = o.applyCloudMetadata(context.Background(), snap)
+	if err := o.check(snap); err != nil {
+		return snapshot{}, err
+	}
+	return snap, nil
 }
 
 // ProtectedID returns a unique ID hashed with an app-specific key.
 // Use this to generate separate IDs for different applications on the same machine,
 // preventing cross-app tracking.
-func ProtectedID(appID string) (string, error) {
-	if err := loadInfo(); err != nil {
-		return "", err
-	}
+//
+// Every appID is recorded for the lifetime of the process (see RegisteredAppIDs). If a logger
+// is installed with SetLogger, a warning is emitted when two visually similar appIDs are used.
+func ProtectedID(appID string, opts ...Option) (string, error) {
+	trackAppID(appID, callerSite(1))
+	return protectedID(appID, opts)
+}
+
+// protectedID is ProtectedID without the appID tracking, for callers passing untrusted appIDs
+// (see Handler) that must not grow the registry.
+func protectedID(appID string, opts []Option) (string, error) {
+	return protectedIDFrom(options.load, appID, opts)
+}
 
-	// Salt the ID with the appID before hashing.
-	hash, err := protect(cachedRawID + ":" + appID)
+// protectedIDFrom is the ProtectedID counterpart of idFrom.
+func protectedIDFrom(load func(options) (snapshot, error), appID string, opts []Option) (string, error) {
+	o := newOptions(opts)
+	snap, err := o.resolveSnapshot(load)
 	if err != nil {
 		return "", err
 	}
 
-	return cachedPrefix + ":" + hash, nil
+	// Salt the ID with the (normalized) appID before hashing.
+	appID = o.normalizeAppID(appID)
+	return formatID(snap.prefix, o.protectedDomain(), []string{snap.rawID, appID}, opts)
 }
 
 // protect hashes the input string using SHA256 to ensure a fixed-length, anonymized output.
 func protect(s string) (string, error) {
+	sum, err := digest(s)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// digest returns the raw SHA256 sum of the trimmed input.
+func digest(s string) ([]byte, error) {
 	s = strings.TrimSpace(s)
 	if s == "" {
-		return "", errors.New("empty machine id")
+		return nil, errors.New("empty machine id")
 	}
 	hash := sha256.New()
 	if _, err := hash.Write([]byte(s)); err != nil {
-		return "", err
+		return nil, err
 	}
-	return hex.EncodeToString(hash.Sum(nil)), nil
+	return hash.Sum(nil), nil
 }
 
 // getHardwareId generates a pseudo-ID based on the MAC addresses of physical network interfaces.
@@ -132,33 +303,40 @@ func getHardwareId() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	return hardwareIDFrom(interfaces)
+}
 
-	var macs []string
-	for _, iface := range interfaces {
-		// Filter out Loopback (127.0.0.1) and interfaces without MAC addresses.
-		if iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) == 0 {
-			continue
-		}
+// hardwareIDFrom builds the MAC fallback value from the given interfaces.
+func hardwareIDFrom(interfaces []net.Interface) (string, error) {
+	if !hasNetwork(interfaces) {
+		// Scratch containers (network namespace with loopback only): a known, permanent state
+		// rather than a failure, so report it without trying the individual interfaces.
+		return "", ErrNoNetwork
+	}
 
-		// Heuristic Filter: Ignore interfaces created by virtualization tools (Docker, KVM, VPNs).
-		// We only want "real" hardware interfaces to ensure the ID remains stable
-		// if the user spins up a new Docker container or VPN.
-		name := strings.ToLower(iface.Name)
-		if strings.Contains(name, "docker") ||
-			strings.Contains(name, "veth") ||
-			strings.Contains(name, "tun") ||
-			strings.Contains(name, "tap") {
-			continue
-		}
+	candidates := filterHardwareAddresses(interfaces, options{})
+	if len(candidates) == 0 {
+		return "", errors.New("no valid network interfaces found for hardware ID fallback")
+	}
 
-		macs = append(macs, iface.HardwareAddr.String())
+	macs := make([]string, len(candidates))
+	for i, c := range candidates {
+		macs[i] = c.MAC
 	}
+	return strings.Join(macs, ","), nil
+}
 
-	// Sort to ensure the order of interfaces doesn't affect the generated ID.
-	sort.Strings(macs)
+// ErrNoNetwork is returned by the MAC fallback when the process runs in a network namespace
+// with only the loopback interface (e.g., a container started with --network none). Use
+// BestEffortID with WithBestEffortStore to get a stable ID in that case.
+var ErrNoNetwork = errors.New("machineid: no network interfaces besides loopback")
 
-	if len(macs) == 0 {
-		return "", errors.New("no valid network interfaces found for hardware ID fallback")
+// hasNetwork reports whether any interface other than loopback exists.
+func hasNetwork(interfaces []net.Interface) bool {
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			return true
+		}
 	}
-	return strings.Join(macs, ","), nil
-}
\ No newline at end of file
+	return false
+}
@@ -1,14 +1,40 @@
 package machineid
 
 import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log/slog"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/banditmoscow1337/machineid/internal/bridge"
+	"github.com/banditmoscow1337/machineid/internal/imds"
 )
 
 // =========================================================================================
@@ -22,6 +48,9 @@ func resetCache() {
 	initialized = false
 	cachedRawID = ""
 	cachedPrefix = ""
+	cachedSource = ""
+	containerScope.Store(nil)
+	invalidateSources()
 }
 
 // mockInterfaces creates a function compatible with net.Interfaces logic.
@@ -242,6 +271,136 @@ func TestGetHardwareID_Logic(t *testing.T) {
 	}
 }
 
+func TestGetHardwareAddresses(t *testing.T) {
+	defer func() { netInterfaces = net.Interfaces }()
+	netInterfaces = mockInterfaces([]net.Interface{
+		{Name: "wlan0", Flags: net.FlagUp, HardwareAddr: net.HardwareAddr{0x3a, 0x11, 0x22, 0x33, 0x44, 0x55}}, // Randomized (locally administered).
+		{Name: "lo", Flags: net.FlagLoopback},
+		{Name: "docker0", Flags: net.FlagUp, HardwareAddr: net.HardwareAddr{0x02, 0x42, 0, 0, 0, 0}},
+		{Name: "eth0", Flags: net.FlagUp, HardwareAddr: net.HardwareAddr{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0xcc}},
+	}, nil)
+
+	tests := []struct {
+		name string
+		opts []Option
+		want []HardwareAddress
+	}{
+		{"Default", nil, []HardwareAddress{{"eth0", "00:1b:21:aa:bb:cc"}, {"wlan0", "3a:11:22:33:44:55"}}},
+		{"Virtual", []Option{WithVirtualInterfaces()}, []HardwareAddress{{"eth0", "00:1b:21:aa:bb:cc"}, {"docker0", "02:42:00:00:00:00"}, {"wlan0", "3a:11:22:33:44:55"}}},
+		{"Universal_Only", []Option{WithVirtualInterfaces(), WithoutLocallyAdministered()}, []HardwareAddress{{"eth0", "00:1b:21:aa:bb:cc"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetHardwareAddresses(tt.opts...)
+			if err != nil || !slices.Equal(got, tt.want) {
+				t.Errorf("GetHardwareAddresses() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	// The default filters are the ones of the MAC fallback.
+	addrs, _ := GetHardwareAddresses()
+	if raw, _ := getHardwareId(); raw != addrs[0].MAC+","+addrs[1].MAC {
+		t.Errorf("fallback %q doesn't match the default addresses %v", raw, addrs)
+	}
+}
+
+func TestVirtualAdapterVendors(t *testing.T) {
+	eth := net.Interface{Name: "Ethernet", HardwareAddr: net.HardwareAddr{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0xcc}}
+	vmnet := net.Interface{Name: "VMware Network Adapter VMnet8", HardwareAddr: net.HardwareAddr{0x00, 0x50, 0x56, 0xc0, 0x00, 0x08}}
+	vbox := net.Interface{Name: "Ethernet 3", HardwareAddr: net.HardwareAddr{0x0a, 0x00, 0x27, 0x00, 0x00, 0x0c}}
+
+	v2 := options{rulesVersion: 2}
+
+	// 1. On the host, the adapters of virtualization software are dropped.
+	if addrs := filterHardwareAddresses([]net.Interface{vmnet, eth, vbox}, v2); len(addrs) != 1 || addrs[0].MAC != "00:1b:21:aa:bb:cc" {
+		t.Errorf("expected the physical NIC only, got %v", addrs)
+	}
+
+	// 2. In a guest, the virtual NIC is all there is: it is kept.
+	if addrs := filterHardwareAddresses([]net.Interface{vmnet}, v2); len(addrs) != 1 || addrs[0].MAC != "00:50:56:c0:00:08" {
+		t.Errorf("expected the guest NIC, got %v", addrs)
+	}
+
+	// 3. WithVirtualInterfaces keeps them.
+	if all := filterHardwareAddresses([]net.Interface{vmnet, eth}, options{rulesVersion: 2, virtualInterfaces: true}); len(all) != 2 {
+		t.Errorf("expected every adapter, got %v", all)
+	}
+
+	// 4. The version 1 rules (the default) hash them, so existing IDs don't change.
+	if raw, err := hardwareIDFrom([]net.Interface{vmnet, eth, vbox}); err != nil || raw != "00:1b:21:aa:bb:cc,00:50:56:c0:00:08,0a:00:27:00:00:0c" {
+		t.Errorf("expected every adapter under the version 1 rules, got %q, %v", raw, err)
+	}
+
+	// 5. WithRulesVersion(2) applies them to the resolved fallback.
+	resetCache()
+	defer resetCache()
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	netInterfaces = mockInterfaces([]net.Interface{vmnet, eth, vbox}, nil)
+	defer func() {
+		getMachineIDFunc = getMachineID
+		netInterfaces = net.Interfaces
+	}()
+
+	v1ID, err := ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2ID, err := ID(WithRulesVersion(2))
+	if err != nil || v2ID == v1ID {
+		t.Errorf("expected a distinct version 2 ID, got %q, %v", v2ID, err)
+	}
+	if again, _ := ID(); again != v1ID {
+		t.Error("the version 2 rules must not change the default ID")
+	}
+	if addrs, _ := GetHardwareAddresses(WithRulesVersion(2)); len(addrs) != 1 || addrs[0].Interface != "Ethernet" {
+		t.Errorf("GetHardwareAddresses(WithRulesVersion(2)) = %v", addrs)
+	}
+}
+
+func TestWithActiveInterfaces(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	running := net.FlagUp | net.FlagRunning
+	eth0 := net.Interface{Name: "eth0", Flags: running, HardwareAddr: net.HardwareAddr{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0x01}}
+	wlan0 := net.Interface{Name: "wlan0", Flags: net.FlagUp, HardwareAddr: net.HardwareAddr{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0x02}}
+	eth1 := net.Interface{Name: "eth1", HardwareAddr: net.HardwareAddr{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0x03}}
+	netInterfaces = mockInterfaces([]net.Interface{eth0, wlan0, eth1}, nil)
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	defaultRouteInterfacesFunc = func() map[string]bool { return map[string]bool{"wlan0": true} }
+	defer func() {
+		netInterfaces = net.Interfaces
+		getMachineIDFunc = getMachineID
+		defaultRouteInterfacesFunc = defaultRouteInterfaces
+	}()
+
+	// eth0 has a carrier, wlan0 owns the default route, eth1 is dormant.
+	want := []HardwareAddress{{"eth0", "00:1b:21:aa:bb:01"}, {"wlan0", "00:1b:21:aa:bb:02"}}
+	if got, _ := GetHardwareAddresses(WithActiveInterfaces()); !slices.Equal(got, want) {
+		t.Errorf("GetHardwareAddresses() = %v, want %v", got, want)
+	}
+
+	active, err := ID(WithActiveInterfaces())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("00:1b:21:aa:bb:01,00:1b:21:aa:bb:02"))
+	if !strings.HasSuffix(active, hex.EncodeToString(sum[:])) {
+		t.Errorf("expected the ID of the active interfaces, got %q", active)
+	}
+	if all, _ := ID(); all == active {
+		t.Error("the option must not change the default fallback")
+	}
+
+	// No active interface: the fallback fails rather than using dormant ones.
+	netInterfaces = mockInterfaces([]net.Interface{eth1}, nil)
+	defaultRouteInterfacesFunc = func() map[string]bool { return nil }
+	if _, err := ID(WithActiveInterfaces()); err == nil {
+		t.Error("expected an error without active interfaces")
+	}
+}
+
 // =========================================================================================
 // LoadInfo Fallback Logic Tests
 // =========================================================================================
@@ -386,4 +545,3384 @@ func TestEnvironmentType_Linux_Detection(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// =========================================================================================
+// Output Encoding Options
+// =========================================================================================
+
+func TestID_Encodings(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getEnvTypeFunc = func() string { return "test-env" }
+	getMachineIDFunc = func() (string, error) { return "test-machine-id", nil }
+	defer func() {
+		getEnvTypeFunc = getEnvironmentType
+		getMachineIDFunc = getMachineID
+	}()
+
+	sum := sha256.Sum256([]byte("test-machine-id"))
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"Default_Hex", nil, hex.EncodeToString(sum[:])},
+		{"Base64URL", []Option{WithEncoding(Base64URL)}, base64.RawURLEncoding.EncodeToString(sum[:])},
+		{"Crockford", []Option{WithEncoding(Crockford)}, crockfordEncoding.EncodeToString(sum[:])},
+		{"Truncated_Hex", []Option{WithLength(10)}, hex.EncodeToString(sum[:])[:10]},
+		{"Short", []Option{Short()}, crockfordEncoding.EncodeToString(sum[:])[:ShortLength]},
+		{"Length_Too_Large", []Option{WithLength(1000)}, hex.EncodeToString(sum[:])},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := ID(tt.opts...)
+			if err != nil {
+				t.Fatalf("ID() failed: %v", err)
+			}
+			if id != "test-env:"+tt.want {
+				t.Errorf("ID() mismatch.\nGot:  %s\nWant: test-env:%s", id, tt.want)
+			}
+		})
+	}
+
+	// Crockford output must never contain the ambiguous letters I, L, O, U.
+	id, _ := ProtectedID("my-app", WithEncoding(Crockford))
+	if strings.ContainsAny(strings.TrimPrefix(id, "test-env:"), "ILOU") {
+		t.Errorf("Crockford output contains excluded letters: %s", id)
+	}
+}
+
+// =========================================================================================
+// Environment Codes
+// =========================================================================================
+
+func TestDescribeEnvironment(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "test-id", nil }
+	defer func() { getMachineIDFunc = getMachineID }()
+
+	// The type is the prefix of ID().
+	info, err := DescribeEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env, _ := Environment(); info.Type != env {
+		t.Errorf("Type = %q, want %q", info.Type, env)
+	}
+	if info.Type != "unknown" && (info.Confidence <= 0 || info.Confidence > 1) {
+		t.Errorf("Confidence = %v, want within (0, 1]", info.Confidence)
+	}
+
+	// Checks that disagree with the cached prefix don't explain it.
+	resetCache()
+	getEnvTypeFunc = func() string { return "test-env" }
+	defer func() { getEnvTypeFunc = getEnvironmentType }()
+	info, err = DescribeEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Type != "test-env" || info.SubType != "" || len(info.Evidence) != 0 || info.Confidence != 0 {
+		t.Errorf("unexpected explanation for a foreign prefix: %+v", *info)
+	}
+}
+
+func TestEnvCode_StableValues(t *testing.T) {
+	// These values are part of the public contract and must never change.
+	stable := map[string]EnvCode{
+		"unknown":    0,
+		"physical":   1,
+		"vm":         2,
+		"container":  3,
+		"docker":     4,
+		"ios":        5,
+		"browser":    6,
+		"wasi":       7,
+		"wsl":        8,
+		"podman":     9,
+		"lxc":        10,
+		"nspawn":     11,
+		"crio":       12,
+		"containerd": 13,
+		"garden":     14,
+		"kubernetes": 15,
+		"serverless": 16,
+	}
+
+	for name, code := range stable {
+		if got := ParseEnvCode(name); got != code {
+			t.Errorf("ParseEnvCode(%q) = %d, want %d", name, got, code)
+		}
+		if got := code.String(); got != name {
+			t.Errorf("EnvCode(%d).String() = %q, want %q", code, got, name)
+		}
+	}
+
+	if got := ParseEnvCode("something-new"); got != EnvUnknown {
+		t.Errorf("ParseEnvCode() for unrecognized input = %d, want EnvUnknown", got)
+	}
+}
+
+func TestEnvironmentCode(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getEnvTypeFunc = func() string { return "vm" }
+	getMachineIDFunc = func() (string, error) { return "id", nil }
+	defer func() {
+		getEnvTypeFunc = getEnvironmentType
+		getMachineIDFunc = getMachineID
+	}()
+
+	code, err := EnvironmentCode()
+	if err != nil {
+		t.Fatalf("EnvironmentCode() failed: %v", err)
+	}
+	if code != EnvVM {
+		t.Errorf("EnvironmentCode() = %d, want %d", code, EnvVM)
+	}
+}
+
+// =========================================================================================
+// Composite Fingerprint
+// =========================================================================================
+
+// mockSources replaces the fingerprint sources with fixed values.
+// A value of "" with a nil error is treated as an unavailable component.
+func mockSources(values map[string]string) func() []componentSource {
+	return func() []componentSource {
+		var sources []componentSource
+		for _, name := range []string{ComponentMachineID, ComponentDMIUUID, ComponentMAC, ComponentDiskSerial, ComponentCPU} {
+			v, ok := values[name]
+			sources = append(sources, componentSource{name, func() (string, error) {
+				if !ok {
+					return "", errors.New("unavailable")
+				}
+				return v, nil
+			}})
+		}
+		return sources
+	}
+}
+
+func TestCollectComponents(t *testing.T) {
+	defer func(orig func() []componentSource) { fingerprintSources = orig }(fingerprintSources)
+
+	fingerprintSources = mockSources(map[string]string{
+		ComponentMachineID: "machine",
+		ComponentMAC:       "   ",
+		ComponentCPU:       "GenuineIntel|Xeon",
+	})
+
+	components := collectComponents()
+	if len(components) != 5 {
+		t.Fatalf("expected 5 components, got %d", len(components))
+	}
+
+	// Order must be stable and unavailable components must be kept as records.
+	names := []string{ComponentMachineID, ComponentDMIUUID, ComponentMAC, ComponentDiskSerial, ComponentCPU}
+	for i, c := range components {
+		if c.Name != names[i] {
+			t.Errorf("component %d = %s, want %s", i, c.Name, names[i])
+		}
+	}
+
+	want, _ := protect("machine")
+	if components[0].Hash != want || components[0].Err != nil {
+		t.Errorf("machine-id component mismatch: %+v", components[0])
+	}
+	if components[1].Hash != "" || components[1].Err == nil {
+		t.Errorf("DMI UUID should be unavailable, got %+v", components[1])
+	}
+	// Whitespace-only values count as unavailable.
+	if components[2].Hash != "" || components[2].Err == nil {
+		t.Errorf("blank MAC should be unavailable, got %+v", components[2])
+	}
+
+	// The bridge used by machineid/x must be installed.
+	if bridge.Components == nil {
+		t.Error("bridge.Components is not installed")
+	}
+}
+
+// =========================================================================================
+// MAC Normalization
+// =========================================================================================
+
+func TestNormalizeMAC(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"aa:bb:cc:0d:0e:0f", "aa:bb:cc:0d:0e:0f", false},
+		{"AA-BB-CC-0D-0E-0F", "aa:bb:cc:0d:0e:0f", false}, // Windows
+		{"aa:bb:cc:d:e:f", "aa:bb:cc:0d:0e:0f", false},    // Stripped zero-padding
+		{"aabb.cc0d.0e0f", "aa:bb:cc:0d:0e:0f", false},    // Cisco
+		{"AABBCC0D0E0F", "aa:bb:cc:0d:0e:0f", false},      // Bare hex
+		{"  aa:bb:cc:0d:0e:0f\n", "aa:bb:cc:0d:0e:0f", false},
+		{"00:11:22:33:44:55:66:77", "00:11:22:33:44:55:66:77", false}, // EUI-64
+		{"", "", true},
+		{"aa:bb:cc", "", true},
+		{"gg:bb:cc:dd:ee:ff", "", true},
+		{"aaa:bb:cc:dd:ee:ff", "", true},
+		{"aa-bb:cc-dd:ee-ff", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeMAC(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeMAC(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeMAC(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestNormalizeMAC_Properties checks that every OS-specific rendering of the same
+// address normalizes to the same value, and that normalization is idempotent.
+func TestNormalizeMAC_Properties(t *testing.T) {
+	addrs := []net.HardwareAddr{
+		{0, 0, 0, 0, 0, 0},
+		{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+		{0xde, 0xad, 0xbe, 0xef, 0x00, 0x0a},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+
+	for _, addr := range addrs {
+		canonical := addr.String()
+		hexStr := hex.EncodeToString(addr)
+
+		stripped := make([]string, len(addr))
+		for i, b := range addr {
+			stripped[i] = strings.TrimPrefix(hex.EncodeToString([]byte{b}), "0")
+			if stripped[i] == "" {
+				stripped[i] = "0"
+			}
+		}
+
+		variants := []string{
+			canonical,
+			strings.ToUpper(strings.ReplaceAll(canonical, ":", "-")),
+			strings.Join(stripped, ":"),
+			hexStr[0:4] + "." + hexStr[4:8] + "." + hexStr[8:12],
+			strings.ToUpper(hexStr),
+		}
+
+		for _, v := range variants {
+			got, err := NormalizeMAC(v)
+			if err != nil {
+				t.Errorf("NormalizeMAC(%q) failed: %v", v, err)
+				continue
+			}
+			if got != canonical {
+				t.Errorf("NormalizeMAC(%q) = %q, want %q", v, got, canonical)
+			}
+			if again, _ := NormalizeMAC(got); again != got {
+				t.Errorf("NormalizeMAC is not idempotent: %q -> %q", got, again)
+			}
+		}
+	}
+}
+
+func FuzzNormalizeMAC(f *testing.F) {
+	for _, seed := range []string{"aa:bb:cc:dd:ee:ff", "AA-BB-CC-DD-EE-FF", "aabb.ccdd.eeff", "a:b:c:d:e:f", "", "zz"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		got, err := NormalizeMAC(s)
+		if err != nil {
+			return
+		}
+		// Output must be parseable, lowercase and stable under re-normalization.
+		if _, perr := net.ParseMAC(got); perr != nil {
+			t.Fatalf("NormalizeMAC(%q) = %q is not a valid MAC: %v", s, got, perr)
+		}
+		if got != strings.ToLower(got) {
+			t.Fatalf("NormalizeMAC(%q) = %q is not lowercase", s, got)
+		}
+		if again, err := NormalizeMAC(got); err != nil || again != got {
+			t.Fatalf("NormalizeMAC not idempotent for %q: %q -> %q (%v)", s, got, again, err)
+		}
+	})
+}
+
+// =========================================================================================
+// Exec Output Parsing (locale independence)
+// =========================================================================================
+
+// ioregPlistFixture is trimmed "ioreg -a -rd1 -c IOPlatformExpertDevice" output.
+// The plist keys are never localized, whatever the system language.
+const ioregPlistFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<array>
+	<dict>
+		<key>IOPlatformSerialNumber</key>
+		<string>C02XXXXXXXXX</string>
+		<key>IOPolledInterface</key>
+		<dict>
+			<key>IOPlatformUUID</key>
+			<integer>0</integer>
+		</dict>
+		<key>model</key>
+		<data>TWFjQm9va1BybzE2LDEA</data>
+		<key>IOPlatformUUID</key>
+		<string>564D8F2A-1B3C-4D5E-8F90-A1B2C3D4E5F6</string>
+		<key>product-name</key>
+		<string>MacBook Pro (16 pouces, 2019) — Überarbeitet 日本語</string>
+	</dict>
+</array>
+</plist>
+`
+
+func TestParsePlistString(t *testing.T) {
+	got, err := parsePlistString([]byte(ioregPlistFixture), "IOPlatformUUID")
+	if err != nil {
+		t.Fatalf("parsePlistString() failed: %v", err)
+	}
+	if got != "564D8F2A-1B3C-4D5E-8F90-A1B2C3D4E5F6" {
+		t.Errorf("parsePlistString() = %q", got)
+	}
+
+	// <data> values are decoded as NUL-terminated strings.
+	got, err = parsePlistString([]byte(ioregPlistFixture), "model")
+	if err != nil || got != "MacBookPro16,1" {
+		t.Errorf("parsePlistString() for data = %q, %v", got, err)
+	}
+
+	// Missing key: no error, empty value.
+	got, err = parsePlistString([]byte(ioregPlistFixture), "DoesNotExist")
+	if err != nil || got != "" {
+		t.Errorf("parsePlistString() for missing key = %q, %v", got, err)
+	}
+}
+
+func TestParseWmicValue(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want string
+	}{
+		{"English", "\r\r\nSerialNumber=S3Z9NB0K123456\r\r\n\r\r\n", "S3Z9NB0K123456"},
+		// Table-format headers are translated, /value keys are not.
+		{"German_Locale", "\r\nSerialNumber=WD-WX12A3456789\r\n", "WD-WX12A3456789"},
+		{"Japanese_Locale", "\r\nSerialNumber=ＪＰ１２３\r\n", "ＪＰ１２３"},
+		{"UTF16_Artifacts", "S\x00e\x00r\x00i\x00a\x00l\x00N\x00u\x00m\x00b\x00e\x00r\x00=\x00A\x00B\x00C\x00", "ABC"},
+		{"Skip_Empty_Values", "SerialNumber=\r\nSerialNumber=SECOND\r\n", "SECOND"},
+		{"Localized_Table_Output_Ignored", "Seriennummer\r\nXYZ\r\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseWmicValue([]byte(tt.out), "serialnumber"); got != tt.want {
+				t.Errorf("parseWmicValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// =========================================================================================
+// MachineInfo & Asset Tag Enrichment
+// =========================================================================================
+
+func TestInfo_AssetTag(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getEnvTypeFunc = func() string { return "physical" }
+	getMachineIDFunc = func() (string, error) { return "test-machine-id", nil }
+	defer func() {
+		getEnvTypeFunc = getEnvironmentType
+		getMachineIDFunc = getMachineID
+	}()
+
+	provider := func(ctx context.Context) (string, error) { return "ASSET-0042", nil }
+
+	// 1. Without a provider the tag is empty.
+	info, err := Info(context.Background())
+	if err != nil {
+		t.Fatalf("Info() failed: %v", err)
+	}
+	if info.AssetTag != "" || info.Environment != "physical" || info.Source != ComponentMachineID {
+		t.Errorf("unexpected info: %+v", info)
+	}
+	if id, _ := ID(); info.ID != id {
+		t.Errorf("Info().ID = %s, want %s", info.ID, id)
+	}
+
+	// 2. Raw tag is attached as-is and does not affect the ID.
+	tagged, err := Info(context.Background(), WithAssetTagProvider(provider))
+	if err != nil {
+		t.Fatalf("Info() failed: %v", err)
+	}
+	if tagged.AssetTag != "ASSET-0042" {
+		t.Errorf("AssetTag = %q, want raw tag", tagged.AssetTag)
+	}
+	if tagged.ID != info.ID {
+		t.Error("asset tag must not change the ID")
+	}
+
+	// 3. Hashed tag on opt-in.
+	hashed, err := Info(context.Background(), WithAssetTagProvider(provider), WithHashedAssetTag())
+	if err != nil {
+		t.Fatalf("Info() failed: %v", err)
+	}
+	if want, _ := protect("ASSET-0042"); hashed.AssetTag != want {
+		t.Errorf("AssetTag = %q, want %q", hashed.AssetTag, want)
+	}
+
+	// 4. Provider errors are surfaced.
+	failing := func(ctx context.Context) (string, error) { return "", errors.New("cmdb down") }
+	if _, err := Info(context.Background(), WithAssetTagProvider(failing)); err == nil {
+		t.Error("expected provider error")
+	}
+}
+
+// =========================================================================================
+// Network Change Re-resolution
+// =========================================================================================
+
+func TestWatchNetwork_FallbackIDChange(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	origGetMachineID, origNetInterfaces, origLinkEvents := getMachineIDFunc, netInterfaces, linkEventsFunc
+	defer func() {
+		getMachineIDFunc, netInterfaces, linkEventsFunc = origGetMachineID, origNetInterfaces, origLinkEvents
+		idChangeCallbacks = nil
+	}()
+
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	netInterfaces = mockInterfaces([]net.Interface{
+		{Name: "eth0", HardwareAddr: net.HardwareAddr{0xAA, 0, 0, 0, 0, 0x01}},
+	}, nil)
+
+	before, err := ID()
+	if err != nil {
+		t.Fatalf("ID() failed: %v", err)
+	}
+
+	events := make(chan struct{})
+	linkEventsFunc = func(ctx context.Context) (<-chan struct{}, error) { return events, nil }
+
+	changes := make(chan [2]string, 1)
+	OnIDChange(func(oldID, newID string) { changes <- [2]string{oldID, newID} })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- WatchNetwork(ctx) }()
+
+	// Swap the NIC and signal a link event.
+	netInterfaces = mockInterfaces([]net.Interface{
+		{Name: "eth0", HardwareAddr: net.HardwareAddr{0xAA, 0, 0, 0, 0, 0x02}},
+	}, nil)
+	events <- struct{}{}
+
+	change := <-changes
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("WatchNetwork() returned %v, want context.Canceled", err)
+	}
+
+	after, _ := ID()
+	if change[0] != before || change[1] != after || before == after {
+		t.Errorf("unexpected change notification %v (before %s, after %s)", change, before, after)
+	}
+}
+
+func TestReevaluateHardwareID_IgnoresOSSource(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	origGetMachineID, origNetInterfaces := getMachineIDFunc, netInterfaces
+	defer func() { getMachineIDFunc, netInterfaces = origGetMachineID, origNetInterfaces }()
+
+	getMachineIDFunc = func() (string, error) { return "os-id", nil }
+	before, _ := ID()
+
+	netInterfaces = mockInterfaces([]net.Interface{
+		{Name: "eth0", HardwareAddr: net.HardwareAddr{0xAA, 0, 0, 0, 0, 0x03}},
+	}, nil)
+	reevaluateHardwareID()
+
+	if after, _ := ID(); after != before {
+		t.Errorf("OS-sourced ID must not be re-evaluated: %s -> %s", before, after)
+	}
+}
+
+// =========================================================================================
+// Consistency Check
+// =========================================================================================
+
+func TestVerifyCurrentMatchesCached(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getEnvTypeFunc = func() string { return "physical" }
+	getMachineIDFunc = func() (string, error) { return "id-1", nil }
+	defer func() {
+		getEnvTypeFunc = getEnvironmentType
+		getMachineIDFunc = getMachineID
+	}()
+
+	ok, err := VerifyCurrentMatchesCached()
+	if err != nil || !ok {
+		t.Fatalf("expected match on first call, got %v, %v", ok, err)
+	}
+	before, _ := ID()
+
+	// 1. The live ID changes: mismatch is reported, cache is untouched.
+	getMachineIDFunc = func() (string, error) { return "id-2", nil }
+	if ok, err := VerifyCurrentMatchesCached(); err != nil || ok {
+		t.Errorf("expected mismatch after ID change, got %v, %v", ok, err)
+	}
+	if after, _ := ID(); after != before {
+		t.Error("VerifyCurrentMatchesCached() must not mutate the cache")
+	}
+	// Nor the source cache: a refresh of the ID still re-reads the cached machine ID.
+	Refresh(SourceEnvironment)
+	if after, _ := ID(); after != before {
+		t.Error("VerifyCurrentMatchesCached() must not drop the cached source values")
+	}
+
+	// 2. The environment changes: the prefix is part of the ID.
+	getMachineIDFunc = func() (string, error) { return "id-1", nil }
+	getEnvTypeFunc = func() string { return "vm" }
+	if ok, _ := VerifyCurrentMatchesCached(); ok {
+		t.Error("expected mismatch after environment change")
+	}
+
+	// 3. Live resolution fails: the error is surfaced.
+	getMachineIDFunc = func() (string, error) { return "", errors.New("permission denied") }
+	if _, err := VerifyCurrentMatchesCached(); err == nil {
+		t.Error("expected error when live resolution fails")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	raw := "id-1"
+	getMachineIDFunc = func() (string, error) { return raw, nil }
+	defer func() { getMachineIDFunc = getMachineID }()
+
+	first, err := ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1. The machine-id is regenerated: the cache keeps serving the old value...
+	raw = "id-2"
+	if id, _ := ID(WithCacheTTL(time.Hour)); id != first {
+		t.Error("a fresh cache must be served")
+	}
+
+	// 2. ...until it is older than the TTL, which re-resolves and updates the cache.
+	mu.Lock()
+	cachedAt = cachedAt.Add(-2 * time.Hour)
+	mu.Unlock()
+	second, _ := ID(WithCacheTTL(time.Hour))
+	if second == first {
+		t.Error("an expired cache must be re-resolved")
+	}
+	if id, _ := ID(); id != second {
+		t.Error("the re-resolution must update the cache")
+	}
+
+	// 3. WithNoCache re-resolves on every call.
+	raw = "id-3"
+	if id, _ := ID(WithNoCache()); id == second {
+		t.Error("WithNoCache must re-resolve")
+	}
+
+	// 4. Resolver instances honor the options too.
+	r := &Resolver{MachineID: func() (string, error) { return raw, nil }}
+	before, _ := r.ID()
+	raw = "id-4"
+	if id, _ := r.ID(WithCacheTTL(time.Hour)); id != before {
+		t.Error("a fresh Resolver cache must be served")
+	}
+	if id, _ := r.ID(WithNoCache()); id == before {
+		t.Error("WithNoCache must re-resolve the Resolver")
+	}
+}
+
+func TestUserID(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "shared-desktop", nil }
+	uid := "1000"
+	currentUserFunc = func() (string, error) { return uid, nil }
+	defer func() {
+		getMachineIDFunc = getMachineID
+		currentUserFunc = currentUser
+	}()
+
+	alice, err := UserID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine, _ := ID()
+	if alice == machine || !strings.HasPrefix(alice, strings.SplitN(machine, ":", 2)[0]+":") {
+		t.Errorf("expected a distinct ID with the environment prefix, got %q (ID %q)", alice, machine)
+	}
+	if again, _ := UserID(); again != alice {
+		t.Error("UserID must be stable")
+	}
+	appA, _ := ProtectedUserID("app-a")
+	appB, _ := ProtectedUserID("app-b")
+	if appA == appB || appA == alice {
+		t.Error("ProtectedUserID must differ per app and from UserID")
+	}
+
+	// Under DerivationSHA256, no ProtectedID input may reproduce the UserID hash.
+	for _, appID := range []string{uid, "user:" + uid} {
+		if p, _ := ProtectedID(appID); p == alice {
+			t.Errorf("UserID must differ from ProtectedID(%q)", appID)
+		}
+	}
+	if p, _ := ProtectedID("user:" + uid + ":app-a"); p == appA {
+		t.Error("ProtectedUserID must differ from ProtectedID(\"user:<uid>:<appID>\")")
+	}
+
+
+	uid = "1001"
+	if bob, _ := UserID(); bob == alice {
+		t.Error("users must get distinct IDs")
+	}
+	if bobA, _ := ProtectedUserID("app-a"); bobA == appA {
+		t.Error("users must get distinct protected IDs")
+	}
+
+	currentUserFunc = func() (string, error) { return "", errors.ErrUnsupported }
+	var srcErr *SourceError
+	if _, err := UserID(); !errors.As(err, &srcErr) || srcErr.Source != SourceUser {
+		t.Errorf("expected a SourceError, got %v", err)
+	}
+
+	// The identity of the running user.
+	if id, err := currentUser(); err != nil || id == "" {
+		t.Errorf("currentUser() = %q, %v", id, err)
+	}
+}
+
+// =========================================================================================
+// OpenTelemetry host.id
+// =========================================================================================
+
+func TestHostID(t *testing.T) {
+	defer func() { getHostIDFunc = getHostID }()
+
+	// The raw value is returned as-is (trimmed), without hashing or prefix.
+	getHostIDFunc = func() (string, error) { return " 4c4c4544-0042-3510-8052-b4c04f4e4d32\n", nil }
+	id, err := HostID()
+	if err != nil {
+		t.Fatalf("HostID() failed: %v", err)
+	}
+	if id != "4c4c4544-0042-3510-8052-b4c04f4e4d32" {
+		t.Errorf("HostID() = %q", id)
+	}
+
+	getHostIDFunc = func() (string, error) { return "", nil }
+	if _, err := HostID(); err == nil {
+		t.Error("expected error for empty host id")
+	}
+
+	getHostIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	if _, err := HostID(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
+// =========================================================================================
+// AppID Registry
+// =========================================================================================
+
+func TestAppIDSkeleton(t *testing.T) {
+	similar := [][2]string{
+		{"My-App", "myapp"},
+		{"my_app", "MY.APP"},
+		{"app1", "appl"},
+		{"c0rp", "corp"},
+		{"modern", "rnodem"},
+	}
+	for _, pair := range similar {
+		if appIDSkeleton(pair[0]) != appIDSkeleton(pair[1]) {
+			t.Errorf("expected %q and %q to be similar", pair[0], pair[1])
+		}
+	}
+
+	if appIDSkeleton("billing") == appIDSkeleton("shipping") {
+		t.Error("unrelated appIDs must not be similar")
+	}
+}
+
+func TestProtectedID_CollisionWarning(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "id", nil }
+	defer func() { getMachineIDFunc = getMachineID }()
+
+	appIDMu.Lock()
+	appIDRegistry = nil
+	appIDMu.Unlock()
+
+	var buf strings.Builder
+	SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer SetLogger(nil)
+
+	ProtectedID("billing-service")
+	ProtectedID("billing-service") // Same appID again: no warning.
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected warning: %s", buf.String())
+	}
+
+	ProtectedID("Billing_Service")
+	if !strings.Contains(buf.String(), "visually similar") || !strings.Contains(buf.String(), "machineid_test.go") {
+		t.Errorf("expected collision warning with call site, got: %s", buf.String())
+	}
+
+	ids := RegisteredAppIDs()
+	if len(ids) != 2 || ids[0] != "Billing_Service" || ids[1] != "billing-service" {
+		t.Errorf("RegisteredAppIDs() = %v", ids)
+	}
+}
+
+func TestTrackAppID_Concurrency(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			trackAppID("concurrent-app", callerSite(0))
+			RegisteredAppIDs()
+		}(i)
+	}
+	wg.Wait()
+}
+
+// =========================================================================================
+// macOS CI VM Classification
+// =========================================================================================
+
+func TestClassifyMacVM(t *testing.T) {
+	existsOnly := func(paths ...string) func(string) bool {
+		return func(p string) bool {
+			for _, want := range paths {
+				if p == want {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	vz := macHardware{model: "VirtualMac2,1"}
+	tests := []struct {
+		name   string
+		hw     macHardware
+		exists func(string) bool
+		want   string
+	}{
+		{"Tart", vz, existsOnly("/opt/homebrew/bin/tart-guest-agent"), "tart"},
+		{"Anka", vz, existsOnly("/Library/Application Support/Veertu"), "anka"},
+		{"Orka_Wins_Over_Tart", vz, existsOnly("/usr/local/bin/orka-vm-tools", "/usr/local/bin/tart-guest-agent"), "orka"},
+		{"UTM_SPICE", vz, existsOnly("/usr/local/bin/spice-vdagent"), "utm"},
+		{"UTM_QEMU", macHardware{model: "QEMU Virtual Machine"}, existsOnly(), "utm"},
+		{"Tart_By_Board_ID", macHardware{model: "Mac14,2", boardID: "VMA2MACOSAP"}, existsOnly("/opt/homebrew/bin/tart-guest-agent"), "tart"},
+		{"Tart_Agent_Under_QEMU", macHardware{model: "QEMU Virtual Machine"}, existsOnly("/opt/homebrew/bin/tart-guest-agent"), "utm"},
+		{"Plain_Apple_Virtualization", vz, existsOnly(), "apple"},
+		{"Agents_Without_Hardware_Signal", macHardware{model: "iMacPro1,1", boardID: "Mac-7BA5B2D9E42DDD94"}, existsOnly("/opt/homebrew/bin/tart-guest-agent", "/Library/Application Support/Veertu"), ""},
+		{"Unknown_Hypervisor", macHardware{model: "iMacPro1,1"}, existsOnly(), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyMacVM(tt.hw, tt.exists); got != tt.want {
+				t.Errorf("classifyMacVM(%+v) = %q, want %q", tt.hw, got, tt.want)
+			}
+		})
+	}
+}
+
+// =========================================================================================
+// Presets
+// =========================================================================================
+
+func TestPresets(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	origGetMachineID, origNetInterfaces := getMachineIDFunc, netInterfaces
+	defer func() { getMachineIDFunc, netInterfaces = origGetMachineID, origNetInterfaces }()
+
+	getMachineIDFunc = func() (string, error) { return "4c4c4544-0042-3510-8052-b4c04f4d4e32", nil }
+	sum := sha256.Sum256([]byte("4c4c4544-0042-3510-8052-b4c04f4d4e32"))
+
+	lic, err := ID(PresetLicensing())
+	if err != nil || !strings.HasSuffix(lic, ":"+hex.EncodeToString(sum[:])) {
+		t.Errorf("PresetLicensing: got %q, %v", lic, err)
+	}
+
+	tel, _ := ID(PresetTelemetry())
+	if !strings.HasSuffix(tel, ":"+base64.RawURLEncoding.EncodeToString(sum[:])[:22]) {
+		t.Errorf("PresetTelemetry: got %q", tel)
+	}
+
+	clu, _ := ID(PresetClustering())
+	if !strings.HasSuffix(clu, ":"+crockfordEncoding.EncodeToString(sum[:])) {
+		t.Errorf("PresetClustering: got %q", clu)
+	}
+
+	// Later options override the preset.
+	if short, _ := ID(PresetClustering(), WithLength(8)); !strings.HasSuffix(short, ":"+crockfordEncoding.EncodeToString(sum[:])[:8]) {
+		t.Errorf("override: got %q", short)
+	}
+
+	// Licensing rejects IDs derived from the MAC fallback.
+	resetCache()
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	netInterfaces = mockInterfaces([]net.Interface{
+		{Name: "eth0", HardwareAddr: net.HardwareAddr{0xAA, 0, 0, 0, 0, 0x01}},
+	}, nil)
+
+	if _, err := ID(PresetLicensing()); !errors.Is(err, ErrFallbackRejected) {
+		t.Errorf("PresetLicensing with MAC fallback: expected ErrFallbackRejected, got %v", err)
+	}
+	if _, err := ProtectedID("app", PresetTelemetry()); err != nil {
+		t.Errorf("PresetTelemetry must accept the MAC fallback: %v", err)
+	}
+
+	// Licensing rejects IDs derived from the gethostid(2) value, although 32 bits pass
+	// DefaultMinEntropy.
+	resetCache()
+	netInterfaces = mockInterfaces(nil, nil)
+	defer func(get func() (string, error)) { getLegacyHostIDFunc = get }(getLegacyHostIDFunc)
+	getLegacyHostIDFunc = func() (string, error) { return "00a8c0de", nil }
+	if _, err := ID(PresetLicensing()); !errors.Is(err, ErrFallbackRejected) {
+		t.Errorf("PresetLicensing with hostid fallback: expected ErrFallbackRejected, got %v", err)
+	}
+}
+
+func TestWithoutHardwareFallback(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	netInterfaces = mockInterfaces([]net.Interface{
+		{Name: "eth0", HardwareAddr: net.HardwareAddr{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0xcc}},
+	}, nil)
+	defer func() {
+		getMachineIDFunc = getMachineID
+		netInterfaces = net.Interfaces
+	}()
+
+	if _, err := ID(); err != nil {
+		t.Fatalf("the MAC fallback applies by default: %v", err)
+	}
+	if _, err := ID(WithoutHardwareFallback()); !errors.Is(err, ErrFallbackRejected) {
+		t.Errorf("expected ErrFallbackRejected, got %v", err)
+	}
+	if _, err := ProtectedID("app", WithoutHardwareFallback()); !errors.Is(err, ErrFallbackRejected) {
+		t.Errorf("expected ErrFallbackRejected, got %v", err)
+	}
+	r := &Resolver{Options: []Option{WithoutHardwareFallback()}}
+	if _, err := r.ID(); !errors.Is(err, ErrFallbackRejected) {
+		t.Errorf("expected ErrFallbackRejected from the Resolver, got %v", err)
+	}
+
+	// The generated ID replaces the MAC fallback.
+	path := filepath.Join(t.TempDir(), "machine-id")
+	defer generatedIDs.Delete(path)
+	if info, err := Info(context.Background(), WithoutHardwareFallback(), WithGeneratedFallback(path)); err != nil || info.Source != SourcePersisted {
+		t.Errorf("expected the generated ID, got %+v, %v", info, err)
+	}
+
+	// An OS source is unaffected.
+	getMachineIDFunc = func() (string, error) { return "os-id", nil }
+	resetCache()
+	if _, err := ID(WithoutHardwareFallback()); err != nil {
+		t.Errorf("expected the OS-sourced ID, got %v", err)
+	}
+}
+
+func TestWithMACQuorum(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	nic := func(name string, last byte) net.Interface {
+		return net.Interface{Name: name, HardwareAddr: net.HardwareAddr{0x00, 0x1b, 0x21, 0xaa, 0xbb, last}}
+	}
+	setNICs := func(ifaces ...net.Interface) {
+		netInterfaces = mockInterfaces(ifaces, nil)
+		Refresh(ComponentMAC)
+	}
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	defer func() {
+		getMachineIDFunc = getMachineID
+		netInterfaces = net.Interfaces
+	}()
+
+	store := FileStore(filepath.Join(t.TempDir(), "mac-anchor.json"))
+	opt := WithMACQuorum(store, 2)
+
+	setNICs(nic("eth0", 1), nic("eth1", 2), nic("eth2", 3))
+	plain, _ := ID()
+	anchored, err := ID(opt)
+	if err != nil || anchored == plain {
+		t.Fatalf("expected the ID to be derived from the anchor: %q, %q, %v", plain, anchored, err)
+	}
+	if id, _ := ID(opt); id != anchored {
+		t.Errorf("expected the anchor to hold, got %q", id)
+	}
+	if a, err := loadMACAnchor(store); err != nil || len(a.MACs) != 3 || a.Salt == "" || a.Anchor == "" {
+		t.Errorf("expected a salted anchor of 3 addresses, got %+v, %v", a, err)
+	}
+	// The store never holds the addresses themselves.
+	data, _ := store.Load()
+	for _, mac := range []string{"00:1b:21:aa:bb:01", "00:1b:21:aa:bb:02", "00:1b:21:aa:bb:03"} {
+		if strings.Contains(string(data), mac) {
+			t.Errorf("the store leaks %s: %s", mac, data)
+		}
+	}
+
+	// 1. A USB dongle is plugged in and one NIC is replaced: 2 of 3 anchored NICs remain.
+	setNICs(nic("eth0", 1), nic("eth1", 2), nic("eth3", 4), nic("usb0", 5))
+	if id, _ := ID(); id == plain {
+		t.Fatal("the plain fallback must change")
+	}
+	if id, err := ID(opt); err != nil || id != anchored {
+		t.Errorf("expected the anchored ID within the quorum, got %q, %v", id, err)
+	}
+
+	// 2. Below the quorum, the current set is anchored.
+	setNICs(nic("eth0", 1), nic("eth3", 4))
+	current, _ := ID(opt)
+	if current == anchored {
+		t.Error("expected a new anchor below the quorum")
+	}
+	setNICs(nic("eth0", 1), nic("eth3", 4), nic("usb0", 5))
+	if id, _ := ID(opt); id != current {
+		t.Errorf("expected the new anchor to hold, got %q", id)
+	}
+
+	// 3. The default quorum is a majority: 1 of 2 anchored NICs is not enough.
+	setNICs(nic("eth3", 4), nic("eth4", 6))
+	if id, _ := ID(WithMACQuorum(store, 0)); id == current {
+		t.Error("expected a new anchor below the majority")
+	}
+
+	// The anchor is cached: later resolutions don't read the store.
+	counting := &countingStore{Store: FileStore(filepath.Join(t.TempDir(), "mac-anchor.json"))}
+	for range 3 {
+		if _, err := ID(WithMACQuorum(counting, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counting.loads != 1 {
+		t.Errorf("expected the store to be loaded once, got %d", counting.loads)
+	}
+
+	// IDs from other sources are not affected.
+	getMachineIDFunc = func() (string, error) { return "os-id", nil }
+	resetCache()
+	want, _ := ID()
+	if id, _ := ID(opt); id != want {
+		t.Errorf("expected %q, got %q", want, id)
+	}
+}
+
+// countingStore counts the loads of a Store.
+type countingStore struct {
+	Store
+	loads int
+}
+
+func (s *countingStore) Load() ([]byte, error) {
+	s.loads++
+	return s.Store.Load()
+}
+
+// =========================================================================================
+// DMI Strings & Sanitization
+// =========================================================================================
+
+func TestInfo_DMISanitization(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "id", nil }
+	getDMIStringsFunc = func() (DMIInfo, error) {
+		return DMIInfo{Vendor: "LENOVO", Product: " 20XW0026GE\n", Family: ""}, nil
+	}
+	defer func() {
+		getMachineIDFunc = getMachineID
+		getDMIStringsFunc = getDMIStrings
+	}()
+
+	vendorHash, _ := protect("LENOVO")
+	productHash, _ := protect("20XW0026GE")
+
+	tests := []struct {
+		name string
+		opts []Option
+		want DMIInfo
+	}{
+		{"Default_Hashed", nil, DMIInfo{Vendor: vendorHash, Product: productHash}},
+		{"Redacted", []Option{WithDMISanitization(SanitizeRedacted)}, DMIInfo{Vendor: RedactedValue, Product: RedactedValue}},
+		{"None", []Option{WithDMISanitization(SanitizeNone)}, DMIInfo{Vendor: "LENOVO", Product: "20XW0026GE"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Info(context.Background(), tt.opts...)
+			if err != nil {
+				t.Fatalf("Info() failed: %v", err)
+			}
+			if info.DMI != tt.want {
+				t.Errorf("DMI = %+v, want %+v", info.DMI, tt.want)
+			}
+		})
+	}
+
+	// DMI errors never fail Info().
+	getDMIStringsFunc = func() (DMIInfo, error) { return DMIInfo{}, os.ErrPermission }
+	if info, err := Info(context.Background()); err != nil || info.DMI != (DMIInfo{}) {
+		t.Errorf("expected empty DMI without error, got %+v, %v", info, err)
+	}
+}
+
+// =========================================================================================
+// SMBIOS Parsing (Windows GetSystemFirmwareTable)
+// =========================================================================================
+
+// buildRawSMBIOS assembles a RawSMBIOSData blob for the given version and structures.
+func buildRawSMBIOS(major, minor byte, structures ...[]byte) []byte {
+	var table []byte
+	for _, s := range structures {
+		table = append(table, s...)
+	}
+	// End-of-Table (Type 127) followed by trailing garbage beyond Length.
+	table = append(table, 127, 4, 0xFF, 0xFE, 0, 0)
+
+	buf := []byte{0, major, minor, 0, 0, 0, 0, 0}
+	buf[4] = byte(len(table))
+	buf[5] = byte(len(table) >> 8)
+	return append(append(buf, table...), 0xDE, 0xAD)
+}
+
+// smbiosType1 builds a System Information structure with the given UUID bytes and strings.
+func smbiosType1(uuid []byte, strs ...string) []byte {
+	s := make([]byte, 0x1B)
+	s[0], s[1] = 1, 0x1B
+	s[4] = 1 // Manufacturer -> string 1
+	copy(s[8:24], uuid)
+	return appendStrings(s, strs...)
+}
+
+func appendStrings(s []byte, strs ...string) []byte {
+	for _, str := range strs {
+		s = append(append(s, str...), 0)
+	}
+	if len(strs) == 0 {
+		s = append(s, 0)
+	}
+	return append(s, 0)
+}
+
+func TestFindUUIDInSMBIOS(t *testing.T) {
+	uuid := []byte{0x44, 0x45, 0x4c, 0x4c, 0x42, 0x00, 0x10, 0x35, 0x80, 0x52, 0xb4, 0xc0, 0x4f, 0x4e, 0x4d, 0x32}
+	// A BIOS Information structure (Type 0) with strings, preceding Type 1.
+	bios := appendStrings([]byte{0, 0x12, 0, 0, 1, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, "Dell Inc.", "1.2.3")
+
+	tests := []struct {
+		name    string
+		buf     []byte
+		want    string
+		wantErr bool
+	}{
+		{"SMBIOS_2.6_Mixed_Endian", buildRawSMBIOS(3, 4, bios, smbiosType1(uuid, "Dell Inc.")), "4c4c4544-0042-3510-8052-b4c04f4e4d32", false},
+		{"SMBIOS_2.4_Mixed_Endian", buildRawSMBIOS(2, 4, smbiosType1(uuid)), "4c4c4544-0042-3510-8052-b4c04f4e4d32", false},
+		{"Unconfigured_UUID", buildRawSMBIOS(3, 0, smbiosType1(make([]byte, 16))), "", false},
+		{"No_Type1", buildRawSMBIOS(3, 0, bios), "", true},
+		{"Truncated", buildRawSMBIOS(3, 0, bios)[:12], "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := parseRawSMBIOS(tt.buf)
+			if err != nil {
+				t.Fatalf("parseRawSMBIOS() failed: %v", err)
+			}
+			got, err := findUUIDInSMBIOS(raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findUUIDInSMBIOS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("findUUIDInSMBIOS() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseRawSMBIOS([]byte{1, 2, 3}); err == nil {
+		t.Error("expected error for short buffer")
+	}
+}
+
+func TestWalkSMBIOS_Strings(t *testing.T) {
+	raw, _ := parseRawSMBIOS(buildRawSMBIOS(3, 4, smbiosType1(make([]byte, 16), "LENOVO", "20XW")))
+
+	var got smbiosStructure
+	walkSMBIOS(raw.table, func(s smbiosStructure) bool {
+		got = s
+		return s.typ != 1
+	})
+
+	if got.typ != 1 || got.str(4) != "LENOVO" || len(got.strings) != 2 || got.str(5) != "" {
+		t.Errorf("unexpected structure: %+v", got)
+	}
+}
+
+func TestFindSerialInSMBIOS(t *testing.T) {
+	t1 := smbiosType1(make([]byte, 16), "Dell Inc.", "PowerEdge", "1.0", "7XK2M93")
+	t1[5], t1[6], t1[7] = 2, 3, 4 // Product, Version, Serial Number string indexes.
+
+	// Baseboard (Type 2): Manufacturer, Product, Version, Serial Number string indexes.
+	t2 := appendStrings([]byte{2, 8, 0, 0, 1, 2, 3, 4}, "Dell Inc.", "0H3YGD", "A00", "/7XK2M93/CNFCW0012345/")
+
+	raw, _ := parseRawSMBIOS(buildRawSMBIOS(3, 4, t1, t2))
+	if got := findSerialInSMBIOS(raw, smbiosSystemInformation); got != "7XK2M93" {
+		t.Errorf("findSerialInSMBIOS() = %q", got)
+	}
+	if got := findSerialInSMBIOS(raw, smbiosBaseboard); got != "/7XK2M93/CNFCW0012345/" {
+		t.Errorf("findSerialInSMBIOS(baseboard) = %q", got)
+	}
+
+	for _, s := range []string{"", "To Be Filled By O.E.M.", "Default string", "0000000", "System Serial Number", "Base Board Serial Number"} {
+		if !isPlaceholderSerial(s) {
+			t.Errorf("isPlaceholderSerial(%q) = false", s)
+		}
+	}
+	if isPlaceholderSerial("7XK2M93") {
+		t.Error("real serial reported as placeholder")
+	}
+}
+
+func TestLoadInfo_ReportsPlatformSource(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "guid", nil }
+	machineIDSource = func() string { return SourceMachineGuid }
+	defer func() {
+		getMachineIDFunc = getMachineID
+		machineIDSource = func() string { return ComponentMachineID }
+	}()
+
+	info, err := Info(context.Background())
+	if err != nil {
+		t.Fatalf("Info() failed: %v", err)
+	}
+	if info.Source != SourceMachineGuid {
+		t.Errorf("Info().Source = %q, want %q", info.Source, SourceMachineGuid)
+	}
+}
+
+// =========================================================================================
+// Container Uniqueness Self-Test
+// =========================================================================================
+
+func TestEnableContainerScoping(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	dir := t.TempDir()
+	getMachineIDFunc = func() (string, error) { return "shared-host-id", nil }
+	defer func() {
+		getMachineIDFunc = getMachineID
+		containerKeyFunc = containerKey
+	}()
+
+	// 1. First container: no collision, ID untouched.
+	containerKeyFunc = func() string { return "container-a" }
+	hostID, _ := ID()
+	if collided, err := EnableContainerScoping(dir); err != nil || collided {
+		t.Fatalf("first container: got %v, %v", collided, err)
+	}
+	if id, _ := ID(); id != hostID {
+		t.Error("ID must not change without a collision")
+	}
+
+	// 2. Second container on the same host: collision, switch to container scope.
+	resetCache()
+	containerKeyFunc = func() string { return "container-b" }
+	collided, err := EnableContainerScoping(dir)
+	if err != nil || !collided {
+		t.Fatalf("second container: got %v, %v", collided, err)
+	}
+	scoped, _ := ID()
+	if scoped == hostID {
+		t.Error("ID must be container-scoped after a collision")
+	}
+
+	// The scope survives every re-resolution, and a later call refreshes the registration.
+	Refresh()
+	if id, _ := ID(); id != scoped {
+		t.Error("Refresh must keep the container scope")
+	}
+	if id, _ := ID(WithNoCache()); id != scoped {
+		t.Error("WithNoCache must keep the container scope")
+	}
+	if ok, err := VerifyCurrentMatchesCached(); err != nil || !ok {
+		t.Errorf("the re-resolved ID must match the scoped one, got %v, %v", ok, err)
+	}
+	if collided, err := EnableContainerScoping(dir); err != nil || !collided {
+		t.Errorf("a later call must report the scope, got %v, %v", collided, err)
+	}
+	if id, _ := ID(); id != scoped {
+		t.Error("a later call must not scope the ID twice")
+	}
+
+	// 3. Stale entries are ignored.
+	resetCache()
+	defer func(ttl time.Duration) { rendezvousTTL = ttl }(rendezvousTTL)
+	rendezvousTTL = -time.Second
+	containerKeyFunc = func() string { return "container-c" }
+	if collided, _ := EnableContainerScoping(dir); collided {
+		t.Error("stale entries must not count as collisions")
+	}
+
+	// The shared directory must never contain the raw ID.
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), "shared-host-id") || strings.Contains(e.Name(), "container-") {
+			t.Errorf("entry leaks raw data: %s", e.Name())
+		}
+	}
+}
+
+func TestContainerScopingAfterFallbackPolicy(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	getSecondaryMachineIDFunc = func() (string, error) { return "baked-dbus-id", nil }
+	netInterfaces = mockInterfaces([]net.Interface{
+		{Name: "Ethernet", HardwareAddr: net.HardwareAddr{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0x01}},
+	}, nil)
+	defer func(get func() (string, error)) {
+		getMachineIDFunc = getMachineID
+		getSecondaryMachineIDFunc = get
+		netInterfaces = net.Interfaces
+		containerKeyFunc = containerKey
+		idChangeCallbacks = nil
+	}(getSecondaryMachineIDFunc)
+
+	dir := t.TempDir()
+	containerKeyFunc = func() string { return "container-a" }
+	if _, err := EnableContainerScoping(dir); err != nil {
+		t.Fatal(err)
+	}
+	v2 := WithRulesVersion(2)
+	quorum := WithMACQuorum(FileStore(filepath.Join(t.TempDir(), "mac-anchor.json")), 0)
+	hostMAC, _ := ID()
+	hostV2, _ := ID(v2)
+	hostQuorum, _ := ID(quorum)
+
+	containerKeyFunc = func() string { return "container-b" }
+	if collided, err := EnableContainerScoping(dir); err != nil || !collided {
+		t.Fatalf("second container: got %v, %v", collided, err)
+	}
+
+	// 1. Steps of the fallback policy replacing the raw ID keep the scope.
+	if id, _ := ID(v2); id == hostV2 {
+		t.Error("the D-Bus machine-id of the version 2 rules must be container-scoped")
+	}
+	if id, _ := ID(quorum); id == hostQuorum {
+		t.Error("the anchored MAC set must be container-scoped")
+	}
+
+	// 2. A link event without a NIC change doesn't undo the scope, and a change is reported
+	// with scoped IDs.
+	scoped, _ := ID()
+	if scoped == hostMAC {
+		t.Fatal("the MAC fallback must be container-scoped")
+	}
+	changes := make(chan [2]string, 2)
+	OnIDChange(func(oldID, newID string) { changes <- [2]string{oldID, newID} })
+	reevaluateHardwareID()
+	if id, _ := ID(); id != scoped || len(changes) != 0 {
+		t.Errorf("a link event without a NIC change must keep the scoped ID, got %q, %d changes", id, len(changes))
+	}
+
+	netInterfaces = mockInterfaces([]net.Interface{
+		{Name: "Ethernet", HardwareAddr: net.HardwareAddr{0x00, 0x1b, 0x21, 0xaa, 0xbb, 0x02}},
+	}, nil)
+	reevaluateHardwareID()
+	after, _ := ID()
+	if len(changes) != 1 {
+		t.Fatalf("expected one change notification, got %d", len(changes))
+	}
+	if change := <-changes; change[0] != scoped || change[1] != after || after == scoped {
+		t.Errorf("unexpected change notification %v (before %s, after %s)", change, scoped, after)
+	}
+	key := containerScope.Swap(nil)
+	unscoped, _ := ID()
+	containerScope.Store(key)
+	if unscoped == after {
+		t.Error("the new MAC-derived ID must stay container-scoped")
+	}
+}
+
+// =========================================================================================
+// Binary Encodings
+// =========================================================================================
+
+func TestMachineInfoBinaryEncodings(t *testing.T) {
+	info := &MachineInfo{
+		ID:          "vm:ab",
+		Environment: "vm",
+		Source:      ComponentMachineID,
+		DMI:         DMIInfo{Vendor: "QEMU"},
+	}
+
+	// Keys sorted by length, then bytewise: id, env, dmi, source. Empty fields omitted.
+	wantCBOR := []byte{
+		0xa4,
+		0x62, 'i', 'd', 0x65, 'v', 'm', ':', 'a', 'b',
+		0x63, 'd', 'm', 'i', 0xa1, 0x66, 'v', 'e', 'n', 'd', 'o', 'r', 0x64, 'Q', 'E', 'M', 'U',
+		0x63, 'e', 'n', 'v', 0x62, 'v', 'm',
+		0x66, 's', 'o', 'u', 'r', 'c', 'e', 0x6a, 'm', 'a', 'c', 'h', 'i', 'n', 'e', '-', 'i', 'd',
+	}
+	got, err := info.MarshalCBOR()
+	if err != nil || !bytes.Equal(got, wantCBOR) {
+		t.Errorf("CBOR mismatch:\n got %x\nwant %x (err %v)", got, wantCBOR, err)
+	}
+
+	wantMsgpack := []byte{
+		0x84,
+		0xa2, 'i', 'd', 0xa5, 'v', 'm', ':', 'a', 'b',
+		0xa3, 'd', 'm', 'i', 0x81, 0xa6, 'v', 'e', 'n', 'd', 'o', 'r', 0xa4, 'Q', 'E', 'M', 'U',
+		0xa3, 'e', 'n', 'v', 0xa2, 'v', 'm',
+		0xa6, 's', 'o', 'u', 'r', 'c', 'e', 0xaa, 'm', 'a', 'c', 'h', 'i', 'n', 'e', '-', 'i', 'd',
+	}
+	got, err = info.MarshalMsgpack()
+	if err != nil || !bytes.Equal(got, wantMsgpack) {
+		t.Errorf("msgpack mismatch:\n got %x\nwant %x (err %v)", got, wantMsgpack, err)
+	}
+
+	// Long strings use the multi-byte length forms.
+	long := strings.Repeat("x", 300)
+	got, _ = (&MachineInfo{ID: long}).MarshalCBOR()
+	if !bytes.Equal(got[:6], []byte{0xa1, 0x62, 'i', 'd', 0x79, 0x01}) || got[6] != 0x2c {
+		t.Errorf("unexpected CBOR head for long string: %x", got[:7])
+	}
+}
+
+func TestMachineInfoJSON(t *testing.T) {
+	info := MachineInfo{
+		ID:          "vm:ab",
+		Environment: "vm",
+		Source:      ComponentMachineID,
+		DMI:         DMIInfo{Vendor: "QEMU"},
+		Kubernetes:  &KubernetesInfo{Namespace: "default", Pod: "web-0"},
+		Degradation: DegradationFallback,
+	}
+
+	got, err := json.Marshal(info)
+	want := `{"schema":1,"id":"vm:ab","env":"vm","source":"machine-id","dmi":{"vendor":"QEMU"},"k8s":{"namespace":"default","pod":"web-0"},"degradation":"fallback"}`
+	if err != nil || string(got) != want {
+		t.Errorf("JSON mismatch:\n got %s\nwant %s (err %v)", got, want, err)
+	}
+
+	var decoded MachineInfo
+	if err := json.Unmarshal(got, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, info) {
+		t.Errorf("round trip mismatch: %+v", decoded)
+	}
+
+	for _, bad := range []string{`{"id":"vm:ab"}`, `{"schema":2}`, `{"schema":1,"degradation":"worse"}`} {
+		if err := json.Unmarshal([]byte(bad), &decoded); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+}
+
+// =========================================================================================
+// Source Priority
+// =========================================================================================
+
+func TestSetSourcePriority(t *testing.T) {
+	resetCache()
+	defer resetCache()
+	defer SetSourcePriority()
+
+	optionalSources["test-denied"] = func() (string, error) { return "", os.ErrPermission }
+	optionalSources["test-missing"] = func() (string, error) { return "", os.ErrNotExist }
+	defer func() {
+		delete(optionalSources, "test-denied")
+		delete(optionalSources, "test-missing")
+	}()
+
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	netInterfaces = mockInterfaces([]net.Interface{
+		{Name: "eth0", HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}},
+	}, nil)
+	defer func() {
+		getMachineIDFunc = getMachineID
+		netInterfaces = net.Interfaces
+	}()
+
+	// 1. Unknown sources are rejected.
+	if err := SetSourcePriority("no-such-source"); err == nil {
+		t.Error("expected an error for an unknown source")
+	}
+
+	// 2. All sources missing: MAC fallback as usual.
+	if err := SetSourcePriority("test-missing", ComponentMachineID); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err := load(); err != nil || snap.source != ComponentMAC {
+		t.Errorf("expected MAC fallback, got %+v, %v", snap, err)
+	}
+
+	// 3. A real failure with no success is reported instead of silently falling back.
+	if err := SetSourcePriority("test-denied", "test-missing"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := load(); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("expected ErrPermission, got %v", err)
+	}
+
+	// 4. A later source rescues an earlier failure.
+	getMachineIDFunc = func() (string, error) { return "os-id", nil }
+	if err := SetSourcePriority("test-denied", ComponentMachineID); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err := load(); err != nil || snap.rawID != "os-id" {
+		t.Errorf("expected machine-id, got %+v, %v", snap, err)
+	}
+}
+
+func TestParallelSources(t *testing.T) {
+	resetCache()
+	defer resetCache()
+	defer SetSourcePriority()
+
+	slow := func(d time.Duration, id string, err error) func() (string, error) {
+		return func() (string, error) {
+			time.Sleep(d)
+			return id, err
+		}
+	}
+	cancelled := make(chan struct{})
+	optionalSources["test-slow-missing"] = slow(100*time.Millisecond, "", os.ErrNotExist)
+	optionalSources["test-slow-ok"] = slow(100*time.Millisecond, "slow-id", nil)
+	optionalSources["test-fast-ok"] = slow(0, "fast-id", nil)
+	optionalSources["test-blocking"] = func() (string, error) { select {} }
+	sourceResolvers["test-blocking"] = func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return "", ctx.Err()
+	}
+	defer func() {
+		for _, name := range []string{"test-slow-missing", "test-slow-ok", "test-fast-ok", "test-blocking"} {
+			delete(optionalSources, name)
+		}
+		delete(sourceResolvers, "test-blocking")
+	}()
+
+	// Priorities are kept: a faster, lower-priority source doesn't win.
+	if err := SetSourcePriority("test-slow-ok", "test-fast-ok"); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err := load(); err != nil || snap.rawID != "slow-id" {
+		t.Errorf("expected the first source, got %+v, %v", snap, err)
+	}
+
+	// Sources are probed concurrently: the chain takes as long as its slowest needed source,
+	// and sources still running once the answer is known are cancelled.
+	if err := SetSourcePriority("test-slow-missing", "test-slow-ok", "test-fast-ok", "test-blocking"); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if snap, err := load(); err != nil || snap.rawID != "slow-id" || snap.source != "test-slow-ok" {
+		t.Errorf("expected the second source, got %+v, %v", snap, err)
+	}
+	if elapsed := time.Since(start); elapsed > 180*time.Millisecond {
+		t.Errorf("sources were probed serially: %v", elapsed)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("the remaining source was not cancelled")
+	}
+}
+
+func TestResolver(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "global-id", nil }
+	defer func() { getMachineIDFunc = getMachineID }()
+
+	raw := "tenant-a"
+	a := &Resolver{
+		MachineID:   func() (string, error) { return raw, nil },
+		Environment: func() string { return "vm" },
+		Options:     []Option{Short()},
+	}
+	b := &Resolver{
+		MachineID:   func() (string, error) { return "", os.ErrNotExist },
+		Environment: func() string { return "physical" },
+		Interfaces: mockInterfaces([]net.Interface{
+			{Name: "eth0", HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}},
+		}, nil),
+	}
+
+	// Each Resolver uses its own sources and options, and leaves the package state alone.
+	idA, err := a.ID()
+	if err != nil || !strings.HasPrefix(idA, "vm:") || len(idA) != len("vm:")+ShortLength {
+		t.Fatalf("unexpected ID %q, %v", idA, err)
+	}
+	if full, _ := a.ID(WithLength(0)); len(full) == len(idA) {
+		t.Error("call options must override the Resolver options")
+	}
+	idB, err := b.ID()
+	if err != nil || !strings.HasPrefix(idB, "physical:") {
+		t.Fatalf("unexpected ID %q, %v", idB, err)
+	}
+	if snap, _ := b.load(options{}); snap.source != ComponentMAC {
+		t.Errorf("expected the MAC fallback, got %+v", snap)
+	}
+	if snap, err := load(); err != nil || snap.rawID != "global-id" {
+		t.Errorf("the package state must be unaffected, got %+v, %v", snap, err)
+	}
+	if p, _ := a.ProtectedID("app"); p == idA {
+		t.Error("ProtectedID must differ from ID")
+	}
+
+	// Results are cached until Refresh.
+	raw = "tenant-a-rotated"
+	if again, _ := a.ID(); again != idA {
+		t.Error("the Resolver must cache its resolution")
+	}
+	a.Refresh()
+	if rotated, _ := a.ID(); rotated == idA {
+		t.Error("Refresh must re-run the sources")
+	}
+
+	// Concurrent use is safe.
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			a.ID()
+			a.Refresh()
+		})
+	}
+	wg.Wait()
+}
+
+// assetTagSource is a custom Source reading a fixed value.
+type assetTagSource struct {
+	value string
+	err   error
+}
+
+func (assetTagSource) Name() string { return "test-asset-tag" }
+
+func (s assetTagSource) Resolve(context.Context) (string, error) { return s.value, s.err }
+
+func TestRegisterSource(t *testing.T) {
+	resetCache()
+	defer resetCache()
+	defer SetSourcePriority()
+	defer delete(optionalSources, "test-asset-tag")
+	defer delete(sourceResolvers, "test-asset-tag")
+
+	getMachineIDFunc = func() (string, error) { return "os-id", nil }
+	defer func() { getMachineIDFunc = getMachineID }()
+
+	src := &assetTagSource{value: "ASSET-0042"}
+	if err := RegisterSource(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterSource(src); err == nil {
+		t.Error("expected an error for a duplicate source")
+	}
+	if err := RegisterSource(assetTagSourceNamed(ComponentMAC)); err == nil {
+		t.Error("expected an error for a reserved name")
+	}
+	if !slices.ContainsFunc(Sources(), func(d SourceDescriptor) bool {
+		return d.Name == "test-asset-tag" && d.Role == SourceRoleOptional
+	}) {
+		t.Error("registered source missing from Sources()")
+	}
+
+	// The custom source takes precedence when named first.
+	if err := SetSourcePriority("test-asset-tag", ComponentMachineID); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err := load(); err != nil || snap.rawID != "ASSET-0042" || snap.source != "test-asset-tag" {
+		t.Errorf("expected the asset tag, got %+v, %v", snap, err)
+	}
+
+	// A missing value moves on to the next source.
+	src.err = os.ErrNotExist
+	resetCache()
+	if snap, err := load(); err != nil || snap.rawID != "os-id" {
+		t.Errorf("expected machine-id, got %+v, %v", snap, err)
+	}
+}
+
+// assetTagSourceNamed is a Source with an arbitrary name.
+type assetTagSourceNamed string
+
+func (s assetTagSourceNamed) Name() string { return string(s) }
+
+func (assetTagSourceNamed) Resolve(context.Context) (string, error) { return "", nil }
+
+// =========================================================================================
+// Report Signing
+// =========================================================================================
+
+func TestSignReport(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(nil)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	signers := map[string]crypto.Signer{"ed25519": edKey, "ecdsa": ecKey}
+	for name, signer := range signers {
+		t.Run(name, func(t *testing.T) {
+			report := &MachineInfo{ID: "vm:abc", Environment: "vm", Source: ComponentMachineID}
+
+			sig, err := SignReport(report, signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyReport(report, signer.Public(), sig); err != nil {
+				t.Errorf("valid signature rejected: %v", err)
+			}
+
+			// Any modification of the report invalidates the signature.
+			tampered := *report
+			tampered.Environment = "physical"
+			if err := VerifyReport(&tampered, signer.Public(), sig); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("expected ErrInvalidSignature, got %v", err)
+			}
+		})
+	}
+
+	if err := VerifyReport(&MachineInfo{}, "not a key", nil); err == nil || errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected an unsupported key error, got %v", err)
+	}
+}
+
+// =========================================================================================
+// Environment Variable Capture
+// =========================================================================================
+
+func TestWithEnvCapture(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "test-id", nil }
+	defer func() { getMachineIDFunc = getMachineID }()
+
+	t.Setenv("NODE_NAME", "worker-1")
+	t.Setenv("POD_UID", "0b9f-uid")
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", "http://169.254.170.2/v4/abc")
+	t.Setenv("SECRET_TOKEN", "must-not-leak")
+
+	// 1. Nothing is captured without the option.
+	info, err := Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Env != nil {
+		t.Errorf("expected no env capture by default, got %v", info.Env)
+	}
+
+	// 2. Only allow-listed variables are captured, with their redaction rules.
+	info, err = Info(context.Background(), WithEnvCapture(OrchestrationEnv()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uidHash, _ := protect("0b9f-uid")
+	want := map[string]string{
+		"NODE_NAME":                     "worker-1",
+		"POD_UID":                       uidHash,
+		"ECS_CONTAINER_METADATA_URI_V4": RedactedValue,
+	}
+	if len(info.Env) != len(want) {
+		t.Errorf("expected %d variables, got %v", len(want), info.Env)
+	}
+	for k, v := range want {
+		if info.Env[k] != v {
+			t.Errorf("Env[%s] = %q, want %q", k, info.Env[k], v)
+		}
+	}
+
+	// 3. The captured variables are part of the canonical encoding, in sorted order.
+	a, _ := info.MarshalCBOR()
+	b, _ := info.MarshalCBOR()
+	if !bytes.Equal(a, b) || !bytes.Contains(a, []byte("env_vars")) {
+		t.Error("env vars must be encoded deterministically")
+	}
+}
+
+func TestParseSystemProfilerHardware(t *testing.T) {
+	// Labels in the text output are localized; the JSON keys are not.
+	const fixture = `{
+  "SPHardwareDataType" : [
+    {
+      "_name" : "hardware_overview",
+      "machine_name" : "MacBook Pro",
+      "platform_UUID" : "564D8F2A-1B3C-4D5E-8F90-A1B2C3D4E5F6",
+      "serial_number" : "C02XK0ABJGH5"
+    }
+  ]
+}`
+	uuid, serial, err := parseSystemProfilerHardware([]byte(fixture))
+	if err != nil || uuid != "564D8F2A-1B3C-4D5E-8F90-A1B2C3D4E5F6" || serial != "C02XK0ABJGH5" {
+		t.Errorf("parseSystemProfilerHardware() = %q, %q, %v", uuid, serial, err)
+	}
+
+	if _, _, err := parseSystemProfilerHardware([]byte("Hardware:\n  Seriennummer: X")); err == nil {
+		t.Error("expected an error for text output")
+	}
+}
+
+func TestParseStorageDeviceSerial(t *testing.T) {
+	// STORAGE_DEVICE_DESCRIPTOR header (36 bytes) followed by the strings.
+	desc := make([]byte, 36)
+	desc = append(desc, "Samsung SSD\x00"...)
+	binary.LittleEndian.PutUint32(desc[24:], uint32(len(desc)))
+	desc = append(desc, "  S4EWNX0R123456 \x00"...)
+
+	got, err := parseStorageDeviceSerial(desc)
+	if err != nil || got != "S4EWNX0R123456" {
+		t.Errorf("parseStorageDeviceSerial() = %q, %v", got, err)
+	}
+
+	// No serial (offset 0), truncated and out-of-range descriptors.
+	binary.LittleEndian.PutUint32(desc[24:], 0)
+	if _, err := parseStorageDeviceSerial(desc); err == nil {
+		t.Error("expected an error for a missing serial")
+	}
+	binary.LittleEndian.PutUint32(desc[24:], 4096)
+	if _, err := parseStorageDeviceSerial(desc); err == nil {
+		t.Error("expected an error for an out-of-range offset")
+	}
+	if _, err := parseStorageDeviceSerial(desc[:10]); err == nil {
+		t.Error("expected an error for a truncated descriptor")
+	}
+}
+
+// =========================================================================================
+// Per-Source Cache
+// =========================================================================================
+
+func TestRefreshPerSource(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	defer func(orig func() []componentSource) { fingerprintSources = orig }(fingerprintSources)
+
+	var envCalls, idCalls, cpuCalls int
+	getEnvTypeFunc = func() string { envCalls++; return "vm" }
+	getMachineIDFunc = func() (string, error) { idCalls++; return "id-1", nil }
+	fingerprintSources = func() []componentSource {
+		return []componentSource{
+			{ComponentMachineID, getMachineIDFunc},
+			{ComponentCPU, func() (string, error) { cpuCalls++; return "cpu", nil }},
+		}
+	}
+	defer func() {
+		getEnvTypeFunc = getEnvironmentType
+		getMachineIDFunc = getMachineID
+	}()
+
+	id1, _ := ID()
+	collectComponents()
+	collectComponents()
+	if envCalls != 1 || idCalls != 1 || cpuCalls != 1 {
+		t.Fatalf("sources must be resolved once: env=%d id=%d cpu=%d", envCalls, idCalls, cpuCalls)
+	}
+
+	// 1. Refreshing a fingerprint component doesn't touch the ID sources.
+	Refresh(ComponentCPU)
+	collectComponents()
+	ID()
+	if envCalls != 1 || idCalls != 1 || cpuCalls != 2 {
+		t.Errorf("only the CPU must be re-run: env=%d id=%d cpu=%d", envCalls, idCalls, cpuCalls)
+	}
+
+	// 2. Refreshing the machine-id re-resolves the ID, but not the environment.
+	getMachineIDFunc = func() (string, error) { idCalls++; return "id-2", nil }
+	Refresh(ComponentMachineID)
+	id2, _ := ID()
+	if id2 == id1 || envCalls != 1 || idCalls != 2 {
+		t.Errorf("expected a new ID without env detection: env=%d id=%d", envCalls, idCalls)
+	}
+
+	// 3. Refresh() without arguments re-runs everything. The ID and the fingerprint share
+	// the machine-id entry, so it still runs only once.
+	Refresh()
+	ID()
+	collectComponents()
+	if envCalls != 2 || idCalls != 3 || cpuCalls != 3 {
+		t.Errorf("expected every source to re-run: env=%d id=%d cpu=%d", envCalls, idCalls, cpuCalls)
+	}
+}
+
+// =========================================================================================
+// TupleHash Derivation
+// =========================================================================================
+
+func TestTupleHash256(t *testing.T) {
+	// NIST SP 800-185 TupleHash256 sample #4.
+	got := tupleHash256([]string{"\x00\x01\x02", "\x10\x11\x12\x13\x14\x15"}, "", 64)
+	want := "cfb7058caca5e668f81a12a20a2195ce97a925f1dba3e7449a56f82201ec607311ac2696b1ab5ea2352df1423bde7bd4bb78c9aed1a853c78672f9eb23bbe194"
+	if hex.EncodeToString(got) != want {
+		t.Errorf("tupleHash256() = %x", got)
+	}
+}
+
+func TestWithDerivationTupleHash(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getEnvTypeFunc = func() string { return "physical" }
+	getMachineIDFunc = func() (string, error) { return "a:b", nil }
+	defer func() {
+		getEnvTypeFunc = getEnvironmentType
+		getMachineIDFunc = getMachineID
+	}()
+
+	th := WithDerivation(DerivationTupleHash)
+
+	// 1. The default derivation is unchanged; TupleHash produces different IDs.
+	legacy, _ := ID()
+	tuple, _ := ID(th)
+	if legacy == tuple || !strings.HasPrefix(tuple, "physical:") || len(tuple) != len(legacy) {
+		t.Errorf("unexpected IDs: %q, %q", legacy, tuple)
+	}
+
+	// 2. ":"-joining is ambiguous, tuples are not: ("a:b", "c") vs ("a", "b:c").
+	p1, _ := ProtectedID("c", th)
+	resetCache()
+	getMachineIDFunc = func() (string, error) { return "a", nil }
+	p2, _ := ProtectedID("b:c", th)
+	if p1 == p2 {
+		t.Error("TupleHash must separate tuple elements")
+	}
+	l1, _ := ProtectedID("b:c")
+	resetCache()
+	getMachineIDFunc = func() (string, error) { return "a:b", nil }
+	l2, _ := ProtectedID("c")
+	if l1 != l2 {
+		t.Error("the default derivation is expected to keep its historical behavior")
+	}
+
+	// 3. Digest size: hex length follows, and a short digest is not a prefix of a long one.
+	short, _ := ID(th, WithDigestSize(16))
+	long, _ := ID(th, WithDigestSize(64))
+	if len(short) != len("physical:")+32 || len(long) != len("physical:")+128 {
+		t.Errorf("unexpected lengths: %q, %q", short, long)
+	}
+	if strings.HasPrefix(long, short) {
+		t.Error("digest size must be an input of the derivation")
+	}
+}
+
+// =========================================================================================
+// Dual-Stack Identity
+// =========================================================================================
+
+func TestDualStackID(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	store := FileStore(path)
+
+	getEnvTypeFunc = func() string { return "physical" }
+	getMachineIDFunc = func() (string, error) { return "image-a", nil }
+	defer func() {
+		getEnvTypeFunc = getEnvironmentType
+		getMachineIDFunc = getMachineID
+	}()
+
+	// 1. First run: no previous identity.
+	ds, err := DualStackID(store)
+	if err != nil || ds.Previous != "" {
+		t.Fatalf("first run: %+v, %v", ds, err)
+	}
+	oldID := ds.Current
+	if fi, err := os.Stat(path); err != nil || (runtime.GOOS != "windows" && fi.Mode().Perm() != 0o600) {
+		t.Errorf("state file must be private: %v, %v", fi, err)
+	}
+
+	// 2. Reimage: machine-id regenerated. Previous is reported, also after a restart.
+	resetCache()
+	getMachineIDFunc = func() (string, error) { return "image-b", nil }
+	for range 2 {
+		ds, err = DualStackID(store)
+		if err != nil || ds.Previous != oldID || ds.Current == oldID {
+			t.Fatalf("after reimage: %+v, %v", ds, err)
+		}
+	}
+
+	// Options and appIDs apply to both identities.
+	pds, _ := DualStackProtectedID(store, "app", Short())
+	wantPrev, _ := formatID("physical", domainProtectedID, []string{"image-a", "app"}, []Option{Short()})
+	if pds.Previous != wantPrev {
+		t.Errorf("DualStackProtectedID().Previous = %q, want %q", pds.Previous, wantPrev)
+	}
+
+	// 3. Commit: the previous identity is forgotten.
+	if err := CommitDualStack(store); err != nil {
+		t.Fatal(err)
+	}
+	if ds, _ = DualStackID(store); ds.Previous != "" {
+		t.Errorf("previous identity must be forgotten after commit, got %q", ds.Previous)
+	}
+}
+
+func TestMigrationNeeded(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getEnvTypeFunc = func() string { return "physical" }
+	getMachineIDFunc = func() (string, error) { return "image-a", nil }
+	currentRules = 1
+	defer func() {
+		getEnvTypeFunc = getEnvironmentType
+		getMachineIDFunc = getMachineID
+		getMachineIDV2Func = nil
+		currentRules = RulesVersion
+	}()
+
+	// 1. First run records the binding.
+	store := FileStore(filepath.Join(t.TempDir(), "rules.json"))
+	if needed, err := MigrationNeeded(store); err != nil || needed {
+		t.Fatalf("first run: %v, %v", needed, err)
+	}
+
+	// 2. Reimaging without a rules update is not a migration.
+	resetCache()
+	getMachineIDFunc = func() (string, error) { return "image-b", nil }
+	if needed, err := MigrationNeeded(store); err != nil || needed {
+		t.Errorf("value change without rules update: %v, %v", needed, err)
+	}
+
+	// 3. Neither is reimaging next to a rules update that keeps the value: it is recorded silently.
+	resetCache()
+	getMachineIDFunc = func() (string, error) { return "image-c", nil }
+	currentRules = 2
+	if needed, err := MigrationNeeded(store); err != nil || needed {
+		t.Errorf("rules update without change: %v, %v", needed, err)
+	}
+	if state, _ := loadMigrationState(store); state.Rules != 2 {
+		t.Errorf("expected the rules update to be recorded, got %+v", state)
+	}
+
+	// 4. A rules update that changes the source asks for a migration, until committed.
+	currentRules = 1
+	store = FileStore(filepath.Join(t.TempDir(), "rules.json"))
+	if needed, err := MigrationNeeded(store); err != nil || needed {
+		t.Fatalf("first run: %v, %v", needed, err)
+	}
+	currentRules = 2
+	getMachineIDV2Func = func() (string, string, error) { return "machine-guid", SourceMachineGuid, nil }
+	for range 2 {
+		if needed, err := MigrationNeeded(store); err != nil || !needed {
+			t.Fatalf("rules update: %v, %v", needed, err)
+		}
+	}
+	if err := CommitMigration(store); err != nil {
+		t.Fatal(err)
+	}
+	if needed, _ := MigrationNeeded(store); needed {
+		t.Error("migration must not be reported after commit")
+	}
+	if state, _ := loadMigrationState(store); state.Rules != 2 || state.Source != SourceMachineGuid {
+		t.Errorf("expected the binding under the new rules, got %+v", state)
+	}
+}
+
+func TestWithRulesVersion(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getEnvTypeFunc = func() string { return "physical" }
+	getMachineIDFunc = func() (string, error) { return "test-id", nil }
+	defer func() {
+		getEnvTypeFunc = getEnvironmentType
+		getMachineIDFunc = getMachineID
+	}()
+
+	th := WithDerivation(DerivationTupleHash)
+	v1, _ := ProtectedID("app", th)
+	if same, _ := ProtectedID("app", th, WithRulesVersion(1)); same != v1 {
+		t.Errorf("rules version 1 must keep the original tag: %q != %q", same, v1)
+	}
+	if v2, _ := ProtectedID("app", th, WithRulesVersion(2)); v2 == v1 {
+		t.Error("rules version 2 must change the tag")
+	}
+
+	// The SHA256 derivation has no tag.
+	sha, _ := ProtectedID("app")
+	if same, _ := ProtectedID("app", WithRulesVersion(2)); same != sha {
+		t.Errorf("DerivationSHA256 must ignore the rules version: %q != %q", same, sha)
+	}
+}
+
+func TestMachineIDV2Rules(t *testing.T) {
+	resetCache()
+	defer resetCache()
+	defer SetSourcePriority()
+
+	getMachineIDFunc = func() (string, error) { return "smbios-uuid", nil }
+	getMachineIDV2Func = func() (string, string, error) { return "machine-guid", SourceMachineGuid, nil }
+	defer func() {
+		getMachineIDFunc = getMachineID
+		getMachineIDV2Func = nil
+	}()
+
+	// The version 1 rules keep the original chain; version 2 reads the platform machine ID with
+	// its own chain.
+	if snap, err := (options{}).load(); err != nil || snap.rawID != "smbios-uuid" {
+		t.Fatalf("expected the version 1 chain, got %+v, %v", snap, err)
+	}
+	snap, err := options{rulesVersion: 2}.load()
+	if err != nil || snap.rawID != "machine-guid" || snap.source != SourceMachineGuid {
+		t.Errorf("expected the version 2 chain, got %+v, %v", snap, err)
+	}
+	v1, _ := ID()
+	if v2, _ := ID(WithRulesVersion(2)); v2 == v1 {
+		t.Error("the version 2 rules must derive the ID from their own chain")
+	}
+
+	// Sources selected with SetSourcePriority are kept.
+	optionalSources["test-v2"] = func() (string, error) { return "priority-id", nil }
+	defer delete(optionalSources, "test-v2")
+	if err := SetSourcePriority("test-v2", ComponentMachineID); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err := (options{rulesVersion: 2}).load(); err != nil || snap.rawID != "priority-id" {
+		t.Errorf("expected the prioritized source, got %+v, %v", snap, err)
+	}
+}
+
+func TestOptionsApplyToEveryResolution(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "smbios-uuid", nil }
+	getMachineIDV2Func = func() (string, string, error) { return "machine-guid", SourceMachineGuid, nil }
+	defer func() {
+		getMachineIDFunc = getMachineID
+		getMachineIDV2Func = nil
+	}()
+
+	v2 := WithRulesVersion(2)
+	want, _ := ID(v2)
+	ds, err := DualStackID(FileStore(filepath.Join(t.TempDir(), "dualstack.json")), v2)
+	if err != nil || ds.Current != want {
+		t.Errorf("DualStackID().Current = %q, %v, want %q", ds.Current, err, want)
+	}
+	if report := Doctor(v2); report.Err != nil || report.Source != SourceMachineGuid {
+		t.Errorf("Doctor must diagnose the version 2 resolution, got %q, %v", report.Source, report.Err)
+	}
+	if ok, err := VerifyCurrentMatchesCached(v2); err != nil || !ok {
+		t.Errorf("VerifyCurrentMatchesCached() = %v, %v", ok, err)
+	}
+}
+
+func TestEphemeralID(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getBootIDFunc = func() (string, error) { return "6f1c4e0a-boot-1", nil }
+	containerKeyFunc = func() string { return "sandbox-a" }
+	getMachineIDFunc = func() (string, error) { return "6f1c4e0a-boot-1", nil }
+	defer func() {
+		getBootIDFunc = getBootID
+		containerKeyFunc = containerKey
+		getMachineIDFunc = getMachineID
+	}()
+
+	id, err := EphemeralID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(id, EphemeralPrefix+":") {
+		t.Errorf("EphemeralID() = %q, want the %q prefix", id, EphemeralPrefix)
+	}
+	if again, _ := EphemeralID(); again != id {
+		t.Errorf("EphemeralID() must be stable within a boot: %q != %q", again, id)
+	}
+	if machine, _ := ID(); SameMachine(machine, id) {
+		t.Error("EphemeralID() must never equal ID()")
+	}
+
+	// A new sandbox or a reboot gets a new ID.
+	containerKeyFunc = func() string { return "sandbox-b" }
+	if other, _ := EphemeralID(); other == id {
+		t.Error("EphemeralID() must change with the container")
+	}
+	containerKeyFunc = func() string { return "sandbox-a" }
+	getBootIDFunc = func() (string, error) { return "6f1c4e0a-boot-2", nil }
+	if other, _ := EphemeralID(); other == id {
+		t.Error("EphemeralID() must change with the boot")
+	}
+
+	getBootIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	var se *SourceError
+	if _, err := EphemeralID(); !errors.As(err, &se) || se.Source != SourceBootID || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a boot-id SourceError, got %v", err)
+	}
+}
+
+func TestBootID(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getBootIDFunc = func() (string, error) { return "6f1c4e0a-boot-1", nil }
+	getMachineIDFunc = func() (string, error) { return "machine-a", nil }
+	defer func() {
+		getBootIDFunc = getBootID
+		getMachineIDFunc = getMachineID
+	}()
+
+	id, err := BootID()
+	if err != nil || !strings.HasPrefix(id, BootPrefix+":") {
+		t.Fatalf("BootID() = %q, %v", id, err)
+	}
+	if again, _ := BootID(); again != id {
+		t.Error("BootID() must be stable within a boot")
+	}
+	if ephemeral, _ := EphemeralID(); strings.SplitN(ephemeral, ":", 2)[1] == strings.SplitN(id, ":", 2)[1] {
+		t.Error("BootID() must differ from EphemeralID()")
+	}
+	for _, appID := range []string{"6f1c4e0a-boot-1", "boot:6f1c4e0a-boot-1"} {
+		if p, _ := ProtectedID(appID); strings.SplitN(p, ":", 2)[1] == strings.SplitN(id, ":", 2)[1] {
+			t.Errorf("BootID() must differ from ProtectedID(%q)", appID)
+		}
+	}
+
+	// A reboot rotates it; machines sharing a boot counter (Windows) still differ.
+	getBootIDFunc = func() (string, error) { return "6f1c4e0a-boot-2", nil }
+	if other, _ := BootID(); other == id {
+		t.Error("BootID() must change with the boot")
+	}
+	getBootIDFunc = func() (string, error) { return "6f1c4e0a-boot-1", nil }
+	getMachineIDFunc = func() (string, error) { return "machine-b", nil }
+	resetCache()
+	if other, _ := BootID(); other == id {
+		t.Error("BootID() must change with the machine")
+	}
+
+	getBootIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	var se *SourceError
+	if _, err := BootID(); !errors.As(err, &se) || se.Source != SourceBootID || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a boot-id SourceError, got %v", err)
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	err := errors.Join(
+		&SourceError{Source: "ioreg", Err: fmt.Errorf("%w: IOPlatformUUID not found", ErrParse)},
+		&SourceError{Source: "system_profiler", Err: os.ErrNotExist},
+	)
+
+	var se *SourceError
+	if !errors.As(err, &se) || se.Source != "ioreg" {
+		t.Errorf("errors.As() = %v", se)
+	}
+	if !errors.Is(err, ErrParse) || !strings.Contains(err.Error(), "source system_profiler") {
+		t.Errorf("unexpected error chain: %v", err)
+	}
+}
+
+// =========================================================================================
+// Node Feature Discovery
+// =========================================================================================
+
+func TestWriteNFDFeatures(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getEnvTypeFunc = func() string { return "vm" }
+	getMachineIDFunc = func() (string, error) { return "test-id", nil }
+	defer func() {
+		getEnvTypeFunc = getEnvironmentType
+		getMachineIDFunc = getMachineID
+	}()
+
+	if detail := getEnvironmentDetail(); detail != "" {
+		t.Skipf("environment detail %q present on this host", detail)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteNFDFeatures(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	short, _ := ID(Short())
+	want := "machineid.env=vm\n" +
+		"machineid.source=machine-id\n" +
+		"machineid.id=" + strings.TrimPrefix(short, "vm:") + "\n"
+	if buf.String() != want {
+		t.Errorf("WriteNFDFeatures() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestNFDLabelValue(t *testing.T) {
+	tests := map[string]string{
+		"vm/apple":               "vm-apple",
+		"-leading.and.trailing_": "leading.and.trailing",
+		strings.Repeat("a", 70):  strings.Repeat("a", 63),
+		"":                       "",
+	}
+	for in, want := range tests {
+		if got := nfdLabelValue(in); got != want {
+			t.Errorf("nfdLabelValue(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseID(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getEnvTypeFunc = func() string { return "vm" }
+	getMachineIDFunc = func() (string, error) { return "test-id", nil }
+	defer func() {
+		getEnvTypeFunc = getEnvironmentType
+		getMachineIDFunc = getMachineID
+	}()
+
+	hexID, _ := ID()
+	bare, _ := ID(WithoutPrefix())
+	b64, _ := ProtectedID("app", WithEncoding(Base64URL))
+	short, _ := ID(Short())
+	for _, s := range []string{hexID, bare, b64, short} {
+		id, err := ParseID(s)
+		if err != nil {
+			t.Errorf("ParseID(%q) failed: %v", s, err)
+			continue
+		}
+		if id.String() != s || !SameMachine(id.Hash(), s) {
+			t.Errorf("ParseID(%q) = %+v", s, id)
+		}
+	}
+	if id, _ := ParseID(hexID); id.Prefix() != "vm" || id.Hash() != bare {
+		t.Errorf("unexpected parts %q and %q", id.Prefix(), id.Hash())
+	}
+	if id, _ := ParseID(bare); id.Prefix() != "" {
+		t.Errorf("a bare hash must have no prefix, got %q", id.Prefix())
+	}
+
+	for _, s := range []string{"", "vm:", ":abc", "VM:abc", "vm:ab c", "vm:abc:def", "vm:" + strings.Repeat("a", 129)} {
+		if _, err := ParseID(s); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("ParseID(%q) = %v, want ErrInvalidID", s, err)
+		}
+	}
+
+	// Text round trip, e.g. through JSON.
+	var decoded struct{ ID MachineID }
+	data, err := json.Marshal(struct{ ID MachineID }{mustParseID(t, hexID)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.ID.String() != hexID {
+		t.Errorf("JSON round trip: %s -> %v, %v", data, decoded.ID, err)
+	}
+	if _, err := json.Marshal(MachineID{}); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("the zero MachineID must not marshal, got %v", err)
+	}
+}
+
+func mustParseID(t *testing.T, s string) MachineID {
+	t.Helper()
+	id, err := ParseID(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestWithSeparator(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getEnvTypeFunc = func() string { return "vm" }
+	getMachineIDFunc = func() (string, error) { return "test-id", nil }
+	defer func() {
+		getEnvTypeFunc = getEnvironmentType
+		getMachineIDFunc = getMachineID
+	}()
+
+	id, _ := ID()
+	bare, _ := ID(WithoutPrefix())
+	if got, _ := ID(WithSeparator("_")); got != "vm_"+bare {
+		t.Errorf("ID(WithSeparator(\"_\")) = %q, want %q", got, "vm_"+bare)
+	}
+	if got, _ := ID(WithSeparator("")); got != id {
+		t.Errorf("an empty separator must keep \":\", got %q", got)
+	}
+	if got, _ := ID(WithSeparator("_"), WithoutPrefix()); got != bare {
+		t.Errorf("WithoutPrefix must win over WithSeparator, got %q", got)
+	}
+}
+
+// =========================================================================================
+// Environment Prefix Drift
+// =========================================================================================
+
+func TestPrefixDrift(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	var buf bytes.Buffer
+	SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer SetLogger(nil)
+
+	env := "physical"
+	getEnvTypeFunc = func() string { return env }
+	getMachineIDFunc = func() (string, error) { return "same-raw-id", nil }
+	defer func() {
+		getEnvTypeFunc = getEnvironmentType
+		getMachineIDFunc = getMachineID
+	}()
+
+	before, _ := ID()
+	bare, _ := ID(WithoutPrefix())
+	if strings.Contains(bare, ":") || !strings.HasSuffix(before, ":"+bare) {
+		t.Errorf("WithoutPrefix() = %q, ID() = %q", bare, before)
+	}
+
+	// P2V migration: same raw ID, new environment class.
+	env = "vm"
+	Refresh(SourceEnvironment)
+	after, _ := ID()
+	if after == before {
+		t.Fatal("expected the prefixed ID to change")
+	}
+	if !SameMachine(before, after) || SameMachine(before, "physical:other") {
+		t.Error("SameMachine() must ignore the environment prefix only")
+	}
+	if !strings.Contains(buf.String(), "old_prefix=physical") || !strings.Contains(buf.String(), "new_prefix=vm") {
+		t.Errorf("expected a prefix drift warning, got %q", buf.String())
+	}
+
+	// The dual-stack store flags the drift across runs.
+	store := FileStore(filepath.Join(t.TempDir(), "state.json"))
+	env = "physical"
+	Refresh(SourceEnvironment)
+	DualStackID(store)
+	env = "vm"
+	Refresh(SourceEnvironment)
+	ds, err := DualStackID(store)
+	if err != nil || !ds.PrefixDrift || ds.Previous != before {
+		t.Errorf("expected prefix drift in dual stack, got %+v, %v", ds, err)
+	}
+}
+
+// =========================================================================================
+// AppID Normalization
+// =========================================================================================
+
+func TestResolutionTrace(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	var buf bytes.Buffer
+	SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer SetLogger(nil)
+
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	netInterfaces = mockInterfaces([]net.Interface{
+		{Name: "eth0", HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}},
+		{Name: "docker0", HardwareAddr: net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x01}},
+	}, nil)
+	defer func() {
+		getMachineIDFunc = getMachineID
+		netInterfaces = net.Interfaces
+	}()
+
+	if _, err := ID(); err != nil {
+		t.Fatal(err)
+	}
+	trace := buf.String()
+	for _, want := range []string{
+		"source tried", "source=machine-id",
+		"falling back to MAC addresses",
+		"interface skipped", "interface=docker0 reason=virtual",
+		"ID resolved", "source=mac", "raw_hash=",
+	} {
+		if !strings.Contains(trace, want) {
+			t.Errorf("trace is missing %q:\n%s", want, trace)
+		}
+	}
+	if strings.Contains(trace, "00:11:22:33:44:55") {
+		t.Error("the trace must not contain the raw ID")
+	}
+
+	// Below debug level, nothing is traced.
+	buf.Reset()
+	SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	resetCache()
+	ID()
+	if buf.Len() != 0 {
+		t.Errorf("unexpected output at info level:\n%s", buf.String())
+	}
+}
+
+func TestAppIDNormalization(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "test-id", nil }
+	defer func() { getMachineIDFunc = getMachineID }()
+
+	composed := "Caf\u00e9"    // é as a single code point (NFC).
+	decomposed := "Cafe\u0301" // e + combining acute accent (NFD).
+
+	// 1. Default: LatinNFC unifies the composed and decomposed spellings.
+	a, _ := ProtectedID(composed)
+	b, _ := ProtectedID(decomposed)
+	if a != b {
+		t.Error("appIDs must be composed to NFC by default")
+	}
+	if def, _ := ProtectedID(composed, WithAppIDNormalizer(LatinNFC)); def != a {
+		t.Error("LatinNFC must be the default normalizer")
+	}
+
+	// 2. Opt-out: a nil normalizer uses the appID byte-for-byte.
+	raw, _ := ProtectedID(decomposed, WithAppIDNormalizer(nil))
+	if raw == b {
+		t.Error("a nil normalizer must use the appID byte-for-byte")
+	}
+	if raw, _ := ProtectedID(composed, WithAppIDNormalizer(nil)); raw != a {
+		t.Error("composed appIDs must not depend on the normalizer")
+	}
+
+	// 3. A custom normalizer replaces the default.
+	fold := func(appID string) string { return strings.ToLower(LatinNFC(appID)) }
+	a, _ = ProtectedID("CAF\u00c9", WithAppIDNormalizer(fold))
+	b, _ = ProtectedID(decomposed, WithAppIDNormalizer(fold))
+	if a != b {
+		t.Error("the normalizer must be applied before derivation")
+	}
+	ascii, _ := ProtectedID("my-app", WithAppIDNormalizer(nil))
+	if normalized, _ := ProtectedID("my-app"); normalized != ascii {
+		t.Error("the default normalizer must not change ASCII appIDs")
+	}
+}
+
+func TestLatinNFC(t *testing.T) {
+	tests := map[string]string{
+		"my-app":        "my-app",
+		"Cafe\u0301":    "Caf\u00e9",
+		"Caf\u00e9":     "Caf\u00e9",
+		"e\u0302\u0323": "\u1ec7", // marks out of canonical order: ệ
+		"e\u0323\u0302": "\u1ec7",
+		"\u00ea\u0323":  "\u1ec7", // precomposed base plus a lower mark
+		"a\u0301\u0301": "\u00e1\u0301",
+		"x\u0301":       "x\u0301", // no precomposed form
+		"\u0301a":       "\u0301a",
+		"e\u0341":       "\u00e9",       // singleton mark decomposition
+		"\u03b1\u0301":  "\u03b1\u0301", // non-Latin scripts are left as they are
+	}
+	for in, want := range tests {
+		if got := LatinNFC(in); got != want {
+			t.Errorf("LatinNFC(%+q) = %+q, want %+q", in, got, want)
+		}
+	}
+}
+
+// =========================================================================================
+// Environment Tags
+// =========================================================================================
+
+func TestInfoEnvironmentTags(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "abc", nil }
+	getEnvironmentTagsFunc = func() []string { return []string{TagRosetta} }
+	defer func() {
+		getMachineIDFunc = getMachineID
+		getEnvironmentTagsFunc = getEnvironmentTags
+	}()
+
+	info, err := Info(context.Background())
+	if err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	if len(info.Tags) != 1 || info.Tags[0] != TagRosetta {
+		t.Errorf("expected tags [%s], got %v", TagRosetta, info.Tags)
+	}
+
+	wantCBOR := []byte{0xa1, 0x64, 't', 'a', 'g', 's', 0x81, 0x67, 'r', 'o', 's', 'e', 't', 't', 'a'}
+	if got, _ := (&MachineInfo{Tags: info.Tags}).MarshalCBOR(); !bytes.Equal(got, wantCBOR) {
+		t.Errorf("CBOR mismatch:\n got %x\nwant %x", got, wantCBOR)
+	}
+	wantMsgpack := []byte{0x81, 0xa4, 't', 'a', 'g', 's', 0x91, 0xa7, 'r', 'o', 's', 'e', 't', 't', 'a'}
+	if got, _ := (&MachineInfo{Tags: info.Tags}).MarshalMsgpack(); !bytes.Equal(got, wantMsgpack) {
+		t.Errorf("msgpack mismatch:\n got %x\nwant %x", got, wantMsgpack)
+	}
+}
+
+// =========================================================================================
+// Best-Effort ID
+// =========================================================================================
+
+func TestWithGeneratedFallback(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	defer func(get func() (string, error)) {
+		getMachineIDFunc = getMachineID
+		getLegacyHostIDFunc = get
+		netInterfaces = net.Interfaces
+	}(getLegacyHostIDFunc)
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	getLegacyHostIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	netInterfaces = mockInterfaces([]net.Interface{{Name: "lo", Flags: net.FlagLoopback}}, nil)
+
+	if _, err := ID(); !errors.Is(err, ErrNoNetwork) {
+		t.Fatalf("expected ErrNoNetwork without the option, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "myapp", "machine-id")
+	clear := func() { generatedIDs.Delete(path) }
+	defer clear()
+
+	// Concurrent first runs agree on the generated ID.
+	ids := make([]string, 8)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids[i], _ = ID(WithGeneratedFallback(path))
+		}()
+	}
+	wg.Wait()
+	for _, id := range ids {
+		if id == "" || id != ids[0] {
+			t.Fatalf("expected one generated ID, got %q", ids)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if uuid := strings.TrimSpace(string(data)); err != nil || len(uuid) != 36 || strings.Count(uuid, "-") != 4 {
+		t.Errorf("expected a UUID in %s, got %q, %v", path, data, err)
+	}
+
+	// Next run (new process): the stored ID is reused.
+	clear()
+	resetCache()
+	if again, _ := ID(WithGeneratedFallback(path)); again != ids[0] {
+		t.Errorf("expected the stored ID %q, got %q", ids[0], again)
+	}
+	if info, _ := Info(context.Background(), WithGeneratedFallback(path)); info.Source != SourcePersisted {
+		t.Errorf("expected source %q, got %+v", SourcePersisted, info)
+	}
+	if got := BestEffortID(WithGeneratedFallback(path)); got != ids[0] {
+		t.Errorf("BestEffortID must return the generated ID %q, got %q", ids[0], got)
+	}
+	if _, err := ID(WithGeneratedFallback(path), PresetLicensing()); !errors.Is(err, ErrFallbackRejected) {
+		t.Errorf("PresetLicensing must reject the generated ID, got %v", err)
+	}
+
+	if _, err := GeneratedIDPath("../etc"); err == nil {
+		t.Error("GeneratedIDPath must reject paths")
+	}
+	if p, err := GeneratedIDPath("myapp"); err != nil || filepath.Base(filepath.Dir(p)) != "myapp" {
+		t.Errorf("GeneratedIDPath() = %q, %v", p, err)
+	}
+}
+
+func TestSystemdSandbox(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	defer func() {
+		getMachineIDFunc = getMachineID
+		netInterfaces = net.Interfaces
+	}()
+
+	// Before network-online.target, the NICs exist but may be down: the MAC fallback doesn't
+	// depend on their state.
+	mac := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	netInterfaces = mockInterfaces([]net.Interface{{Name: "eth0", HardwareAddr: mac}}, nil)
+	down, err := ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetCache()
+	netInterfaces = mockInterfaces([]net.Interface{{Name: "eth0", Flags: net.FlagUp | net.FlagRunning, HardwareAddr: mac}}, nil)
+	if up, _ := ID(); up != down {
+		t.Errorf("the fallback changed when the link came up: %q != %q", down, up)
+	}
+
+	// Under DynamicUser= without a machine-id or network, the best-effort ID is persisted in
+	// the StateDirectory= of the unit.
+	netInterfaces = mockInterfaces([]net.Interface{{Name: "lo", Flags: net.FlagLoopback}}, nil)
+	t.Setenv("STATE_DIRECTORY", "")
+	if _, err := StateDirectoryStore("machine-id"); !errors.Is(err, ErrNoStateDirectory) {
+		t.Errorf("expected ErrNoStateDirectory, got %v", err)
+	}
+	state := t.TempDir()
+	t.Setenv("STATE_DIRECTORY", state+string(os.PathListSeparator)+t.TempDir())
+	if _, err := StateDirectoryStore("../machine-id"); err == nil {
+		t.Error("a path must be rejected as state file name")
+	}
+	store, err := StateDirectoryStore("machine-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetCache()
+	id := BestEffortID(WithBestEffortStore(store))
+	if _, err := os.Stat(filepath.Join(state, "machine-id")); err != nil {
+		t.Errorf("expected the state in the first state directory: %v", err)
+	}
+	resetCache() // Service restart.
+	if again := BestEffortID(WithBestEffortStore(store)); again != id {
+		t.Errorf("the persisted ID changed across restarts: %q != %q", id, again)
+	}
+}
+
+func TestBestEffortID(t *testing.T) {
+	resetCache()
+	defer resetCache()
+	defer func() { getMachineIDFunc = getMachineID }()
+
+	// A working source is returned unchanged.
+	getMachineIDFunc = func() (string, error) { return "abc", nil }
+	want, _ := ID()
+	if got := BestEffortID(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if info := BestEffortInfo(context.Background()); info.Degradation != DegradationNone {
+		t.Errorf("expected no degradation, got %v", info.Degradation)
+	}
+
+	// A hard failure degrades to a per-process random ID.
+	resetCache()
+	getMachineIDFunc = func() (string, error) { return "", os.ErrPermission }
+	random := BestEffortID()
+	if random == "" || random != BestEffortID() {
+		t.Errorf("the random ID must be stable within the process, got %q", random)
+	}
+	info := BestEffortInfo(context.Background())
+	if info.Degradation != DegradationRandom || info.Source != SourceRandom || info.ID != random {
+		t.Errorf("unexpected report: %+v", info)
+	}
+	// The degraded ID is not cached: ID() still reports the problem.
+	if _, err := ID(); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("expected ID() to keep failing, got %v", err)
+	}
+
+	// With a store, the random ID is persisted and reused.
+	store := FileStore(filepath.Join(t.TempDir(), "best-effort"))
+	persisted := BestEffortID(WithBestEffortStore(store))
+	if persisted == random {
+		t.Error("the persisted ID must not reuse the per-process ID")
+	}
+	if again := BestEffortID(WithBestEffortStore(store)); again != persisted {
+		t.Errorf("expected the persisted ID %q, got %q", persisted, again)
+	}
+	if info := BestEffortInfo(context.Background(), WithBestEffortStore(store)); info.Degradation != DegradationPersisted {
+		t.Errorf("expected DegradationPersisted, got %v", info.Degradation)
+	}
+
+	// An unusable store degrades further to the per-process ID.
+	bad := FileStore(filepath.Join(t.TempDir(), "missing", "best-effort"))
+	if got := BestEffortID(WithBestEffortStore(bad)); got != random {
+		t.Errorf("expected the per-process ID, got %q", got)
+	}
+
+	// Options that reject the real ID also degrade.
+	resetCache()
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	netInterfaces = mockInterfaces([]net.Interface{
+		{Name: "eth0", HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}},
+	}, nil)
+	defer func() { netInterfaces = net.Interfaces }()
+	if info := BestEffortInfo(context.Background()); info.Degradation != DegradationFallback {
+		t.Errorf("expected DegradationFallback, got %v", info.Degradation)
+	}
+	if got := BestEffortID(PresetLicensing()); got != random {
+		t.Errorf("expected the per-process ID for a rejected fallback, got %q", got)
+	}
+}
+
+// =========================================================================================
+// Sources Registry
+// =========================================================================================
+
+func TestDisableSource(t *testing.T) {
+	resetCache()
+	defer resetCache()
+	defer EnableSource(ComponentMachineID)
+
+	getMachineIDFunc = func() (string, error) { return "abc", nil }
+	netInterfaces = mockInterfaces([]net.Interface{
+		{Name: "eth0", HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}},
+	}, nil)
+	defer func() {
+		getMachineIDFunc = getMachineID
+		netInterfaces = net.Interfaces
+	}()
+
+	sources := Sources()
+	if len(sources) == 0 || sources[0].Name != ComponentMachineID || sources[0].Role != SourceRolePrimary {
+		t.Fatalf("expected the primary source first, got %+v", sources)
+	}
+	for _, d := range sources {
+		if !d.Enabled {
+			t.Errorf("source %s must be enabled by default", d.Name)
+		}
+	}
+
+	native, _ := ID()
+	if err := DisableSource(ComponentMachineID); err != nil {
+		t.Fatal(err)
+	}
+	info, err := Info(context.Background())
+	if err != nil || info.Source != ComponentMAC {
+		t.Fatalf("expected the MAC fallback, got %+v, %v", info, err)
+	}
+	if info.ID == native {
+		t.Error("disabling the source must change the ID")
+	}
+	if Sources()[0].Enabled {
+		t.Error("machine-id must be reported as disabled")
+	}
+	if _, err := cachedSourceValue(ComponentMachineID, getMachineIDFunc); !errors.Is(err, ErrSourceDisabled) || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected ErrSourceDisabled, got %v", err)
+	}
+
+	if err := EnableSource(ComponentMachineID); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := ID(); id != native {
+		t.Errorf("expected %q after re-enabling, got %q", native, id)
+	}
+
+	if err := DisableSource("no-such-source"); err == nil {
+		t.Error("expected an error for an unknown source")
+	}
+}
+
+func TestProbeSources(t *testing.T) {
+	resetCache()
+	defer resetCache()
+	defer EnableSource(ComponentMAC)
+
+	getMachineIDFunc = func() (string, error) { return "abc", nil }
+	netInterfaces = mockInterfaces(nil, nil)
+	defer func() {
+		getMachineIDFunc = getMachineID
+		netInterfaces = net.Interfaces
+	}()
+
+	statuses := ProbeSources()
+	if len(statuses) != len(Sources()) {
+		t.Fatalf("expected one status per source, got %+v", statuses)
+	}
+	want, _ := protect("abc")
+	if s := statuses[0]; s.Name != ComponentMachineID || s.Err != nil || s.Hash != want {
+		t.Errorf("unexpected machine-id status: %+v", s)
+	}
+	for _, s := range statuses {
+		if s.Name == ComponentMAC && (s.Err == nil || s.Hash != "") {
+			t.Errorf("expected the MAC source to fail without interfaces, got %+v", s)
+		}
+	}
+
+	// Disabled sources report ErrSourceDisabled.
+	if err := DisableSource(ComponentMAC); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range ProbeSources() {
+		if s.Name == ComponentMAC && (s.Enabled || !errors.Is(s.Err, ErrSourceDisabled)) {
+			t.Errorf("expected the MAC source to be disabled, got %+v", s)
+		}
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	resetCache()
+	defer resetCache()
+	defer EnableSource(SourceHostID)
+
+	getMachineIDFunc = func() (string, error) { return "", &SourceError{Source: "test", Err: os.ErrPermission} }
+	netInterfaces = mockInterfaces([]net.Interface{{Name: "lo", Flags: net.FlagLoopback}}, nil)
+	defer func() {
+		getMachineIDFunc = getMachineID
+		netInterfaces = net.Interfaces
+	}()
+	if err := DisableSource(SourceHostID); err != nil {
+		t.Fatal(err)
+	}
+
+	report := Doctor()
+	if report.Err == nil || report.Strength != nil {
+		t.Errorf("expected a resolution error, got %+v", report)
+	}
+	problems := map[string]SourceDiagnosis{}
+	for _, d := range report.Sources {
+		problems[d.Name] = d
+	}
+	for name, want := range map[string]Problem{
+		ComponentMachineID: ProblemPermission,
+		ComponentMAC:       ProblemMissing,
+		SourceHostID:       ProblemDisabled,
+	} {
+		if d := problems[name]; d.Problem != want || d.Hint == "" {
+			t.Errorf("%s: expected %q with a hint, got %+v", name, want, d)
+		}
+	}
+
+	// A healthy machine reports the resolved source.
+	getMachineIDFunc = func() (string, error) { return "4c4c4544003957108052b4c04f384833", nil }
+	resetCache()
+	report = Doctor()
+	if report.Err != nil || report.Source != ComponentMachineID || report.Strength == nil || report.Sources[0].Problem != ProblemNone {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	for err, want := range map[error]Problem{
+		fmt.Errorf("ioreg: %w", ErrParse): ProblemParse,
+		errors.New("boom"):                ProblemOther,
+		disabledError(ComponentMAC):       ProblemDisabled,
+	} {
+		if got := classifyProblem(err); got != want {
+			t.Errorf("classifyProblem(%v) = %q, want %q", err, got, want)
+		}
+	}
+}
+
+// =========================================================================================
+// MAC Fallback Golden Values
+// =========================================================================================
+
+// TestHardwareIdGolden pins the MAC fallback: the sort key (address bytes, then name) must keep
+// producing these exact values whatever the interface order or naming scheme.
+func TestHardwareIdGolden(t *testing.T) {
+	defer func() { netInterfaces = net.Interfaces }()
+
+	const (
+		wantRaw = "00:11:22:33:44:55,00:11:22:33:44:55:66:77,0a:00:27:00:00:01,aa:bb:cc:dd:ee:ff"
+		wantID  = "e20b7fbc14fd0750d096f237f4e3dfac499b665af7cc35fe4dc72d5a095fba78"
+	)
+	sets := [][]net.Interface{
+		{
+			{Name: "eth0", HardwareAddr: net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}},
+			{Name: "eth1", HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}},
+			{Name: "ib0", HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}},
+			{Name: "wlan0", HardwareAddr: net.HardwareAddr{0x0a, 0x00, 0x27, 0x00, 0x00, 0x01}},
+		},
+		// Renamed (predictable interface names) and reported in a different order.
+		{
+			{Name: "wlp2s0", HardwareAddr: net.HardwareAddr{0x0a, 0x00, 0x27, 0x00, 0x00, 0x01}},
+			{Name: "ibp1s0", HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}},
+			{Name: "enp3s0", HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}},
+			{Name: "lo", Flags: net.FlagLoopback},
+			{Name: "enp4s0", HardwareAddr: net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}},
+			{Name: "docker0", HardwareAddr: net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}},
+		},
+	}
+
+	for i, ifaces := range sets {
+		netInterfaces = mockInterfaces(ifaces, nil)
+		raw, err := getHardwareId()
+		if err != nil || raw != wantRaw {
+			t.Errorf("set %d: got %q, %v\nwant %q", i, raw, err, wantRaw)
+		}
+		if id, _ := protect(raw); id != wantID {
+			t.Errorf("set %d: got ID hash %s, want %s", i, id, wantID)
+		}
+	}
+}
+
+// TestHardwareIdGoldenV2 freezes the MAC fallback under WithRulesVersion(2): the VirtualBox
+// host-only adapter, the bridge and the WireGuard interface are no longer hashed.
+func TestHardwareIdGoldenV2(t *testing.T) {
+	const (
+		wantRaw = "00:11:22:33:44:55,00:11:22:33:44:55:66:77,aa:bb:cc:dd:ee:ff"
+		wantID  = "0d187803757c058c6124fffd4830dcc67e814a28553ef3a5fb34eb93b4f3976f"
+	)
+	sets := [][]net.Interface{
+		{
+			{Name: "eth0", HardwareAddr: net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}},
+			{Name: "eth1", HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}},
+			{Name: "ib0", HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}},
+			{Name: "wlan0", HardwareAddr: net.HardwareAddr{0x0a, 0x00, 0x27, 0x00, 0x00, 0x01}},
+		},
+		{
+			{Name: "wlp2s0", HardwareAddr: net.HardwareAddr{0x0a, 0x00, 0x27, 0x00, 0x00, 0x01}},
+			{Name: "ibp1s0", HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}},
+			{Name: "enp3s0", HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}},
+			{Name: "lo", Flags: net.FlagLoopback},
+			{Name: "enp4s0", HardwareAddr: net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}},
+			{Name: "br-1a2b3c4d", HardwareAddr: net.HardwareAddr{0x02, 0x42, 0x5f, 0x00, 0x00, 0x01}},
+			{Name: "wg0", HardwareAddr: net.HardwareAddr{0x06, 0x11, 0x22, 0x33, 0x44, 0x55}},
+		},
+	}
+
+	for i, ifaces := range sets {
+		var macs []string
+		for _, a := range filterHardwareAddresses(ifaces, options{rulesVersion: 2}) {
+			macs = append(macs, a.MAC)
+		}
+		raw := strings.Join(macs, ",")
+		if raw != wantRaw {
+			t.Errorf("set %d: got %q\nwant %q", i, raw, wantRaw)
+		}
+		if id, _ := protect(raw); id != wantID {
+			t.Errorf("set %d: got ID hash %s, want %s", i, id, wantID)
+		}
+	}
+}
+
+// =========================================================================================
+// Scratch Containers (No Network)
+// =========================================================================================
+
+func TestNoNetwork(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	defer func(get func() (string, error)) {
+		getMachineIDFunc = getMachineID
+		getLegacyHostIDFunc = get
+		getEnvTypeFunc = getEnvironmentType
+		netInterfaces = net.Interfaces
+	}(getLegacyHostIDFunc)
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	getLegacyHostIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	getEnvTypeFunc = func() string { return "docker" }
+	netInterfaces = mockInterfaces([]net.Interface{{Name: "lo", Flags: net.FlagLoopback}}, nil)
+
+	if _, err := getHardwareId(); !errors.Is(err, ErrNoNetwork) {
+		t.Errorf("expected ErrNoNetwork from the MAC fallback, got %v", err)
+	}
+	if _, err := ID(); !errors.Is(err, ErrNoNetwork) {
+		t.Errorf("expected ID() to report ErrNoNetwork, got %v", err)
+	}
+
+	store := FileStore(filepath.Join(t.TempDir(), "best-effort"))
+	info := BestEffortInfo(context.Background(), WithBestEffortStore(store))
+	if info.Degradation != DegradationPersisted || info.EnvironmentDetail != DetailNoNetwork {
+		t.Errorf("unexpected report: %+v", info)
+	}
+	if id := BestEffortID(WithBestEffortStore(store)); id != info.ID {
+		t.Errorf("expected the persisted ID %q, got %q", info.ID, id)
+	}
+
+	// Machines (as opposed to containers) without network don't get the container detail.
+	getEnvTypeFunc = func() string { return "physical" }
+	resetCache()
+	if info := BestEffortInfo(context.Background()); info.EnvironmentDetail == DetailNoNetwork {
+		t.Errorf("expected no %q detail outside containers", DetailNoNetwork)
+	}
+}
+
+// =========================================================================================
+// Debug Handler
+// =========================================================================================
+
+func TestHandler(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "abc", nil }
+	getDMIStringsFunc = func() (DMIInfo, error) { return DMIInfo{Vendor: "QEMU"}, nil }
+	defer func() {
+		getMachineIDFunc = getMachineID
+		getDMIStringsFunc = getDMIStrings
+	}()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DebugPath, nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	var report debugReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	short, _ := ID(Short())
+	if report.ID != short || report.Source != ComponentMachineID || report.Degradation != "none" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if report.DMI == nil || report.DMI.Vendor != RedactedValue {
+		t.Errorf("DMI strings must be redacted, got %+v", report.DMI)
+	}
+	if len(report.Sources) == 0 || report.Sources[0].Name != ComponentMachineID || !report.Sources[0].Cached {
+		t.Errorf("unexpected sources: %+v", report.Sources)
+	}
+
+	// ?app= is refused unless the appID is allowed.
+	for _, h := range []http.Handler{Handler(), Handler(WithDebugApps("other"))} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DebugPath+"?app=sidecar", nil))
+		if rec.Code != http.StatusForbidden || strings.Contains(rec.Body.String(), "protected_id") {
+			t.Errorf("expected 403 for an unlisted app, got %d %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	// An allowed ?app= adds the protected ID; options select the ID format.
+	rec = httptest.NewRecorder()
+	Handler(WithLength(0), WithDebugApps("sidecar")).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DebugPath+"?app=sidecar", nil))
+	report = debugReport{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	full, _ := ID(Short(), WithLength(0))
+	protected, _ := ProtectedID("sidecar", Short(), WithLength(0))
+	if report.ID != full || report.ProtectedID != protected {
+		t.Errorf("unexpected IDs: %+v", report)
+	}
+
+	// Resolution failures are reported, with the source states.
+	resetCache()
+	getMachineIDFunc = func() (string, error) { return "", os.ErrPermission }
+	rec = httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DebugPath, nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"error"`) {
+		t.Errorf("expected 503 with an error, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, DebugPath, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+// =========================================================================================
+// Architectures of the Same Machine
+// =========================================================================================
+
+func TestArchIndependence(t *testing.T) {
+	resetCache()
+	defer resetCache()
+	defer func(a string) { goarch = a }(goarch)
+
+	// A dual-boot machine: the hardware-anchored source is the same in both userlands, the
+	// OS-install source (machine-id) is not.
+	raw := "4c4c4544-0042-3510-8052-b4c04f4d4e32"
+	getMachineIDFunc = func() (string, error) { return raw, nil }
+	defer func() { getMachineIDFunc = getMachineID }()
+
+	ids := func(arch string, opts ...Option) (string, string) {
+		t.Helper()
+		goarch = arch
+		id, err := ID(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pid, err := ProtectedID("app", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id, pid
+	}
+
+	for _, d := range []Derivation{DerivationSHA256, DerivationTupleHash} {
+		amd64, amd64P := ids("amd64", WithDerivation(d))
+		arm64, arm64P := ids("arm64", WithDerivation(d))
+		if amd64 != arm64 || amd64P != arm64P {
+			t.Errorf("derivation %d: IDs must not depend on the architecture by default", d)
+		}
+
+		amd64A, amd64AP := ids("amd64", WithDerivation(d), WithArch())
+		arm64A, arm64AP := ids("arm64", WithDerivation(d), WithArch())
+		if amd64A == arm64A || amd64AP == arm64AP {
+			t.Errorf("derivation %d: WithArch must separate the architectures", d)
+		}
+		if amd64A == amd64 || amd64AP == amd64P {
+			t.Errorf("derivation %d: WithArch must change the ID", d)
+		}
+	}
+
+	// The other userland's OS install has its own machine-id.
+	first, _ := ids("amd64")
+	raw = "0f1e2d3c4b5a69788796a5b4c3d2e1f0"
+	resetCache()
+	if second, _ := ids("arm64"); second == first {
+		t.Error("distinct OS installs must get distinct IDs")
+	}
+}
+
+// =========================================================================================
+// Cloud Instance ID
+// =========================================================================================
+
+func TestWithCloudMetadata(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "baked-into-the-ami", nil }
+	calls := 0
+	instanceID := "aws:i-0abc123"
+	getCloudInstanceIDFunc = func(ctx context.Context) (string, error) {
+		calls++
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("metadata queries must have a deadline")
+		}
+		if instanceID == "" {
+			return "", imds.ErrNotFound
+		}
+		return instanceID, nil
+	}
+	defer func() {
+		getMachineIDFunc = getMachineID
+		getCloudInstanceIDFunc = imds.InstanceID
+	}()
+
+	plain, _ := ID()
+	cloud, err := ID(WithCloudMetadata(true))
+	if err != nil || cloud == plain {
+		t.Fatalf("expected an instance-scoped ID, got %q, %v", cloud, err)
+	}
+	info, err := Info(context.Background(), WithCloudMetadata(true))
+	if err != nil || info.ID != cloud || info.Source != SourceCloudInstanceID {
+		t.Errorf("unexpected report: %+v, %v", info, err)
+	}
+	if calls != 1 {
+		t.Errorf("expected the instance ID to be cached, got %d queries", calls)
+	}
+	if got := BestEffortID(WithCloudMetadata(true)); got != cloud {
+		t.Errorf("BestEffortID must return ID(), got %q, want %q", got, cloud)
+	}
+	if id, _ := ID(WithCloudMetadata(false)); id != plain {
+		t.Error("WithCloudMetadata(false) must not change the ID")
+	}
+
+	// Outside the cloud the usual ID is returned, and the negative outcome is cached too.
+	instanceID = ""
+	Refresh(SourceCloudInstanceID)
+	for range 2 {
+		if id, err := ID(WithCloudMetadata(true)); err != nil || id != plain {
+			t.Errorf("expected the usual ID outside the cloud, got %q, %v", id, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected one more query, got %d", calls)
+	}
+}
+
+// =========================================================================================
+// Minimum Entropy
+// =========================================================================================
+
+func TestWithMinEntropy(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	raw := "0000"
+	getMachineIDFunc = func() (string, error) { return raw, nil }
+	defer func() { getMachineIDFunc = getMachineID }()
+
+	// The check is off by default.
+	if _, err := ID(); err != nil {
+		t.Fatalf("unexpected error without a minimum: %v", err)
+	}
+
+	var weak *WeakIdentityError
+	_, err := ID(PresetLicensing())
+	if !errors.Is(err, ErrWeakIdentity) || !errors.As(err, &weak) {
+		t.Fatalf("expected a WeakIdentityError, got %v", err)
+	}
+	if weak.Source != ComponentMachineID || weak.Length != 4 || weak.Entropy != 0 || weak.Min != DefaultMinEntropy {
+		t.Errorf("unexpected error details: %+v", weak)
+	}
+	if _, err := ProtectedID("app", WithMinEntropy(DefaultMinEntropy)); !errors.Is(err, ErrWeakIdentity) {
+		t.Errorf("expected ProtectedID to fail, got %v", err)
+	}
+
+	tests := []struct {
+		raw  string
+		weak bool
+	}{
+		{"00:00:00:00:00:00", true},
+		{"abcd", true},
+		{"7XYZ123", false}, // Service tag.
+		{"4c4c4544-0042-3510-8052-b4c04f4d4e32", false},
+	}
+	for _, tt := range tests {
+		raw = tt.raw
+		resetCache()
+		if _, err := ID(WithMinEntropy(DefaultMinEntropy)); errors.Is(err, ErrWeakIdentity) != tt.weak {
+			t.Errorf("%q (%.1f bits): expected weak=%v, got %v", tt.raw, entropyBits(tt.raw), tt.weak, err)
+		}
+	}
+}
+
+// =========================================================================================
+// Serverless Platforms
+// =========================================================================================
+
+func TestStrength(t *testing.T) {
+	resetCache()
+	defer resetCache()
+
+	getMachineIDFunc = func() (string, error) { return "4c4c4544003957108052b4c04f384833", nil }
+	netInterfaces = mockInterfaces([]net.Interface{
+		{Name: "wlan0", HardwareAddr: net.HardwareAddr{0x02, 0x11, 0x22, 0x33, 0x44, 0x55}},
+	}, nil)
+	defer func() {
+		getMachineIDFunc = getMachineID
+		netInterfaces = net.Interfaces
+	}()
+
+	// A random machine-id is as strong as it gets.
+	s, err := Strength()
+	if err != nil || s.Source != ComponentMachineID || s.Score != 1 || len(s.Warnings) != 0 {
+		t.Fatalf("expected a full score, got %+v, %v", s, err)
+	}
+
+	// A placeholder scores low.
+	getMachineIDFunc = func() (string, error) { return "0000", nil }
+	resetCache()
+	if s, _ := Strength(); s.Score > 0.1 || len(s.Warnings) != 1 {
+		t.Errorf("expected a weak score, got %+v", s)
+	}
+
+	// A single randomized Wi-Fi MAC scores lowest among the fallbacks.
+	getMachineIDFunc = func() (string, error) { return "", os.ErrNotExist }
+	resetCache()
+	s, err = Strength()
+	if err != nil || s.Source != ComponentMAC || s.Score > 0.1 || len(s.Warnings) != 3 {
+		t.Errorf("expected a randomized MAC warning, got %+v, %v", s, err)
+	}
+}
+
+func TestSetCommandTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available")
+	}
+	defer SetCommandTimeout(DefaultCommandTimeout)
+
+	SetCommandTimeout(50 * time.Millisecond)
+	start := time.Now()
+	if _, err := runCommand("sleep", "5"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("the wedged tool was not killed in time: %v", elapsed)
+	}
+
+	// Without a deadline, tools run to completion.
+	SetCommandTimeout(0)
+	if _, err := runCommand("sleep", "0"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClassifyServerless(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		paths []string
+		want  string
+	}{
+		{"Lambda", map[string]string{"AWS_LAMBDA_FUNCTION_NAME": "resize", "LAMBDA_TASK_ROOT": "/var/task"}, nil, PlatformAWSLambda},
+		{"Lambda_Layout", nil, []string{"/var/runtime/bootstrap", "/var/task"}, PlatformAWSLambda},
+		{"Cloud_Run", map[string]string{"K_SERVICE": "api", "K_REVISION": "api-00042-xyz"}, nil, PlatformCloudRun},
+		{"Cloud_Run_Job", map[string]string{"CLOUD_RUN_JOB": "nightly"}, nil, PlatformCloudRunJob},
+		{"Cloud_Functions_Gen2", map[string]string{"K_SERVICE": "fn", "K_REVISION": "fn-1", "FUNCTION_TARGET": "Handle"}, nil, PlatformCloudFunctions},
+		{"Cloud_Functions_Gen1", map[string]string{"FUNCTION_NAME": "fn", "GCP_PROJECT": "acme"}, nil, PlatformCloudFunctions},
+		{"Azure_Functions", map[string]string{"FUNCTIONS_WORKER_RUNTIME": "node"}, nil, PlatformAzureFunctions},
+		{"Knative_Without_Revision", map[string]string{"K_SERVICE": "api"}, nil, ""},
+		{"None", nil, []string{"/var/task"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(k string) string { return tt.env[k] }
+			exists := func(p string) bool { return slices.Contains(tt.paths, p) }
+			if got, _ := classifyServerless(getenv, exists); got != tt.want {
+				t.Errorf("classifyServerless() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// =========================================================================================
+// Manifest
+// =========================================================================================
+
+func TestDescribe(t *testing.T) {
+	m := Describe()
+	if m.RulesVersion != RulesVersion || m.Platform != runtime.GOOS+"/"+runtime.GOARCH {
+		t.Errorf("unexpected manifest header: %+v", m)
+	}
+	if len(m.Sources) == 0 || m.Sources[0].Name != ComponentMachineID {
+		t.Errorf("expected the platform sources, got %+v", m.Sources)
+	}
+
+	// Every exported constructor returning an Option (presets aside) must be described.
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	described := map[string]bool{}
+	for _, d := range m.Options {
+		described[d.Name] = true
+	}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !fn.Name.IsExported() || fn.Type.Results == nil || len(fn.Type.Results.List) != 1 {
+				continue
+			}
+			if ident, ok := fn.Type.Results.List[0].Type.(*ast.Ident); !ok || ident.Name != "Option" {
+				continue
+			}
+			if !described[fn.Name.Name] && !slices.Contains(m.Presets, fn.Name.Name) {
+				t.Errorf("option %s is missing from the manifest", fn.Name.Name)
+			}
+		}
+	}
+
+	// The manifest is a copy.
+	m.Options[0].Scope[0] = "changed"
+	if Describe().Options[0].Scope[0] == "changed" {
+		t.Error("Describe must return a copy")
+	}
+}
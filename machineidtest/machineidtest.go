@@ -0,0 +1,51 @@
+// Package machineidtest lets tests of code using machineid pin the values it resolves, so
+// IDs are deterministic and independent of the machine running the tests.
+//
+// The overrides apply to the whole process and are undone when the test finishes: don't use
+// them in parallel tests. They panic outside test binaries.
+package machineidtest
+
+import (
+	"testing"
+
+	// Importing machineid fills in the hooks.
+	_ "github.com/banditmoscow1337/machineid"
+	"github.com/banditmoscow1337/machineid/internal/hooks"
+)
+
+// SetID makes the platform source return id as the raw machine identifier until the end of the
+// test. machineid.ID() then returns the same value as on a machine whose machine-id is id, and
+// ProtectedID() still differs per app.
+func SetID(tb testing.TB, id string) {
+	tb.Helper()
+	setID(tb, func() (string, error) { return id, nil })
+}
+
+// SetIDError makes the platform source fail with err until the end of the test. An error
+// wrapping os.ErrNotExist selects the MAC address fallback; other errors are returned by
+// machineid.ID().
+func SetIDError(tb testing.TB, err error) {
+	tb.Helper()
+	setID(tb, func() (string, error) { return "", err })
+}
+
+// SetEnv sets the detected environment (the ID prefix, e.g., "vm") until the end of the test.
+func SetEnv(tb testing.TB, env string) {
+	tb.Helper()
+	override(tb, hooks.SetEnvironment, func() string { return env })
+}
+
+func setID(tb testing.TB, get func() (string, error)) {
+	tb.Helper()
+	override(tb, hooks.SetMachineID, get)
+}
+
+// override installs a hook until the end of the test. It refuses to run outside test binaries,
+// so importing this package can't be used to spoof the ID of a production binary.
+func override[T any](tb testing.TB, set func(T) (restore func()), v T) {
+	tb.Helper()
+	if !testing.Testing() {
+		panic("machineidtest: the machineid sources can only be overridden in tests")
+	}
+	tb.Cleanup(set(v))
+}
@@ -0,0 +1,51 @@
+package machineidtest
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/banditmoscow1337/machineid"
+)
+
+func TestSetID(t *testing.T) {
+	original, origErr := machineid.ID()
+
+	t.Run("override", func(t *testing.T) {
+		SetID(t, "fixed-id")
+		SetEnv(t, "vm")
+
+		id, err := machineid.ID()
+		if err != nil || !strings.HasPrefix(id, "vm:") {
+			t.Fatalf("unexpected ID %q, %v", id, err)
+		}
+		if again, _ := machineid.ID(); again != id {
+			t.Error("the ID must be deterministic")
+		}
+
+		SetID(t, "other-id")
+		if other, _ := machineid.ID(); other == id {
+			t.Error("a new override must be applied immediately")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		failure := errors.New("permission denied")
+		SetIDError(t, failure)
+		if _, err := machineid.ID(); !errors.Is(err, failure) {
+			t.Errorf("expected the injected error, got %v", err)
+		}
+
+		SetIDError(t, os.ErrNotExist)
+		SetEnv(t, "physical")
+		if info, err := machineid.Info(t.Context()); err == nil && info.Source != machineid.ComponentMAC {
+			t.Errorf("expected the MAC fallback, got %q", info.Source)
+		}
+	})
+
+	// Cleanup restores the platform sources.
+	if id, err := machineid.ID(); id != original || (err == nil) != (origErr == nil) {
+		t.Errorf("expected %q, %v after cleanup, got %q, %v", original, origErr, id, err)
+	}
+}
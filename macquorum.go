@@ -0,0 +1,172 @@
+package machineid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// macAnchors caches the anchored MAC sets by store, so the store is only read once per process.
+var macAnchors sync.Map
+
+// WithMACQuorum makes an ID derived from the MAC fallback tolerate NIC changes. The first such
+// resolution anchors the MAC set in store, and the ID is derived from the anchor instead of the
+// addresses. Later resolutions keep the anchor as long as at least quorum of the anchored
+// addresses are still present, so plugging in a USB Ethernet dongle or replacing one of several
+// NICs doesn't flip the ID. Below the quorum, the current set is anchored instead and the ID
+// changes. Values <= 0 select a majority of the anchored addresses. IDs from other sources are
+// not affected.
+//
+// The store holds a random salt, the salted hash of each address and the anchor, never the
+// addresses themselves. Since the ID is derived from the anchor, enabling the option changes a
+// MAC-derived ID once.
+func WithMACQuorum(store Store, quorum int) Option {
+	return func(o *options) {
+		o.macQuorumStore = store
+		o.macQuorum = quorum
+	}
+}
+
+// macAnchor is the persisted form of the anchored MAC set.
+type macAnchor struct {
+	// Salt is the random salt (hex) of the hashes below.
+	Salt string `json:"salt"`
+	// Anchor is the salted SHA256 (hex) of the fallback value when the set was anchored.
+	Anchor string `json:"anchor"`
+	// MACs holds the salted SHA256 (hex) of each anchored address.
+	MACs []string `json:"macs"`
+}
+
+// applyMACQuorum replaces a MAC-derived resolution with the anchored one, if WithMACQuorum was
+// given and enough anchored addresses are still present.
+func (o options) applyMACQuorum(snap snapshot, err error) (snapshot, error) {
+	if err != nil || o.macQuorumStore == nil || snap.source != ComponentMAC {
+		return snap, err
+	}
+	// The anchor always looks strong: judge the addresses it stands for.
+	if err := checkEntropy(snap, o.minEntropy); err != nil {
+		return snapshot{}, err
+	}
+
+	anchor, err := cachedMACAnchor(o.macQuorumStore)
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	if anchor.Anchor != "" {
+		current := macHashes(anchor.Salt, snap.rawID)
+		matched := 0
+		for _, h := range anchor.MACs {
+			if slices.Contains(current, h) {
+				matched++
+			}
+		}
+		quorum := o.macQuorum
+		if quorum <= 0 {
+			quorum = len(anchor.MACs)/2 + 1
+		}
+		quorum = min(quorum, len(anchor.MACs))
+		if matched >= quorum {
+			if matched < len(anchor.MACs) || len(current) > len(anchor.MACs) {
+				logDebug("machineid: MAC set changed within the quorum, keeping the anchored ID", "matched", matched, "anchored", len(anchor.MACs), "quorum", quorum)
+			}
+			snap.rawID = anchor.rawID()
+			return snap, nil
+		}
+		logWarn("machineid: MAC set changed beyond the quorum, re-anchoring the fallback ID", "matched", matched, "anchored", len(anchor.MACs), "quorum", quorum)
+	}
+
+	if anchor, err = newMACAnchor(snap.rawID); err != nil {
+		return snapshot{}, err
+	}
+	data, err := json.Marshal(anchor)
+	if err != nil {
+		return snapshot{}, err
+	}
+	if err := o.macQuorumStore.Save(data); err != nil {
+		return snapshot{}, fmt.Errorf("machineid: save MAC anchor: %w", err)
+	}
+	cacheMACAnchor(o.macQuorumStore, anchor)
+	snap.rawID = anchor.rawID()
+	return snap, nil
+}
+
+// rawID returns the raw ID derived from the anchor. The "mac-anchor:" namespace keeps it from
+// ever colliding with a real raw ID.
+func (a macAnchor) rawID() string {
+	return "mac-anchor:" + a.Anchor
+}
+
+// newMACAnchor anchors the MAC fallback value raw with a new salt.
+func newMACAnchor(raw string) (macAnchor, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return macAnchor{}, err
+	}
+	salt := hex.EncodeToString(b)
+	anchor, err := protect(salt + ":" + raw)
+	if err != nil {
+		return macAnchor{}, err
+	}
+	return macAnchor{Salt: salt, Anchor: anchor, MACs: macHashes(salt, raw)}, nil
+}
+
+// macHashes returns the salted hash of each address of a MAC fallback value.
+func macHashes(salt, raw string) []string {
+	var hashes []string
+	for mac := range strings.SplitSeq(raw, ",") {
+		// The salt is never empty, so protect can't fail.
+		h, _ := protect(salt + ":" + mac)
+		hashes = append(hashes, h)
+	}
+	return hashes
+}
+
+// cachedMACAnchor returns the anchored MAC set of store, loading it on first use.
+func cachedMACAnchor(store Store) (macAnchor, error) {
+	if comparableStore(store) {
+		if anchor, ok := macAnchors.Load(store); ok {
+			return anchor.(macAnchor), nil
+		}
+	}
+	anchor, err := loadMACAnchor(store)
+	if err == nil && anchor.Anchor != "" {
+		cacheMACAnchor(store, anchor)
+	}
+	return anchor, err
+}
+
+// cacheMACAnchor records the anchored MAC set of store. Stores that can't be map keys (e.g., a
+// struct holding a slice) are not cached and are read on every resolution.
+func cacheMACAnchor(store Store, anchor macAnchor) {
+	if comparableStore(store) {
+		macAnchors.Store(store, anchor)
+	}
+}
+
+func comparableStore(store Store) bool {
+	return reflect.TypeOf(store).Comparable()
+}
+
+// loadMACAnchor loads the anchored MAC set; it is empty if none was saved yet.
+func loadMACAnchor(store Store) (macAnchor, error) {
+	var anchor macAnchor
+	data, err := store.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		return anchor, nil
+	}
+	if err != nil {
+		return anchor, fmt.Errorf("machineid: load MAC anchor: %w", err)
+	}
+	if err := json.Unmarshal(data, &anchor); err != nil {
+		return anchor, fmt.Errorf("machineid: decode MAC anchor: %w", err)
+	}
+	return anchor, nil
+}
@@ -0,0 +1,83 @@
+package machineid
+
+import (
+	"slices"
+	"strings"
+)
+
+// macHardware holds the hardware signals of a macOS guest: the hw.model (or hw.target) sysctl
+// and the board-id of the IODeviceTree platform node. Unlike the guest tools, these are set by
+// the hypervisor and can't be faked from inside the VM by installing a package.
+type macHardware struct {
+	model   string
+	boardID string
+}
+
+// Hardware classes of macOS guests, see macHardware.class.
+const (
+	macHardwareVZ   = "vz"   // Apple Virtualization.framework
+	macHardwareQEMU = "qemu" // QEMU (e.g., UTM in emulation mode)
+)
+
+// class returns the virtualization stack the hardware signals point to, or "" if none is known.
+func (h macHardware) class() string {
+	// Virtualization.framework guests report a "VirtualMac" model (e.g., "VirtualMac2,1") and a
+	// "VMA2MACOSAP" board-id.
+	if strings.HasPrefix(h.model, "VirtualMac") || strings.HasPrefix(h.boardID, "VirtualMac") ||
+		strings.HasPrefix(h.boardID, "VMA") {
+		return macHardwareVZ
+	}
+	if strings.Contains(strings.ToLower(h.model), "qemu") || strings.Contains(strings.ToLower(h.boardID), "qemu") {
+		return macHardwareQEMU
+	}
+	return ""
+}
+
+// macVMProviders lists heuristics recognizing macOS virtualization stacks popular in CI.
+// Most of them run on top of Virtualization.framework and look identical at the hardware level,
+// so the provider is told apart by its guest tools/agents. The tools only count as supporting
+// evidence: a provider matches only if the hardware belongs to one of its classes.
+// The list is ordered: the first match wins.
+var macVMProviders = []struct {
+	name    string
+	classes []string
+	paths   []string
+}{
+	// MacStadium Orka (its VM tools are checked first: Orka 3 is built on Virtualization.framework
+	// like Tart and Anka, and may ship their agents as well).
+	{"orka", []string{macHardwareVZ}, []string{"/usr/local/bin/orka-vm-tools", "/Library/LaunchDaemons/com.macstadium.orka-vm-tools.plist"}},
+	// Veertu Anka (Anka Build Cloud): guest addons are installed under Veertu's support directory.
+	{"anka", []string{macHardwareVZ}, []string{"/Library/Application Support/Veertu", "/usr/local/bin/anka-guest"}},
+	// Cirrus Labs Tart: the guest agent is installed via Homebrew or baked into the image.
+	{"tart", []string{macHardwareVZ}, []string{"/opt/homebrew/bin/tart-guest-agent", "/usr/local/bin/tart-guest-agent"}},
+	// UTM: guests rely on the SPICE agent for clipboard/resolution sharing.
+	{"utm", []string{macHardwareVZ, macHardwareQEMU}, []string{"/usr/local/bin/spice-vdagent", "/Library/LaunchAgents/org.freedesktop.spice-vdagent.plist"}},
+}
+
+// classifyMacVM returns the provider detail for a macOS VM.
+// hw holds the hardware signals, exists reports whether a path is present.
+// It returns the CI provider name if one is recognized, "apple" for other
+// Virtualization.framework guests, and "" if nothing more specific is known.
+func classifyMacVM(hw macHardware, exists func(path string) bool) string {
+	class := hw.class()
+	if class == "" {
+		return ""
+	}
+
+	for _, p := range macVMProviders {
+		if !slices.Contains(p.classes, class) {
+			continue
+		}
+		for _, path := range p.paths {
+			if exists(path) {
+				return p.name
+			}
+		}
+	}
+
+	// UTM in emulation mode is the only QEMU frontend for macOS guests.
+	if class == macHardwareQEMU {
+		return "utm"
+	}
+	return "apple"
+}
@@ -0,0 +1,99 @@
+package machineid
+
+import (
+	"runtime"
+	"slices"
+)
+
+// Manifest describes the settings supported by this build of machineid on the current platform,
+// so configuration UIs and management planes can render them instead of hard-coding the
+// capabilities of each release. Values are named after the Go identifiers (e.g., "WithEncoding",
+// "Crockford"). Everything listed belongs to the stable API; see the machineid/x package for
+// experimental features.
+type Manifest struct {
+	// RulesVersion is the version of the source selection rules (see RulesVersion).
+	RulesVersion int
+	// Platform is the GOOS/GOARCH pair of the running binary.
+	Platform string
+	// Options lists the Option constructors.
+	Options []OptionDescriptor
+	// Sources lists the sources available on the platform, as returned by Sources().
+	Sources []SourceDescriptor
+	// Encodings, Derivations, Sanitizations and Presets list the accepted values.
+	Encodings     []string
+	Derivations   []string
+	Sanitizations []string
+	Presets       []string
+}
+
+// OptionDescriptor describes an Option constructor.
+type OptionDescriptor struct {
+	// Name is the constructor name (e.g., "WithLength").
+	Name string
+	// Argument is the type of its argument: empty if it has none, a Go type (e.g., "int"), or
+	// the Manifest list holding the accepted values (e.g., "Encodings").
+	Argument string
+	// Scope lists the functions affected by the option.
+	Scope []string
+}
+
+var (
+	// scopeID lists the functions deriving an ID; they all accept the formatting options.
+	scopeID = []string{"ID", "ProtectedID", "UserID", "ProtectedUserID", "BootID", "Info"}
+	// scopeInfo lists the functions building a MachineInfo.
+	scopeInfo = []string{"Info", "BestEffortInfo"}
+	// scopeCache lists the functions reading the cached resolution through the options.
+	scopeCache = []string{"ID", "ProtectedID", "UserID", "ProtectedUserID", "BootID", "Info", "Strength", "BestEffortID", "BestEffortInfo"}
+)
+
+// optionDescriptors describes every Option constructor. Keep it in sync when adding options.
+var optionDescriptors = []OptionDescriptor{
+	{Name: "WithEncoding", Argument: "Encodings", Scope: scopeID},
+	{Name: "WithDerivation", Argument: "Derivations", Scope: scopeID},
+	{Name: "WithDigestSize", Argument: "int", Scope: scopeID},
+	{Name: "WithLength", Argument: "int", Scope: scopeID},
+	{Name: "Short", Scope: scopeID},
+	{Name: "WithoutPrefix", Scope: scopeID},
+	{Name: "WithSeparator", Argument: "string", Scope: scopeID},
+	{Name: "WithArch", Scope: scopeID},
+	{Name: "WithMinEntropy", Argument: "float64", Scope: scopeID},
+	{Name: "WithCloudMetadata", Argument: "bool", Scope: scopeID},
+	{Name: "WithGeneratedFallback", Argument: "string", Scope: scopeID},
+	{Name: "WithCacheTTL", Argument: "time.Duration", Scope: scopeCache},
+	{Name: "WithNoCache", Scope: scopeCache},
+	{Name: "WithoutHardwareFallback", Scope: scopeCache},
+	{Name: "WithMACQuorum", Argument: "Store, int", Scope: scopeCache},
+	{Name: "WithActiveInterfaces", Scope: append(slices.Clip(scopeCache), "GetHardwareAddresses")},
+	{Name: "WithAppIDNormalizer", Argument: "Normalizer", Scope: []string{"ProtectedID", "ProtectedUserID"}},
+	{Name: "WithRulesVersion", Argument: "int", Scope: append(slices.Clip(scopeCache), "GetHardwareAddresses")},
+	{Name: "WithBestEffortStore", Argument: "Store", Scope: []string{"BestEffortID", "BestEffortInfo"}},
+	{Name: "WithAssetTagProvider", Argument: "func", Scope: scopeInfo},
+	{Name: "WithHashedAssetTag", Scope: scopeInfo},
+	{Name: "WithDMISanitization", Argument: "Sanitizations", Scope: scopeInfo},
+	{Name: "WithEnvCapture", Argument: "EnvRule", Scope: scopeInfo},
+	{Name: "WithKubernetesMetadata", Argument: "Sanitizations", Scope: scopeInfo},
+	{Name: "WithVirtualInterfaces", Scope: []string{"GetHardwareAddresses"}},
+	{Name: "WithoutLocallyAdministered", Scope: []string{"GetHardwareAddresses"}},
+	{Name: "WithDebugApps", Argument: "...string", Scope: []string{"Handler"}},
+}
+
+// Describe returns the manifest of the settings supported at runtime. The returned value is a
+// copy; Sources reflects the sources registered and disabled so far.
+func Describe() Manifest {
+	opts := make([]OptionDescriptor, len(optionDescriptors))
+	for i, d := range optionDescriptors {
+		d.Scope = append([]string(nil), d.Scope...)
+		opts[i] = d
+	}
+
+	return Manifest{
+		RulesVersion:  RulesVersion,
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		Options:       opts,
+		Sources:       Sources(),
+		Encodings:     []string{"Hex", "Base64URL", "Crockford"},
+		Derivations:   []string{"DerivationSHA256", "DerivationTupleHash"},
+		Sanitizations: []string{"SanitizeHashed", "SanitizeRedacted", "SanitizeNone"},
+		Presets:       []string{"PresetLicensing", "PresetTelemetry", "PresetClustering"},
+	}
+}
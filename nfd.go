@@ -0,0 +1,77 @@
+package machineid
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// NFDFeatureDir is where Node Feature Discovery's local source reads feature files from.
+const NFDFeatureDir = "/etc/kubernetes/node-feature-discovery/features.d"
+
+// nfdPrefix namespaces the feature names; NFD turns "machineid.env=vm" into the node label
+// "feature.node.kubernetes.io/machineid.env=vm".
+const nfdPrefix = "machineid."
+
+// WriteNFDFeatures writes the detection results in the Node Feature Discovery feature-file
+// format ("name=value" lines), so cluster operators can label nodes with the virtualization
+// class and a hardware identity digest. Write the output to a file in NFDFeatureDir (e.g., from
+// a DaemonSet init container).
+//
+// The identity digest is ID(opts...) shortened to a valid label value; by default it uses Short().
+// Values that are not valid Kubernetes label values are sanitized.
+func WriteNFDFeatures(w io.Writer, opts ...Option) error {
+	if len(opts) == 0 {
+		opts = []Option{Short()}
+	}
+
+	info, err := Info(context.Background(), opts...)
+	if err != nil {
+		return err
+	}
+	hash := stripPrefix(info.ID)
+
+	features := []struct{ name, value string }{
+		{"env", info.Environment},
+		{"env-detail", info.EnvironmentDetail},
+		{"source", info.Source},
+		{"id", hash},
+	}
+	for _, f := range features {
+		v := nfdLabelValue(f.value)
+		if v == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s%s=%s\n", nfdPrefix, f.name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nfdLabelValue converts s into a valid Kubernetes label value: at most 63 characters of
+// [A-Za-z0-9-_.], starting and ending with an alphanumeric character.
+func nfdLabelValue(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			b[i] = '-'
+		}
+	}
+	if len(b) > 63 {
+		b = b[:63]
+	}
+
+	isAlnum := func(c byte) bool {
+		return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+	}
+	for len(b) > 0 && !isAlnum(b[0]) {
+		b = b[1:]
+	}
+	for len(b) > 0 && !isAlnum(b[len(b)-1]) {
+		b = b[:len(b)-1]
+	}
+	return string(b)
+}
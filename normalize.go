@@ -0,0 +1,121 @@
+package machineid
+
+import "sync"
+
+// Normalizer transforms an appID before it is mixed into a ProtectedID, so spellings that
+// should be equivalent yield the same ID. machineid/normalize provides full Unicode normalizers.
+type Normalizer func(appID string) string
+
+// WithAppIDNormalizer replaces the default appID normalizer (LatinNFC), e.g. with
+// WithAppIDNormalizer(normalize.NFCFold). A nil normalizer opts out: the appID is used
+// byte-for-byte, as releases before normalization did.
+func WithAppIDNormalizer(n Normalizer) Option {
+	if n == nil {
+		n = func(appID string) string { return appID }
+	}
+	return func(o *options) {
+		o.appIDNormalizer = n
+	}
+}
+
+// normalizeAppID applies the configured normalizer, LatinNFC by default.
+func (o options) normalizeAppID(appID string) string {
+	if o.appIDNormalizer == nil {
+		return LatinNFC(appID)
+	}
+	return o.appIDNormalizer(appID)
+}
+
+var (
+	latinOnce sync.Once
+	// latinDecompose and latinCompose index latinCompositions by composite and by pair.
+	latinDecompose map[rune][2]rune
+	latinCompose   map[[2]rune]rune
+)
+
+// LatinNFC composes appIDs to Unicode NFC for Latin script without depending on
+// golang.org/x/text: letters from the Latin-1 Supplement, Latin Extended-A/B and Latin
+// Extended Additional blocks followed by marks from the Combining Diacritical Marks block
+// (e.g., "e" + U+0301 from a macOS file name) compose to their precomposed form (U+00E9).
+// Other scripts and marks are left as they are; use normalize.NFC for full NFC.
+// AppIDs without combining marks, including every ASCII appID, are returned unchanged.
+func LatinNFC(appID string) string {
+	if !hasCombiningMark(appID) {
+		return appID
+	}
+	latinOnce.Do(func() {
+		latinDecompose = make(map[rune][2]rune, len(latinCompositions))
+		latinCompose = make(map[[2]rune]rune, len(latinCompositions))
+		for _, c := range latinCompositions {
+			latinDecompose[c[0]] = [2]rune{c[1], c[2]}
+			latinCompose[[2]rune{c[1], c[2]}] = c[0]
+		}
+	})
+
+	// 1. Full canonical decomposition.
+	var runes []rune
+	for _, r := range appID {
+		runes = decomposeLatin(runes, r)
+	}
+
+	// 2. Canonical ordering: stable sort of each run of marks by combining class.
+	for i := 1; i < len(runes); i++ {
+		for j := i; j > 0; j-- {
+			cc, prev := combiningClass(runes[j]), combiningClass(runes[j-1])
+			if cc == 0 || prev <= cc {
+				break
+			}
+			runes[j], runes[j-1] = runes[j-1], runes[j]
+		}
+	}
+
+	// 3. Canonical composition: a mark joins the last starter unless a mark of the same or a
+	// higher class stands between them.
+	out := runes[:0]
+	starter := -1
+	for _, r := range runes {
+		cc := combiningClass(r)
+		if starter >= 0 && cc != 0 {
+			blocked := len(out)-1 > starter && combiningClass(out[len(out)-1]) >= cc
+			if c, ok := latinCompose[[2]rune{out[starter], r}]; ok && !blocked {
+				out[starter] = c
+				continue
+			}
+		}
+		if cc == 0 {
+			starter = len(out)
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// hasCombiningMark reports whether s contains a rune from the Combining Diacritical Marks block.
+func hasCombiningMark(s string) bool {
+	for _, r := range s {
+		if r >= 0x0300 && r < 0x0370 {
+			return true
+		}
+	}
+	return false
+}
+
+// decomposeLatin appends the full canonical decomposition of r to runes.
+func decomposeLatin(runes []rune, r rune) []rune {
+	if d, ok := markDecompositions[r]; ok {
+		return append(runes, d...)
+	}
+	if d, ok := latinDecompose[r]; ok {
+		return append(decomposeLatin(runes, d[0]), d[1])
+	}
+	return append(runes, r)
+}
+
+// combiningClass returns the canonical combining class of r; runes outside the Combining
+// Diacritical Marks block are treated as starters (class 0).
+func combiningClass(r rune) uint8 {
+	if r >= 0x0300 && r < 0x0370 {
+		return combiningClasses[r-0x0300]
+	}
+	return 0
+}
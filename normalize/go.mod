@@ -0,0 +1,5 @@
+module github.com/banditmoscow1337/machineid/normalize
+
+go 1.25.5
+
+require golang.org/x/text v0.33.0
@@ -0,0 +1,26 @@
+// Package normalize provides Unicode appID normalizers for machineid.WithAppIDNormalizer, e.g.
+//
+//	machineid.ProtectedID(appID, machineid.WithAppIDNormalizer(normalize.NFC))
+//
+// The package is separate from machineid so that only applications that import it depend on
+// golang.org/x/text. machineid itself composes appIDs with machineid.LatinNFC, which covers
+// Latin script only.
+package normalize
+
+import (
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NFC composes the appID to Unicode NFC, so an app name typed on one platform (e.g., "é" as
+// U+00E9 on Windows) and the decomposed form produced by another ("e" + U+0301, common on
+// macOS) yield the same ProtectedID.
+func NFC(appID string) string {
+	return norm.NFC.String(appID)
+}
+
+// NFCFold applies NFC and Unicode case folding, so "MyApp" and "myapp" yield the same
+// ProtectedID.
+func NFCFold(appID string) string {
+	return cases.Fold().String(norm.NFC.String(appID))
+}
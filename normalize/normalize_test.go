@@ -0,0 +1,27 @@
+package normalize
+
+import "testing"
+
+func TestNFC(t *testing.T) {
+	composed := "Caf\u00e9"    // é as a single code point (NFC).
+	decomposed := "Cafe\u0301" // e + combining acute accent (NFD).
+
+	if NFC(composed) != NFC(decomposed) {
+		t.Error("NFC must unify composed and decomposed appIDs")
+	}
+	if got := NFC("my-app"); got != "my-app" {
+		t.Errorf("NFC must not change ASCII appIDs, got %q", got)
+	}
+	if NFC("MyApp") == NFC("myapp") {
+		t.Error("NFC must keep the case")
+	}
+}
+
+func TestNFCFold(t *testing.T) {
+	if NFCFold("MyApp") != NFCFold("myapp") {
+		t.Error("NFCFold must ignore case")
+	}
+	if NFCFold("CAFÉ") != NFCFold("café") {
+		t.Error("NFCFold must also normalize to NFC")
+	}
+}
@@ -0,0 +1,390 @@
+package machineid
+
+import (
+	"context"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Encoding selects how the SHA256 digest is rendered into the returned ID string.
+type Encoding int
+
+const (
+	// Hex renders the digest as lowercase hexadecimal (64 chars). This is the default.
+	Hex Encoding = iota
+	// Base64URL renders the digest as unpadded, URL-safe base64 (43 chars).
+	Base64URL
+	// Crockford renders the digest as unpadded Crockford base32 (52 chars).
+	// The alphabet excludes I, L, O and U, which makes it easy to read out loud.
+	Crockford
+)
+
+// Derivation selects how the raw identifier is turned into the hash part of the ID.
+type Derivation int
+
+const (
+	// DerivationSHA256 hashes the inputs joined with ":" using SHA256. This is the default;
+	// it produces the same IDs as earlier releases.
+	DerivationSHA256 Derivation = iota
+	// DerivationTupleHash hashes the inputs as a tuple with TupleHash256 (NIST SP 800-185).
+	// Each input is length-prefixed, so ("a:b", "c") and ("a", "b:c") can't collide, and every
+	// API uses its own customization string, so ID() and ProtectedID() outputs are unrelated.
+	// The digest size is configurable with WithDigestSize.
+	DerivationTupleHash
+)
+
+// Domain separation strings used as TupleHash customization, one per API.
+// They are part of the derivation and must never change.
+const (
+	domainID          = "machineid ID"
+	domainProtectedID = "machineid ProtectedID"
+	domainEphemeralID = "machineid EphemeralID"
+	domainBootID      = "machineid BootID"
+
+	domainUserID          = "machineid UserID"
+	domainProtectedUserID = "machineid ProtectedUserID"
+)
+
+// sha256Tagged lists the domains that DerivationSHA256 hashes as the first tuple item. ID,
+// ProtectedID and EphemeralID predate domain separation and hash the bare tuple; the other APIs
+// would otherwise equal a ProtectedID with a crafted appID (e.g., UserID and
+// ProtectedID(uid)). No ProtectedID input can start with a domain, as the raw ID comes first.
+var sha256Tagged = map[string]bool{
+	domainBootID:          true,
+	domainUserID:          true,
+	domainProtectedUserID: true,
+}
+
+// DefaultDigestSize is the digest size in bytes used by DerivationTupleHash unless
+// WithDigestSize is given.
+const DefaultDigestSize = 32
+
+// ErrFallbackRejected is returned when the ID could only be derived from the MAC address
+// fallback (or generated, see WithGeneratedFallback), but the options (e.g., PresetLicensing)
+// require an OS or hardware source.
+var ErrFallbackRejected = errors.New("machineid: ID derived from MAC fallback rejected by options")
+
+// ShortLength is the number of characters kept by the Short option.
+const ShortLength = 12
+
+// crockfordEncoding uses Douglas Crockford's base32 alphabet without padding.
+var crockfordEncoding = base32.NewEncoding("0123456789ABCDEFGHJKMNPQRSTVWXYZ").WithPadding(base32.NoPadding)
+
+// options holds the settings applied by ID(), ProtectedID() and Info().
+type options struct {
+	encoding Encoding
+	length   int // 0 means "no truncation"
+
+	derivation Derivation
+	digestSize int // DerivationTupleHash only; 0 means DefaultDigestSize
+
+	// noPrefix drops the "<environment>:" prefix from the ID.
+	noPrefix bool
+	// separator replaces the ":" between the prefix and the hash ("" keeps the default).
+	separator string
+
+	// includeArch adds the architecture of the running binary to the derivation.
+	includeArch bool
+
+	// appIDNormalizer normalizes appIDs (ProtectedID() only, nil means LatinNFC).
+	appIDNormalizer Normalizer
+
+	// cloudMetadata derives the ID from the cloud instance ID when available.
+	cloudMetadata bool
+
+	// rejectFallback makes ID() fail instead of returning an ID derived from MAC addresses,
+	// the gethostid(2) value, or generated.
+	rejectFallback bool
+	// noHardwareFallback makes the resolution fail instead of falling back to the MAC addresses
+	// or the gethostid(2) value.
+	noHardwareFallback bool
+	// macQuorumStore anchors the MAC set for WithMACQuorum; macQuorum is the quorum.
+	macQuorumStore Store
+	macQuorum      int
+
+	// virtualInterfaces and noLocallyAdministered configure GetHardwareAddresses.
+	virtualInterfaces     bool
+	noLocallyAdministered bool
+	// activeInterfaces restricts the MAC fallback to interfaces with a carrier or default route.
+	activeInterfaces bool
+	// rulesVersion selects the MAC fallback rules and the ProtectedID domain-separation tag
+	// (see WithRulesVersion).
+	rulesVersion int
+	// minEntropy is the minimum estimated entropy of the raw ID, in bits (0 disables the check).
+	minEntropy float64
+	// cacheTTL bounds the age of the cached resolution (0 keeps it until Refresh); noCache
+	// re-resolves on every call.
+	cacheTTL time.Duration
+	noCache  bool
+
+	// BestEffortID() and BestEffortInfo() only.
+	bestEffortStore Store
+	// generatedFallback is the path of the generated ID used when every source fails.
+	generatedFallback string
+
+	// Handler() only: the appIDs whose ProtectedID may be served.
+	debugApps []string
+
+	// Info() only.
+	assetTagProvider func(ctx context.Context) (string, error)
+	hashAssetTag     bool
+	dmiSanitization  Sanitization
+	envRules         []EnvRule
+
+	kubernetesMetadata     bool
+	kubernetesSanitization Sanitization
+}
+
+// Option configures the output of ID(), ProtectedID() and Info().
+// Options that only affect Info() are ignored by the other functions.
+type Option func(*options)
+
+// WithEncoding selects the encoding used for the hash part of the ID.
+func WithEncoding(enc Encoding) Option {
+	return func(o *options) {
+		o.encoding = enc
+	}
+}
+
+// WithDerivation selects the derivation scheme. Changing it changes every ID, so pick one
+// before persisting IDs.
+func WithDerivation(d Derivation) Option {
+	return func(o *options) {
+		o.derivation = d
+	}
+}
+
+// WithDigestSize sets the digest size in bytes for DerivationTupleHash (e.g., 16 for compact
+// IDs, 64 for a wider margin). The size is an input of the hash, so a shorter digest is not a
+// prefix of a longer one. Values <= 0 select DefaultDigestSize. It has no effect on DerivationSHA256.
+func WithDigestSize(n int) Option {
+	return func(o *options) {
+		o.digestSize = n
+	}
+}
+
+// WithoutPrefix makes ID() and ProtectedID() return the hash only, without the
+// "<environment>:" prefix. The environment class can change for the same machine
+// (e.g., after a P2V migration), so use it where IDs are used as lookup keys.
+func WithoutPrefix() Option {
+	return func(o *options) {
+		o.noPrefix = true
+	}
+}
+
+// WithSeparator replaces the ":" between the environment prefix and the hash (e.g., "_" or "-"
+// for systems where ":" is an illegal character). An empty separator keeps ":"; use
+// WithoutPrefix for the bare hash. SameMachine only recognizes the default separator.
+func WithSeparator(sep string) Option {
+	return func(o *options) {
+		o.separator = sep
+	}
+}
+
+// goarch is the architecture mixed in by WithArch. It is a variable so tests can simulate
+// other builds.
+var goarch = runtime.GOARCH
+
+// WithArch mixes the architecture of the running binary (runtime.GOARCH, e.g. "arm64") into
+// the hash. By default the architecture is not part of the ID: amd64 and arm64 builds on the
+// same machine (or userlands of a dual-boot machine reading the same source) get the same ID.
+// Use it if each architecture must be counted as a distinct installation.
+func WithArch() Option {
+	return func(o *options) {
+		o.includeArch = true
+	}
+}
+
+// WithLength truncates the encoded hash to n characters.
+// Values <= 0 (or larger than the encoded hash) leave the hash untouched.
+// Note: Truncation reduces uniqueness; keep n large enough for your fleet size.
+func WithLength(n int) Option {
+	return func(o *options) {
+		o.length = n
+	}
+}
+
+// Short produces a human-dictatable "short ID": Crockford base32 truncated to ShortLength chars.
+// 12 Crockford chars carry 60 bits of the hash, which is plenty for support tickets.
+func Short() Option {
+	return func(o *options) {
+		o.encoding = Crockford
+		o.length = ShortLength
+	}
+}
+
+// WithAssetTagProvider registers a callback returning the corporate asset tag (e.g., from a CMDB).
+// Info() attaches the returned value to MachineInfo.AssetTag as-is, so the anonymous ID can be
+// correlated with inventory records in a single report. Use WithHashedAssetTag to hash it instead.
+func WithAssetTagProvider(provider func(ctx context.Context) (string, error)) Option {
+	return func(o *options) {
+		o.assetTagProvider = provider
+	}
+}
+
+// WithHashedAssetTag makes Info() report the SHA256 (hex) of the asset tag instead of the raw value.
+func WithHashedAssetTag() Option {
+	return func(o *options) {
+		o.hashAssetTag = true
+	}
+}
+
+// check verifies that the resolved state satisfies the options.
+func (o options) check(snap snapshot) error {
+	if o.rejectFallback && (snap.source == ComponentMAC || snap.source == SourceHostID || snap.source == SourcePersisted) {
+		return ErrFallbackRejected
+	}
+	return checkEntropy(snap, o.minEntropy)
+}
+
+// WithoutHardwareFallback makes ID(), ProtectedID() and Info() fail with ErrFallbackRejected
+// instead of falling back to the MAC addresses (or the gethostid(2) value) when no OS source
+// (e.g., /etc/machine-id) is available. A MAC-derived ID changes with NICs and is easily
+// spoofed, so security-sensitive callers may rather fail than silently get one. Combined with
+// WithGeneratedFallback, the generated ID is used instead.
+func WithoutHardwareFallback() Option {
+	return func(o *options) {
+		o.noHardwareFallback = true
+	}
+}
+
+// fallbackSources are the sources a resolution falls back on, which the options may re-run.
+type fallbackSources struct {
+	// interfaces enumerates the interfaces of the MAC fallback.
+	interfaces func() ([]net.Interface, error)
+	// machineID is the secondary machine ID of the version 2 rules, nil if there is none.
+	machineID func() (string, error)
+	// machineIDV2 is the platform machine ID of the version 2 rules, nil if it is read like
+	// under version 1.
+	machineIDV2 func() (string, string, error)
+}
+
+// platformFallbackSources returns the fallback sources of the package-level resolution.
+func platformFallbackSources() fallbackSources {
+	return fallbackSources{
+		interfaces:  netInterfaces,
+		machineID:   getSecondaryMachineIDFunc,
+		machineIDV2: getMachineIDV2Func,
+	}
+}
+
+// applyFallbackPolicy applies the options controlling the hardware fallback to a resolution.
+func (o options) applyFallbackPolicy(fallback fallbackSources, snap snapshot, err error) (snapshot, error) {
+	snap, err = o.applyMachineIDV2(fallback.machineIDV2, snap, err)
+	snap, err = o.applySecondaryMachineID(fallback.machineID, snap, err)
+	snap, err = o.rejectHardwareFallback(snap, err)
+	return o.applyMACQuorum(o.applyMACRules(fallback.interfaces, snap, err))
+}
+
+// applyMachineIDV2 replaces a resolution by the platform machine ID source, or one that fell
+// back to the MAC addresses or the gethostid(2) value, by the platform machine ID of the version
+// 2 rules, if WithRulesVersion(2) was given and it is available. Resolutions by other sources
+// (e.g., set with SetSourcePriority) are kept.
+func (o options) applyMachineIDV2(get func() (string, string, error), snap snapshot, err error) (snapshot, error) {
+	if err != nil || get == nil || o.rulesVersion < 2 || !sourceEnabled(ComponentMachineID) {
+		return snap, err
+	}
+	if snap.source != machineIDSource() && snap.source != ComponentMAC && snap.source != SourceHostID {
+		return snap, nil
+	}
+	id, source, getErr := get()
+	if getErr != nil || id == "" {
+		logDebug("machineid: no version 2 machine ID", "error", getErr)
+		return snap, nil
+	}
+	snap.rawID, snap.source = id, source
+	return snap, nil
+}
+
+// applySecondaryMachineID replaces a resolution that fell back to the MAC addresses or the
+// gethostid(2) value by the secondary machine ID (the D-Bus copy of the machine-id on Linux),
+// if WithRulesVersion(2) was given and it is available.
+func (o options) applySecondaryMachineID(get func() (string, error), snap snapshot, err error) (snapshot, error) {
+	if err != nil || get == nil || o.rulesVersion < 2 || snap.source != ComponentMAC && snap.source != SourceHostID {
+		return snap, err
+	}
+	id, getErr := get()
+	if getErr != nil || id == "" {
+		logDebug("machineid: no secondary machine ID", "error", getErr)
+		return snap, nil
+	}
+	snap.rawID, snap.source = id, ComponentMachineID
+	return snap, nil
+}
+
+// rejectHardwareFallback turns a resolution that fell back to the MAC addresses or the
+// gethostid(2) value into an error, if WithoutHardwareFallback was given.
+func (o options) rejectHardwareFallback(snap snapshot, err error) (snapshot, error) {
+	if err == nil && o.noHardwareFallback && (snap.source == ComponentMAC || snap.source == SourceHostID) {
+		return snapshot{}, fmt.Errorf("%w: no OS source, the ID would derive from %s", ErrFallbackRejected, snap.source)
+	}
+	return snap, err
+}
+
+// newOptions applies opts on top of the defaults (hex, full length).
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// derive hashes the tuple according to the derivation scheme. domain identifies the calling API.
+func (o options) derive(domain string, tuple []string) ([]byte, error) {
+	if o.includeArch {
+		if strings.TrimSpace(tuple[0]) == "" {
+			return nil, errors.New("empty machine id")
+		}
+		tuple = append(tuple[:len(tuple):len(tuple)], goarch)
+	}
+	if o.derivation != DerivationTupleHash {
+		if sha256Tagged[domain] {
+			if strings.TrimSpace(tuple[0]) == "" {
+				return nil, errors.New("empty machine id")
+			}
+			tuple = append([]string{domain}, tuple...)
+		}
+		return digest(strings.Join(tuple, ":"))
+	}
+
+	tuple = append([]string(nil), tuple...)
+	for i := range tuple {
+		tuple[i] = strings.TrimSpace(tuple[i])
+	}
+	if tuple[0] == "" {
+		return nil, errors.New("empty machine id")
+	}
+	size := o.digestSize
+	if size <= 0 {
+		size = DefaultDigestSize
+	}
+	return tupleHash256(tuple, domain, size), nil
+}
+
+// encode renders the digest according to the options.
+func (o options) encode(sum []byte) string {
+	var s string
+	switch o.encoding {
+	case Base64URL:
+		s = base64.RawURLEncoding.EncodeToString(sum)
+	case Crockford:
+		s = crockfordEncoding.EncodeToString(sum)
+	default:
+		s = hex.EncodeToString(sum)
+	}
+
+	if o.length > 0 && o.length < len(s) {
+		s = s[:o.length]
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package machineid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidID is wrapped by the errors of ParseID.
+var ErrInvalidID = errors.New("machineid: invalid ID")
+
+// maxHashLength bounds the hash part accepted by ParseID: a 64-byte TupleHash digest in hex.
+const maxHashLength = 128
+
+// MachineID is a parsed ID, as returned by ID(), ProtectedID() or EphemeralID(). The zero value
+// is not a valid ID.
+type MachineID struct {
+	prefix string
+	hash   string
+}
+
+// ParseID parses and validates an ID in the "<environment>:<hash>" format, or a bare hash
+// (WithoutPrefix). The prefix must be lowercase letters, digits and hyphens, and the hash must
+// only use characters of the Hex, Base64URL or Crockford encodings. IDs created with
+// WithSeparator must be converted back to ":" first.
+func ParseID(s string) (MachineID, error) {
+	prefix, hash, ok := strings.Cut(s, ":")
+	if !ok {
+		prefix, hash = "", s
+	} else if prefix == "" {
+		return MachineID{}, fmt.Errorf("%w %q: empty prefix", ErrInvalidID, s)
+	}
+
+	for _, r := range prefix {
+		if !('a' <= r && r <= 'z' || '0' <= r && r <= '9' || r == '-') {
+			return MachineID{}, fmt.Errorf("%w %q: invalid character %q in prefix", ErrInvalidID, s, r)
+		}
+	}
+	if hash == "" || len(hash) > maxHashLength {
+		return MachineID{}, fmt.Errorf("%w %q: hash length %d out of range", ErrInvalidID, s, len(hash))
+	}
+	// The Base64URL alphabet is a superset of the Hex and Crockford ones.
+	for _, r := range hash {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-' || r == '_') {
+			return MachineID{}, fmt.Errorf("%w %q: invalid character %q in hash", ErrInvalidID, s, r)
+		}
+	}
+	return MachineID{prefix: prefix, hash: hash}, nil
+}
+
+// Prefix returns the environment prefix (e.g., "vm"), or "" for a bare hash.
+func (id MachineID) Prefix() string {
+	return id.prefix
+}
+
+// Hash returns the hash part, which SameMachine compares.
+func (id MachineID) Hash() string {
+	return id.hash
+}
+
+// String returns the ID in the format it was parsed from.
+func (id MachineID) String() string {
+	if id.prefix == "" {
+		return id.hash
+	}
+	return id.prefix + ":" + id.hash
+}
+
+// MarshalText implements encoding.TextMarshaler, so IDs can be stored as JSON strings or text
+// columns.
+func (id MachineID) MarshalText() ([]byte, error) {
+	if id.hash == "" {
+		return nil, fmt.Errorf("%w: zero MachineID", ErrInvalidID)
+	}
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler with the validation of ParseID.
+func (id *MachineID) UnmarshalText(text []byte) error {
+	parsed, err := ParseID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
@@ -0,0 +1,29 @@
+package machineid
+
+import (
+	"bytes"
+	"net"
+	"slices"
+)
+
+// withPermanentAddresses replaces the current hardware address of the interfaces by the
+// permanent (burned-in) one where the OS exposes it, so MAC spoofing or randomization doesn't
+// change the MAC fallback. It is part of the version 2 MAC rules (see RulesVersion).
+func withPermanentAddresses(interfaces []net.Interface) []net.Interface {
+	interfaces = slices.Clone(interfaces)
+	for i, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) == 0 {
+			continue
+		}
+		perm := permanentAddress(iface)
+		// Virtual devices report no permanent address, or an all-zero one.
+		if len(perm) != len(iface.HardwareAddr) || !slices.ContainsFunc(perm, func(b byte) bool { return b != 0 }) {
+			continue
+		}
+		if !bytes.Equal(perm, iface.HardwareAddr) {
+			logDebug("machineid: using the permanent address", "interface", iface.Name)
+			interfaces[i].HardwareAddr = perm
+		}
+	}
+	return interfaces
+}
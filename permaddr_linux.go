@@ -0,0 +1,46 @@
+package machineid
+
+import (
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ethtoolPermAddr is struct ethtool_perm_addr with room for MAX_ADDR_LEN bytes.
+type ethtoolPermAddr struct {
+	cmd  uint32
+	size uint32
+	data [32]byte
+}
+
+// ifreqData is struct ifreq with the ifr_data member of the union.
+type ifreqData struct {
+	name [unix.IFNAMSIZ]byte
+	data unsafe.Pointer
+	_    [24 - unsafe.Sizeof(uintptr(0))]byte
+}
+
+// permanentAddress returns the permanent address of the interface, as reported by
+// "ethtool -P", or nil if the driver doesn't report one.
+func permanentAddress(iface net.Interface) net.HardwareAddr {
+	if len(iface.Name) >= unix.IFNAMSIZ {
+		return nil
+	}
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil
+	}
+	defer unix.Close(fd)
+
+	perm := ethtoolPermAddr{cmd: unix.ETHTOOL_GPERMADDR, size: uint32(len(ethtoolPermAddr{}.data))}
+	ifr := ifreqData{data: unsafe.Pointer(&perm)}
+	copy(ifr.name[:], iface.Name)
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.SIOCETHTOOL, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		return nil
+	}
+	if perm.size > uint32(len(perm.data)) {
+		return nil
+	}
+	return net.HardwareAddr(perm.data[:perm.size])
+}
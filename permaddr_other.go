@@ -0,0 +1,10 @@
+//go:build !linux && !windows
+
+package machineid
+
+import "net"
+
+// permanentAddress reports no permanent address on this platform: the current one is used.
+func permanentAddress(net.Interface) net.HardwareAddr {
+	return nil
+}
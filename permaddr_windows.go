@@ -0,0 +1,20 @@
+package machineid
+
+import (
+	"net"
+
+	"golang.org/x/sys/windows"
+)
+
+// permanentAddress returns the permanent address of the interface, as reported by the IP
+// Helper API (MIB_IF_ROW2.PermanentPhysicalAddress), or nil if it is unavailable.
+func permanentAddress(iface net.Interface) net.HardwareAddr {
+	row := windows.MibIfRow2{InterfaceIndex: uint32(iface.Index)}
+	if err := windows.GetIfEntry2Ex(windows.MibIfEntryNormal, &row); err != nil {
+		return nil
+	}
+	if row.PhysicalAddressLength > uint32(len(row.PermanentPhysicalAddress)) {
+		return nil
+	}
+	return net.HardwareAddr(row.PermanentPhysicalAddress[:row.PhysicalAddressLength])
+}
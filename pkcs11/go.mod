@@ -0,0 +1,12 @@
+module github.com/banditmoscow1337/machineid/pkcs11
+
+go 1.25.5
+
+require (
+	github.com/banditmoscow1337/machineid v1.0.0
+	github.com/miekg/pkcs11 v1.1.2
+)
+
+require golang.org/x/sys v0.39.0 // indirect
+
+replace github.com/banditmoscow1337/machineid => ../
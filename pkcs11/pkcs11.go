@@ -0,0 +1,142 @@
+// Package pkcs11 derives a machine identifier from a hardware security module (HSM) or
+// smartcard through a PKCS#11 module.
+//
+// In regulated environments the identity must live in certified hardware rather than in OS
+// files. The identifier is derived either from the public part of a key stored on the token
+// (Config.KeyLabel) or, if no key is named, from the token's manufacturer, model and serial
+// number. Private key material is never read.
+//
+// The package is separate from machineid so that only applications that import it depend on
+// cgo and the PKCS#11 bindings. Without cgo, every function returns an error wrapping
+// errors.ErrUnsupported.
+package pkcs11
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/banditmoscow1337/machineid"
+)
+
+// SourceName is the name under which Register adds the token to the machineid source chain.
+const SourceName = "pkcs11"
+
+// Config selects the PKCS#11 module, token and key.
+type Config struct {
+	// Module is the path of the vendor's PKCS#11 library (e.g., "/usr/lib/softhsm/libsofthsm2.so").
+	Module string
+	// TokenLabel selects the token by label. If empty, the first slot with a token is used.
+	TokenLabel string
+	// PIN logs in as the normal user. It is only needed if the key is a private object.
+	PIN string
+	// KeyLabel names the key (CKA_LABEL) whose public part identifies the machine.
+	// If empty, the token serial number is used instead.
+	KeyLabel string
+}
+
+// token is an open session on the selected token. It is an interface so tests can
+// substitute a fake module.
+type token interface {
+	// info returns the token's manufacturer ID, model and serial number.
+	info() (manufacturer, model, serial string, err error)
+	// publicKey returns the encoded public part of the key labeled label.
+	publicKey(label string) ([]byte, error)
+	close()
+}
+
+// openFunc opens a session on the configured token.
+var openFunc = openToken
+
+// ErrKeyNotFound is returned when the token holds no public key with the configured label.
+var ErrKeyNotFound = errors.New("pkcs11: key not found")
+
+// ID returns the SHA256 (hex) of the configured key's public part, or of the token identity
+// if no key is configured.
+func ID(cfg Config) (string, error) {
+	if cfg.Module == "" {
+		return "", errors.New("pkcs11: no module configured")
+	}
+
+	t, err := openFunc(cfg)
+	if err != nil {
+		return "", fmt.Errorf("pkcs11: open: %w", err)
+	}
+	defer t.close()
+
+	// The material is domain-separated, so a key and a token can never produce the same ID.
+	var material []byte
+	if cfg.KeyLabel != "" {
+		pub, err := t.publicKey(cfg.KeyLabel)
+		if err != nil {
+			return "", fmt.Errorf("pkcs11: read key %q: %w", cfg.KeyLabel, err)
+		}
+		material = append([]byte("key\x00"), pub...)
+	} else {
+		manufacturer, model, serial, err := t.info()
+		if err != nil {
+			return "", fmt.Errorf("pkcs11: read token info: %w", err)
+		}
+		if serial == "" {
+			return "", errors.New("pkcs11: token has no serial number")
+		}
+		// Serial numbers are only unique per manufacturer and model.
+		material = []byte("token\x00" + manufacturer + "\x00" + model + "\x00" + serial)
+	}
+
+	sum := sha256.Sum256(material)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// encodePublicKey encodes the key type and the public attribute values (modulus and exponent
+// for RSA, curve parameters and point for EC keys) with length prefixes, so the encoding is
+// unambiguous.
+func encodePublicKey(keyType uint, values ...[]byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(keyType))
+	for _, v := range values {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(v)))
+		b = append(b, v...)
+	}
+	return b
+}
+
+// ProtectedID returns an app-specific identifier derived from ID(), so the same token
+// can't be correlated across applications.
+func ProtectedID(cfg Config, appID string) (string, error) {
+	id, err := ID(cfg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(id + ":" + appID))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Register adds the token as the opt-in machineid source SourceName. Name it in
+// machineid.SetSourcePriority to derive machineid.ID from the HSM, e.g.
+//
+//	pkcs11.Register(cfg)
+//	machineid.SetSourcePriority(pkcs11.SourceName, machineid.ComponentMachineID)
+//
+// Call it once at startup, before SetSourcePriority.
+func Register(cfg Config) error {
+	return machineid.RegisterSource(source{cfg})
+}
+
+// source is the token as a machineid.Source.
+type source struct {
+	cfg Config
+}
+
+func (source) Name() string { return SourceName }
+
+// Resolve reads the token. PKCS#11 calls can't be interrupted, so ctx is only checked before
+// opening the session.
+func (s source) Resolve(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return ID(s.cfg)
+}
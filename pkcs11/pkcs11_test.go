@@ -0,0 +1,109 @@
+package pkcs11
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/banditmoscow1337/machineid"
+)
+
+// fakeToken serves a fixed token identity and a set of public keys by label.
+type fakeToken struct {
+	manufacturer, model, serial string
+	keys                        map[string][]byte
+	closed                      bool
+}
+
+func (f *fakeToken) info() (string, string, string, error) {
+	return f.manufacturer, f.model, f.serial, nil
+}
+
+func (f *fakeToken) publicKey(label string) ([]byte, error) {
+	pub, ok := f.keys[label]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return pub, nil
+}
+
+func (f *fakeToken) close() { f.closed = true }
+
+func TestID(t *testing.T) {
+	defer func() { openFunc = openToken }()
+
+	fake := &fakeToken{
+		manufacturer: "SoftHSM project",
+		model:        "SoftHSM v2",
+		serial:       "7c1f2a9e3b4d5e6f",
+		keys:         map[string][]byte{"machine": encodePublicKey(0, []byte{0xc5, 0x01}, []byte{0x01, 0x00, 0x01})},
+	}
+	openFunc = func(Config) (token, error) { return fake, nil }
+
+	if _, err := ID(Config{}); err == nil {
+		t.Error("expected an error without a module")
+	}
+
+	cfg := Config{Module: "libfake.so"}
+	tokenID, err := ID(cfg)
+	if err != nil || len(tokenID) != 64 {
+		t.Fatalf("unexpected token ID %q, %v", tokenID, err)
+	}
+	if !fake.closed {
+		t.Error("the session must be closed")
+	}
+
+	cfg.KeyLabel = "machine"
+	keyID, err := ID(cfg)
+	if err != nil || len(keyID) != 64 {
+		t.Fatalf("unexpected key ID %q, %v", keyID, err)
+	}
+	if keyID == tokenID {
+		t.Error("key and token IDs must be domain separated")
+	}
+
+	// A different serial on the same model is a different token.
+	fake.serial = "0000000000000001"
+	if again, _ := ID(Config{Module: "libfake.so"}); again == tokenID {
+		t.Error("different serials must produce different IDs")
+	}
+
+	// ProtectedID separates applications.
+	a, _ := ProtectedID(cfg, "app-a")
+	b, _ := ProtectedID(cfg, "app-b")
+	if a == b || a == keyID {
+		t.Error("ProtectedID must differ between apps and from ID")
+	}
+
+	cfg.KeyLabel = "missing"
+	if _, err := ID(cfg); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer func() { openFunc = openToken }()
+	defer machineid.SetSourcePriority()
+
+	openFunc = func(Config) (token, error) {
+		return &fakeToken{manufacturer: "m", model: "x", serial: "s"}, nil
+	}
+	cfg := Config{Module: "libfake.so"}
+
+	if err := Register(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(cfg); err == nil {
+		t.Error("registering twice must fail")
+	}
+	if err := machineid.SetSourcePriority(SourceName, machineid.ComponentMachineID); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := machineid.Info(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Source != SourceName {
+		t.Errorf("expected source %q, got %q", SourceName, info.Source)
+	}
+}
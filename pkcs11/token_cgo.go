@@ -0,0 +1,151 @@
+//go:build cgo
+
+package pkcs11
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	p11 "github.com/miekg/pkcs11"
+)
+
+type ctxToken struct {
+	ctx     *p11.Ctx
+	slot    uint
+	session p11.SessionHandle
+}
+
+// openToken loads the module, selects the token and opens a read-only session,
+// logging in if a PIN is configured.
+func openToken(cfg Config) (token, error) {
+	ctx := p11.New(cfg.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("cannot load module %q", cfg.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, err
+	}
+
+	t := &ctxToken{ctx: ctx}
+	if err := t.open(cfg); err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *ctxToken) open(cfg Config) error {
+	slots, err := t.ctx.GetSlotList(true)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, slot := range slots {
+		if cfg.TokenLabel != "" {
+			info, err := t.ctx.GetTokenInfo(slot)
+			if err != nil || strings.TrimSpace(info.Label) != cfg.TokenLabel {
+				continue
+			}
+		}
+		t.slot, found = slot, true
+		break
+	}
+	if !found {
+		return errors.New("no matching token present")
+	}
+
+	if t.session, err = t.ctx.OpenSession(t.slot, p11.CKF_SERIAL_SESSION); err != nil {
+		return err
+	}
+	if cfg.PIN != "" {
+		if err := t.ctx.Login(t.session, p11.CKU_USER, cfg.PIN); err != nil {
+			t.ctx.CloseSession(t.session)
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *ctxToken) info() (string, string, string, error) {
+	info, err := t.ctx.GetTokenInfo(t.slot)
+	if err != nil {
+		return "", "", "", err
+	}
+	// The fields are fixed-width and padded with blanks.
+	return strings.TrimSpace(info.ManufacturerID), strings.TrimSpace(info.Model), strings.TrimSpace(info.SerialNumber), nil
+}
+
+func (t *ctxToken) publicKey(label string) ([]byte, error) {
+	template := []*p11.Attribute{
+		p11.NewAttribute(p11.CKA_CLASS, p11.CKO_PUBLIC_KEY),
+		p11.NewAttribute(p11.CKA_LABEL, label),
+	}
+	if err := t.ctx.FindObjectsInit(t.session, template); err != nil {
+		return nil, err
+	}
+	objects, _, err := t.ctx.FindObjects(t.session, 1)
+	t.ctx.FindObjectsFinal(t.session)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	attrs, err := t.ctx.GetAttributeValue(t.session, objects[0], []*p11.Attribute{
+		p11.NewAttribute(p11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	keyType, err := attrUint(attrs[0].Value)
+	if err != nil {
+		return nil, err
+	}
+
+	var types []uint
+	switch keyType {
+	case p11.CKK_RSA:
+		types = []uint{p11.CKA_MODULUS, p11.CKA_PUBLIC_EXPONENT}
+	case p11.CKK_EC:
+		types = []uint{p11.CKA_EC_PARAMS, p11.CKA_EC_POINT}
+	default:
+		return nil, fmt.Errorf("unsupported key type %#x", keyType)
+	}
+
+	query := make([]*p11.Attribute, len(types))
+	for i, typ := range types {
+		query[i] = p11.NewAttribute(typ, nil)
+	}
+	if attrs, err = t.ctx.GetAttributeValue(t.session, objects[0], query); err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(attrs))
+	for i, a := range attrs {
+		values[i] = a.Value
+	}
+	return encodePublicKey(keyType, values...), nil
+}
+
+func (t *ctxToken) close() {
+	t.ctx.CloseSession(t.session)
+	t.ctx.Finalize()
+	t.ctx.Destroy()
+}
+
+// attrUint decodes a CK_ULONG attribute, stored in native byte order and width.
+func attrUint(b []byte) (uint, error) {
+	switch len(b) {
+	case 4:
+		return uint(binary.NativeEndian.Uint32(b)), nil
+	case 8:
+		return uint(binary.NativeEndian.Uint64(b)), nil
+	default:
+		return 0, fmt.Errorf("invalid CK_ULONG of %d bytes", len(b))
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !cgo
+
+package pkcs11
+
+import "errors"
+
+// openToken needs cgo to load the PKCS#11 module.
+func openToken(Config) (token, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -1,20 +1,91 @@
-//go:build darwin
+//go:build darwin && !ios
 
 package machineid
 
 import (
-	"os/exec"
+	"os"
 	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// VM detection runs sysctl and exec lookups. The hardware can't change while the process
+	// runs, so the result (including a negative one) is computed once and reused.
+	vmOnce     sync.Once
+	vmFound    bool
+	vmDetail   string
+	vmEvidence string
 )
 
-func getEnvironmentType() string {
-	// Check sysctl for machdep.cpu.features containing VMM
-	cmd := exec.Command("sysctl", "machdep.cpu.features")
-	out, err := cmd.Output()
-	if err == nil {
-		if strings.Contains(string(out), "VMM") {
-			return "vm"
+func detectEnvironment() envMatch {
+	if isVM, _ := detectVM(); isVM {
+		return envMatch{"vm", vmEvidence, confidenceSignature}
+	}
+	return envMatch{"physical", "", confidenceDefault}
+}
+
+// detectEnvironmentDetail returns the VM provider, e.g. "vm/tart", "vm/anka", or "vm/apple"
+// for other Virtualization.framework guests.
+func detectEnvironmentDetail() (string, string) {
+	if isVM, detail := detectVM(); isVM && detail != "" {
+		return "vm/" + detail, vmEvidence
+	}
+	return "", ""
+}
+
+// detectVM reports whether we run in a VM, and the provider detail (see classifyMacVM).
+func detectVM() (bool, string) {
+	vmOnce.Do(func() {
+		model, _ := unix.Sysctl("hw.model")
+		target, _ := unix.Sysctl("hw.target")
+
+		// 1. Apple Virtualization.framework
+		// Guests report a "VirtualMac" model (e.g., "VirtualMac2,1") and hw.target on Apple Silicon.
+		// This covers CI providers running macOS VMs (EC2 Mac dedicated hosts with Anka/Tart, etc.).
+		if strings.HasPrefix(model, "VirtualMac") {
+			vmFound, vmEvidence = true, "sysctl:hw.model="+model
+		} else if strings.HasPrefix(target, "VirtualMac") {
+			vmFound, vmEvidence = true, "sysctl:hw.target="+target
 		}
+
+		// 2. Generic hypervisor flag
+		// kern.hv_vmm_present is 1 whenever the kernel runs under a hypervisor.
+		if v, err := unix.SysctlUint32("kern.hv_vmm_present"); err == nil && v == 1 && !vmFound {
+			vmFound, vmEvidence = true, "sysctl:kern.hv_vmm_present"
+		}
+
+		// 3. Check sysctl for machdep.cpu.features containing VMM (Intel Macs on older releases)
+		if !vmFound {
+			if out, err := runCommand("sysctl", "-n", "machdep.cpu.features"); err == nil {
+				if vmFound = strings.Contains(string(out), "VMM"); vmFound {
+					vmEvidence = "sysctl:machdep.cpu.features"
+				}
+			}
+		}
+
+		if vmFound {
+			hw := macHardware{model: model, boardID: getDeviceTreeBoardID()}
+			if strings.HasPrefix(target, "VirtualMac") {
+				hw.model = target
+			}
+			vmDetail = classifyMacVM(hw, func(path string) bool {
+				_, err := os.Stat(path)
+				return err == nil
+			})
+		}
+	})
+	return vmFound, vmDetail
+}
+
+// getDeviceTreeBoardID returns the board-id of the IODeviceTree platform node, or "" if it
+// can't be read. Virtualization.framework guests report "VMA2MACOSAP".
+func getDeviceTreeBoardID() string {
+	out, err := runCommand("ioreg", "-a", "-rd1", "-p", "IODeviceTree", "-c", "IOPlatformExpertDevice")
+	if err != nil {
+		return ""
 	}
-	return "physical"
-}
\ No newline at end of file
+	id, _ := parsePlistString(out, "board-id")
+	return id
+}
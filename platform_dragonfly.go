@@ -0,0 +1,23 @@
+//go:build dragonfly
+
+package machineid
+
+import "strings"
+
+func detectEnvironment() envMatch {
+	// The kernel reports the detected hypervisor in kern.vm_guest
+	// ("none" on bare metal, otherwise e.g. "vmware", "kvm", "generic").
+	guest, err := sysctlString("kern.vm_guest")
+	if err == nil {
+		if g := strings.ToLower(strings.TrimSpace(guest)); g != "" && g != "none" {
+			return envMatch{"vm", "sysctl:kern.vm_guest=" + g, confidenceSignature}
+		}
+	}
+
+	return envMatch{"physical", "", confidenceDefault}
+}
+
+// detectEnvironmentDetail returns no extra detail on this platform.
+func detectEnvironmentDetail() (string, string) {
+	return "", ""
+}
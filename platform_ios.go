@@ -0,0 +1,13 @@
+//go:build ios
+
+package machineid
+
+func detectEnvironment() envMatch {
+	// Apps are always sandboxed on iOS; the simulator also reports "ios".
+	return envMatch{"ios", "goos:ios", confidencePlatform}
+}
+
+// detectEnvironmentDetail returns no extra detail on this platform.
+func detectEnvironmentDetail() (string, string) {
+	return "", ""
+}
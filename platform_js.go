@@ -0,0 +1,13 @@
+//go:build js
+
+package machineid
+
+func detectEnvironment() envMatch {
+	// We can't tell the hardware apart from inside the browser sandbox.
+	return envMatch{"browser", "goos:js", confidencePlatform}
+}
+
+// detectEnvironmentDetail returns no extra detail on this platform.
+func detectEnvironmentDetail() (string, string) {
+	return "", ""
+}
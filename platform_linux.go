@@ -10,26 +10,94 @@ import (
 var osReadFile = os.ReadFile
 var osStat = os.Stat
 
-func getEnvironmentType() string {
+func detectEnvironment() envMatch {
+	// 0. Check for Serverless Platforms
+	// Functions run in containers or microVMs (Lambda on Firecracker, Cloud Run in gVisor),
+	// but their IDs are per sandbox, so the platform is what callers need to know.
+	if platform, evidence := serverlessPlatform(); platform != "" {
+		return envMatch{"serverless", evidence, confidenceMarker}
+	}
+
 	// 1. Check for Containerization
+
+	// Check for Kubernetes first: pods run on any of the runtimes below,
+	// and the orchestrator is what scopes the workload.
+	if evidence := kubernetesEvidence(); evidence != "" {
+		return envMatch{"kubernetes", evidence, confidenceMarker}
+	}
 	
 	// Check for the presence of /.dockerenv.
 	// This file is created by the Docker daemon inside the container root.
 	if _, err := osStat("/.dockerenv"); err == nil {
-		return "docker"
+		return envMatch{"docker", "file:/.dockerenv", confidenceMarker}
 	}
 	
+	// Check for the Podman marker.
+	// Podman (and Buildah) create /run/.containerenv in every container.
+	if _, err := osStat("/run/.containerenv"); err == nil {
+		return envMatch{"podman", "file:/run/.containerenv", confidenceMarker}
+	}
+
+	// Check the container manager variable.
+	// Container managers following the systemd container interface set "container=<manager>"
+	// in the environment of PID 1 and, for unprivileged readers, in /run/systemd/container.
+	if env, evidence := containerManager(); env != "" {
+		return envMatch{env, evidence, confidenceMarker}
+	}
+
 	// Check Control Groups (cgroups).
 	// Processes in containers are assigned to specific cgroups. 
 	// The path often contains "docker" or "kubepods" (Kubernetes).
 	if cgroup, err := osReadFile("/proc/1/cgroup"); err == nil {
 		cgroupData := string(cgroup)
-		if strings.Contains(cgroupData, "docker") || strings.Contains(cgroupData, "kubepods") {
-			return "container"
+		if strings.Contains(cgroupData, "kubepods") {
+			return envMatch{"kubernetes", "cgroup:kubepods", confidenceHeuristic}
 		}
+		if strings.Contains(cgroupData, "docker") {
+			return envMatch{"container", "cgroup:docker", confidenceHeuristic}
+		}
+		// Runtimes without a marker file are recognized by their cgroup naming.
+		for _, rt := range []struct{ name, env string }{
+			{"crio", "crio"},
+			{"containerd", "containerd"},
+			{"garden", "garden"},
+			{"/lxc/", "lxc"},
+			{"lxc.payload", "lxc"},
+		} {
+			if strings.Contains(cgroupData, rt.name) {
+				return envMatch{rt.env, "cgroup:" + rt.name, confidenceHeuristic}
+			}
+		}
+	}
+
+	// Check cgroup v2 (unified hierarchy).
+	// With cgroup namespaces, containers only see "0::/" in /proc/1/cgroup, so the
+	// runtime is recognized by the mounts it sets up instead.
+	if isCgroupV2() {
+		if env, evidence := containerFromMountinfo(); env != "" {
+			return envMatch{env, evidence, confidenceHeuristic}
+		}
+	}
+
+	// Check for gVisor sandboxes without a runtime marker (the Sentry hides the host).
+	if inGVisor() {
+		return envMatch{"container", "proc:/proc/version=gvisor", confidenceSignature}
+	}
+
+	// Check for the Windows Subsystem for Linux.
+	// Checked after containers (Docker Desktop runs containers on the WSL2 kernel),
+	// but before the hypervisor checks since WSL2 is a lightweight Hyper-V VM.
+	if wsl, _, evidence := detectWSL(); wsl {
+		return envMatch{"wsl", evidence, confidenceSignature}
 	}
 
 	// 2. Check for Virtual Machines (Hypervisors)
+	// Hypervisors are recognized from sysfs, the DMI strings, the kernel command line and
+	// the CPUID hypervisor leaves (which, unlike DMI, need no privileges).
+	if vm, _, evidence := detectHypervisor(); vm {
+		return envMatch{"vm", evidence, confidenceSignature}
+	}
+
 	// We read the DMI (Desktop Management Interface) data exposed by the kernel in sysfs.
 	// Note: Reading /sys/class/dmi usually requires root or specific permissions. 
 	// If we can't read it (err != nil), we fail gracefully and assume "physical".
@@ -37,20 +105,192 @@ func getEnvironmentType() string {
 	// Check Product Name
 	if product, err := osReadFile("/sys/class/dmi/id/product_name"); err == nil {
 		s := strings.ToLower(string(product))
-		if strings.Contains(s, "virtual") || strings.Contains(s, "vmware") || strings.Contains(s, "qemu") || strings.Contains(s, "kvm") {
-			return "vm"
+		if strings.Contains(s, "virtual") {
+			return envMatch{"vm", "dmi:product_name", confidenceHeuristic}
 		}
 	}
-	
-	// Check System Vendor
-	if vendor, err := osReadFile("/sys/class/dmi/id/sys_vendor"); err == nil {
-		s := strings.ToLower(string(vendor))
-		// QEMU/KVM often puts identifiers in the vendor field.
-		if strings.Contains(s, "qemu") || strings.Contains(s, "kvm") {
-			return "vm"
+
+	// Default assumption: Physical hardware
+	return envMatch{"physical", "", confidenceDefault}
+}
+
+// serverlessPlatform returns the serverless platform we run on (see classifyServerless).
+func serverlessPlatform() (platform, evidence string) {
+	return classifyServerless(os.Getenv, func(path string) bool {
+		_, err := osStat(path)
+		return err == nil
+	})
+}
+
+// isCgroupV2 reports whether the unified cgroup v2 hierarchy is mounted.
+func isCgroupV2() bool {
+	_, err := osStat("/sys/fs/cgroup/cgroup.controllers")
+	return err == nil
+}
+
+// containerFromMountinfo recognizes a container from /proc/self/mountinfo: the runtimes
+// bind-mount files (hostname, resolv.conf...) from their state directories, and run the
+// container on an overlay root filesystem. It returns the environment type and the
+// mount that matched, or "" if none did.
+func containerFromMountinfo() (env, evidence string) {
+	data, err := osReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return "", ""
+	}
+
+	// Format: id parent major:minor root mountpoint options [optional...] - fstype source super
+	// Only the root (the mounted path within its filesystem) is matched: on the host, the
+	// runtime state directories show up as mount points (e.g., a container's shm), not roots.
+	overlayRoot := false
+	for _, line := range strings.Split(string(data), "\n") {
+		pre, post, ok := strings.Cut(line, " - ")
+		fields, fs := strings.Fields(pre), strings.Fields(post)
+		if !ok || len(fields) < 5 || len(fs) == 0 {
+			continue
+		}
+
+		for _, rt := range []struct{ dir, env string }{
+			{"/kubelet/pods/", "kubernetes"},
+			{"/containers/storage/", "podman"},
+			{"/docker/containers/", "container"},
+			{"/containerd/", "containerd"},
+		} {
+			if strings.Contains(fields[3], rt.dir) {
+				return rt.env, "mountinfo:" + rt.dir
+			}
+		}
+		if fields[4] == "/" && fs[0] == "overlay" {
+			overlayRoot = true
 		}
 	}
 
-	// Default assumption: Physical hardware
-	return "physical"
-}
\ No newline at end of file
+	// An overlay root alone is also used by live and immutable distributions, so it only
+	// counts in a private cgroup namespace (PID 1 at the root of the hierarchy).
+	if !overlayRoot {
+		return "", ""
+	}
+	cgroup, err := osReadFile("/proc/1/cgroup")
+	if err != nil || strings.TrimSpace(string(cgroup)) != "0::/" {
+		return "", ""
+	}
+	return "container", "mountinfo:overlay-root"
+}
+
+// containerManager maps the systemd container interface "container" variable to an
+// environment type, or returns "" if it is unset or unknown. The evidence names where the
+// variable was read.
+func containerManager() (env, evidence string) {
+	var manager string
+	if data, err := osReadFile("/run/systemd/container"); err == nil {
+		manager, evidence = strings.TrimSpace(string(data)), "file:/run/systemd/container"
+	} else if environ, err := osReadFile("/proc/1/environ"); err == nil {
+		for _, kv := range strings.Split(string(environ), "\x00") {
+			if v, ok := strings.CutPrefix(kv, "container="); ok {
+				manager, evidence = v, "proc:/proc/1/environ"
+				break
+			}
+		}
+	}
+	evidence += "=" + manager
+
+	switch manager {
+	case "lxc", "lxc-libvirt":
+		return "lxc", evidence
+	case "systemd-nspawn":
+		return "nspawn", evidence
+	case "podman":
+		return "podman", evidence
+	case "docker":
+		return "docker", evidence
+	case "", "wsl":
+		// systemd also reports WSL through this interface; detectWSL classifies it.
+		return "", ""
+	default:
+		return "container", evidence
+	}
+}
+
+// detectEnvironmentDetail returns the platform of serverless functions (e.g., "serverless/aws-lambda"),
+// the WSL version ("wsl/1" or "wsl/2") under WSL, "container/gvisor" in gVisor sandboxes, and
+// the hypervisor in VMs (e.g., "vm/xen"), with the check that found it.
+func detectEnvironmentDetail() (string, string) {
+	if platform, evidence := serverlessPlatform(); platform != "" {
+		return "serverless/" + platform, evidence
+	}
+	if wsl, version, evidence := detectWSL(); wsl {
+		return "wsl/" + version, evidence
+	}
+	if inGVisor() {
+		return "container/gvisor", "proc:/proc/version=gvisor"
+	}
+	if vm, name, evidence := detectHypervisor(); vm && name != "" {
+		return "vm/" + name, evidence
+	}
+	return "", ""
+}
+
+// detectHypervisor reports whether the kernel runs under a hypervisor, its name (e.g., "xen",
+// "hyperv", "firecracker") if it is recognized, and the check that found it. Matching the DMI
+// product name alone misses Xen PV guests and microVMs, which have no DMI tables, and fails
+// for non-root processes on distributions restricting /sys/class/dmi.
+func detectHypervisor() (bool, string, string) {
+	// Xen reports itself in sysfs, also to PV guests. The control domain (dom0) runs
+	// on the hardware and is not a guest.
+	if t, err := osReadFile("/sys/hypervisor/type"); err == nil && strings.TrimSpace(string(t)) == "xen" {
+		if caps, err := osReadFile("/proc/xen/capabilities"); err == nil && strings.Contains(string(caps), "control_d") {
+			return false, "", ""
+		}
+		return true, "xen", "sysfs:/sys/hypervisor/type=xen"
+	}
+
+	vendor, _ := osReadFile("/sys/class/dmi/id/sys_vendor")
+	product, _ := osReadFile("/sys/class/dmi/id/product_name")
+	bios, _ := osReadFile("/sys/class/dmi/id/bios_vendor")
+	if name := hypervisorFromDMI(string(vendor), string(product), string(bios)); name != "" {
+		return true, name, "dmi:" + name
+	}
+
+	// Firecracker boots the kernel without firmware: there are no DMI tables, and the
+	// virtio devices are declared on the kernel command line.
+	if cmdline, err := osReadFile("/proc/cmdline"); err == nil && strings.Contains(string(cmdline), "virtio_mmio.device=") {
+		return true, "firecracker", "cmdline:virtio_mmio.device"
+	}
+
+	// The CPUID hypervisor bit is readable by any process (x86 only).
+	if present, vendor := hypervisorCPUID(); present {
+		return true, hypervisorFromCPUID(vendor), "cpuid:" + vendor
+	}
+	return false, "", ""
+}
+
+// gVisorKernelVersion is the fixed kernel build string gVisor's Sentry reports in /proc/version.
+const gVisorKernelVersion = "#1 SMP Sun Jan 10 15:06:54 PST 2016"
+
+// inGVisor reports whether we run in a gVisor (runsc) sandbox. The Sentry implements its
+// own restricted /proc, with a made-up kernel version.
+func inGVisor() bool {
+	version, err := osReadFile("/proc/version")
+	return err == nil && strings.Contains(string(version), gVisorKernelVersion)
+}
+
+// detectWSL reports whether we run under WSL, its version ("1" or "2"), and the check that
+// found it. Both kernels report "Microsoft" in /proc/version; the WSL2 kernel is a real Linux
+// build named "...-microsoft-standard[-WSL2]". The WSLInterop binfmt entry exists on
+// both unless interop was disabled.
+func detectWSL() (bool, string, string) {
+	version, err := osReadFile("/proc/version")
+	if err == nil {
+		v := strings.ToLower(string(version))
+		if strings.Contains(v, "microsoft") {
+			if strings.Contains(v, "microsoft-standard") || strings.Contains(v, "wsl2") {
+				return true, "2", "proc:/proc/version=microsoft-standard"
+			}
+			return true, "1", "proc:/proc/version=microsoft"
+		}
+	}
+
+	if _, err := osStat("/proc/sys/fs/binfmt_misc/WSLInterop"); err == nil {
+		return true, "2", "file:/proc/sys/fs/binfmt_misc/WSLInterop"
+	}
+	return false, "", ""
+}
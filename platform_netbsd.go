@@ -0,0 +1,26 @@
+//go:build netbsd
+
+package machineid
+
+import "strings"
+
+func detectEnvironment() envMatch {
+	// Check the SMBIOS vendor and product names exposed via the machdep.dmi sysctl tree.
+	vendor, _ := sysctlString("machdep.dmi.system-vendor")
+	product, _ := sysctlString("machdep.dmi.system-product")
+
+	v := strings.ToLower(vendor)
+	p := strings.ToLower(product)
+
+	if strings.Contains(p, "virtual") || strings.Contains(p, "vmware") || strings.Contains(p, "kvm") ||
+		strings.Contains(v, "qemu") || strings.Contains(v, "bochs") || strings.Contains(v, "xen") {
+		return envMatch{"vm", "sysctl:machdep.dmi", confidenceHeuristic}
+	}
+
+	return envMatch{"physical", "", confidenceDefault}
+}
+
+// detectEnvironmentDetail returns no extra detail on this platform.
+func detectEnvironmentDetail() (string, string) {
+	return "", ""
+}
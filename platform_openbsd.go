@@ -0,0 +1,30 @@
+//go:build openbsd
+
+package machineid
+
+import "strings"
+
+func detectEnvironment() envMatch {
+	// Check the SMBIOS vendor and product names exposed via sysctl.
+	// Guests of OpenBSD's own hypervisor (vmm/vmd) report vendor "OpenBSD" and product "VMM".
+	vendor, _ := sysctlString("hw.vendor")
+	product, _ := sysctlString("hw.product")
+
+	v := strings.ToLower(vendor)
+	p := strings.ToLower(product)
+
+	if strings.Contains(v, "openbsd") && p == "vmm" {
+		return envMatch{"vm", "sysctl:hw.vendor=OpenBSD", confidenceSignature}
+	}
+	if strings.Contains(p, "virtual") || strings.Contains(p, "vmware") || strings.Contains(p, "kvm") ||
+		strings.Contains(v, "qemu") || strings.Contains(v, "bochs") || strings.Contains(v, "xen") {
+		return envMatch{"vm", "sysctl:hw.product", confidenceHeuristic}
+	}
+
+	return envMatch{"physical", "", confidenceDefault}
+}
+
+// detectEnvironmentDetail returns no extra detail on this platform.
+func detectEnvironmentDetail() (string, string) {
+	return "", ""
+}
@@ -1,7 +1,12 @@
-//go:build !linux && !windows && !darwin
+//go:build !linux && !windows && !darwin && !openbsd && !netbsd && !dragonfly && !js && !wasip1
 
 package machineid
 
-func getEnvironmentType() string {
-	return "unknown"
-}
\ No newline at end of file
+func detectEnvironment() envMatch {
+	return envMatch{env: "unknown"}
+}
+
+// detectEnvironmentDetail returns no extra detail on this platform.
+func detectEnvironmentDetail() (string, string) {
+	return "", ""
+}
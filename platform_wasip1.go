@@ -0,0 +1,13 @@
+//go:build wasip1
+
+package machineid
+
+func detectEnvironment() envMatch {
+	// The module can't inspect the host; it only knows it runs in a WASI runtime.
+	return envMatch{"wasi", "goos:wasip1", confidencePlatform}
+}
+
+// detectEnvironmentDetail returns no extra detail on this platform.
+func detectEnvironmentDetail() (string, string) {
+	return "", ""
+}
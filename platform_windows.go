@@ -3,26 +3,35 @@
 package machineid
 
 import (
+	"os"
 	"strings"
 
 	"golang.org/x/sys/windows/registry"
 )
 
-func getEnvironmentType() string {
-	// 1. Check for specific VM Registry Keys
-	// These keys are commonly present in guest environments.
-
-	// Microsoft Hyper-V
-	if checkKeyExists(`SOFTWARE\Microsoft\Virtual Machine\Guest\Parameters`) {
-		return "vm"
+func detectEnvironment() envMatch {
+	// Azure Functions on Windows plans: the platform, rather than the host, scopes the ID.
+	if platform, evidence := serverlessPlatform(); platform != "" {
+		return envMatch{"serverless", evidence, confidenceMarker}
 	}
-	// VMware
-	if checkKeyExists(`SOFTWARE\VMware, Inc.\VMware Tools`) {
-		return "vm"
+
+	// 0. Windows containers
+	// Checked first: Hyper-V isolated containers run in a utility VM and would
+	// otherwise match the VM checks below.
+	if evidence := windowsContainerEvidence(); evidence != "" {
+		return envMatch{"container", evidence, confidenceMarker}
 	}
-	// Oracle VirtualBox
-	if checkKeyExists(`SOFTWARE\Oracle\VirtualBox Guest Additions`) {
-		return "vm"
+
+	// 1. Check for specific VM Registry Keys
+	// These keys are commonly present in guest environments.
+	for _, key := range []string{
+		`SOFTWARE\Microsoft\Virtual Machine\Guest\Parameters`, // Microsoft Hyper-V
+		`SOFTWARE\VMware, Inc.\VMware Tools`,                  // VMware
+		`SOFTWARE\Oracle\VirtualBox Guest Additions`,          // Oracle VirtualBox
+	} {
+		if checkKeyExists(key) {
+			return envMatch{"vm", `registry:HKLM\` + key, confidenceSignature}
+		}
 	}
 
 	// 2. Check BIOS Information via Registry
@@ -49,16 +58,48 @@ func getEnvironmentType() string {
 
 		// Check for generic VM terms in model/manufacturer
 		if strings.Contains(m, "virtual") || strings.Contains(m, "vmware") || strings.Contains(m, "kvm") {
-			return "vm"
+			return envMatch{"vm", "registry:SystemProductName", confidenceHeuristic}
 		}
 
 		// Windows Containers / Hyper-V specific checks
 		if strings.Contains(man, "microsoft corporation") && strings.Contains(m, "virtual") {
-			return "vm"
+			return envMatch{"vm", "registry:SystemManufacturer", confidenceHeuristic}
 		}
 	}
 
-	return "physical"
+	// 3. CPUID hypervisor bit
+	// The registry keys above depend on guest tools being installed; CPUID doesn't.
+	if present, vendor := hypervisorCPUID(); present {
+		return envMatch{"vm", "cpuid:" + vendor, confidenceSignature}
+	}
+
+	return envMatch{"physical", "", confidenceDefault}
+}
+
+// serverlessPlatform returns the serverless platform we run on (see classifyServerless).
+func serverlessPlatform() (platform, evidence string) {
+	return classifyServerless(os.Getenv, func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	})
+}
+
+// windowsContainerEvidence returns the registry entry showing that we run in a Windows Server
+// or Hyper-V isolated container, or "". Container images set the ContainerType value, and run
+// the Container Execution Agent (CExecSvc), which only exists inside containers.
+func windowsContainerEvidence() string {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Control`, registry.QUERY_VALUE)
+	if err == nil {
+		_, _, err = k.GetIntegerValue("ContainerType")
+		k.Close()
+		if err == nil {
+			return `registry:HKLM\SYSTEM\CurrentControlSet\Control\ContainerType`
+		}
+	}
+	if checkKeyExists(`SYSTEM\CurrentControlSet\Services\cexecsvc`) {
+		return `registry:HKLM\SYSTEM\CurrentControlSet\Services\cexecsvc`
+	}
+	return ""
 }
 
 // checkKeyExists returns true if the specified registry key exists under HKLM.
@@ -69,4 +110,19 @@ func checkKeyExists(subKey string) bool {
 	}
 	k.Close()
 	return true
-}
\ No newline at end of file
+}
+
+// detectEnvironmentDetail returns the platform of serverless functions (e.g.,
+// "serverless/azure-functions"), or the hypervisor named by CPUID in VMs (e.g., "vm/hyperv"),
+// with the check that found it.
+func detectEnvironmentDetail() (string, string) {
+	if platform, evidence := serverlessPlatform(); platform != "" {
+		return "serverless/" + platform, evidence
+	}
+	if present, vendor := hypervisorCPUID(); present {
+		if name := hypervisorFromCPUID(vendor); name != "" {
+			return "vm/" + name, "cpuid:" + vendor
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,24 @@
+package machineid
+
+import "strings"
+
+// SameMachine reports whether two IDs (or two ProtectedIDs for the same appID) created with the
+// same options designate the same machine. Only the hash parts are compared, so IDs that differ
+// only by their environment prefix (e.g., "physical:ab12" and "vm:ab12") are equal.
+func SameMachine(a, b string) bool {
+	return stripPrefix(a) == stripPrefix(b)
+}
+
+// stripPrefix returns the hash part of an ID. IDs created WithoutPrefix are returned as-is.
+func stripPrefix(id string) string {
+	if i := strings.LastIndexByte(id, ':'); i >= 0 {
+		return id[i+1:]
+	}
+	return id
+}
+
+// warnPrefixDrift flags an environment change for an unchanged raw ID.
+func warnPrefixDrift(oldPrefix, newPrefix string) {
+	logWarn("machineid: environment prefix changed while the raw ID stayed the same; prefixed IDs changed (use WithoutPrefix or SameMachine for lookups)",
+		"old_prefix", oldPrefix, "new_prefix", newPrefix)
+}
@@ -0,0 +1,52 @@
+package machineid
+
+// The presets below bundle the output format (encoding, length) and the acceptance policy
+// (fallbacks, minimum entropy) for the three dominant use cases. They don't change which sources
+// are consulted or in which order (see SetSourcePriority), and don't persist anything (see
+// WithGeneratedFallback). They can be combined with further options; later options override
+// earlier ones, e.g. ID(PresetTelemetry(), WithLength(16)).
+
+// PresetLicensing targets node-locked licensing: the ID must be hard to spoof and must not
+// drift. It uses the full-length hex hash and rejects IDs derived from MAC addresses, which
+// change with NIC swaps and are trivially spoofable, or from the 32-bit gethostid(2) value,
+// which is often unset or copied with images (ErrFallbackRejected), as well as raw IDs below
+// DefaultMinEntropy (ErrWeakIdentity).
+func PresetLicensing() Option {
+	return bundle(
+		WithEncoding(Hex),
+		WithLength(0),
+		WithMinEntropy(DefaultMinEntropy),
+		func(o *options) { o.rejectFallback = true },
+	)
+}
+
+// PresetTelemetry targets usage analytics: compact, anonymized IDs that are always available.
+// It uses 22 base64url characters (132 bits, ample for any fleet size), accepts the MAC
+// fallback, and hashes the asset tag so reports never carry the raw inventory value.
+// Combine it with ProtectedID to avoid cross-app correlation.
+func PresetTelemetry() Option {
+	return bundle(
+		WithEncoding(Base64URL),
+		WithLength(22),
+		WithHashedAssetTag(),
+	)
+}
+
+// PresetClustering targets node identity in clusters (leader election, sharding, membership):
+// IDs must be unique per node and easy for operators to compare. It uses the full Crockford
+// base32 hash (no ambiguous letters) and accepts the MAC fallback so every node always gets an ID.
+func PresetClustering() Option {
+	return bundle(
+		WithEncoding(Crockford),
+		WithLength(0),
+	)
+}
+
+// bundle combines several options into one.
+func bundle(opts ...Option) Option {
+	return func(o *options) {
+		for _, opt := range opts {
+			opt(o)
+		}
+	}
+}
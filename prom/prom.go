@@ -0,0 +1,71 @@
+// Package prom exposes the machine identity as Prometheus metrics, so fleet dashboards can join
+// metrics by machine identity:
+//
+//	machineid_info{env="vm",env_detail="vm/kvm",source="machine-id",id_hash="..."} 1
+//	machineid_resolutions_total{source="machine-id"} 3
+//	machineid_resolution_errors_total 0
+//
+// The package is separate from machineid so that only applications that import it depend on
+// the Prometheus client library.
+package prom
+
+import (
+	"context"
+
+	"github.com/banditmoscow1337/machineid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// infoFunc resolves the identity. It is a variable so tests can substitute a fixed report.
+var infoFunc = machineid.Info
+
+// Collector is a prometheus.Collector reporting the machine identity. The identity is resolved
+// on every scrape, served from the machineid cache.
+type Collector struct {
+	opts        []machineid.Option
+	info        *prometheus.Desc
+	resolutions *prometheus.CounterVec
+	errors      prometheus.Counter
+}
+
+// NewCollector returns a Collector whose id_hash label is the ID returned by
+// machineid.ID(opts...), without the environment prefix (reported in the env label instead).
+// Pass machineid.Short() for compact labels.
+func NewCollector(opts ...machineid.Option) *Collector {
+	return &Collector{
+		opts: append(append([]machineid.Option(nil), opts...), machineid.WithoutPrefix()),
+		info: prometheus.NewDesc("machineid_info",
+			"Machine identity, always 1.",
+			[]string{"env", "env_detail", "source", "id_hash"}, nil),
+		resolutions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "machineid_resolutions_total",
+			Help: "Identity resolutions by the source the ID was derived from (e.g., mac for the fallback).",
+		}, []string{"source"}),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "machineid_resolution_errors_total",
+			Help: "Identity resolutions that failed.",
+		}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.info
+	c.resolutions.Describe(ch)
+	c.errors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector. If the identity can't be resolved, machineid_info is
+// omitted and the error counter is incremented.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	info, err := infoFunc(context.Background(), c.opts...)
+	if err != nil {
+		c.errors.Inc()
+	} else {
+		c.resolutions.WithLabelValues(info.Source).Inc()
+		ch <- prometheus.MustNewConstMetric(c.info, prometheus.GaugeValue, 1,
+			info.Environment, info.EnvironmentDetail, info.Source, info.ID)
+	}
+	c.resolutions.Collect(ch)
+	c.errors.Collect(ch)
+}
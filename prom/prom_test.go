@@ -0,0 +1,57 @@
+package prom
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/banditmoscow1337/machineid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	defer func() { infoFunc = machineid.Info }()
+
+	var fail bool
+	infoFunc = func(ctx context.Context, opts ...machineid.Option) (*machineid.MachineInfo, error) {
+		if fail {
+			return nil, errors.New("no source")
+		}
+		return &machineid.MachineInfo{ID: "abc123", Environment: "vm", EnvironmentDetail: "vm/kvm", Source: machineid.ComponentMAC}, nil
+	}
+
+	c := NewCollector(machineid.Short())
+	want := `
+# HELP machineid_info Machine identity, always 1.
+# TYPE machineid_info gauge
+machineid_info{env="vm",env_detail="vm/kvm",id_hash="abc123",source="mac"} 1
+# HELP machineid_resolution_errors_total Identity resolutions that failed.
+# TYPE machineid_resolution_errors_total counter
+machineid_resolution_errors_total 0
+# HELP machineid_resolutions_total Identity resolutions by the source the ID was derived from (e.g., mac for the fallback).
+# TYPE machineid_resolutions_total counter
+machineid_resolutions_total{source="mac"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	// A failure drops the info metric and counts the error.
+	fail = true
+	want = `
+# HELP machineid_resolution_errors_total Identity resolutions that failed.
+# TYPE machineid_resolution_errors_total counter
+machineid_resolution_errors_total 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), "machineid_info", "machineid_resolution_errors_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollector_Live(t *testing.T) {
+	c := NewCollector()
+	if n := testutil.CollectAndCount(c, "machineid_info"); n > 1 {
+		t.Errorf("expected at most one info metric, got %d", n)
+	}
+}
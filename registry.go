@@ -0,0 +1,170 @@
+package machineid
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrSourceDisabled is returned by sources turned off with DisableSource. It wraps
+// os.ErrNotExist: a disabled source is treated like a missing one, so the fallback chain
+// moves on to the next source.
+var ErrSourceDisabled = fmt.Errorf("machineid: source disabled: %w", os.ErrNotExist)
+
+// SourceRole describes how a source takes part in the identity.
+type SourceRole string
+
+const (
+	// SourceRolePrimary is the platform default source of the raw ID (ComponentMachineID).
+	SourceRolePrimary SourceRole = "primary"
+	// SourceRoleOptional sources are only used when named in SetSourcePriority.
+	SourceRoleOptional SourceRole = "optional"
+	// SourceRoleFallback sources are used when the configured sources are missing.
+	SourceRoleFallback SourceRole = "fallback"
+	// SourceRoleFingerprint sources only feed the experimental fingerprint (machineid/x).
+	SourceRoleFingerprint SourceRole = "fingerprint"
+)
+
+// SourceDescriptor describes a source available on the current platform.
+type SourceDescriptor struct {
+	Name    string
+	Role    SourceRole
+	Enabled bool
+}
+
+// disabledSources holds the names turned off with DisableSource.
+var disabledSources = struct {
+	sync.RWMutex
+	names map[string]bool
+}{names: map[string]bool{}}
+
+// SourceStatus is the outcome of a source run by ProbeSources.
+type SourceStatus struct {
+	SourceDescriptor
+	// Hash is the SHA256 (hex) of the raw value, empty if the source failed.
+	Hash string
+	// Err is the reason the source failed, nil if it produced a value.
+	Err error
+}
+
+// Sources lists the sources available on the current platform: the primary source, the
+// optional ones (see SetSourcePriority), the fallbacks, then the fingerprint-only components.
+// It doesn't run them; see ProbeSources.
+func Sources() []SourceDescriptor {
+	out := []SourceDescriptor{{Name: ComponentMachineID, Role: SourceRolePrimary}}
+
+	optional := make([]string, 0, len(optionalSources))
+	for name := range optionalSources {
+		optional = append(optional, name)
+	}
+	sort.Strings(optional)
+	for _, name := range optional {
+		out = append(out, SourceDescriptor{Name: name, Role: SourceRoleOptional})
+	}
+
+	out = append(out,
+		SourceDescriptor{Name: ComponentMAC, Role: SourceRoleFallback},
+		SourceDescriptor{Name: SourceHostID, Role: SourceRoleFallback},
+	)
+
+	for _, src := range fingerprintSources() {
+		if !slices.ContainsFunc(out, func(d SourceDescriptor) bool { return d.Name == src.name }) {
+			out = append(out, SourceDescriptor{Name: src.name, Role: SourceRoleFingerprint})
+		}
+	}
+
+	for i := range out {
+		out[i].Enabled = sourceEnabled(out[i].Name)
+	}
+	return out
+}
+
+// ProbeSources runs every source listed by Sources and reports whether it succeeded and the
+// hash of the value it produced. It is meant for support diagnostics, e.g. to find out which
+// source two machines reporting the same ID share. Values are served from the cache like for
+// ID(), so call Refresh first to re-read them; raw values are never reported.
+func ProbeSources() []SourceStatus {
+	getters := map[string]func() (string, error){
+		ComponentMachineID: getMachineIDFunc,
+		ComponentMAC:       getHardwareId,
+		SourceHostID:       getLegacyHostIDFunc,
+	}
+	if getters[SourceHostID] == nil {
+		getters[SourceHostID] = func() (string, error) {
+			return "", fmt.Errorf("no hostid on %s: %w", runtime.GOOS, os.ErrNotExist)
+		}
+	}
+	for _, src := range fingerprintSources() {
+		if _, ok := getters[src.name]; !ok {
+			getters[src.name] = src.get
+		}
+	}
+	for name, get := range optionalSources {
+		getters[name] = get
+	}
+
+	descriptors := Sources()
+	out := make([]SourceStatus, 0, len(descriptors))
+	for _, d := range descriptors {
+		s := SourceStatus{SourceDescriptor: d}
+		raw, err := cachedSourceValue(d.Name, getters[d.Name])
+		if err == nil && strings.TrimSpace(raw) == "" {
+			err = fmt.Errorf("empty value: %w", os.ErrNotExist)
+		}
+		if err == nil {
+			s.Hash, err = protect(raw)
+		}
+		s.Err = err
+		out = append(out, s)
+	}
+	return out
+}
+
+// EnableSource turns a source disabled with DisableSource back on.
+func EnableSource(name string) error {
+	return setSourceEnabled(name, true)
+}
+
+// DisableSource turns off a misbehaving source (e.g., one that hangs or returns garbage on
+// some hardware) without recompiling. A disabled source fails with ErrSourceDisabled, so the
+// chain moves on as if it were missing. Disabling a source the ID was derived from changes
+// the ID: the cached ID is discarded.
+func DisableSource(name string) error {
+	return setSourceEnabled(name, false)
+}
+
+func setSourceEnabled(name string, enabled bool) error {
+	if !slices.ContainsFunc(Sources(), func(d SourceDescriptor) bool { return d.Name == name }) {
+		return fmt.Errorf("machineid: source %q is not available on this platform", name)
+	}
+
+	disabledSources.Lock()
+	if enabled {
+		delete(disabledSources.names, name)
+	} else {
+		disabledSources.names[name] = true
+	}
+	disabledSources.Unlock()
+
+	invalidateSources(name)
+	mu.Lock()
+	initialized = false
+	mu.Unlock()
+	return nil
+}
+
+// sourceEnabled reports whether name was not turned off with DisableSource.
+func sourceEnabled(name string) bool {
+	disabledSources.RLock()
+	defer disabledSources.RUnlock()
+	return !disabledSources.names[name]
+}
+
+// disabledError returns the error reported by the disabled source name.
+func disabledError(name string) error {
+	return &SourceError{Source: name, Err: ErrSourceDisabled}
+}
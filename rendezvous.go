@@ -0,0 +1,131 @@
+package machineid
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// rendezvousTTL bounds how long an entry of another container counts as alive.
+// Entries are refreshed on every EnableContainerScoping call.
+var rendezvousTTL = 24 * time.Hour
+
+// containerKeyFunc identifies the current container. It is a variable so tests can mock it.
+var containerKeyFunc = containerKey
+
+// containerScope holds the container key the raw ID is scoped to once EnableContainerScoping
+// detected a collision (nil before). options.load applies it to every resolution, after the
+// fallback policy; the cache keeps the host's raw ID.
+var containerScope atomic.Pointer[string]
+
+// EnableContainerScoping is an opt-in self-test for containers sharing one host.
+//
+// Containers usually see the host's machine-id (bind-mounted or baked into the image), so several
+// containers on one host report the same ID, which silently double-counts licenses or telemetry.
+// Each container registers itself in dir, a directory shared by all containers on the host
+// (e.g., a host path bind-mounted at the same location). If another live container already
+// reported the same ID, this process switches to a container-scoped derivation of the ID and
+// EnableContainerScoping returns true. The switch lasts for the lifetime of the process, across
+// Refresh and every later resolution, and later calls keep returning true.
+//
+// Only the containers registering after another one get scoped: the first container of a host
+// keeps the host's ID. The scoped ID is derived from the container ID, so it changes whenever
+// the container is recreated (e.g., on every deployment); persist it accordingly.
+//
+// A shared directory is used rather than an abstract unix socket, because abstract sockets are
+// scoped to a network namespace and most containers don't share one. Call it at startup, before
+// persisting or reporting the ID; calling it again refreshes the registration.
+func EnableContainerScoping(dir string) (bool, error) {
+	snap, err := load()
+	if err != nil {
+		return false, err
+	}
+
+	key := containerKeyFunc()
+	if key == "" {
+		return false, errors.New("cannot identify the current container")
+	}
+	// The cache holds the host's ID, never the scoped one (see options.load).
+	scoped := containerScope.Load() != nil
+
+	idHash, err := protect(snap.prefix + ":" + snap.rawID)
+	if err != nil {
+		return false, err
+	}
+	keyHash, err := protect(key)
+	if err != nil {
+		return false, err
+	}
+
+	// Entry names only contain hashes: the shared directory never reveals the raw ID.
+	prefix := idHash[:16] + "."
+	own := filepath.Join(dir, prefix+keyHash[:16])
+	if err := os.WriteFile(own, nil, 0o644); err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if err := os.Chtimes(own, now, now); err != nil {
+		return false, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+
+	collision := false
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), prefix) || filepath.Join(dir, e.Name()) == own {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil || now.Sub(fi.ModTime()) > rendezvousTTL {
+			continue
+		}
+		collision = true
+		break
+	}
+
+	if !collision || scoped {
+		return scoped, nil
+	}
+
+	containerScope.Store(&key)
+	return true, nil
+}
+
+// containerSuffix is appended to the raw ID to scope it to the container identified by key.
+func containerSuffix(key string) string {
+	return ":container:" + key
+}
+
+// scopeToContainer applies the scope recorded by EnableContainerScoping to a resolution.
+// It must run after every step replacing the raw ID.
+func scopeToContainer(snap snapshot) snapshot {
+	if key := containerScope.Load(); key != nil {
+		snap.rawID += containerSuffix(*key)
+	}
+	return snap
+}
+
+// containerIDPattern matches the 64-hex container IDs used by Docker, containerd and CRI-O.
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// containerKey returns the container ID from the cgroup/mount tables, or the hostname
+// (which container runtimes set to the short container ID by default).
+func containerKey() string {
+	for _, path := range []string{"/proc/self/cgroup", "/proc/self/mountinfo"} {
+		if b, err := os.ReadFile(path); err == nil {
+			if id := containerIDPattern.Find(b); id != nil {
+				return string(id)
+			}
+		}
+	}
+
+	host, _ := os.Hostname()
+	return host
+}
@@ -0,0 +1,123 @@
+package machineid
+
+import (
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Resolver resolves the ID independently of the package-level state. It has its own cache, its
+// own sources and default options, so libraries embedding machineid don't fight over the global
+// configuration (SetSourcePriority, DisableSource, Refresh), and tests can substitute sources
+// without touching package variables.
+//
+// The zero value uses the platform sources. Set the fields before the first call and don't
+// change them afterwards. A Resolver is safe for concurrent use.
+type Resolver struct {
+	// MachineID returns the raw machine identifier. Nil uses the platform default source.
+	// An error wrapping os.ErrNotExist, or an empty value, selects the MAC address fallback.
+	MachineID func() (string, error)
+	// Environment returns the environment type used as prefix (e.g., "vm"). Nil uses the
+	// platform detection.
+	Environment func() string
+	// Interfaces lists the network interfaces of the MAC address fallback. Nil uses the
+	// interfaces of the machine, then the gethostid(2) value if none is usable.
+	Interfaces func() ([]net.Interface, error)
+	// Options are applied before the options given to each call.
+	Options []Option
+
+	mu         sync.Mutex
+	snap       *snapshot
+	resolvedAt time.Time
+}
+
+// ID is the Resolver counterpart of the package-level ID.
+func (r *Resolver) ID(opts ...Option) (string, error) {
+	return idFrom(r.load, r.options(opts))
+}
+
+// ProtectedID is the Resolver counterpart of the package-level ProtectedID. Like it, every appID
+// is recorded (see RegisteredAppIDs).
+func (r *Resolver) ProtectedID(appID string, opts ...Option) (string, error) {
+	trackAppID(appID, callerSite(1))
+	return protectedIDFrom(r.load, appID, r.options(opts))
+}
+
+// Refresh discards the cached resolution, so the next call re-runs every source.
+func (r *Resolver) Refresh() {
+	r.mu.Lock()
+	r.snap = nil
+	r.mu.Unlock()
+}
+
+// options prepends the default options of the Resolver.
+func (r *Resolver) options(opts []Option) []Option {
+	if len(r.Options) == 0 {
+		return opts
+	}
+	return append(slices.Clip(r.Options), opts...)
+}
+
+// load is the Resolver counterpart of options.load.
+func (r *Resolver) load(o options) (snapshot, error) {
+	snap, err := r.cached(o)
+	fallback := platformFallbackSources()
+	if r.Interfaces != nil {
+		fallback.interfaces = r.Interfaces
+	}
+	if r.MachineID != nil {
+		fallback.machineID, fallback.machineIDV2 = nil, nil
+	}
+	return o.applyFallbackPolicy(fallback, snap, err)
+}
+
+// cached returns the cached resolution, resolving it on first use or when WithCacheTTL or
+// WithNoCache ask for it. Like the package-level cache, failures are not cached, so the next
+// call retries.
+func (r *Resolver) cached(o options) (snapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.snap != nil && !o.noCache && (o.cacheTTL <= 0 || time.Since(r.resolvedAt) < o.cacheTTL) {
+		return *r.snap, nil
+	}
+
+	snap, err := r.resolve()
+	if err != nil {
+		return snapshot{}, err
+	}
+	r.snap, r.resolvedAt = &snap, time.Now()
+	return snap, nil
+}
+
+func (r *Resolver) resolve() (snapshot, error) {
+	res := resolution{
+		environment: getEnvTypeFunc,
+		sources: func() (string, string, error) {
+			id, err := getMachineIDFunc()
+			return id, machineIDSource(), err
+		},
+		mac:    getHardwareId,
+		hostID: getLegacyHostIDFunc,
+	}
+	if r.Environment != nil {
+		res.environment = r.Environment
+	}
+	if r.MachineID != nil {
+		res.sources = func() (string, string, error) {
+			id, err := r.MachineID()
+			return id, ComponentMachineID, err
+		}
+	}
+	if r.Interfaces != nil {
+		res.mac = func() (string, error) {
+			interfaces, err := r.Interfaces()
+			if err != nil {
+				return "", err
+			}
+			return hardwareIDFrom(interfaces)
+		}
+		res.hostID = nil
+	}
+	return res.run()
+}
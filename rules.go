@@ -0,0 +1,140 @@
+package machineid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// RulesVersion is the latest version of the source selection and detection rules. A version
+// that changes the value a source prefers on some machines (e.g., a new preferred source or a
+// fixed parser) only applies with WithRulesVersion, so IDs don't change when the library is
+// upgraded.
+//
+// Version 2 reads /var/lib/dbus/machine-id on Linux machines without /etc/machine-id, and
+// prefers the registry MachineGuid over the SMBIOS UUID and the disk serial on Windows. It
+// derives the MAC fallback from the permanent (burned-in) addresses on Linux and Windows,
+// instead of the current ones, and ignores bridges, bonds, overlay VPNs, interfaces without a
+// backing device and, next to other interfaces, the adapters of virtualization software.
+const RulesVersion = 2
+
+// currentRules is the rules version recorded by MigrationNeeded. It is a variable so tests can
+// simulate later releases.
+var currentRules = RulesVersion
+
+// WithRulesVersion selects the rules version (see RulesVersion). It defaults to 1, the original
+// rules, so IDs don't change when the library is upgraded. Under DerivationTupleHash, the
+// version is also mixed into the domain-separation tag of ProtectedID(), never into the hashed
+// material; DerivationSHA256 has no tag. After MigrationNeeded reported a change, re-bind with
+// WithRulesVersion(RulesVersion) so the new IDs can't be confused with the old ones.
+func WithRulesVersion(v int) Option {
+	return func(o *options) {
+		o.rulesVersion = v
+	}
+}
+
+// protectedDomain returns the domain-separation tag of ProtectedID for the configured rules
+// version. Version 1 keeps the original tag.
+func (o options) protectedDomain() string {
+	if o.rulesVersion <= 1 {
+		return domainProtectedID
+	}
+	return domainProtectedID + " v" + strconv.Itoa(o.rulesVersion)
+}
+
+// migrationState is the persisted form: the rules version and source the machine was bound
+// with, and a hash of the raw ID (the raw ID itself is not stored).
+type migrationState struct {
+	Rules  int    `json:"rules"`
+	Source string `json:"source"`
+	Hash   string `json:"hash"`
+}
+
+// MigrationNeeded reports whether updated rules (a higher RulesVersion than the one recorded in
+// store) changed the source or value the ID is derived from, so the application should re-bind
+// the machine and then call CommitMigration. The ID is resolved like ID(opts...), once under the
+// recorded rules and once under the current ones, and only a difference between the two is
+// reported: a value that changed without a rules update (e.g., after reimaging) is not; see
+// DualStackID. The first call records the binding under the rules version of opts.
+func MigrationNeeded(store Store, opts ...Option) (bool, error) {
+	state, err := loadMigrationState(store)
+	if err != nil {
+		return false, err
+	}
+	if state.Rules == 0 {
+		// First run: remember the current binding.
+		rules := max(newOptions(opts).rulesVersion, 1)
+		return false, recordMigrationState(store, rules, opts)
+	}
+	if state.Rules >= currentRules {
+		return false, nil
+	}
+
+	bound, err := resolveUnderRules(state.Rules, opts)
+	if err != nil {
+		return false, err
+	}
+	current, err := resolveUnderRules(currentRules, opts)
+	if err != nil {
+		return false, err
+	}
+	if bound.source != current.source || bound.rawID != current.rawID {
+		return true, nil
+	}
+	// The rules changed, but not for this machine: record them without a migration.
+	return false, recordMigrationState(store, currentRules, opts)
+}
+
+// CommitMigration records the binding under the current rules once the application has
+// re-bound the machine. opts must be the options given to MigrationNeeded.
+func CommitMigration(store Store, opts ...Option) error {
+	return recordMigrationState(store, currentRules, opts)
+}
+
+// resolveUnderRules resolves the ID like ID(opts...) under the given rules version.
+func resolveUnderRules(rules int, opts []Option) (snapshot, error) {
+	o := newOptions(opts)
+	o.rulesVersion = rules
+	return o.resolveSnapshot(options.load)
+}
+
+// recordMigrationState saves the binding under the given rules version.
+func recordMigrationState(store Store, rules int, opts []Option) error {
+	snap, err := resolveUnderRules(rules, opts)
+	if err != nil {
+		return err
+	}
+	hash, err := protect(snap.rawID)
+	if err != nil {
+		return err
+	}
+	return saveMigrationState(store, migrationState{Rules: rules, Source: snap.source, Hash: hash})
+}
+
+func loadMigrationState(store Store) (migrationState, error) {
+	var state migrationState
+	data, err := store.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("machineid: load state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("machineid: decode state: %w", err)
+	}
+	return state, nil
+}
+
+func saveMigrationState(store Store, state migrationState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := store.Save(data); err != nil {
+		return fmt.Errorf("machineid: save state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package machineid
+
+// Serverless platforms reported in MachineInfo.EnvironmentDetail as "serverless/<platform>".
+// IDs resolved there identify a short-lived sandbox (often a shared image), not a machine.
+const (
+	PlatformAWSLambda      = "aws-lambda"
+	PlatformCloudRun       = "cloud-run"
+	PlatformCloudRunJob    = "cloud-run-job"
+	PlatformCloudFunctions = "cloud-functions"
+	PlatformAzureFunctions = "azure-functions"
+)
+
+// classifyServerless returns the serverless platform the process runs on, or "" if none is
+// recognized, and the variable or file that identified it. getenv reads an environment
+// variable, exists reports whether a path is present.
+// The platforms inject well-known variables into every function; they are checked before the
+// filesystem layout, which custom runtimes and images may not follow.
+func classifyServerless(getenv func(string) string, exists func(path string) bool) (platform, evidence string) {
+	set := func(name string) bool {
+		if getenv(name) != "" {
+			evidence = "env:" + name
+			return true
+		}
+		return false
+	}
+
+	switch {
+	case set("AWS_LAMBDA_FUNCTION_NAME") || set("LAMBDA_TASK_ROOT"):
+		return PlatformAWSLambda, evidence
+	// Cloud Functions (2nd gen) run on Cloud Run and also set K_SERVICE: check them first.
+	case set("FUNCTION_TARGET") || (getenv("GCP_PROJECT") != "" && set("FUNCTION_NAME")):
+		return PlatformCloudFunctions, evidence
+	case getenv("K_REVISION") != "" && set("K_SERVICE"):
+		return PlatformCloudRun, evidence
+	case set("CLOUD_RUN_JOB"):
+		return PlatformCloudRunJob, evidence
+	case set("FUNCTIONS_WORKER_RUNTIME") || set("FUNCTIONS_EXTENSION_VERSION"):
+		return PlatformAzureFunctions, evidence
+	}
+
+	// The Lambda managed runtimes install the bootstrap under /var/runtime and the function
+	// code under /var/task.
+	if exists("/var/runtime/bootstrap") && exists("/var/task") {
+		return PlatformAWSLambda, "file:/var/runtime/bootstrap"
+	}
+	return "", ""
+}
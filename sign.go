@@ -0,0 +1,80 @@
+package machineid
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidSignature is returned by VerifyReport when the signature doesn't match the report.
+var ErrInvalidSignature = errors.New("machineid: invalid report signature")
+
+// reportSignatureContext separates report signatures from any other use of the same key.
+const reportSignatureContext = "machineid-report-v1\x00"
+
+// signedMessage returns the bytes covered by the signature: the context followed by the
+// canonical (deterministic CBOR) encoding of the report.
+func signedMessage(report *MachineInfo) ([]byte, error) {
+	enc, err := report.MarshalCBOR()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(reportSignatureContext), enc...), nil
+}
+
+// SignReport returns a detached signature over the canonical encoding of the report, so backend
+// services can verify that reports weren't modified in transit by proxies or agents.
+// Ed25519 keys sign the message directly; other keys (ECDSA, RSA PKCS #1 v1.5) sign its SHA256.
+func SignReport(report *MachineInfo, signer crypto.Signer) ([]byte, error) {
+	if report == nil {
+		return nil, errors.New("machineid: nil report")
+	}
+
+	msg, err := signedMessage(report)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return signer.Sign(rand.Reader, msg, crypto.Hash(0))
+	}
+	sum := sha256.Sum256(msg)
+	return signer.Sign(rand.Reader, sum[:], crypto.SHA256)
+}
+
+// VerifyReport checks a signature produced by SignReport. The public key must be an
+// ed25519.PublicKey, *ecdsa.PublicKey or *rsa.PublicKey. It returns ErrInvalidSignature
+// if the report or the signature were modified.
+func VerifyReport(report *MachineInfo, pub crypto.PublicKey, sig []byte) error {
+	if report == nil {
+		return errors.New("machineid: nil report")
+	}
+
+	msg, err := signedMessage(report)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(msg)
+
+	var ok bool
+	switch key := pub.(type) {
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, msg, sig)
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(key, sum[:], sig)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) == nil
+	default:
+		return fmt.Errorf("machineid: unsupported public key type %T", pub)
+	}
+
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
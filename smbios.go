@@ -0,0 +1,179 @@
+package machineid
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// rawSMBIOS is the decoded RawSMBIOSData structure returned by GetSystemFirmwareTable('RSMB'):
+//
+//	struct RawSMBIOSData {
+//	  BYTE  Used20CallingMethod;
+//	  BYTE  SMBIOSMajorVersion;
+//	  BYTE  SMBIOSMinorVersion;
+//	  BYTE  DmiRevision;
+//	  DWORD Length;          <-- 4 bytes indicating the size of the data following
+//	  BYTE  SMBIOSTableData[];
+//	}
+type rawSMBIOS struct {
+	table []byte
+}
+
+// parseRawSMBIOS decodes the RawSMBIOSData header and bounds the table by its Length field.
+func parseRawSMBIOS(buf []byte) (rawSMBIOS, error) {
+	if len(buf) < 8 {
+		return rawSMBIOS{}, errors.New("smbios buffer too small")
+	}
+
+	length := int(binary.LittleEndian.Uint32(buf[4:8]))
+	table := buf[8:]
+	if length < len(table) {
+		table = table[:length]
+	}
+	return rawSMBIOS{table: table}, nil
+}
+
+// smbiosStructure is one structure of the SMBIOS table.
+type smbiosStructure struct {
+	typ       byte
+	formatted []byte   // The formatted area, including the 4-byte header.
+	strings   []string // The unformatted string-set; string N is strings[N-1].
+}
+
+// str returns the string referenced by the byte at offset in the formatted area ("" if unset).
+func (s smbiosStructure) str(offset int) string {
+	if offset >= len(s.formatted) {
+		return ""
+	}
+	idx := int(s.formatted[offset])
+	if idx == 0 || idx > len(s.strings) {
+		return ""
+	}
+	return s.strings[idx-1]
+}
+
+// walkSMBIOS iterates the structures of the table until fn returns false,
+// the End-of-Table structure (Type 127) is reached, or the data is truncated.
+func walkSMBIOS(table []byte, fn func(s smbiosStructure) bool) {
+	// SMBIOS data is a sequence of structures.
+	// Header format: [Type (1b)] [Length (1b)] [Handle (2b)] ... [Data] ... [Strings] [Double Null \0\0]
+	i := 0
+	for i+4 <= len(table) {
+		typ := table[i]
+		length := int(table[i+1])
+		if length < 4 || i+length > len(table) {
+			return
+		}
+
+		s := smbiosStructure{typ: typ, formatted: table[i : i+length]}
+
+		// The unformatted section is a list of NUL-terminated strings, ending with an extra NUL.
+		// A structure without strings is followed directly by the double NUL.
+		j := i + length
+		start := j
+		for {
+			if j+1 >= len(table) {
+				return
+			}
+			if table[j] == 0 {
+				if j > start {
+					s.strings = append(s.strings, string(table[start:j]))
+				}
+				if table[j+1] == 0 {
+					j += 2
+					break
+				}
+				start = j + 1
+			}
+			j++
+		}
+
+		if !fn(s) || typ == 127 {
+			return
+		}
+		i = j
+	}
+}
+
+// findUUIDInSMBIOS returns the UUID of the System Information structure (Type 1).
+func findUUIDInSMBIOS(raw rawSMBIOS) (string, error) {
+	uuid, found := "", false
+	walkSMBIOS(raw.table, func(s smbiosStructure) bool {
+		// Offset 0x08 (8) is where the UUID starts within the Type 1 structure.
+		// The structure length must be at least 0x19 (25 bytes) to hold the 16-byte UUID.
+		if s.typ != 1 || len(s.formatted) < 25 {
+			return true
+		}
+		uuid, found = formatSMBIOSUUID(s.formatted[8:24]), true
+		return false
+	})
+
+	if !found {
+		return "", fmt.Errorf("uuid not found in smbios")
+	}
+	return uuid, nil
+}
+
+// formatSMBIOSUUID formats the 16 bytes into a standard UUID string.
+// CRITICAL: SMBIOS 2.6+ specification dictates mixed-endian encoding for the UUID.
+// - The first 3 fields (DWORD, WORD, WORD) are Little-Endian.
+// - The last 2 fields (Char array) are Big-Endian (Network Byte Order).
+// We must swap bytes in the first 3 fields to match the standard string representation.
+// Earlier versions did not specify the byte order (dmidecode reads them as Big-Endian), but
+// the IDs of such machines have always been derived from the mixed-endian form, so it is kept.
+func formatSMBIOSUUID(b []byte) string {
+	// Check for invalid UUIDs (all zeros or all ones) often found in unconfigured hardware.
+	if isAll(b, 0x00) || isAll(b, 0xFF) {
+		return ""
+	}
+
+	return fmt.Sprintf("%02x%02x%02x%02x-%02x%02x-%02x%02x-%02x%02x-%02x%02x%02x%02x%02x%02x",
+		b[3], b[2], b[1], b[0], // Field 1: Swap DWORD (4 bytes)
+		b[5], b[4], // Field 2: Swap WORD (2 bytes)
+		b[7], b[6], // Field 3: Swap WORD (2 bytes)
+		b[8], b[9], b[10], b[11], b[12], b[13], b[14], b[15]) // Remaining bytes are Big-Endian
+}
+
+func isAll(b []byte, v byte) bool {
+	for _, x := range b {
+		if x != v {
+			return false
+		}
+	}
+	return true
+}
+
+// SMBIOS structure types holding a serial number.
+const (
+	smbiosSystemInformation = 1
+	smbiosBaseboard         = 2
+)
+
+// findSerialInSMBIOS returns the serial number string of the first structure of type typ
+// (System Information or Baseboard).
+func findSerialInSMBIOS(raw rawSMBIOS, typ byte) string {
+	serial := ""
+	walkSMBIOS(raw.table, func(s smbiosStructure) bool {
+		if s.typ != typ {
+			return true
+		}
+		// In both types, offset 0x07 holds the string number of the Serial Number.
+		serial = strings.TrimSpace(s.str(7))
+		return false
+	})
+	return serial
+}
+
+// isPlaceholderSerial reports whether a serial is empty or one of the fillers OEMs ship
+// instead of a real value (e.g., "To Be Filled By O.E.M.", "Default string", "0").
+func isPlaceholderSerial(serial string) bool {
+	s := strings.ToLower(strings.TrimSpace(serial))
+	switch s {
+	case "", "0", "none", "n/a", "na", "default string", "system serial number", "base board serial number",
+		"to be filled by o.e.m.", "to be filled by oem", "not specified", "not applicable", "123456789":
+		return true
+	}
+	return strings.Trim(s, "0") == "" || strings.Trim(s, "f") == ""
+}
@@ -0,0 +1,198 @@
+package machineid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// optionalSources holds the opt-in sources available on the current platform, keyed by name.
+// Platforms register them in init; they are only used if named in SetSourcePriority.
+var optionalSources = map[string]func() (string, error){}
+
+// sourceResolvers holds the context-aware form of the sources added with RegisterSource, so
+// the chain can cancel them once a higher-priority source has answered.
+var sourceResolvers = map[string]func(ctx context.Context) (string, error){}
+
+// maxParallelSources bounds the number of sources of a chain probed at the same time.
+const maxParallelSources = 4
+
+// Source is a custom source of the raw ID, e.g. a corporate asset tag file. Once registered with
+// RegisterSource, it can be named in SetSourcePriority to take part in the fallback chain.
+type Source interface {
+	// Name identifies the source in SetSourcePriority, Sources and MachineInfo.Source.
+	Name() string
+	// Resolve returns the raw ID. An error wrapping os.ErrNotExist, or an empty value, moves the
+	// chain on to the next source; other errors are reported if no later source succeeds.
+	Resolve(ctx context.Context) (string, error)
+}
+
+// RegisterSource adds a custom source that can then be named in SetSourcePriority, e.g.
+//
+//	machineid.RegisterSource(assetTag{path: "/etc/asset-tag"})
+//	machineid.SetSourcePriority("asset-tag", machineid.ComponentMachineID)
+//
+// Like the platform sources, it must be registered at startup, before SetSourcePriority. The
+// name must not be taken by another source.
+func RegisterSource(src Source) error {
+	err := registerSource(src.Name(), func() (string, error) {
+		return src.Resolve(context.Background())
+	})
+	if err == nil {
+		sourceResolvers[src.Name()] = src.Resolve
+	}
+	return err
+}
+
+// registerSource adds an opt-in source contributed by an optional package or an application
+// (see RegisterSource).
+func registerSource(name string, get func() (string, error)) error {
+	switch name {
+	case "", ComponentMachineID, ComponentMAC, SourceHostID, SourceEnvironment, SourcePersisted, SourceRandom:
+		return fmt.Errorf("machineid: invalid source name %q", name)
+	}
+	if _, ok := optionalSources[name]; ok {
+		return fmt.Errorf("machineid: source %q is already registered", name)
+	}
+	optionalSources[name] = get
+	return nil
+}
+
+// sourcePriority holds the chain configured by SetSourcePriority (nil means the platform default).
+var sourcePriority atomic.Pointer[[]string]
+
+// SetSourcePriority configures the ordered chain of sources used to resolve the raw ID, e.g.
+// SetSourcePriority(ComponentDMIUUID, ComponentMachineID) on Linux for a hardware-rooted ID that
+// survives OS reinstalls. ComponentMachineID names the platform default and is always available.
+// Calling it without arguments restores the default chain.
+//
+// Sources are tried in order; a failing source moves on to the next one. If every source is
+// missing, the MAC address fallback applies as usual. Up to four sources are probed
+// concurrently, so slow sources don't add up: the first source in the chain that succeeds wins
+// as soon as every source before it has failed, and the others are cancelled (see Source).
+// Lower-priority sources may therefore be started speculatively, before a higher-priority one
+// answered, and even if it succeeds; they must not have side effects.
+//
+// Changing the chain changes the ID, so configure it once at startup: the cached ID is
+// discarded.
+func SetSourcePriority(sources ...string) error {
+	for _, name := range sources {
+		if name == ComponentMachineID {
+			continue
+		}
+		if _, ok := optionalSources[name]; !ok {
+			return fmt.Errorf("machineid: source %q is not available on this platform", name)
+		}
+	}
+
+	if len(sources) == 0 {
+		sourcePriority.Store(nil)
+	} else {
+		chain := append([]string(nil), sources...)
+		sourcePriority.Store(&chain)
+	}
+
+	mu.Lock()
+	initialized = false
+	mu.Unlock()
+	return nil
+}
+
+// resolveSources runs the configured chain and returns the raw ID with the name of its source.
+// It returns an error wrapping os.ErrNotExist (or an empty ID) if the MAC fallback should apply.
+func resolveSources() (string, string, error) {
+	return resolveSourcesWith(cachedSourceValue)
+}
+
+// resolveSourcesWith is resolveSources, reading the platform machine ID through value (see
+// resolveWith).
+func resolveSourcesWith(value func(name string, get func() (string, error)) (string, error)) (string, string, error) {
+	chain := sourcePriority.Load()
+	if chain == nil {
+		id, err := value(ComponentMachineID, getMachineIDFunc)
+		logDebug("machineid: source tried", "source", ComponentMachineID, "empty", err == nil && id == "", "error", err)
+		return id, machineIDSource(), err
+	}
+
+	type entry struct {
+		name string
+		get  func(ctx context.Context) (string, error)
+	}
+	// The hooks are read once here: slower sources keep running after the first success, and
+	// must not observe later changes (e.g., tests restoring them).
+	getID, idSource := getMachineIDFunc, machineIDSource
+	var entries []entry
+	for _, name := range *chain {
+		if !sourceEnabled(name) {
+			continue
+		}
+		e := entry{name: name}
+		switch resolve, ok := sourceResolvers[name]; {
+		case name == ComponentMachineID:
+			e.get = func(context.Context) (string, error) {
+				return value(ComponentMachineID, getID)
+			}
+		case ok:
+			e.get = resolve
+		default:
+			get := optionalSources[name]
+			e.get = func(context.Context) (string, error) { return get() }
+		}
+		entries = append(entries, e)
+	}
+
+	// Probe the sources concurrently, starting them in chain order as slots free up. Results
+	// are consumed in chain order, so priorities are kept.
+	type result struct {
+		id, source string
+		err        error
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make([]chan result, len(entries))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	go func() {
+		slots := make(chan struct{}, maxParallelSources)
+		for i, e := range entries {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				defer func() { <-slots }()
+				id, err := e.get(ctx)
+				source := e.name
+				if e.name == ComponentMachineID {
+					// Platforms with a multi-tier chain (Windows) report the tier that answered.
+					source = idSource()
+				}
+				results[i] <- result{id, source, err}
+			}()
+		}
+	}()
+
+	// Only real failures are reported: a joined os.ErrNotExist would trigger the MAC fallback.
+	var errs []error
+	for i, e := range entries {
+		r := <-results[i]
+		logDebug("machineid: source tried", "source", e.name, "empty", r.err == nil && strings.TrimSpace(r.id) == "", "error", r.err)
+		if r.err == nil && strings.TrimSpace(r.id) != "" {
+			return r.id, r.source, nil
+		}
+
+		if r.err != nil && !errors.Is(r.err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("%s: %w", e.name, r.err))
+		}
+	}
+
+	if len(errs) == 0 {
+		return "", "", os.ErrNotExist
+	}
+	return "", "", errors.Join(errs...)
+}
@@ -0,0 +1,34 @@
+package machineid
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// parseStorageDeviceSerial extracts the serial number from a STORAGE_DEVICE_DESCRIPTOR, as
+// returned by IOCTL_STORAGE_QUERY_PROPERTY (StorageDeviceProperty). The serial is a
+// NUL-terminated ASCII string at SerialNumberOffset; an offset of 0 means "no serial".
+// The parser is platform independent so it can be tested everywhere.
+func parseStorageDeviceSerial(buf []byte) (string, error) {
+	// Version, Size, 4 single-byte fields, then the Vendor/Product/Revision/Serial offsets.
+	const serialOffsetPos = 24
+	if len(buf) < serialOffsetPos+4 {
+		return "", errors.New("storage descriptor too short")
+	}
+
+	off := binary.LittleEndian.Uint32(buf[serialOffsetPos:])
+	if off == 0 || off == 0xffffffff || int(off) >= len(buf) {
+		return "", errors.New("disk reports no serial number")
+	}
+
+	s := buf[off:]
+	if i := strings.IndexByte(string(s), 0); i >= 0 {
+		s = s[:i]
+	}
+	serial := strings.TrimSpace(string(s))
+	if serial == "" {
+		return "", errors.New("disk reports no serial number")
+	}
+	return serial, nil
+}
@@ -0,0 +1,73 @@
+package machineid
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Store persists identity state between runs. Load must return an error wrapping
+// os.ErrNotExist if nothing was saved yet.
+//
+// The state contains the raw machine identifier, so implementations must keep it private
+// (FileStore writes with mode 0600).
+type Store interface {
+	Load() ([]byte, error)
+	Save(data []byte) error
+}
+
+// FileStore returns a Store keeping the state in a single file
+// (e.g., /var/lib/<app>/machineid.json). Writes are atomic: a crash never leaves a torn file.
+func FileStore(path string) Store {
+	return fileStore(path)
+}
+
+// ErrNoStateDirectory is returned by StateDirectoryStore outside of a systemd service with
+// StateDirectory= set.
+var ErrNoStateDirectory = errors.New("machineid: STATE_DIRECTORY not set (StateDirectory= missing from the unit?)")
+
+// StateDirectoryStore returns a FileStore keeping the state in file name within the state
+// directory systemd creates for the service (StateDirectory=, passed in $STATE_DIRECTORY; the
+// first one if several are configured). Unlike a fixed path under /var/lib, it is writable under
+// DynamicUser= and ProtectSystem=strict, and survives restarts.
+func StateDirectoryStore(name string) (Store, error) {
+	dirs := filepath.SplitList(os.Getenv("STATE_DIRECTORY"))
+	if len(dirs) == 0 || dirs[0] == "" {
+		return nil, ErrNoStateDirectory
+	}
+	if name == "" || filepath.Base(name) != name {
+		return nil, errors.New("machineid: state file name must not contain a path")
+	}
+	return FileStore(filepath.Join(dirs[0], name)), nil
+}
+
+type fileStore string
+
+func (f fileStore) Load() ([]byte, error) {
+	return os.ReadFile(string(f))
+}
+
+func (f fileStore) Save(data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(string(f)), filepath.Base(string(f))+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), string(f))
+}
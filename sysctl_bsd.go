@@ -0,0 +1,18 @@
+//go:build openbsd || netbsd || dragonfly
+
+package machineid
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// sysctlString reads a string sysctl. It is a variable so tests can mock it.
+var sysctlString = unix.Sysctl
+
+// isGenericUUID reports whether uuid is an unconfigured placeholder (all zeros or all F's).
+func isGenericUUID(uuid string) bool {
+	s := strings.ReplaceAll(strings.ToLower(uuid), "-", "")
+	return strings.Trim(s, "0") == "" || strings.Trim(s, "f") == ""
+}
@@ -0,0 +1,12 @@
+package machineid
+
+// Environment tags reported in MachineInfo.Tags. They flag execution conditions that
+// don't change the environment type but can affect how the ID was derived.
+const (
+	// TagRosetta is set when the process runs under Rosetta 2 translation on Apple Silicon.
+	// Translated processes see an emulated x86_64 CPU, so CPU-derived data differs from
+	// native processes on the same machine.
+	TagRosetta = "rosetta"
+)
+
+var getEnvironmentTagsFunc = getEnvironmentTags
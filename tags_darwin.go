@@ -0,0 +1,18 @@
+//go:build darwin && !ios
+
+package machineid
+
+import "golang.org/x/sys/unix"
+
+// procTranslated reads sysctl.proc_translated: 1 under Rosetta 2, 0 for native processes.
+// The sysctl doesn't exist on Intel Macs and older releases, which is reported as native.
+var procTranslated = func() (uint32, error) {
+	return unix.SysctlUint32("sysctl.proc_translated")
+}
+
+func getEnvironmentTags() []string {
+	if v, err := procTranslated(); err == nil && v == 1 {
+		return []string{TagRosetta}
+	}
+	return nil
+}
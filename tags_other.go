@@ -0,0 +1,8 @@
+//go:build !darwin || ios
+
+package machineid
+
+// getEnvironmentTags reports no tags on this platform.
+func getEnvironmentTags() []string {
+	return nil
+}
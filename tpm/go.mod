@@ -0,0 +1,7 @@
+module github.com/banditmoscow1337/machineid/tpm
+
+go 1.25.5
+
+require github.com/google/go-tpm v0.9.8
+
+require golang.org/x/sys v0.39.0 // indirect
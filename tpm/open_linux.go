@@ -0,0 +1,18 @@
+//go:build linux
+
+package tpm
+
+import (
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/google/go-tpm/tpm2/transport/linuxtpm"
+)
+
+// openTPM prefers the kernel resource manager, which allows concurrent users
+// (e.g., tpm2-abrmd or other agents), over the raw device.
+func openTPM() (transport.TPMCloser, error) {
+	t, err := linuxtpm.Open("/dev/tpmrm0")
+	if err == nil {
+		return t, nil
+	}
+	return linuxtpm.Open("/dev/tpm0")
+}
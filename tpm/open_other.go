@@ -0,0 +1,13 @@
+//go:build !linux && !windows
+
+package tpm
+
+import (
+	"errors"
+
+	"github.com/google/go-tpm/tpm2/transport"
+)
+
+func openTPM() (transport.TPMCloser, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package tpm
+
+import (
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/google/go-tpm/tpm2/transport/windowstpm"
+)
+
+// openTPM opens the TPM through the TPM Base Services (tbs.dll).
+func openTPM() (transport.TPMCloser, error) {
+	return windowstpm.Open()
+}
@@ -0,0 +1,175 @@
+// Package tpm derives a machine identifier from the TPM 2.0 endorsement key (EK).
+//
+// The EK is generated inside the TPM by the manufacturer and never leaves it, so the
+// identifier is tamper-resistant and survives OS reinstalls and disk swaps. This makes it
+// suitable for licensing and zero-trust enrollment. Opening the TPM usually requires
+// elevated privileges (root or the tss group on Linux, Administrator on Windows).
+//
+// The package is separate from machineid so that only applications that import it
+// depend on go-tpm. It is supported on Linux and Windows; other platforms return an
+// error wrapping errors.ErrUnsupported.
+package tpm
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+)
+
+// ekHandle is the persistent handle the TCG EK Credential Profile reserves for the RSA EK.
+const ekHandle = tpm2.TPMHandle(0x81010001)
+
+// openFunc opens the platform TPM. It is a variable so tests can substitute a fake transport.
+var openFunc = openTPM
+
+// EKPublicKey returns the public part of the endorsement key (an *rsa.PublicKey or
+// *ecdsa.PublicKey).
+func EKPublicKey() (crypto.PublicKey, error) {
+	t, err := openFunc()
+	if err != nil {
+		return nil, fmt.Errorf("tpm: open: %w", err)
+	}
+	defer t.Close()
+
+	pub, err := ekPublic(t)
+	if err != nil {
+		return nil, fmt.Errorf("tpm: read endorsement key: %w", err)
+	}
+	return tpm2.Pub(*pub)
+}
+
+// ID returns the SHA256 (hex) of the DER-encoded endorsement key.
+func ID() (string, error) {
+	pub, err := EKPublicKey()
+	if err != nil {
+		return "", err
+	}
+	return ekID(pub)
+}
+
+// DefaultPCRs are the PCRs read by PCRDigest and IntegrityID when none are given. PCR 7 measures
+// the Secure Boot policy: its state, the PK, KEK, db and dbx variables, and the certificates that
+// verified the boot chain.
+var DefaultPCRs = []int{7}
+
+// PCRDigest returns the SHA256 (hex) of the SHA-256 bank values of the given PCRs (DefaultPCRs if
+// none), in the order given. It reports the boot configuration measured by the firmware and the
+// boot loader; see IntegrityID to bind the identity to it.
+func PCRDigest(pcrs ...int) (string, error) {
+	t, err := openFunc()
+	if err != nil {
+		return "", fmt.Errorf("tpm: open: %w", err)
+	}
+	defer t.Close()
+	return pcrDigest(t, pcrs)
+}
+
+// IntegrityID returns an identifier bound to both the TPM and the boot configuration: the SHA256
+// (hex) of ID() and PCRDigest(pcrs...). It changes if the measured boot configuration is tampered
+// with (e.g., Secure Boot disabled or a key enrolled), but also on legitimate changes measured into
+// the selected PCRs, such as a dbx update. Security agents should re-enroll after planned updates.
+func IntegrityID(pcrs ...int) (string, error) {
+	t, err := openFunc()
+	if err != nil {
+		return "", fmt.Errorf("tpm: open: %w", err)
+	}
+	defer t.Close()
+
+	pub, err := ekPublic(t)
+	if err != nil {
+		return "", fmt.Errorf("tpm: read endorsement key: %w", err)
+	}
+	key, err := tpm2.Pub(*pub)
+	if err != nil {
+		return "", fmt.Errorf("tpm: read endorsement key: %w", err)
+	}
+	id, err := ekID(key)
+	if err != nil {
+		return "", err
+	}
+	digest, err := pcrDigest(t, pcrs)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(id + ":" + digest))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ekID hashes the DER encoding of the endorsement key.
+func ekID(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("tpm: encode endorsement key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// pcrDigest reads the PCRs one at a time from the SHA-256 bank and hashes their values.
+func pcrDigest(t transport.TPM, pcrs []int) (string, error) {
+	if len(pcrs) == 0 {
+		pcrs = DefaultPCRs
+	}
+
+	h := sha256.New()
+	for _, pcr := range pcrs {
+		if pcr < 0 || pcr > 23 {
+			return "", fmt.Errorf("tpm: invalid PCR %d", pcr)
+		}
+		rsp, err := tpm2.PCRRead{
+			PCRSelectionIn: tpm2.TPMLPCRSelection{
+				PCRSelections: []tpm2.TPMSPCRSelection{{
+					Hash:      tpm2.TPMAlgSHA256,
+					PCRSelect: tpm2.PCClientCompatible.PCRs(uint(pcr)),
+				}},
+			},
+		}.Execute(t)
+		if err != nil {
+			return "", fmt.Errorf("tpm: read PCR %d: %w", pcr, err)
+		}
+		// A TPM without an active SHA-256 bank returns an empty selection.
+		if len(rsp.PCRValues.Digests) != 1 {
+			return "", fmt.Errorf("tpm: read PCR %d: SHA-256 bank not available", pcr)
+		}
+		h.Write(rsp.PCRValues.Digests[0].Buffer)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ProtectedID returns an app-specific identifier derived from ID(), so the same TPM
+// can't be correlated across applications.
+func ProtectedID(appID string) (string, error) {
+	id, err := ID()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(id + ":" + appID))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ekPublic reads the persisted EK. If the platform didn't persist it, the EK is recreated from
+// the TCG reference RSA template: the TPM derives it deterministically from its endorsement seed,
+// so the result is the same key.
+func ekPublic(t transport.TPM) (*tpm2.TPMTPublic, error) {
+	rsp, readErr := tpm2.ReadPublic{ObjectHandle: ekHandle}.Execute(t)
+	if readErr == nil {
+		return rsp.OutPublic.Contents()
+	}
+
+	created, err := tpm2.CreatePrimary{
+		PrimaryHandle: tpm2.TPMRHEndorsement,
+		InPublic:      tpm2.New2B(tpm2.RSAEKTemplate),
+	}.Execute(t)
+	if err != nil {
+		return nil, errors.Join(readErr, err)
+	}
+	defer tpm2.FlushContext{FlushHandle: created.ObjectHandle}.Execute(t)
+
+	return created.OutPublic.Contents()
+}
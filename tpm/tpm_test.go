@@ -0,0 +1,161 @@
+package tpm
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+)
+
+// fakeTPM answers TPM2_ReadPublic with a fixed EK and TPM2_PCR_Read from the SHA-256 values in
+// pcrs (PCRs not in the map read as an inactive bank), or fails every command if ek is nil.
+type fakeTPM struct {
+	ek   *tpm2.TPMTPublic
+	pcrs map[int][]byte
+}
+
+func (f *fakeTPM) Send(cmd []byte) ([]byte, error) {
+	if f.ek == nil {
+		return nil, errors.New("no tpm")
+	}
+
+	var preimage []byte
+	var err error
+	switch tpm2.TPMCC(binary.BigEndian.Uint32(cmd[6:10])) {
+	case tpm2.TPMCCPCRRead:
+		// The command carries a single selection: count || hash || sizeofSelect || bitmap.
+		var rsp tpm2.PCRReadResponse
+		for i, bits := range cmd[17:20] {
+			for b := range 8 {
+				if value, ok := f.pcrs[i*8+b]; ok && bits&(1<<b) != 0 {
+					rsp.PCRValues.Digests = append(rsp.PCRValues.Digests, tpm2.TPM2BDigest{Buffer: value})
+				}
+			}
+		}
+		preimage, err = tpm2.MarshalResponse(tpm2.PCRRead{}, &rsp)
+	default:
+		preimage, err = tpm2.MarshalResponse(tpm2.ReadPublic{ObjectHandle: ekHandle}, &tpm2.ReadPublicResponse{
+			OutPublic: tpm2.New2B(*f.ek),
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The preimage is responseCode || commandCode || parameters; the wire format is
+	// tag || size || responseCode || parameters.
+	params := preimage[8:]
+	rsp := binary.BigEndian.AppendUint16(nil, uint16(tpm2.TPMSTNoSessions))
+	rsp = binary.BigEndian.AppendUint32(rsp, uint32(10+len(params)))
+	rsp = binary.BigEndian.AppendUint32(rsp, 0)
+	return append(rsp, params...), nil
+}
+
+func (f *fakeTPM) Close() error { return nil }
+
+// testEK returns the reference EK template filled with a deterministic modulus.
+func testEK(seed byte) *tpm2.TPMTPublic {
+	modulus := make([]byte, 256)
+	for i := range modulus {
+		modulus[i] = seed + byte(i)
+	}
+	modulus[0] |= 0x80
+
+	ek := tpm2.RSAEKTemplate
+	ek.Unique = tpm2.NewTPMUPublicID(tpm2.TPMAlgRSA, &tpm2.TPM2BPublicKeyRSA{Buffer: modulus})
+	return &ek
+}
+
+func TestID(t *testing.T) {
+	defer func() { openFunc = openTPM }()
+
+	fake := &fakeTPM{ek: testEK(1)}
+	openFunc = func() (transport.TPMCloser, error) { return fake, nil }
+
+	pub, err := EKPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	modulus, _ := fake.ek.Unique.RSA()
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok || rsaPub.N.Cmp(new(big.Int).SetBytes(modulus.Buffer)) != 0 {
+		t.Fatalf("unexpected EK public key: %#v", pub)
+	}
+
+	id1, err := ID()
+	if err != nil || len(id1) != 64 {
+		t.Fatalf("unexpected ID %q, %v", id1, err)
+	}
+	if again, _ := ID(); again != id1 {
+		t.Error("ID must be stable")
+	}
+
+	// A different TPM produces a different ID.
+	fake.ek = testEK(2)
+	if id2, _ := ID(); id2 == id1 {
+		t.Error("different endorsement keys must produce different IDs")
+	}
+
+	// ProtectedID separates applications.
+	a, _ := ProtectedID("app-a")
+	b, _ := ProtectedID("app-b")
+	if a == b {
+		t.Error("ProtectedID must differ between apps")
+	}
+
+	// A failing TPM surfaces an error.
+	fake.ek = nil
+	if _, err := ID(); err == nil {
+		t.Error("expected an error from a failing TPM")
+	}
+}
+
+func TestIntegrityID(t *testing.T) {
+	defer func() { openFunc = openTPM }()
+
+	pcr := func(b byte) []byte { return bytes.Repeat([]byte{b}, 32) }
+	fake := &fakeTPM{ek: testEK(1), pcrs: map[int][]byte{0: pcr(0), 7: pcr(7)}}
+	openFunc = func() (transport.TPMCloser, error) { return fake, nil }
+
+	digest, err := PCRDigest()
+	if err != nil || len(digest) != 64 {
+		t.Fatalf("unexpected PCR digest %q, %v", digest, err)
+	}
+	if both, _ := PCRDigest(0, 7); both == digest {
+		t.Error("the PCR selection must change the digest")
+	}
+
+	id, err := ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrity, err := IntegrityID()
+	if err != nil || len(integrity) != 64 || integrity == id {
+		t.Fatalf("unexpected integrity ID %q, %v", integrity, err)
+	}
+	if again, _ := IntegrityID(); again != integrity {
+		t.Error("IntegrityID must be stable")
+	}
+
+	// Changing the Secure Boot state changes the integrity ID but not the EK ID.
+	fake.pcrs[7] = pcr(8)
+	if changed, _ := IntegrityID(); changed == integrity {
+		t.Error("a PCR change must change IntegrityID")
+	}
+	if same, _ := ID(); same != id {
+		t.Error("a PCR change must not change ID")
+	}
+
+	// Unavailable banks and invalid indices are errors.
+	if _, err := PCRDigest(3); err == nil {
+		t.Error("expected an error for an inactive bank")
+	}
+	if _, err := PCRDigest(24); err == nil {
+		t.Error("expected an error for an invalid PCR")
+	}
+}
@@ -0,0 +1,42 @@
+package machineid
+
+import (
+	"crypto/sha3"
+	"encoding/binary"
+	"math/bits"
+)
+
+// tupleHash256 implements TupleHash256 (NIST SP 800-185): every element of the tuple is
+// length-prefixed before hashing, so ("a:b", "c") and ("a", "b:c") produce unrelated outputs.
+// custom is the customization string used for domain separation; size is the output in bytes.
+func tupleHash256(tuple []string, custom string, size int) []byte {
+	h := sha3.NewCSHAKE256([]byte("TupleHash"), []byte(custom))
+	for _, x := range tuple {
+		h.Write(leftEncode(uint64(len(x)) * 8))
+		h.Write([]byte(x))
+	}
+	h.Write(rightEncode(uint64(size) * 8))
+
+	out := make([]byte, size)
+	h.Read(out)
+	return out
+}
+
+// leftEncode encodes x as its minimal big-endian bytes, preceded by their count.
+func leftEncode(x uint64) []byte {
+	b := encodeMinimal(x)
+	return append([]byte{byte(len(b))}, b...)
+}
+
+// rightEncode encodes x as its minimal big-endian bytes, followed by their count.
+func rightEncode(x uint64) []byte {
+	b := encodeMinimal(x)
+	return append(b, byte(len(b)))
+}
+
+func encodeMinimal(x uint64) []byte {
+	n := max((bits.Len64(x)+7)/8, 1)
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], x)
+	return buf[8-n:]
+}
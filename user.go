@@ -0,0 +1,79 @@
+package machineid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+)
+
+// SourceUser names the OS user identity in the errors of UserID and ProtectedUserID.
+const SourceUser = "user"
+
+var currentUserFunc = currentUser
+
+// currentUser returns the identity of the user running the process: the SID on Windows, the
+// numeric UID elsewhere. The UID is used rather than the user name, which can be renamed.
+func currentUser() (string, error) {
+	if runtime.GOOS == "windows" {
+		u, err := user.Current()
+		if err != nil {
+			return "", err
+		}
+		return u.Uid, nil
+	}
+	uid := os.Getuid()
+	if uid < 0 {
+		return "", fmt.Errorf("no user ID on %s: %w", runtime.GOOS, errors.ErrUnsupported)
+	}
+	return strconv.Itoa(uid), nil
+}
+
+// UserID returns an ID unique to the machine and the OS user running the process (the UID, or
+// the SID on Windows), so per-user installs on a shared machine get distinct but stable IDs
+// (e.g., for per-seat licensing). It is never equal to ID(). Options apply as for ID().
+//
+// Format: "<environment>:<hash>"
+//
+// It fails with a *SourceError if the user can't be determined (e.g., on WASI).
+func UserID(opts ...Option) (string, error) {
+	uid, err := userTupleItem()
+	if err != nil {
+		return "", err
+	}
+	o := newOptions(opts)
+	snap, err := o.resolveSnapshot(options.load)
+	if err != nil {
+		return "", err
+	}
+	return formatID(snap.prefix, domainUserID, []string{snap.rawID, uid}, opts)
+}
+
+// ProtectedUserID is the UserID counterpart of ProtectedID: the ID is also salted with appID,
+// so apps can't correlate their users. Like ProtectedID, every appID is recorded.
+func ProtectedUserID(appID string, opts ...Option) (string, error) {
+	trackAppID(appID, callerSite(1))
+
+	uid, err := userTupleItem()
+	if err != nil {
+		return "", err
+	}
+	o := newOptions(opts)
+	snap, err := o.resolveSnapshot(options.load)
+	if err != nil {
+		return "", err
+	}
+	appID = o.normalizeAppID(appID)
+	return formatID(snap.prefix, domainProtectedUserID, []string{snap.rawID, uid, appID}, opts)
+}
+
+// userTupleItem returns the user identity as hashed by UserID.
+func userTupleItem() (string, error) {
+	uid, err := currentUserFunc()
+	if err != nil {
+		return "", &SourceError{Source: SourceUser, Err: err}
+	}
+	return uid, nil
+}
@@ -0,0 +1,37 @@
+package machineid
+
+// VerifyCurrentMatchesCached re-runs environment detection and ID resolution without
+// mutating the cache, and reports whether the live environment still produces the cached ID.
+// Both are resolved like ID(opts...), so pass the options the ID is used with.
+//
+// Long-lived daemons can call it periodically as a cheap sanity check (e.g., to export a metric)
+// and decide themselves whether to restart or alert. If nothing is cached yet, the cache is
+// populated first, so the first call always reports a match.
+func VerifyCurrentMatchesCached(opts ...Option) (bool, error) {
+	o := newOptions(opts)
+	cached, err := o.resolveSnapshot(options.load)
+	if err != nil {
+		return false, err
+	}
+
+	// Re-run the sources contributing to the ID, without serving them from the source cache
+	// or replacing the values it holds.
+	current, err := o.resolveSnapshot(func(o options) (snapshot, error) {
+		return o.applyPolicy(resolveWith(uncachedSourceValue))
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// Compare the formatted IDs rather than the raw values: the environment prefix
+	// is part of the ID, and raw values differing only by whitespace hash the same.
+	cachedID, err := formatID(cached.prefix, domainID, []string{cached.rawID}, nil)
+	if err != nil {
+		return false, err
+	}
+	currentID, err := formatID(current.prefix, domainID, []string{current.rawID}, nil)
+	if err != nil {
+		return false, err
+	}
+	return cachedID == currentID, nil
+}
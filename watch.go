@@ -0,0 +1,104 @@
+package machineid
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	// callbacksMu guards idChangeCallbacks.
+	callbacksMu       sync.Mutex
+	idChangeCallbacks []func(oldID, newID string)
+
+	linkEventsFunc = linkEvents
+)
+
+// OnIDChange registers fn to be called when a running WatchNetwork detects that the ID changed.
+// Both values are formatted like ID() with default options. Callbacks run on the watcher goroutine.
+func OnIDChange(fn func(oldID, newID string)) {
+	callbacksMu.Lock()
+	defer callbacksMu.Unlock()
+	idChangeCallbacks = append(idChangeCallbacks, fn)
+}
+
+// WatchNetwork subscribes to network link change events (netlink on Linux,
+// NotifyIpInterfaceChange on Windows, periodic polling elsewhere) and re-evaluates the ID
+// when it was derived from the MAC fallback. If the ID changed, the cache is updated and
+// the OnIDChange callbacks fire, instead of silently serving a stale value forever.
+//
+// IDs from OS-specific sources are not affected by NIC changes and are never re-evaluated.
+// WatchNetwork blocks until ctx is done; run it in its own goroutine.
+func WatchNetwork(ctx context.Context) error {
+	events, err := linkEventsFunc(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-events:
+			if !ok {
+				return ctx.Err()
+			}
+			reevaluateHardwareID()
+		}
+	}
+}
+
+// reevaluateHardwareID recomputes the MAC fallback ID and swaps it into the cache if it changed.
+func reevaluateHardwareID() {
+	mu.Lock()
+	if !initialized || cachedSource != ComponentMAC {
+		mu.Unlock()
+		return
+	}
+	oldRaw, prefix := cachedRawID, cachedPrefix
+	mu.Unlock()
+
+	// Resolve outside the lock: enumerating interfaces can be slow.
+	invalidateSources(ComponentMAC)
+	newRaw, err := cachedSourceValue(ComponentMAC, getHardwareId)
+	if err != nil || newRaw == oldRaw {
+		// Keep serving the last known ID if the NICs are temporarily gone (e.g., during a link flap).
+		return
+	}
+
+	mu.Lock()
+	// Another goroutine may have replaced the cache in the meantime.
+	if cachedRawID != oldRaw || cachedSource != ComponentMAC {
+		mu.Unlock()
+		return
+	}
+	cachedRawID = newRaw
+	mu.Unlock()
+
+	// The cache holds the host's raw ID: format both like ID(), with the container scope.
+	oldSnap := scopeToContainer(snapshot{rawID: oldRaw, prefix: prefix})
+	newSnap := scopeToContainer(snapshot{rawID: newRaw, prefix: prefix})
+	oldID, err := formatID(prefix, domainID, []string{oldSnap.rawID}, nil)
+	if err != nil {
+		return
+	}
+	newID, err := formatID(prefix, domainID, []string{newSnap.rawID}, nil)
+	if err != nil {
+		return
+	}
+
+	callbacksMu.Lock()
+	callbacks := append([]func(string, string){}, idChangeCallbacks...)
+	callbacksMu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(oldID, newID)
+	}
+}
+
+// notify performs a non-blocking send, coalescing bursts of events into a single wake-up.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package machineid
+
+import (
+	"context"
+
+	"golang.org/x/sys/unix"
+)
+
+// linkEvents subscribes to RTMGRP_LINK netlink messages (interfaces added, removed, or changed).
+func linkEvents(ctx context.Context) (<-chan struct{}, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: unix.RTMGRP_LINK}); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	// Closing a socket does not reliably unblock a pending recvfrom on Linux.
+	// A receive timeout lets the reader notice ctx cancellation.
+	tv := unix.Timeval{Sec: 1}
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	events := make(chan struct{}, 1)
+	go func() {
+		defer close(events)
+		defer unix.Close(fd)
+
+		buf := make([]byte, 4096)
+		for ctx.Err() == nil {
+			n, _, err := unix.Recvfrom(fd, buf, 0)
+			if err != nil {
+				if err == unix.EAGAIN || err == unix.EINTR {
+					continue
+				}
+				return
+			}
+			if n > 0 {
+				notify(events)
+			}
+		}
+	}()
+	return events, nil
+}
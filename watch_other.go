@@ -0,0 +1,31 @@
+//go:build !linux && !windows
+
+package machineid
+
+import (
+	"context"
+	"time"
+)
+
+// pollInterval is how often interfaces are re-checked on platforms without a change notification API.
+var pollInterval = 30 * time.Second
+
+// linkEvents emits a tick every pollInterval.
+func linkEvents(ctx context.Context) (<-chan struct{}, error) {
+	events := make(chan struct{}, 1)
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				notify(events)
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,39 @@
+//go:build windows
+
+package machineid
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	// Callbacks created by windows.NewCallback are never freed, so we create one per process
+	// and fan the notifications out through a package-level channel.
+	ifaceCallbackOnce sync.Once
+	ifaceCallback     uintptr
+	ifaceEvents       = make(chan struct{}, 1)
+)
+
+// linkEvents subscribes to interface changes via NotifyIpInterfaceChange.
+func linkEvents(ctx context.Context) (<-chan struct{}, error) {
+	ifaceCallbackOnce.Do(func() {
+		ifaceCallback = windows.NewCallback(func(callerContext, row, notificationType uintptr) uintptr {
+			notify(ifaceEvents)
+			return 0
+		})
+	})
+
+	var handle windows.Handle
+	if err := windows.NotifyIpInterfaceChange(windows.AF_UNSPEC, ifaceCallback, nil, false, &handle); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		windows.CancelMibChangeNotify2(handle)
+	}()
+	return ifaceEvents, nil
+}
@@ -0,0 +1,65 @@
+package x
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/banditmoscow1337/machineid"
+)
+
+// Drift reports how the fingerprint changed since the baseline saved with SaveBaseline.
+type Drift struct {
+	// Score is the Match score against the baseline: 1 if nothing changed.
+	Score float64
+	// Changed lists the current components that differ from the baseline (see Fingerprint.Match).
+	// A new MAC set alone typically means the same host with a new network card, while a changed
+	// DMI UUID with an unchanged machine-id points to a cloned VM.
+	Changed []Component
+}
+
+// SaveBaseline resolves the fingerprint and saves its canonical encoding (component hashes
+// only, never raw values) to path, atomically and with mode 0600.
+func SaveBaseline(path string) error {
+	fp, err := GetFingerprint()
+	if err != nil {
+		return err
+	}
+	if err := machineid.FileStore(path).Save([]byte(fp.Canonical())); err != nil {
+		return fmt.Errorf("save baseline: %w", err)
+	}
+	return nil
+}
+
+// CheckDrift resolves the fingerprint and compares it with the baseline saved at path. The error
+// wraps os.ErrNotExist if no baseline was saved yet.
+func CheckDrift(path string) (*Drift, error) {
+	data, err := machineid.FileStore(path).Load()
+	if err != nil {
+		return nil, fmt.Errorf("load baseline: %w", err)
+	}
+	baseline, err := parseCanonical(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := GetFingerprint()
+	if err != nil {
+		return nil, err
+	}
+	score, changed := fp.Match(baseline)
+	return &Drift{Score: score, Changed: changed}, nil
+}
+
+// parseCanonical decodes the encoding produced by Fingerprint.Canonical.
+func parseCanonical(s string) (*Fingerprint, error) {
+	fp := &Fingerprint{}
+	for line := range strings.Lines(s) {
+		name, hash, ok := strings.Cut(strings.TrimSuffix(line, "\n"), "=")
+		if !ok || name == "" {
+			return nil, errors.New("malformed baseline")
+		}
+		fp.Components = append(fp.Components, Component{Name: name, Hash: hash})
+	}
+	return fp, nil
+}
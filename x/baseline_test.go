@@ -0,0 +1,53 @@
+package x
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/banditmoscow1337/machineid"
+	"github.com/banditmoscow1337/machineid/internal/bridge"
+)
+
+func TestCheckDrift(t *testing.T) {
+	defer func(orig func() []bridge.Component) { components = orig }(components)
+
+	hashes := map[string]string{
+		machineid.ComponentMachineID: "aaaa",
+		machineid.ComponentDMIUUID:   "bbbb",
+		machineid.ComponentMAC:       "cccc",
+		machineid.ComponentCPU:       "dddd",
+	}
+	components = mockComponents(hashes)
+
+	path := filepath.Join(t.TempDir(), "baseline")
+	if _, err := CheckDrift(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist without a baseline, got %v", err)
+	}
+	if err := SaveBaseline(path); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "mac=cccc\n") {
+		t.Errorf("unexpected baseline:\n%s", data)
+	}
+
+	drift, err := CheckDrift(path)
+	if err != nil || drift.Score != 1 || len(drift.Changed) != 0 {
+		t.Fatalf("expected no drift, got %+v, %v", drift, err)
+	}
+
+	// A new network card changes the MAC set only.
+	hashes[machineid.ComponentMAC] = "eeee"
+	drift, err = CheckDrift(path)
+	if err != nil || len(drift.Changed) != 1 || drift.Changed[0].Name != machineid.ComponentMAC || drift.Score != 0.75 {
+		t.Fatalf("expected MAC drift, got %+v, %v", drift, err)
+	}
+
+	// A corrupt baseline is reported.
+	os.WriteFile(path, []byte("garbage\n"), 0o600)
+	if _, err := CheckDrift(path); err == nil {
+		t.Error("expected an error for a malformed baseline")
+	}
+}
@@ -0,0 +1,154 @@
+package x
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// Default Bloom filter parameters: 512 bits and 3 hash functions keep the false positive
+// rate below 0.1% for the handful of components of a fingerprint.
+const (
+	DefaultBloomBits   = 512
+	DefaultBloomHashes = 3
+)
+
+// BloomFilter is a salted Bloom filter of fingerprint component hashes.
+//
+// It lets a central service match returning machines after partial hardware changes without
+// ever receiving the component hashes: the service stores the filter sent at enrollment and
+// compares it with the filter sent later (see Similarity). Membership is keyed with the salt,
+// so filters built with different salts (e.g., per tenant) can't be correlated.
+type BloomFilter struct {
+	bits []byte
+	m    uint32 // number of bits
+	k    uint8  // number of hash functions
+	salt []byte
+}
+
+// NewBloomFilter returns an empty filter with m bits (rounded up to a multiple of 8) and k hash
+// functions, keyed with salt. Use the same parameters and salt for filters you want to compare.
+func NewBloomFilter(m uint32, k uint8, salt []byte) (*BloomFilter, error) {
+	if m == 0 || k == 0 {
+		return nil, errors.New("bloom filter needs at least one bit and one hash function")
+	}
+	m = (m + 7) &^ 7
+	if m == 0 {
+		return nil, errors.New("bloom filter too large")
+	}
+	return &BloomFilter{
+		bits: make([]byte, m/8),
+		m:    m,
+		k:    k,
+		salt: append([]byte(nil), salt...),
+	}, nil
+}
+
+// BloomFilter returns a filter (DefaultBloomBits, DefaultBloomHashes) holding every available
+// component as a "name=hash" record. Unavailable components are not added.
+func (f *Fingerprint) BloomFilter(salt []byte) *BloomFilter {
+	b, _ := NewBloomFilter(DefaultBloomBits, DefaultBloomHashes, salt)
+	for _, c := range f.Components {
+		if c.Available() {
+			b.Add(c.Name + "=" + c.Hash)
+		}
+	}
+	return b
+}
+
+// indexes derives the k bit positions of item by double hashing an HMAC-SHA256 of the item.
+func (b *BloomFilter) indexes(item string) []uint32 {
+	mac := hmac.New(sha256.New, b.salt)
+	mac.Write([]byte(item))
+	sum := mac.Sum(nil)
+
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	idx := make([]uint32, b.k)
+	for i := range idx {
+		idx[i] = uint32((h1 + uint64(i)*h2) % uint64(b.m))
+	}
+	return idx
+}
+
+// Add inserts an item into the filter.
+func (b *BloomFilter) Add(item string) {
+	for _, i := range b.indexes(item) {
+		b.bits[i/8] |= 1 << (i % 8)
+	}
+}
+
+// Test reports whether the item may be in the filter. False positives are possible,
+// false negatives are not.
+func (b *BloomFilter) Test(item string) bool {
+	for _, i := range b.indexes(item) {
+		if b.bits[i/8]&(1<<(i%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Similarity estimates the Jaccard similarity (0..1) of the sets held by two filters, from the
+// bit counts of their union and intersection. Typically 1 for the same machine, less after a
+// hardware change (e.g., 0.71 if one of six components changed), and near 0 for another machine.
+// Both filters must share the same parameters and salt.
+func (b *BloomFilter) Similarity(other *BloomFilter) (float64, error) {
+	if b.m != other.m || b.k != other.k || !hmac.Equal(b.salt, other.salt) {
+		return 0, errors.New("bloom filters have different parameters or salts")
+	}
+
+	var onesA, onesB, onesUnion int
+	for i := range b.bits {
+		onesA += bits.OnesCount8(b.bits[i])
+		onesB += bits.OnesCount8(other.bits[i])
+		onesUnion += bits.OnesCount8(b.bits[i] | other.bits[i])
+	}
+
+	union := b.estimate(onesUnion)
+	if union == 0 {
+		return 0, nil
+	}
+	intersection := max(b.estimate(onesA)+b.estimate(onesB)-union, 0)
+	return min(intersection/union, 1), nil
+}
+
+// estimate returns the estimated number of items in a filter with the given number of set bits
+// (Swamidass & Baldi).
+func (b *BloomFilter) estimate(ones int) float64 {
+	m, k := float64(b.m), float64(b.k)
+	if ones >= int(b.m) {
+		ones = int(b.m) - 1
+	}
+	return -m / k * math.Log(1-float64(ones)/m)
+}
+
+// MarshalBinary encodes the filter as m (4 bytes), k (1 byte) and the bit array.
+// The salt is not included: it is a secret shared by the clients and never sent.
+func (b *BloomFilter) MarshalBinary() ([]byte, error) {
+	out := binary.BigEndian.AppendUint32(nil, b.m)
+	out = append(out, b.k)
+	return append(out, b.bits...), nil
+}
+
+// UnmarshalBloomFilter decodes a filter produced by MarshalBinary. The salt is only needed to
+// Add or Test items; comparing filters with Similarity requires the same salt on both sides.
+func UnmarshalBloomFilter(data, salt []byte) (*BloomFilter, error) {
+	if len(data) < 5 {
+		return nil, errors.New("bloom filter too short")
+	}
+	// Check the size before allocating: the data may come from an untrusted client.
+	m := binary.BigEndian.Uint32(data)
+	if m%8 != 0 || uint64(len(data)-5) != uint64(m)/8 {
+		return nil, errors.New("bloom filter size mismatch")
+	}
+	b, err := NewBloomFilter(m, data[4], salt)
+	if err != nil {
+		return nil, err
+	}
+	copy(b.bits, data[5:])
+	return b, nil
+}
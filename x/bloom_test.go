@@ -0,0 +1,76 @@
+package x
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func testFingerprint(hashes ...string) *Fingerprint {
+	names := []string{"machine-id", "dmi-uuid", "mac", "disk-serial", "cpu", "board-serial"}
+	fp := &Fingerprint{}
+	for i, h := range hashes {
+		fp.Components = append(fp.Components, Component{Name: names[i], Hash: h})
+	}
+	return fp
+}
+
+func TestBloomFilter(t *testing.T) {
+	salt := []byte("tenant-salt")
+	enrolled := testFingerprint("a1", "b2", "c3", "d4", "e5", "f6").BloomFilter(salt)
+
+	// 1. Members test positive; the filter never contains raw component hashes.
+	if !enrolled.Test("mac=c3") || enrolled.Test("mac=zz") {
+		t.Error("unexpected membership result")
+	}
+	data, _ := enrolled.MarshalBinary()
+	if bytes.Contains(data, []byte("c3")) {
+		t.Error("filter leaks component hashes")
+	}
+
+	// 2. Similarity: same machine, one NIC swapped, different machine.
+	tests := []struct {
+		name     string
+		fp       *Fingerprint
+		min, max float64
+	}{
+		{"Same", testFingerprint("a1", "b2", "c3", "d4", "e5", "f6"), 0.99, 1},
+		{"One_Changed", testFingerprint("a1", "b2", "XX", "d4", "e5", "f6"), 0.55, 0.85},
+		{"Other_Machine", testFingerprint("q1", "q2", "q3", "q4", "q5", "q6"), 0, 0.15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := enrolled.Similarity(tt.fp.BloomFilter(salt))
+			if err != nil || got < tt.min || got > tt.max {
+				t.Errorf("Similarity() = %.2f, %v; want [%.2f, %.2f]", got, err, tt.min, tt.max)
+			}
+		})
+	}
+
+	// 3. Different salts can't be compared.
+	other := testFingerprint("a1").BloomFilter([]byte("other-salt"))
+	if _, err := enrolled.Similarity(other); err == nil {
+		t.Error("expected an error for different salts")
+	}
+}
+
+func TestUnmarshalBloomFilter(t *testing.T) {
+	salt := []byte("s")
+	b := testFingerprint("a1", "b2").BloomFilter(salt)
+	data, _ := b.MarshalBinary()
+
+	decoded, err := UnmarshalBloomFilter(data, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := b.Similarity(decoded); s < 0.99 {
+		t.Errorf("round trip changed the filter: similarity %.2f", s)
+	}
+
+	// Sizes that don't match the header are rejected before allocating.
+	for _, bad := range [][]byte{data[:3], data[:len(data)-1], {0xff, 0xff, 0xff, 0xf8, 3}} {
+		if _, err := UnmarshalBloomFilter(bad, salt); err == nil || !strings.Contains(err.Error(), "bloom filter") {
+			t.Errorf("expected an error for %x, got %v", bad, err)
+		}
+	}
+}
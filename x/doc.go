@@ -0,0 +1,9 @@
+// Package x contains experimental machineid features.
+//
+// Everything in this package may change or disappear in any release, including the
+// values it produces: do not persist its output where a later change would hurt
+// (e.g., license files), unless you are prepared to re-enroll machines.
+//
+// Features graduate into the stable machineid package once their output format is frozen.
+// Experimental sources are never used implicitly; callers opt in by importing this package.
+package x
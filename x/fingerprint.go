@@ -0,0 +1,133 @@
+package x
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/banditmoscow1337/machineid"
+	"github.com/banditmoscow1337/machineid/internal/bridge"
+)
+
+// Component is a single, independently resolved piece of a Fingerprint.
+type Component struct {
+	// Name identifies the source (e.g., machineid.ComponentMachineID).
+	Name string
+	// Hash is the SHA256 (hex) of the raw value. It is empty if the source was unavailable.
+	Hash string
+	// Err records why the source was unavailable. It is nil on success.
+	Err error
+}
+
+// Available reports whether the component produced a value.
+func (c Component) Available() bool {
+	return c.Err == nil && c.Hash != ""
+}
+
+// Fingerprint is a composite identifier built from several independent sources
+// (machine-id, DMI UUID, MAC set, disk serial, CPU info, baseboard serial).
+// Unlike machineid.ID(), which depends on a single source, a Fingerprint degrades gracefully:
+// if one source changes or disappears, the remaining components still describe the machine.
+type Fingerprint struct {
+	// Components are always listed in the same, stable order.
+	Components []Component
+}
+
+// components resolves the raw components. It is a variable so tests can substitute them.
+var components = func() []bridge.Component {
+	// Referencing the machineid package guarantees its init (which installs the bridge) has run.
+	_ = machineid.ComponentMachineID
+	return bridge.Components()
+}
+
+// GetFingerprint resolves every fingerprint component.
+// Individual source failures are recorded per component; an error is returned only
+// if no component could be resolved at all.
+func GetFingerprint() (*Fingerprint, error) {
+	raw := components()
+	fp := &Fingerprint{Components: make([]Component, 0, len(raw))}
+
+	available := 0
+	for _, r := range raw {
+		c := Component{Name: r.Name, Hash: r.Hash, Err: r.Err}
+		if c.Available() {
+			available++
+		}
+		fp.Components = append(fp.Components, c)
+	}
+
+	if available == 0 {
+		return nil, errors.New("no fingerprint components available")
+	}
+	return fp, nil
+}
+
+// Component returns the component with the given name, if present.
+func (f *Fingerprint) Component(name string) (Component, bool) {
+	for _, c := range f.Components {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Component{}, false
+}
+
+// Canonical returns the stable textual encoding of the fingerprint:
+// one "name=hash" record per line, in component order. Unavailable components
+// are encoded with an empty hash so the record layout never shifts.
+func (f *Fingerprint) Canonical() string {
+	var b strings.Builder
+	for _, c := range f.Components {
+		b.WriteString(c.Name)
+		b.WriteByte('=')
+		if c.Available() {
+			b.WriteString(c.Hash)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Hash returns the SHA256 (hex) of the canonical encoding.
+// Note: Any component change alters this hash. Use the per-component records for tolerant matching.
+func (f *Fingerprint) Hash() string {
+	sum := sha256.Sum256([]byte(f.Canonical()))
+	return hex.EncodeToString(sum[:])
+}
+
+// Match compares the fingerprint with an earlier one (e.g., recorded at enrollment) component by
+// component, for tolerant node-locked licensing: "the same machine despite a NIC swap". The score
+// is the fraction of components that are equal, out of those available in either fingerprint;
+// components unavailable in both are ignored. changed lists the components of f that differ
+// (including those that appeared or disappeared), so callers can apply their own policy, e.g.
+// accept a score of at least 0.6 (3 of 5 components) unless the DMI UUID changed.
+func (f *Fingerprint) Match(other *Fingerprint) (score float64, changed []Component) {
+	var compared, equal int
+	seen := make(map[string]bool, len(f.Components))
+	for _, c := range f.Components {
+		seen[c.Name] = true
+		o, _ := other.Component(c.Name)
+		if !c.Available() && !o.Available() {
+			continue
+		}
+		compared++
+		if c.Available() && o.Available() && c.Hash == o.Hash {
+			equal++
+		} else {
+			changed = append(changed, c)
+		}
+	}
+	// Components only the earlier fingerprint knows about have disappeared.
+	for _, o := range other.Components {
+		if !seen[o.Name] && o.Available() {
+			compared++
+			changed = append(changed, Component{Name: o.Name, Err: errors.New("component missing")})
+		}
+	}
+
+	if compared == 0 {
+		return 0, changed
+	}
+	return float64(equal) / float64(compared), changed
+}
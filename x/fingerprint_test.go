@@ -0,0 +1,147 @@
+package x
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/banditmoscow1337/machineid"
+	"github.com/banditmoscow1337/machineid/internal/bridge"
+)
+
+// mockComponents replaces the resolved components with fixed hashes.
+// Names missing from hashes are reported as unavailable.
+func mockComponents(hashes map[string]string) func() []bridge.Component {
+	return func() []bridge.Component {
+		var out []bridge.Component
+		for _, name := range []string{machineid.ComponentMachineID, machineid.ComponentDMIUUID, machineid.ComponentMAC, machineid.ComponentDiskSerial, machineid.ComponentCPU} {
+			if h, ok := hashes[name]; ok {
+				out = append(out, bridge.Component{Name: name, Hash: h})
+			} else {
+				out = append(out, bridge.Component{Name: name, Err: errors.New("unavailable")})
+			}
+		}
+		return out
+	}
+}
+
+func TestGetFingerprint(t *testing.T) {
+	defer func(orig func() []bridge.Component) { components = orig }(components)
+
+	components = mockComponents(map[string]string{
+		machineid.ComponentMachineID: "aaaa",
+		machineid.ComponentMAC:       "bbbb",
+		machineid.ComponentCPU:       "cccc",
+	})
+
+	fp, err := GetFingerprint()
+	if err != nil {
+		t.Fatalf("GetFingerprint() failed: %v", err)
+	}
+
+	if c, ok := fp.Component(machineid.ComponentDMIUUID); !ok || c.Available() || c.Err == nil {
+		t.Errorf("DMI UUID should be unavailable, got %+v", c)
+	}
+	if c, _ := fp.Component(machineid.ComponentMachineID); c.Hash != "aaaa" {
+		t.Errorf("machine-id hash mismatch: %+v", c)
+	}
+
+	canonical := fp.Canonical()
+	if canonical != "machine-id=aaaa\ndmi-uuid=\nmac=bbbb\ndisk-serial=\ncpu=cccc\n" {
+		t.Errorf("unexpected canonical encoding:\n%s", canonical)
+	}
+
+	// The hash must be deterministic.
+	fp2, _ := GetFingerprint()
+	if fp.Hash() != fp2.Hash() || len(fp.Hash()) != 64 {
+		t.Errorf("Fingerprint hash is not deterministic: %s vs %s", fp.Hash(), fp2.Hash())
+	}
+}
+
+func TestGetFingerprint_NoComponents(t *testing.T) {
+	defer func(orig func() []bridge.Component) { components = orig }(components)
+
+	components = mockComponents(nil)
+
+	if _, err := GetFingerprint(); err == nil || !strings.Contains(err.Error(), "no fingerprint components") {
+		t.Errorf("expected error when no component is available, got %v", err)
+	}
+}
+
+func TestGetFingerprint_Live(t *testing.T) {
+	// On any real machine at least one component (usually the MAC set or machine-id) resolves.
+	fp, err := GetFingerprint()
+	if err != nil {
+		t.Skipf("no fingerprint components in this environment: %v", err)
+	}
+	if len(fp.Components) != 6 {
+		t.Errorf("expected 6 components, got %d", len(fp.Components))
+	}
+}
+
+func TestFingerprintMatch(t *testing.T) {
+	enrolled := testFingerprint("a1", "b2", "c3", "d4", "e5")
+
+	tests := []struct {
+		name    string
+		fp      *Fingerprint
+		score   float64
+		changed []string
+	}{
+		{"Same", testFingerprint("a1", "b2", "c3", "d4", "e5"), 1, nil},
+		{"NIC_Swap", testFingerprint("a1", "b2", "XX", "d4", "e5"), 0.8, []string{"mac"}},
+		{"Disk_Gone", testFingerprint("a1", "b2", "c3", "", "e5"), 0.8, []string{"disk-serial"}},
+		{"Component_Missing", testFingerprint("a1", "b2", "c3", "d4"), 0.8, []string{"cpu"}},
+		{"Other_Machine", testFingerprint("q1", "q2", "q3", "q4", "q5"), 0, []string{"machine-id", "dmi-uuid", "mac", "disk-serial", "cpu"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, changed := tt.fp.Match(enrolled)
+			var names []string
+			for _, c := range changed {
+				names = append(names, c.Name)
+			}
+			if score != tt.score || strings.Join(names, ",") != strings.Join(tt.changed, ",") {
+				t.Errorf("Match() = %.2f, %v; want %.2f, %v", score, names, tt.score, tt.changed)
+			}
+		})
+	}
+
+	// Components unavailable on both sides don't count.
+	if score, changed := testFingerprint("a1", "").Match(testFingerprint("a1", "")); score != 1 || len(changed) != 0 {
+		t.Errorf("expected a full match, got %.2f, %+v", score, changed)
+	}
+	if score, _ := (&Fingerprint{}).Match(&Fingerprint{}); score != 0 {
+		t.Errorf("empty fingerprints must not match, got %.2f", score)
+	}
+}
+
+func TestFingerprintJSON(t *testing.T) {
+	fp := testFingerprint("a1", "b2")
+	fp.Components[1] = Component{Name: fp.Components[1].Name, Err: errors.New("permission denied")}
+
+	data, err := json.Marshal(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"schema":1,"hash":"` + fp.Hash() + `","components":[{"name":"machine-id","hash":"a1"},{"name":"dmi-uuid","error":"permission denied"}]}`
+	if string(data) != want {
+		t.Errorf("JSON mismatch:\n got %s\nwant %s", data, want)
+	}
+
+	var decoded Fingerprint
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Hash() != fp.Hash() || decoded.Components[1].Err == nil || decoded.Components[1].Err.Error() != "permission denied" {
+		t.Errorf("round trip mismatch: %+v", decoded)
+	}
+
+	tampered := strings.Replace(string(data), `"hash":"a1"`, `"hash":"zz"`, 1)
+	for _, bad := range []string{tampered, `{"schema":2}`} {
+		if err := json.Unmarshal([]byte(bad), &decoded); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package x
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// FingerprintSchemaVersion is the version of the JSON encoding of Fingerprint, written to its
+// "schema" field. It is bumped on incompatible changes only.
+const FingerprintSchemaVersion = 1
+
+// fingerprintJSON is the JSON encoding of Fingerprint.
+type fingerprintJSON struct {
+	Schema     int             `json:"schema"`
+	Hash       string          `json:"hash"`
+	Components []componentJSON `json:"components"`
+}
+
+type componentJSON struct {
+	Name  string `json:"name"`
+	Hash  string `json:"hash,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// MarshalJSON encodes the fingerprint as a JSON object tagged with FingerprintSchemaVersion,
+// listing the components in order with the reason unavailable ones failed, and the Hash of the
+// fingerprint.
+func (f Fingerprint) MarshalJSON() ([]byte, error) {
+	v := fingerprintJSON{
+		Schema:     FingerprintSchemaVersion,
+		Hash:       f.Hash(),
+		Components: make([]componentJSON, 0, len(f.Components)),
+	}
+	for _, c := range f.Components {
+		cj := componentJSON{Name: c.Name, Hash: c.Hash}
+		if c.Err != nil {
+			cj.Error = c.Err.Error()
+		}
+		v.Components = append(v.Components, cj)
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a fingerprint encoded by MarshalJSON. It rejects other schema versions
+// and fingerprints whose components don't match the recorded hash. Component errors are
+// restored as plain errors carrying the original message.
+func (f *Fingerprint) UnmarshalJSON(data []byte) error {
+	var v fingerprintJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Schema != FingerprintSchemaVersion {
+		return fmt.Errorf("unsupported fingerprint schema %d", v.Schema)
+	}
+
+	fp := Fingerprint{Components: make([]Component, 0, len(v.Components))}
+	for _, cj := range v.Components {
+		c := Component{Name: cj.Name, Hash: cj.Hash}
+		if cj.Error != "" {
+			c.Err = errors.New(cj.Error)
+		}
+		fp.Components = append(fp.Components, c)
+	}
+	if fp.Hash() != v.Hash {
+		return errors.New("fingerprint hash mismatch")
+	}
+	*f = fp
+	return nil
+}